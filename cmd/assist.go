@@ -4,6 +4,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 
 	"github.com/krkn-chaos/krknctl/pkg/assist"
@@ -95,7 +96,7 @@ Examples:
 		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Print container runtime info
-			(*scenarioOrchestrator).PrintContainerRuntime()
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
 
 			// Check if Docker is being used on macOS arm64 - requires Podman for Apple Silicon GPU support
 			if (*scenarioOrchestrator).GetContainerRuntime() == orchestratormodels.Docker {
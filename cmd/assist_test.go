@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/provider/factory"
@@ -44,7 +45,7 @@ func (m *MockScenarioOrchestrator) Run(image, containerName string, env map[stri
 	return &id, nil
 }
 
-func (m *MockScenarioOrchestrator) RunAttached(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, stdout io.Writer, stderr io.Writer, commChan *chan *string, ctx context.Context, registry *models.RegistryV2, publishPorts []string, podmanCreate *scenarioorchestrator.PodmanCreateOptions) (*string, error) {
+func (m *MockScenarioOrchestrator) RunAttached(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, stdout io.Writer, stderr io.Writer, commChan *chan *string, ctx context.Context, registry *models.RegistryV2, publishPorts []string, podmanCreate *scenarioorchestrator.PodmanCreateOptions, timeout *time.Duration) (*string, error) {
 	id := "mock-container-id"
 	return &id, nil
 }
@@ -53,7 +54,7 @@ func (m *MockScenarioOrchestrator) GetContainerRuntime() orchestratormodels.Cont
 	return orchestratormodels.Podman
 }
 
-func (m *MockScenarioOrchestrator) PrintContainerRuntime() {}
+func (m *MockScenarioOrchestrator) PrintContainerRuntime(w io.Writer) {}
 
 func (m *MockScenarioOrchestrator) GetConfig() config.Config {
 	config, _ := config.LoadConfig()
@@ -65,17 +66,33 @@ func (m *MockScenarioOrchestrator) GetContainerRuntimeSocket(userID *int) (*stri
 	return &socket, nil
 }
 
+func (m *MockScenarioOrchestrator) Logs(containerID *string, options orchestratormodels.LogOptions, stdout io.Writer, stderr io.Writer, ctx context.Context) error {
+	return nil
+}
+
 func (m *MockScenarioOrchestrator) Kill(containerID *string, ctx context.Context) error {
 	return nil
 }
 
-func (m *MockScenarioOrchestrator) CleanContainers(ctx context.Context) (*int, error) {
+func (m *MockScenarioOrchestrator) PullImage(image string, pullPolicy orchestratormodels.PullPolicy, registry *models.RegistryV2, commChan *chan *string, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockScenarioOrchestrator) CleanContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*int, error) {
 	count := 0
 	return &count, nil
 }
 
+func (m *MockScenarioOrchestrator) ListFinishedContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.Container, error) {
+	return nil, nil
+}
+
+func (m *MockScenarioOrchestrator) RemoveContainer(containerID *string, ctx context.Context) error {
+	return nil
+}
+
 // Implement other required interface methods as no-ops
-func (m *MockScenarioOrchestrator) RunGraph(scenarios orchestratormodels.ScenarioSet, resolvedGraph orchestratormodels.ResolvedGraph, extraEnv map[string]string, extraVolumeMounts map[string]string, cache bool, commChannel chan *orchestratormodels.GraphCommChannel, registry *models.RegistryV2, userID *int) {
+func (m *MockScenarioOrchestrator) RunGraph(scenarios orchestratormodels.ScenarioSet, resolvedGraph orchestratormodels.ResolvedGraph, extraEnv map[string]string, extraVolumeMounts map[string]string, stdout io.Writer, pullPolicy orchestratormodels.PullPolicy, schedule orchestratormodels.Schedule, commChannel chan *orchestratormodels.GraphCommChannel, ctx context.Context, registry *models.RegistryV2, userID *int, labels *orchestratormodels.ContainerLabels, runDir string) {
 }
 func (m *MockScenarioOrchestrator) AttachWait(containerID *string, stdout io.Writer, stderr io.Writer, ctx context.Context) (*bool, error) {
 	return nil, nil
@@ -83,10 +100,10 @@ func (m *MockScenarioOrchestrator) AttachWait(containerID *string, stdout io.Wri
 func (m *MockScenarioOrchestrator) Attach(containerID *string, signalChannel chan os.Signal, stdout io.Writer, stderr io.Writer, ctx context.Context) (bool, error) {
 	return false, nil
 }
-func (m *MockScenarioOrchestrator) ListRunningContainers(ctx context.Context) (*map[int64]orchestratormodels.Container, error) {
+func (m *MockScenarioOrchestrator) ListRunningContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*map[int64]orchestratormodels.Container, error) {
 	return nil, nil
 }
-func (m *MockScenarioOrchestrator) ListRunningScenarios(ctx context.Context) (*[]orchestratormodels.ScenarioContainer, error) {
+func (m *MockScenarioOrchestrator) ListRunningScenarios(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.ScenarioContainer, error) {
 	return nil, nil
 }
 func (m *MockScenarioOrchestrator) InspectScenario(container orchestratormodels.Container, ctx context.Context) (*orchestratormodels.ScenarioContainer, error) {
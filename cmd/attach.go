@@ -1,22 +1,35 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// attachColors are cycled through to tell apart the scenarios attached together
+var attachColors = []color.Attribute{color.FgCyan, color.FgMagenta, color.FgYellow, color.FgBlue, color.FgGreen, color.FgHiRed}
+
 func NewAttachCmd(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator) *cobra.Command {
 	var command = &cobra.Command{
-		Use:   "attach",
+		Use:   "attach [index...]",
 		Short: "connects krknctl to the running scenario logs",
-		Long:  `connects krknctl to the running scenario logs`,
-		Args:  cobra.ExactArgs(1),
+		Long: `connects krknctl to the running scenario logs, the scenarios are selected by the indexes
+printed by list running, by the run they belong to (--run) or all the running scenarios are
+attached (--all). When more than one scenario is attached every line is prefixed by its node
+or scenario name. Detaching with CTRL+C never stops the scenarios`,
+		Args: cobra.ArbitraryArgs,
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
 			if err != nil {
@@ -26,7 +39,7 @@ func NewAttachCmd(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrato
 			if err != nil {
 				return nil, cobra.ShellCompDirectiveNoFileComp
 			}
-			scenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx)
+			scenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx, nil)
 			if err != nil || scenarios == nil {
 				return nil, cobra.ShellCompDirectiveNoFileComp
 			}
@@ -38,7 +51,7 @@ func NewAttachCmd(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrato
 			}
 			var results []string
 			for id, description := range completions {
-				if strings.HasPrefix(id, toComplete) {
+				if strings.HasPrefix(id, toComplete) && !slices.Contains(args, id) {
 					results = append(results, fmt.Sprintf("%s\t%s", id, description))
 				}
 			}
@@ -47,50 +60,170 @@ func NewAttachCmd(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrato
 
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			(*scenarioOrchestrator).PrintContainerRuntime()
-			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+			all, err := cmd.Flags().GetBool("all")
 			if err != nil {
 				return err
 			}
-			ctx, err := (*scenarioOrchestrator).Connect(*socket)
+			runID, err := cmd.Flags().GetString("run")
 			if err != nil {
 				return err
 			}
-			runningScenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx)
+			filterExpr, err := cmd.Flags().GetString("filter")
 			if err != nil {
 				return err
 			}
+			if len(args) > 0 && (all || runID != "") {
+				return errors.New("scenario ids can't be set together with --all or --run")
+			}
+			if len(args) == 0 && !all && runID == "" {
+				return errors.New("neither scenario id, --run nor --all specified")
+			}
+			var filter *regexp.Regexp
+			if filterExpr != "" {
+				filter, err = regexp.Compile(filterExpr)
+				if err != nil {
+					return fmt.Errorf("invalid filter %s: %w", filterExpr, err)
+				}
+			}
 
-			scenarioID, err := strconv.ParseInt(args[0], 10, 64)
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
+			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
 			if err != nil {
-				return fmt.Errorf("invalid scenario id: %s, scenario id must be a number", args[0])
+				return err
 			}
-
-			if scenarioID > int64(len(*runningScenarios)-1) {
-				return fmt.Errorf("invalid scenario id: %d, scenario id out of range", scenarioID)
+			ctx, err := (*scenarioOrchestrator).Connect(*socket)
+			if err != nil {
+				return err
 			}
-			_, err = color.New(color.FgGreen, color.Underline).Println("hit CTRL+C to stop streaming scenario output (scenario won't be interrupted)")
+			runningScenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx, runFilter(runID))
 			if err != nil {
 				return err
 			}
-			scenario := (*runningScenarios)[scenarioID]
-			ctx, err = (*scenarioOrchestrator).Connect(*socket)
+			var running []orchestratorModels.ScenarioContainer
+			if runningScenarios != nil {
+				running = *runningScenarios
+			}
+
+			scenarios, err := resolveAttachTargets(args, running)
 			if err != nil {
 				return err
 			}
-			interrupted, err := (*scenarioOrchestrator).AttachWait(&scenario.Container.ID, os.Stdout, os.Stderr, ctx)
+			if len(scenarios) == 0 {
+				if runID != "" {
+					fmt.Printf("no running scenarios in run %s\n", runID)
+				} else {
+					fmt.Println("no running scenarios")
+				}
+				return nil
+			}
+			_, err = color.New(color.FgGreen, color.Underline).Println("hit CTRL+C to stop streaming scenario output (scenario won't be interrupted)")
 			if err != nil {
 				return err
 			}
-			if *interrupted {
-				_, err = color.New(color.FgRed, color.Underline).Println(fmt.Sprintf("scenario output terminated, container %s still running", scenario.Container.ID))
-				if err != nil {
-					return err
+
+			// a single signal detaches from all the scenarios, the closed channel
+			// is received by every attach and the containers are left running
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigCh)
+			detach := make(chan os.Signal)
+			go func() {
+				if _, ok := <-sigCh; ok {
+					close(detach)
 				}
+			}()
+
+			prefixes := attachPrefixes(scenarios)
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			errs := make([]error, len(scenarios))
+			interrupted := make([]bool, len(scenarios))
+			for i, scenario := range scenarios {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					stdout := newPrefixWriter(os.Stdout, prefixes[i], &mu)
+					stdout.filter = filter
+					stderr := newPrefixWriter(os.Stderr, prefixes[i], &mu)
+					stderr.filter = filter
+					interrupted[i], errs[i] = (*scenarioOrchestrator).Attach(&scenario.Container.ID, detach, stdout, stderr, ctx)
+					stdout.Flush()
+					stderr.Flush()
+				}()
 			}
-			return nil
+			wg.Wait()
+
+			for i, scenario := range scenarios {
+				if interrupted[i] {
+					_, err = color.New(color.FgRed, color.Underline).Println(fmt.Sprintf("scenario output terminated, container %s still running", scenario.Container.ID))
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return errors.Join(errs...)
 
 		},
 	}
 	return command
 }
+
+// resolveAttachTargets selects the scenarios attached by their index as printed
+// by list running, no argument attaches all the running ones
+func resolveAttachTargets(args []string, running []orchestratorModels.ScenarioContainer) ([]orchestratorModels.ScenarioContainer, error) {
+	if len(args) == 0 {
+		return running, nil
+	}
+	var scenarios []orchestratorModels.ScenarioContainer
+	attached := make(map[string]bool)
+	for _, arg := range args {
+		scenarioID, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario id: %s, scenario id must be a number", arg)
+		}
+		if scenarioID < 0 || scenarioID >= len(running) {
+			return nil, fmt.Errorf("invalid scenario id: %d, scenario id out of range", scenarioID)
+		}
+		scenario := running[scenarioID]
+		if !attached[scenario.Container.ID] {
+			attached[scenario.Container.ID] = true
+			scenarios = append(scenarios, scenario)
+		}
+	}
+	return scenarios, nil
+}
+
+// attachPrefixes returns the color coded prefix of every scenario, named after its graph node
+// or its scenario, a single scenario is not prefixed
+func attachPrefixes(scenarios []orchestratorModels.ScenarioContainer) []string {
+	prefixes := make([]string, len(scenarios))
+	if len(scenarios) < 2 {
+		return prefixes
+	}
+	names := make([]string, len(scenarios))
+	count := make(map[string]int)
+	for i, scenario := range scenarios {
+		switch {
+		case scenario.Container.Labels != nil && scenario.Container.Labels.NodeID != "":
+			names[i] = scenario.Container.Labels.NodeID
+		case scenario.Scenario != nil && scenario.Scenario.Name != "":
+			names[i] = scenario.Scenario.Name
+		default:
+			names[i] = scenario.Container.Name
+		}
+		count[names[i]]++
+	}
+	width := 0
+	for i, scenario := range scenarios {
+		// the same node of two different runs
+		if count[names[i]] > 1 {
+			names[i] = scenario.Container.Name
+		}
+		width = max(width, len(names[i]))
+	}
+	for i, name := range names {
+		prefix := fmt.Sprintf("[%s]%s ", name, strings.Repeat(" ", width-len(name)))
+		prefixes[i] = color.New(attachColors[i%len(attachColors)]).Sprint(prefix)
+	}
+	return prefixes
+}
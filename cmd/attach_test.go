@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveAttachTargets(t *testing.T) {
+	running := []orchestratorModels.ScenarioContainer{
+		{Container: &orchestratorModels.Container{ID: "4f53cda18c2b", Name: "krknctl-pod-scenarios-1"}},
+		{Container: &orchestratorModels.Container{ID: "4f1a2b3c4d5e", Name: "krknctl-node-cpu-hog-2"}},
+	}
+	scenarios, err := resolveAttachTargets(nil, running)
+	assert.Nil(t, err)
+	assert.Len(t, scenarios, 2)
+
+	scenarios, err = resolveAttachTargets([]string{"1", "0", "1"}, running)
+	assert.Nil(t, err)
+	assert.Len(t, scenarios, 2)
+	assert.Equal(t, "krknctl-node-cpu-hog-2", scenarios[0].Container.Name)
+
+	_, err = resolveAttachTargets([]string{"2"}, running)
+	assert.NotNil(t, err)
+	_, err = resolveAttachTargets([]string{"krknctl-pod-scenarios-1"}, running)
+	assert.NotNil(t, err)
+}
+
+func TestAttachPrefixes(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	single := []orchestratorModels.ScenarioContainer{
+		{Container: &orchestratorModels.Container{Name: "krknctl-pod-scenarios-1"}},
+	}
+	assert.Equal(t, []string{""}, attachPrefixes(single))
+
+	scenarios := []orchestratorModels.ScenarioContainer{
+		{Container: &orchestratorModels.Container{Name: "krknctl-pods-1", Labels: &orchestratorModels.ContainerLabels{RunID: "a", NodeID: "pods"}}},
+		{Container: &orchestratorModels.Container{Name: "krknctl-cpu-1"}, Scenario: &orchestratorModels.Scenario{Name: "node-cpu-hog"}},
+		{Container: &orchestratorModels.Container{Name: "krknctl-other"}},
+	}
+	assert.Equal(t, []string{"[pods]          ", "[node-cpu-hog]  ", "[krknctl-other] "}, attachPrefixes(scenarios))
+
+	// the same node of two runs is told apart by the container name
+	scenarios[1].Container.Labels = &orchestratorModels.ContainerLabels{RunID: "b", NodeID: "pods"}
+	prefixes := attachPrefixes(scenarios)
+	assert.True(t, strings.HasPrefix(prefixes[0], "[krknctl-pods-1]"))
+	assert.True(t, strings.HasPrefix(prefixes[1], "[krknctl-cpu-1]"))
+}
@@ -1,45 +1,385 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
+const (
+	cleanContainer  = "container"
+	cleanRun        = "run"
+	cleanKubeconfig = "kubeconfig"
+	cleanLog        = "log"
+	cleanGraph      = "graph"
+	cleanReport     = "report"
+)
+
+// legacyGraphDump matches the random graphs dumped in the working directory by the previous versions
+var legacyGraphDump = regexp.MustCompile(`^random-graph-[0-9]+\.json$`)
+
+type cleanOptions struct {
+	RunID     string
+	OlderThan time.Duration
+	KeepLast  int
+	// FailedOnly selects the failed or aborted runs and the containers exited with a non zero status
+	FailedOnly bool
+	Containers bool
+	Files      bool
+}
+
+// selectsRuns tells if the runs are selected by a filter, the files left by the
+// previous versions belong to no run and are kept
+func (o cleanOptions) selectsRuns() bool {
+	return o.RunID != "" || o.KeepLast > 0 || o.FailedOnly
+}
+
+// removesRunDirs tells if the run directories of the selected runs are removed,
+// without any filter only their kubeconfig copies are
+func (o cleanOptions) removesRunDirs() bool {
+	return o.selectsRuns() || o.OlderThan > 0
+}
+
+// cleanItem is a container or a file removed by clean
+type cleanItem struct {
+	Kind    string
+	Name    string
+	RunID   string
+	Started time.Time
+	Status  string
+	remove  func() error
+}
+
 func NewCleanCommand(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator, config config.Config) *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "clean",
 		Short: "cleans already run scenario files and containers",
-		Long:  `cleans already run scenario files and containers`,
-		Args:  cobra.NoArgs,
+		Long: `cleans already run scenario files and containers: the exited krknctl containers, the kubeconfig
+copies of the finished runs and the log files, random graph dumps and resiliency reports left in the
+working directory by the previous versions. The run directories are kept unless the runs are selected
+by --run, --keep-last, --failed-only or --older-than. The running scenarios are never touched,
+--dry-run prints what would be removed`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			(*scenarioOrchestrator).PrintContainerRuntime()
-			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+			var options cleanOptions
+			var err error
+			if options.RunID, err = cmd.Flags().GetString("run"); err != nil {
+				return err
+			}
+			if options.OlderThan, err = cmd.Flags().GetDuration("older-than"); err != nil {
+				return err
+			}
+			if options.KeepLast, err = cmd.Flags().GetInt("keep-last"); err != nil {
+				return err
+			}
+			if options.FailedOnly, err = cmd.Flags().GetBool("failed-only"); err != nil {
+				return err
+			}
+			containersOnly, err := cmd.Flags().GetBool("containers-only")
+			if err != nil {
+				return err
+			}
+			filesOnly, err := cmd.Flags().GetBool("files-only")
+			if err != nil {
+				return err
+			}
+			dryRun, err := cmd.Flags().GetBool("dry-run")
 			if err != nil {
 				return err
 			}
-			ctx, err := (*scenarioOrchestrator).Connect(*socket)
+			outputDir, err := cmd.Flags().GetString("output-dir")
 			if err != nil {
 				return err
 			}
-			deletedContainers, err := (*scenarioOrchestrator).CleanContainers(ctx)
+			gzipLogs, err := cmd.Flags().GetBool("gzip-logs")
 			if err != nil {
 				return err
 			}
-			deletedKubeconfigFiles, err := utils.CleanKubeconfigFiles(config)
+			if options.OlderThan < 0 || options.KeepLast < 0 {
+				return errors.New("--older-than and --keep-last can't be negative")
+			}
+			options.Containers = !filesOnly
+			options.Files = !containersOnly
+
+			root, err := workspace.Root(outputDir, config)
 			if err != nil {
 				return err
 			}
-			deletedLogFiles, err := utils.CleanLogFiles(config)
+			runs, err := workspace.List(root)
 			if err != nil {
 				return err
 			}
-			fmt.Printf("%d containers, %d kubeconfig files, %d log files deleted\n", *deletedContainers, *deletedKubeconfigFiles, *deletedLogFiles)
-			return nil
+			var containers []orchestratorModels.Container
+			var removeContainer func(containerID string) error
+			if options.Containers {
+				(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
+				socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+				if err != nil {
+					return err
+				}
+				ctx, err := (*scenarioOrchestrator).Connect(*socket)
+				if err != nil {
+					return err
+				}
+				finished, err := (*scenarioOrchestrator).ListFinishedContainers(ctx, runFilter(options.RunID))
+				if err != nil {
+					return err
+				}
+				containers = *finished
+				removeContainer = func(containerID string) error {
+					return (*scenarioOrchestrator).RemoveContainer(&containerID, ctx)
+				}
+			}
+
+			now := time.Now()
+			kept := keptRuns(runs, containers, options.KeepLast)
+			var items []cleanItem
+			if options.Containers {
+				items = append(items, cleanContainers(containers, options, kept, now, removeContainer)...)
+			}
+			if options.Files {
+				files, err := cleanFiles(runs, options, kept, now, config)
+				if err != nil {
+					return err
+				}
+				items = append(items, files...)
+			}
+
+			if len(items) == 0 {
+				fmt.Println("nothing to clean")
+			} else {
+				NewCleanTable(items).Print()
+			}
+			if dryRun {
+				fmt.Printf("%d containers, %d files would be removed (dry run)\n", countContainers(items), len(items)-countContainers(items))
+				return nil
+			}
+			var cleanErrors []error
+			removed := make(map[string]bool)
+			removedContainers, removedFiles := 0, 0
+			for _, item := range items {
+				if err := item.remove(); err != nil && !os.IsNotExist(err) {
+					cleanErrors = append(cleanErrors, fmt.Errorf("failed to remove %s %s: %w", item.Kind, item.Name, err))
+					continue
+				}
+				removed[item.Name] = true
+				if item.Kind == cleanContainer {
+					removedContainers++
+				} else {
+					removedFiles++
+				}
+			}
+			fmt.Printf("%d containers, %d files removed\n", removedContainers, removedFiles)
+
+			if gzipLogs && options.Files {
+				compressedLogFiles := 0
+				for _, run := range runs {
+					if !run.Finished() || removed[run.Dir] || (options.RunID != "" && run.Metadata.RunID != options.RunID) {
+						continue
+					}
+					compressed, err := run.CompressLogs()
+					compressedLogFiles += compressed
+					if err != nil {
+						cleanErrors = append(cleanErrors, err)
+						break
+					}
+				}
+				fmt.Printf("%d log files compressed in %s\n", compressedLogFiles, root)
+			}
+			return errors.Join(cleanErrors...)
 		},
 	}
 
 	return command
 }
+
+// keptRuns returns the IDs of the keepLast most recent runs, the runs are
+// known by their run directories and by the labels of their containers
+func keptRuns(runs []*workspace.Workspace, containers []orchestratorModels.Container, keepLast int) map[string]bool {
+	kept := make(map[string]bool)
+	if keepLast <= 0 {
+		return kept
+	}
+	started := make(map[string]time.Time)
+	for _, run := range runs {
+		started[run.Metadata.RunID] = run.Metadata.Started
+	}
+	for _, container := range containers {
+		if container.Labels == nil || container.Labels.RunID == "" {
+			continue
+		}
+		containerStarted := time.Unix(container.Started, 0)
+		if s, ok := started[container.Labels.RunID]; !ok || containerStarted.Before(s) {
+			started[container.Labels.RunID] = containerStarted
+		}
+	}
+	runIDs := sortedKeys(started)
+	sort.SliceStable(runIDs, func(i, j int) bool {
+		return started[runIDs[i]].After(started[runIDs[j]])
+	})
+	for i := 0; i < keepLast && i < len(runIDs); i++ {
+		kept[runIDs[i]] = true
+	}
+	return kept
+}
+
+// cleanContainers selects the exited containers matching the options, the
+// containers of a run kept by --keep-last are never selected
+func cleanContainers(containers []orchestratorModels.Container, options cleanOptions, kept map[string]bool, now time.Time, remove func(containerID string) error) []cleanItem {
+	var items []cleanItem
+	for _, container := range containers {
+		runID := ""
+		if container.Labels != nil {
+			runID = container.Labels.RunID
+		}
+		started := time.Unix(container.Started, 0)
+		if kept[runID] ||
+			(options.OlderThan > 0 && started.After(now.Add(-options.OlderThan))) ||
+			(options.FailedOnly && container.ExitStatus == 0) {
+			continue
+		}
+		containerID := container.ID
+		items = append(items, cleanItem{
+			Kind:    cleanContainer,
+			Name:    container.Name,
+			RunID:   runID,
+			Started: started,
+			Status:  fmt.Sprintf("%s (%d)", container.Status, container.ExitStatus),
+			remove:  func() error { return remove(containerID) },
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Started.Before(items[j].Started)
+	})
+	return items
+}
+
+// cleanFiles selects the files of the finished runs matching the options, the whole run
+// directory if the runs are filtered, its kubeconfig copy otherwise. The files left in the
+// working directory and in the temp folder by the previous versions are selected only if
+// no run is.
+func cleanFiles(runs []*workspace.Workspace, options cleanOptions, kept map[string]bool, now time.Time, config config.Config) ([]cleanItem, error) {
+	var items []cleanItem
+	cutoff := now.Add(-options.OlderThan)
+	var keptRunDirs []string
+	for _, run := range runs {
+		metadata := run.Metadata
+		ended := metadata.Started
+		if metadata.Finished != nil {
+			ended = *metadata.Finished
+		}
+		if !run.Finished() ||
+			(options.RunID != "" && metadata.RunID != options.RunID) ||
+			kept[metadata.RunID] ||
+			(options.OlderThan > 0 && ended.After(cutoff)) ||
+			(options.FailedOnly && metadata.Status != workspace.StatusFailed && metadata.Status != workspace.StatusAborted) {
+			continue
+		}
+		if !options.removesRunDirs() {
+			keptRunDirs = append(keptRunDirs, run.Dir)
+			continue
+		}
+		dir := run.Dir
+		items = append(items, cleanItem{
+			Kind:    cleanRun,
+			Name:    dir,
+			RunID:   metadata.RunID,
+			Started: metadata.Started,
+			Status:  string(metadata.Status),
+			remove:  func() error { return os.RemoveAll(dir) },
+		})
+	}
+	if options.selectsRuns() {
+		// the files of the working directory and of the temp folder belong to no run
+		return items, nil
+	}
+	kubeconfigs, err := utils.FindKubeconfigFiles(config, keptRunDirs...)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := legacyFiles(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range append(kubeconfigs, legacy...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if options.OlderThan > 0 && info.ModTime().After(cutoff) {
+			continue
+		}
+		kind := cleanKubeconfig
+		switch {
+		case filepath.Ext(file) == workspace.LogExtension:
+			kind = cleanLog
+		case legacyGraphDump.MatchString(filepath.Base(file)):
+			kind = cleanGraph
+		case filepath.Base(file) == workspace.ReportFile:
+			kind = cleanReport
+		}
+		runID := ""
+		for _, run := range runs {
+			if dir, err := filepath.Abs(run.Dir); err == nil && filepath.Dir(file) == dir {
+				runID = run.Metadata.RunID
+			}
+		}
+		path := file
+		items = append(items, cleanItem{
+			Kind:    kind,
+			Name:    path,
+			RunID:   runID,
+			Started: info.ModTime(),
+			remove:  func() error { return os.Remove(path) },
+		})
+	}
+	return items, nil
+}
+
+// legacyFiles returns the log files, the random graph dumps and the resiliency report
+// written in the working directory by the previous versions
+func legacyFiles(config config.Config) ([]string, error) {
+	files, err := utils.FindLogFiles(config)
+	if err != nil {
+		return nil, err
+	}
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(currentDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && (legacyGraphDump.MatchString(entry.Name()) || entry.Name() == workspace.ReportFile) {
+			files = append(files, filepath.Join(currentDir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+func countContainers(items []cleanItem) int {
+	count := 0
+	for _, item := range items {
+		if item.Kind == cleanContainer {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRuns(t *testing.T, now time.Time) []*workspace.Workspace {
+	root := t.TempDir()
+	var runs []*workspace.Workspace
+	for _, run := range []struct {
+		id     string
+		age    time.Duration
+		status workspace.Status
+	}{
+		{"old-failed", 96 * time.Hour, workspace.StatusFailed},
+		{"old-succeeded", 80 * time.Hour, workspace.StatusSucceeded},
+		{"recent-aborted", time.Hour, workspace.StatusAborted},
+		{"running", time.Minute, workspace.StatusRunning},
+	} {
+		ws, err := workspace.New(root, workspace.Metadata{RunID: run.id, Started: now.Add(-run.age)})
+		assert.Nil(t, err)
+		ws.Metadata.Status = run.status
+		if run.status != workspace.StatusRunning {
+			finished := now.Add(-run.age).Add(time.Minute)
+			ws.Metadata.Finished = &finished
+		}
+		assert.Nil(t, ws.WriteMetadata())
+		runs = append(runs, ws)
+	}
+	return runs
+}
+
+func TestKeptRuns(t *testing.T) {
+	now := time.Now()
+	runs := testRuns(t, now)
+	containers := []orchestratorModels.Container{
+		// a run without run directory
+		{Started: now.Add(-30 * time.Minute).Unix(), Labels: &orchestratorModels.ContainerLabels{RunID: "no-dir"}},
+	}
+	assert.Empty(t, keptRuns(runs, containers, 0))
+	assert.Equal(t, map[string]bool{"running": true, "no-dir": true, "recent-aborted": true}, keptRuns(runs, containers, 3))
+	assert.Len(t, keptRuns(runs, containers, 10), 5)
+}
+
+func TestCleanContainers(t *testing.T) {
+	now := time.Now()
+	containers := []orchestratorModels.Container{
+		{ID: "1", Name: "krknctl-pods-1", Started: now.Add(-96 * time.Hour).Unix(), Status: "exited", ExitStatus: 1, Labels: &orchestratorModels.ContainerLabels{RunID: "a"}},
+		{ID: "2", Name: "krknctl-cpu-2", Started: now.Add(-time.Hour).Unix(), Status: "exited", Labels: &orchestratorModels.ContainerLabels{RunID: "b"}},
+		{ID: "3", Name: "krknctl-memory-3", Started: now.Add(-2 * time.Hour).Unix(), Status: "exited", ExitStatus: 2, Labels: &orchestratorModels.ContainerLabels{RunID: "c"}},
+	}
+	var removed []string
+	remove := func(containerID string) error {
+		removed = append(removed, containerID)
+		return nil
+	}
+	names := func(items []cleanItem) []string {
+		var result []string
+		for _, item := range items {
+			result = append(result, item.Name)
+		}
+		return result
+	}
+
+	items := cleanContainers(containers, cleanOptions{}, nil, now, remove)
+	assert.Equal(t, []string{"krknctl-pods-1", "krknctl-memory-3", "krknctl-cpu-2"}, names(items))
+	assert.Equal(t, "exited (1)", items[0].Status)
+	assert.Nil(t, items[1].remove())
+	assert.Equal(t, []string{"3"}, removed)
+
+	items = cleanContainers(containers, cleanOptions{OlderThan: 72 * time.Hour}, nil, now, remove)
+	assert.Equal(t, []string{"krknctl-pods-1"}, names(items))
+	items = cleanContainers(containers, cleanOptions{FailedOnly: true}, map[string]bool{"c": true}, now, remove)
+	assert.Equal(t, []string{"krknctl-pods-1"}, names(items))
+}
+
+func TestCleanFiles(t *testing.T) {
+	now := time.Now()
+	runs := testRuns(t, now)
+	kubeconfig := filepath.Join(runs[1].Dir, "krknctl-kubeconfig-abcde-1234")
+	assert.Nil(t, os.WriteFile(kubeconfig, []byte("kubeconfig"), 0600))
+	runIDs := func(items []cleanItem) []string {
+		var result []string
+		for _, item := range items {
+			if item.Kind == cleanRun {
+				result = append(result, item.RunID)
+			}
+		}
+		return result
+	}
+
+	// without filters the run directories are kept, their kubeconfig copies are removed
+	items, err := cleanFiles(runs, cleanOptions{}, nil, now, getConfig(t))
+	assert.Nil(t, err)
+	assert.Empty(t, runIDs(items))
+	i := indexOfItem(items, kubeconfig)
+	assert.NotEqual(t, -1, i)
+	assert.Equal(t, cleanKubeconfig, items[i].Kind)
+	assert.Equal(t, "old-succeeded", items[i].RunID)
+
+	items, err = cleanFiles(runs, cleanOptions{FailedOnly: true}, nil, now, getConfig(t))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"old-failed", "recent-aborted"}, runIDs(items))
+	items, err = cleanFiles(runs, cleanOptions{RunID: "old-succeeded"}, nil, now, getConfig(t))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"old-succeeded"}, runIDs(items))
+	// the running run is never removed
+	items, err = cleanFiles(runs, cleanOptions{KeepLast: 1}, keptRuns(runs, nil, 1), now, getConfig(t))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"old-failed", "old-succeeded", "recent-aborted"}, runIDs(items))
+	items, err = cleanFiles(runs, cleanOptions{OlderThan: 72 * time.Hour}, nil, now, getConfig(t))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"old-failed", "old-succeeded"}, runIDs(items))
+
+	assert.Nil(t, items[0].remove())
+	_, err = os.Stat(runs[0].Dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func indexOfItem(items []cleanItem, name string) int {
+	for i, item := range items {
+		if item.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -73,7 +73,7 @@ Optional --chaos-assets-dir and --database-dir default to the stock image paths.
 			}
 			dataDir := filepath.Join(home, ".krknctl", "krkn-dashboard-data")
 
-			(*scenarioOrchestrator).PrintContainerRuntime()
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
 
 			kube := strings.TrimSpace(kubeconfigPath)
 			if kube == "" {
@@ -182,6 +182,12 @@ Optional --chaos-assets-dir and --database-dir default to the stock image paths.
 			}
 
 			containerName := utils.GenerateContainerName(cfg, "krkn-dashboard", nil)
+			if podmanCreate == nil {
+				podmanCreate = &scenarioorchestrator.PodmanCreateOptions{}
+			}
+			dashboardLabels := utils.NewContainerLabels(cfg, "")
+			dashboardLabels.Scenario = "krkn-dashboard"
+			podmanCreate.Labels = &dashboardLabels
 
 			spinner := NewSpinnerWithSuffix(" pulling krkn-dashboard image...")
 			spinner.Start()
@@ -198,7 +204,7 @@ Optional --chaos-assets-dir and --database-dir default to the stock image paths.
 				return err
 			}
 			_, _ = color.New(color.FgYellow).Println("Starting krkn-dashboard - open http://localhost:3000 when the app is ready (Ctrl+C to stop).")
-			_, err = (*scenarioOrchestrator).RunAttached(dashboardImage, containerName, environment, false, volumes, os.Stdout, os.Stderr, &commChan, conn, nil, dashboardPublishPorts(), podmanCreate)
+			_, err = (*scenarioOrchestrator).RunAttached(dashboardImage, containerName, environment, false, volumes, os.Stdout, os.Stderr, &commChan, conn, nil, dashboardPublishPorts(), podmanCreate, nil)
 			if err != nil {
 				return err
 			}
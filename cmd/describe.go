@@ -9,6 +9,7 @@ import (
 	"github.com/krkn-chaos/krknctl/pkg/text"
 	"github.com/spf13/cobra"
 	"log"
+	"os"
 )
 
 func NewDescribeCommand(factory *factory.ProviderFactory, config config.Config) *cobra.Command {
@@ -55,7 +56,7 @@ func NewDescribeCommand(factory *factory.ProviderFactory, config config.Config)
 				}
 			}
 			if registrySettings != nil {
-				logPrivateRegistry(registrySettings.RegistryURL)
+				logPrivateRegistry(os.Stdout, registrySettings.RegistryURL)
 			}
 			spinner := NewSpinnerWithSuffix("fetching scenario details...")
 			spinner.Start()
@@ -85,6 +86,12 @@ func PrintScenarioDetail(scenarioDetail *models.ScenarioDetail) {
 		fmt.Println(line)
 	}
 	fmt.Print("\n")
+	if scenarioDetail.HasRollback {
+		fmt.Printf("%s supported, see krknctl rollback\n", headerFmt("Rollback:"))
+	} else {
+		fmt.Printf("%s not supported\n", headerFmt("Rollback:"))
+	}
+	fmt.Print("\n")
 	PrintGroupedArgumentTables(scenarioDetail.Fields)
 	fmt.Print("\n")
 
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+)
+
+// runOutputJSONEvents streams the run events as JSON lines instead of the spinner and the colored text
+const runOutputJSONEvents = "json-events"
+
+// eventWriter writes the run events as JSON lines, a nil writer discards them
+type eventWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	file    *os.File
+	// runID is set on the events whose producer does not know the run (e.g. the image pre-pull)
+	runID string
+	// output receives the text printed by the run, nil if it is printed to stdout
+	output io.Writer
+}
+
+// validateRunOutput checks the --output of the run commands, the dry run prints the
+// plan as a table or as JSON while the events are streamed only by the real runs
+func validateRunOutput(output string, dryRun bool) error {
+	if output == runOutputJSONEvents {
+		if dryRun {
+			return fmt.Errorf("output %s can't be used with --dry-run, no scenario is started", runOutputJSONEvents)
+		}
+		return nil
+	}
+	if err := validateDryRunOutput(output); err != nil {
+		return fmt.Errorf("%w, %s", err, runOutputJSONEvents)
+	}
+	return nil
+}
+
+// openEventWriter returns the writer of the run events, nil if they are not requested. The events
+// are written to eventsFile if set, to stdout with --output json-events. In the latter case the
+// scenario logs and the text printed by krknctl go to stderr, see Output.
+func openEventWriter(output string, eventsFile string) (*eventWriter, error) {
+	if eventsFile != "" {
+		file, err := os.Create(filepath.Clean(eventsFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the events file %s: %w", eventsFile, err)
+		}
+		return newEventWriter(file, file), nil
+	}
+	if output != runOutputJSONEvents {
+		return nil, nil
+	}
+	writer := newEventWriter(os.Stdout, nil)
+	writer.output = color.Error
+	return writer, nil
+}
+
+func newEventWriter(w io.Writer, file *os.File) *eventWriter {
+	return &eventWriter{encoder: json.NewEncoder(w), file: file}
+}
+
+// Output returns the writer of the scenario logs and of the text printed by the run,
+// stderr when the events are written to stdout so that stdout can be parsed
+func (w *eventWriter) Output() io.Writer {
+	if w == nil || w.output == nil {
+		return color.Output
+	}
+	return w.output
+}
+
+// Emit writes the event as a JSON line
+func (w *eventWriter) Emit(event *orchestratorModels.Event) {
+	if w == nil || event == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if event.RunID == "" {
+		event.RunID = w.runID
+	}
+	if err := w.encoder.Encode(event); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the %s event: %v\n", event.Type, err)
+	}
+}
+
+// RunFinished writes the run-finished event of a single run, err is the error returned by the scenario
+func (w *eventWriter) RunFinished(started time.Time, report string, err error) {
+	if w == nil {
+		return
+	}
+	event := orchestratorModels.NewEvent(orchestratorModels.EventRunFinished, w.runID)
+	event.SetDuration(started)
+	event.Report = report
+	var abortErr *utils.AbortError
+	switch {
+	case err == nil:
+		event.Status = orchestratorModels.EventStatusSucceeded
+	case errors.As(err, &abortErr):
+		event.Status = orchestratorModels.EventStatusAborted
+	default:
+		event.Status = orchestratorModels.EventStatusFailed
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	w.Emit(event)
+}
+
+// Close closes the events file
+func (w *eventWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	if w.file != nil {
+		return w.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRunOutput(t *testing.T) {
+	assert.Nil(t, validateRunOutput("table", false))
+	assert.Nil(t, validateRunOutput("json", true))
+	assert.Nil(t, validateRunOutput(runOutputJSONEvents, false))
+	assert.NotNil(t, validateRunOutput(runOutputJSONEvents, true))
+	err := validateRunOutput("yaml", false)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), runOutputJSONEvents)
+}
+
+func TestEventWriter(t *testing.T) {
+	var events *eventWriter
+	// a nil writer discards the events
+	events.Emit(orchestratorModels.NewEvent(orchestratorModels.EventRunStarted, "run"))
+	events.RunFinished(time.Now(), "", nil)
+	assert.Nil(t, events.Close())
+
+	events, err := openEventWriter("table", "")
+	assert.Nil(t, err)
+	assert.Nil(t, events)
+
+	eventsFile := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err = openEventWriter("table", eventsFile)
+	assert.Nil(t, err)
+	events.runID = "run"
+	events.Emit(orchestratorModels.NewEvent(orchestratorModels.EventPullProgress, ""))
+	events.Emit(orchestratorModels.NewEvent(orchestratorModels.EventLayerFinished, "other"))
+	events.RunFinished(time.Now(), "", &utils.AbortError{Cause: errors.New("interrupted")})
+	assert.Nil(t, events.Close())
+
+	file, err := os.Open(eventsFile)
+	assert.Nil(t, err)
+	defer file.Close()
+	var written []orchestratorModels.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event orchestratorModels.Event
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &event))
+		written = append(written, event)
+	}
+	assert.Len(t, written, 3)
+	assert.Equal(t, orchestratorModels.EventPullProgress, written[0].Type)
+	assert.Equal(t, "run", written[0].RunID)
+	assert.Equal(t, "other", written[1].RunID)
+	assert.Equal(t, orchestratorModels.EventRunFinished, written[2].Type)
+	assert.Equal(t, orchestratorModels.EventStatusAborted, written[2].Status)
+	assert.NotNil(t, written[2].DurationSeconds)
+}
+
+func TestOpenEventWriterStdout(t *testing.T) {
+	stdout := os.Stdout
+	events, err := openEventWriter(runOutputJSONEvents, "")
+	assert.Nil(t, err)
+	// the text output goes to stderr, stdout is left to the events
+	assert.Equal(t, color.Error, events.Output())
+	assert.Equal(t, stdout, os.Stdout)
+	assert.Nil(t, events.Close())
+
+	// without events or with an events file the text output stays on stdout
+	var none *eventWriter
+	assert.Equal(t, color.Output, none.Output())
+	events, err = openEventWriter("table", filepath.Join(t.TempDir(), "events.jsonl"))
+	assert.Nil(t, err)
+	assert.Equal(t, color.Output, events.Output())
+	assert.Nil(t, events.Close())
+}
@@ -1,22 +1,28 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/dependencygraph"
+	"github.com/krkn-chaos/krknctl/pkg/graphrender"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
 	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/dryrun"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	commonutils "github.com/krkn-chaos/krknctl/pkg/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
-	"strings"
+	"sort"
 )
 
 func NewGraphCommand() *cobra.Command {
@@ -36,9 +42,12 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 	var command = &cobra.Command{
 		Use:   "run",
 		Short: "runs a dependency graph based run",
-		Long:  `runs graph based run`,
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
+		Long: `runs graph based run, every scenario starts as soon as all the scenarios it depends on are
+finished (--layered runs the plan step by step instead). A scenario with "when": "on_success"
+runs only if its parents succeeded, with "on_failure" only if one of them failed (e.g. a cleanup
+or a diagnostics collection), otherwise it is skipped and reported as such`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (runErr error) {
 			registrySettings, err := providermodels.NewRegistryV2FromEnv(config)
 			if err != nil {
 				return err
@@ -49,11 +58,63 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 					return err
 				}
 			}
-			(*scenarioOrchestrator).PrintContainerRuntime()
-			if registrySettings != nil {
-				logPrivateRegistry(registrySettings.RegistryURL)
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				return err
+			}
+			outputDir, err := cmd.Flags().GetString("output-dir")
+			if err != nil {
+				return err
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if err := validateRunOutput(output, dryRun); err != nil {
+				return err
+			}
+			eventsFile, err := cmd.Flags().GetString("events-file")
+			if err != nil {
+				return err
+			}
+			pullPolicyFlag, err := cmd.Flags().GetString("pull-policy")
+			if err != nil {
+				return err
+			}
+			pullPolicy, err := models.ParsePullPolicy(pullPolicyFlag)
+			if err != nil {
+				return err
+			}
+			orchestrator := *scenarioOrchestrator
+			var dryRunOrchestrator *dryrun.ScenarioOrchestrator
+			if dryRun {
+				dryRunOrchestrator = dryrun.NewScenarioOrchestrator(config, orchestrator.GetContainerRuntime())
+				orchestrator = dryRunOrchestrator
+			}
+			quiet := dryRun && output == dryRunOutputJSON
+			events, err := openEventWriter(output, eventsFile)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := events.Close(); err != nil && runErr == nil {
+					runErr = err
+				}
+			}()
+			// the events on stdout move the text output to stderr, the spinner included
+			out := events.Output()
+
+			if !quiet {
+				orchestrator.PrintContainerRuntime(out)
+				if registrySettings != nil {
+					logPrivateRegistry(out, registrySettings.RegistryURL)
+				}
 			}
 			spinner := NewSpinnerWithSuffix("running graph based chaos plan...")
+			spinner.Writer = out
+			if quiet {
+				spinner.Writer = os.Stderr
+			}
 			volumes := make(map[string]string)
 			environment := make(map[string]string)
 			kubeconfig, err := cmd.Flags().GetString("kubeconfig")
@@ -102,13 +163,39 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 			if err != nil {
 				return err
 			}
-
-			kubeconfigPath, err := utils.PrepareKubeconfig(&kubeconfig, config)
+			rollbackOnAbort, err := cmd.Flags().GetBool("rollback-on-abort")
 			if err != nil {
 				return err
 			}
-			if kubeconfigPath == nil {
-				return fmt.Errorf("kubeconfig not found: %s", kubeconfig)
+			planTimeout, err := cmd.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
+			}
+			locked, err := cmd.Flags().GetBool("locked")
+			if err != nil {
+				return err
+			}
+			var schedule models.Schedule
+			if schedule.MaxParallel, err = cmd.Flags().GetInt("max-parallel"); err != nil {
+				return err
+			}
+			if schedule.Layered, err = cmd.Flags().GetBool("layered"); err != nil {
+				return err
+			}
+			if schedule.MaxParallel < 0 {
+				return errors.New("--max-parallel can't be negative")
+			}
+
+			// the dry run does not flatten the kubeconfig to avoid leaving temporary files behind
+			kubeconfigPath := dryRunKubeconfig(kubeconfig)
+			if !dryRun {
+				kubeconfigPath, err = utils.PrepareKubeconfig(&kubeconfig, config)
+				if err != nil {
+					return err
+				}
+				if kubeconfigPath == nil {
+					return fmt.Errorf("kubeconfig not found: %s", kubeconfig)
+				}
 			}
 			volumes[*kubeconfigPath] = config.KubeconfigPath
 
@@ -131,6 +218,9 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 			if err != nil {
 				return err
 			}
+			if err = applyPlanTimeout(nodes, planTimeout); err != nil {
+				return err
+			}
 			privateRegistry := false
 			if registrySettings != nil {
 				privateRegistry = true
@@ -141,9 +231,10 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 				name *string
 				err  error
 			})
+			secrets := make(map[string]bool)
 			spinner.Start()
 			go func() {
-				validateGraphScenarioInput(dataProvider, nodes, nameChannel, registrySettings)
+				validateGraphScenarioInput(dataProvider, nodes, nameChannel, registrySettings, orchestrator.GetContainerRuntime(), secrets)
 			}()
 
 			for {
@@ -160,6 +251,19 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 				}
 			}
 
+			if locked {
+				spinner.Suffix = "checking the plan against the lockfile..."
+				lock, err := lockfile.Load(lockfile.Path(args[0]))
+				if err != nil {
+					spinner.Stop()
+					return err
+				}
+				if err := lock.Apply(nodes, dataProvider, registrySettings); err != nil {
+					spinner.Stop()
+					return err
+				}
+			}
+
 			spinner.Stop()
 
 			convertedNodes := make(map[string]dependencygraph.ParentProvider, len(nodes))
@@ -176,70 +280,295 @@ func NewGraphRunCommand(factory *providerfactory.ProviderFactory, scenarioOrches
 
 			executionPlan := graph.TopoSortedLayers()
 			if len(executionPlan) == 0 {
-				_, err = color.New(color.FgYellow).Println("No scenario to execute; the graph file appears to be empty (single-node graphs are not supported).")
+				_, err = color.New(color.FgYellow).Fprintln(out, "No scenario to execute; the graph file appears to be empty (single-node graphs are not supported).")
 				if err != nil {
 					return err
 				}
 				return nil
 			}
 
-			table, err := NewGraphTable(executionPlan, config)
-			if err != nil {
-				return err
+			if !quiet {
+				table, err := NewGraphTable(executionPlan, config)
+				if err != nil {
+					return err
+				}
+				table.WithWriter(out).Print()
+				_, _ = fmt.Fprint(out, "\n\n")
+			}
+			runLabels := utils.NewContainerLabels(config, utils.GraphHash(file))
+			if events != nil {
+				events.runID = runLabels.RunID
+			}
+			// the logs, the report and the run metadata are stored in the run directory
+			var ws *workspace.Workspace
+			var record *history.Record
+			var scenarioErr error
+			runDir := ""
+			if !dryRun {
+				record = history.NewRecord(nodes)
+				ws, err = newGraphRunWorkspace(outputDir, runCommandName(cmd), args[0], nodes, runLabels, orchestrator.GetContainerRuntime(), kubeconfigPath, environment, volumes, secrets, record, config)
+				if err != nil {
+					return err
+				}
+				runDir = ws.Dir
+				record.Images = resolveRunImages(nodes, dataProvider, registrySettings)
+				defer func() {
+					// the scenarios failed without exit-on-error do not fail krknctl but the run
+					if runErr != nil {
+						finishRunWorkspace(ws, record, runErr, config)
+					} else {
+						finishRunWorkspace(ws, record, scenarioErr, config)
+					}
+				}()
+				_, _ = fmt.Fprintf(out, "run ID: %s\nrun directory: %s\n\n", runLabels.RunID, ws.Dir)
 			}
-			table.Print()
-			fmt.Print("\n\n")
 			spinner.Suffix = "starting chaos scenarios..."
 			spinner.Start()
 
 			commChannel := make(chan *models.GraphCommChannel)
+			// Ctrl+C or a failure with exit-on-error abort the run: the running scenarios
+			// are killed, the remaining steps skipped and the partial report written
+			runCtx, abortRun, stopRun := newRunContext()
+			defer stopRun()
 
 			go func() {
-				(*scenarioOrchestrator).RunGraph(nodes, executionPlan, environment, volumes, false, commChannel, registrySettings, nil)
+				orchestrator.RunGraph(nodes, executionPlan, environment, volumes, out, pullPolicy, schedule, commChannel, runCtx, registrySettings, nil, &runLabels, runDir)
 			}()
 
-			for {
-				c := <-commChannel
-				if c == nil {
-					break
-				} else {
-					if c.Err != nil {
-						spinner.Stop()
-						var staterr *utils.ExitError
-						if errors.As(c.Err, &staterr) {
-							if c.ScenarioID != nil && c.ScenarioLogFile != nil {
-								_, err = color.New(color.FgHiRed).Println(fmt.Sprintf("scenario %s at step %d with exit status %d, check log file %s.",
-									*c.ScenarioID,
-									*c.Layer,
-									staterr.ExitStatus,
-									*c.ScenarioLogFile))
-								if err != nil {
-									return err
-								}
-							}
-							if exitOnerror {
-								_, err = color.New(color.FgHiRed).Println(fmt.Sprintf("aborting chaos run with exit status %d", staterr.ExitStatus))
-								if err != nil {
-									return err
-								}
-								os.Exit(staterr.ExitStatus)
-							}
-							spinner.Start()
-						}
-					}
-					spinner.Suffix = fmt.Sprintf("Running step %d scenario(s): %s", *c.Layer, strings.Join(executionPlan[*c.Layer], ", "))
-
+			scenarioErr, err = consumeGraphRun(commChannel, executionPlan, events, record, spinner, out, exitOnerror, runCtx, abortRun)
+			spinner.Stop()
+			if err != nil {
+				return err
+			}
+			if runCtx.Err() != nil {
+				cmd.SilenceUsage = true
+				if rollbackOnAbort && record != nil {
+					rollbackAbortedRun(runLabels.RunID, runLabels.GraphHash, record, dataProvider, registrySettings, scenarioOrchestrator, outputDir, out, config)
 				}
+				return &utils.AbortError{Cause: context.Cause(runCtx)}
+			}
+
+			if dryRunOrchestrator != nil {
+				return PrintDryRunPlan(dryRunOrchestrator.Plan(), secrets, registrySettings, output, config)
+			}
 
+			return nil
+		},
+	}
+	return command
+}
+
+func NewGraphLockCommand(factory *providerfactory.ProviderFactory, config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "lock",
+		Short: "pins the scenario images of a dependency graph to their digests",
+		Long:  `resolves the image tag of every scenario of the plan to its digest and writes the lockfile next to the plan, used by graph run --locked`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registrySettings, err := providermodels.NewRegistryV2FromEnv(config)
+			if err != nil {
+				return err
+			}
+			if registrySettings == nil {
+				registrySettings, err = parsePrivateRepoArgs(cmd, nil)
+				if err != nil {
+					return err
+				}
+			}
+			file, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open scenario file: %s", args[0])
+			}
+			nodes := make(map[string]models.ScenarioNode)
+			if err = json.Unmarshal(file, &nodes); err != nil {
+				return err
 			}
+			dataProvider := GetProvider(registrySettings != nil, factory)
+
+			spinner := NewSpinnerWithSuffix("resolving scenario image digests...")
+			spinner.Start()
+			lock, err := lockfile.Resolve(nodes, dataProvider, registrySettings)
 			spinner.Stop()
+			if err != nil {
+				return err
+			}
+			lockPath := lockfile.Path(args[0])
+			if err := lock.Write(lockPath); err != nil {
+				return err
+			}
+			NewLockfileTable(*lock).Print()
+			fmt.Printf("\nlockfile written to %s\n", lockPath)
+			return nil
+		},
+	}
+	return command
+}
 
+func NewGraphValidateCommand(factory *providerfactory.ProviderFactory, scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator, config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "validate",
+		Short: "checks a dependency graph without running it",
+		Long: `checks the plan without a cluster and without starting any scenario, reporting every problem
+found with the ID of the scenario and its position in the file: JSON syntax errors, unknown keys,
+unknown or cyclic dependencies, invalid timeouts, retries and container options, unknown environment
+variables, invalid or missing required values and volumes mounted on a path that is not a file field
+of the scenario. The fields are checked against the scenario metadata of the registry, skipped with
+--offline. Exits with status 1 if a problem is found.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registrySettings, err := providermodels.NewRegistryV2FromEnv(config)
+			if err != nil {
+				return err
+			}
+			if registrySettings == nil {
+				registrySettings, err = parsePrivateRepoArgs(cmd, nil)
+				if err != nil {
+					return err
+				}
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if err := validateDryRunOutput(output); err != nil {
+				return err
+			}
+			offline, err := cmd.Flags().GetBool("offline")
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open scenario file: %s", args[0])
+			}
+
+			nodes, offsets, broken, problems := decodePlan(data)
+			if nodes != nil {
+				spinner := NewSpinnerWithSuffix("validating the plan...")
+				// the spinner would corrupt the JSON printed on stdout
+				if output == dryRunOutputTable {
+					spinner.Start()
+				}
+				dataProvider := GetProvider(registrySettings != nil, factory)
+				runMounts := []string{config.KubeconfigPath, config.MetricsProfilePath, config.AlertsProfilePath}
+				problems = append(problems, validatePlan(nodes, offsets, broken, data, (*scenarioOrchestrator).GetContainerRuntime(), dataProvider, registrySettings, runMounts, offline)...)
+				spinner.Stop()
+			}
+			sort.SliceStable(problems, func(i, j int) bool {
+				if problems[i].Line != problems[j].Line {
+					return problems[i].Line < problems[j].Line
+				}
+				return problems[i].Column < problems[j].Column
+			})
+
+			result := planValidation{Plan: args[0], Valid: len(problems) == 0, Problems: problems}
+			if result.Problems == nil {
+				result.Problems = []planProblem{}
+			}
+			if output == dryRunOutputJSON {
+				jsonData, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(jsonData))
+			} else if result.Valid {
+				_, err = color.New(color.FgGreen).Println(fmt.Sprintf("%s is a valid plan", args[0]))
+				if err != nil {
+					return err
+				}
+			} else {
+				NewPlanProblemsTable(problems).Print()
+				fmt.Print("\n")
+			}
+			if !result.Valid {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d problem(s) found in %s", len(problems), args[0])
+			}
 			return nil
 		},
 	}
 	return command
 }
 
+func NewGraphRenderCommand(config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "render [plan]",
+		Short: "draws the execution plan of a dependency graph",
+		Long: `draws the steps of the plan and the dependencies between the scenarios as a DOT (Graphviz),
+Mermaid, ASCII or SVG diagram written to stdout, every scenario is labeled with its name and the
+environment variables listed by --label-env. With --run the scenarios are colored by their outcome
+in a previous run, the plan recorded with the run is drawn if no plan is passed.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			formatFlag, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return err
+			}
+			format, err := graphrender.ParseFormat(formatFlag)
+			if err != nil {
+				return err
+			}
+			runID, err := cmd.Flags().GetString("run")
+			if err != nil {
+				return err
+			}
+			labelEnv, err := cmd.Flags().GetStringSlice("label-env")
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 && runID == "" {
+				return errors.New("a plan is required when --run is not set")
+			}
+
+			var record *history.Record
+			if runID != "" {
+				store, err := historyStore(config)
+				if err != nil {
+					return err
+				}
+				record, err = store.Get(runID)
+				if err != nil {
+					return err
+				}
+			}
+			var nodes map[string]models.ScenarioNode
+			if len(args) > 0 {
+				file, err := os.ReadFile(args[0])
+				if err != nil {
+					return fmt.Errorf("failed to open scenario file: %s", args[0])
+				}
+				if err = json.Unmarshal(file, &nodes); err != nil {
+					return err
+				}
+			} else {
+				if !record.Replayable() {
+					return fmt.Errorf("run %s has no plan recorded, only graph and random runs can be rendered without a plan", record.RunID)
+				}
+				nodes = record.PlanSnapshot
+			}
+
+			convertedNodes := make(map[string]dependencygraph.ParentProvider, len(nodes))
+			for key, node := range nodes {
+				convertedNodes[key] = node
+			}
+			graph, err := dependencygraph.NewGraphFromNodes(convertedNodes)
+			if err != nil {
+				return err
+			}
+			executionPlan := graph.TopoSortedLayers()
+			if len(executionPlan) == 0 {
+				return errors.New("no scenario to render; the graph file appears to be empty (single-node graphs are not supported)")
+			}
+			plan := graphrender.NewPlan(nodes, executionPlan, labelEnv)
+			if record != nil {
+				plan.SetOutcomes(runOutcomes(*record))
+			}
+			return graphrender.Render(os.Stdout, plan, format)
+		},
+	}
+	return command
+}
+
 func NewGraphScaffoldCommand(factory *providerfactory.ProviderFactory, config config.Config) *cobra.Command {
 	var command = &cobra.Command{
 		Use:   "scaffold",
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "history",
+		Short: "lists, shows and replays the previous runs",
+		Long: `Every run is recorded in the history file (~/.krknctl/history.jsonl) with its plan, the digests
+of the scenario images, the exit status of every node and the path of the resiliency report`,
+	}
+}
+
+func NewHistoryListCommand(config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "list",
+		Short: "lists the previous runs",
+		Long:  `lists the previous runs, the most recent last`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if err := validateDryRunOutput(output); err != nil {
+				return err
+			}
+			limit, err := cmd.Flags().GetInt("limit")
+			if err != nil {
+				return err
+			}
+			store, err := historyStore(config)
+			if err != nil {
+				return err
+			}
+			records, err := store.List()
+			if err != nil {
+				return err
+			}
+			if limit > 0 && len(records) > limit {
+				records = records[len(records)-limit:]
+			}
+			if output == dryRunOutputJSON {
+				if records == nil {
+					records = []history.Record{}
+				}
+				return printJSON(records)
+			}
+			if len(records) == 0 {
+				fmt.Println("no run recorded yet")
+				return nil
+			}
+			NewHistoryTable(records).Print()
+			return nil
+		},
+	}
+	return command
+}
+
+func NewHistoryShowCommand(config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "show <run-id>",
+		Short: "shows the details of a previous run",
+		Long:  `shows the details of a previous run, a unique prefix of the run ID is enough`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if err := validateDryRunOutput(output); err != nil {
+				return err
+			}
+			store, err := historyStore(config)
+			if err != nil {
+				return err
+			}
+			record, err := store.Get(args[0])
+			if err != nil {
+				return err
+			}
+			if output == dryRunOutputJSON {
+				return printJSON(record)
+			}
+			printRunRecord(*record)
+			return nil
+		},
+	}
+	return command
+}
+
+func NewHistoryReplayCommand(factory *providerfactory.ProviderFactory, scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator, config config.Config) *cobra.Command {
+	graphRunCmd := NewGraphRunCommand(factory, scenarioOrchestrator, config)
+	var command = &cobra.Command{
+		Use:   "replay <run-id>",
+		Short: "runs again the plan of a previous run",
+		Long: `runs again the plan of a previous graph or random run with the scenario images pinned to the
+digests they had at that time, the pinned plan is written as replay-plan.json in the directory of the replayed run`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			// stdout is left to the events
+			out := color.Output
+			if output == runOutputJSONEvents {
+				out = color.Error
+			}
+			store, err := historyStore(config)
+			if err != nil {
+				return err
+			}
+			record, err := store.Get(args[0])
+			if err != nil {
+				return err
+			}
+			plan, unpinned, err := record.ReplayPlan()
+			if err != nil {
+				return err
+			}
+			if len(unpinned) > 0 {
+				_, err = color.New(color.FgYellow).Fprintf(out, "the digest of the image of %s has not been recorded, the current tag is run instead\n", strings.Join(unpinned, ", "))
+				if err != nil {
+					return err
+				}
+			}
+			if err := os.MkdirAll(record.RunDir, 0700); err != nil {
+				return err
+			}
+			planPath := filepath.Join(record.RunDir, history.ReplayPlanFile)
+			data, err := json.MarshalIndent(plan, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(planPath, data, 0600); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "replaying run %s started on %s\n", record.RunID, record.Started.Local().Format(time.RFC1123))
+			return graphRunCmd.RunE(cmd, []string{planPath})
+		},
+	}
+	return command
+}
+
+func printRunRecord(record history.Record) {
+	fmt.Printf("%s %s\n", headerFmt("Run ID:"), record.RunID)
+	fmt.Printf("%s %s\n", headerFmt("Command:"), record.Command)
+	if record.Plan != "" {
+		fmt.Printf("%s %s\n", headerFmt("Plan:"), record.Plan)
+	}
+	status := string(record.Status)
+	if record.Finished != nil {
+		status = fmt.Sprintf("%s (exit status %d)", record.Status, record.ExitStatus)
+	}
+	fmt.Printf("%s %s\n", headerFmt("Status:"), status)
+	if record.Error != "" {
+		fmt.Printf("%s %s\n", headerFmt("Error:"), record.Error)
+	}
+	fmt.Printf("%s %s\n", headerFmt("Started:"), record.Started.Local().Format(time.RFC1123))
+	if record.Finished != nil {
+		fmt.Printf("%s %s (%s)\n", headerFmt("Finished:"), record.Finished.Local().Format(time.RFC1123), record.Finished.Sub(record.Started).Round(time.Second))
+	}
+	fmt.Printf("%s %s %s, %s\n", headerFmt("Runtime:"), record.Runtime, record.Version, record.User)
+	fmt.Printf("%s %s\n", headerFmt("Run directory:"), record.RunDir)
+	if record.Report != "" {
+		fmt.Printf("%s %s\n", headerFmt("Resiliency report:"), record.Report)
+	}
+	fmt.Print("\n")
+	NewHistoryNodesTable(record).Print()
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// runCommandName returns the command that started the run, e.g. graph run
+func runCommandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
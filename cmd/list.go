@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/provider"
 	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	"github.com/krkn-chaos/krknctl/pkg/provider/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
 	"github.com/spf13/cobra"
 	"log"
+	"os"
+	"sync"
 )
 
 func NewListCommand() *cobra.Command {
@@ -42,7 +45,7 @@ func NewListScenariosCommand(factory *providerfactory.ProviderFactory, config co
 				}
 			}
 			if registrySettings != nil {
-				logPrivateRegistry(registrySettings.RegistryURL)
+				logPrivateRegistry(os.Stdout, registrySettings.RegistryURL)
 			}
 
 			privateRegistry := false
@@ -57,8 +60,10 @@ func NewListScenariosCommand(factory *providerfactory.ProviderFactory, config co
 				s.Stop()
 				log.Fatalf("failed to fetch scenarios: %v", err)
 			}
+			s.Suffix = "fetching scenario details..."
+			rollback := fetchRollbackSupport(provider, *scenarios, registrySettings)
 			s.Stop()
-			scenarioTable := NewScenarioTable(scenarios, privateRegistry)
+			scenarioTable := NewScenarioTable(scenarios, privateRegistry, rollback)
 			scenarioTable.Print()
 			fmt.Print("\n")
 			return nil
@@ -74,7 +79,7 @@ func NewListRunningScenario(scenarioOrchestrator *scenarioorchestrator.ScenarioO
 		Long:  `list running krkn-hub scenarios`,
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			(*scenarioOrchestrator).PrintContainerRuntime()
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
 			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
 			if err != nil {
 				return err
@@ -83,7 +88,11 @@ func NewListRunningScenario(scenarioOrchestrator *scenarioorchestrator.ScenarioO
 			if err != nil {
 				return err
 			}
-			runningScenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx)
+			runID, err := cmd.Flags().GetString("run")
+			if err != nil {
+				return err
+			}
+			runningScenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx, runFilter(runID))
 
 			if err != nil {
 				return err
@@ -103,3 +112,30 @@ func NewListRunningScenario(scenarioOrchestrator *scenarioorchestrator.ScenarioO
 	}
 	return command
 }
+
+// fetchRollbackSupport tells which scenarios support rollback, the details of the scenarios are
+// fetched concurrently and the scenarios whose details cannot be fetched are left out
+func fetchRollbackSupport(provider provider.ScenarioDataProvider, scenarios []models.ScenarioTag, registrySettings *models.RegistryV2) map[string]bool {
+	const maxFetches = 8
+	rollback := make(map[string]bool, len(scenarios))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxFetches)
+	for _, scenario := range scenarios {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			scenarioDetail, err := provider.GetScenarioDetail(scenario.Name, registrySettings)
+			if err != nil || scenarioDetail == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			rollback[scenario.Name] = scenarioDetail.HasRollback
+		}()
+	}
+	wg.Wait()
+	return rollback
+}
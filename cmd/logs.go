@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
+	"github.com/spf13/cobra"
+)
+
+// logTarget is a scenario container whose logs are printed, read from the
+// container runtime while the container exists, from the log file otherwise
+type logTarget struct {
+	// Name identifies the target in the output, the node ID if known
+	Name          string
+	ContainerName string
+	LogFile       string
+}
+
+func NewLogsCommand(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator, config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "logs <container|node-id|run-id>",
+		Short: "prints the logs of running and finished scenarios",
+		Long: `prints the logs of a scenario container selected by name or ID, of the last run of a graph node
+or of all the scenarios of a run. The logs are read from the container runtime while the container
+exists, from the log file in the run directory once it has been cleaned`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			var results []string
+			if socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil); err == nil {
+				if ctx, err := (*scenarioOrchestrator).Connect(*socket); err == nil {
+					if containers, err := (*scenarioOrchestrator).ListRunningContainers(ctx, nil); err == nil && containers != nil {
+						for _, container := range *containers {
+							results = append(results, fmt.Sprintf("%s\trunning since %s", container.Name, time.Unix(container.Started, 0).Format(time.RFC1123)))
+						}
+					}
+				}
+			}
+			if store, err := historyStore(config); err == nil {
+				if records, err := store.List(); err == nil {
+					for _, record := range records {
+						results = append(results, fmt.Sprintf("%s\t%s %s on %s", record.RunID, record.Command, record.Status, record.Started.Local().Format(time.RFC1123)))
+					}
+				}
+			}
+			var completions []string
+			for _, result := range results {
+				if strings.HasPrefix(result, toComplete) {
+					completions = append(completions, result)
+				}
+			}
+			return completions, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			follow, err := cmd.Flags().GetBool("follow")
+			if err != nil {
+				return err
+			}
+			since, err := cmd.Flags().GetString("since")
+			if err != nil {
+				return err
+			}
+			tail, err := cmd.Flags().GetInt("tail")
+			if err != nil {
+				return err
+			}
+			timestamps, err := cmd.Flags().GetBool("timestamps")
+			if err != nil {
+				return err
+			}
+			outputDir, err := cmd.Flags().GetString("output-dir")
+			if err != nil {
+				return err
+			}
+			sinceTime, err := orchestratorModels.ParseLogSince(since, time.Now())
+			if err != nil {
+				return err
+			}
+			options := orchestratorModels.LogOptions{Follow: follow, Since: sinceTime, Tail: tail, Timestamps: timestamps}
+
+			// the persisted logs can be read without the container runtime
+			var ctx context.Context
+			socket, connectErr := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+			if connectErr == nil {
+				ctx, connectErr = (*scenarioOrchestrator).Connect(*socket)
+			}
+			var running []orchestratorModels.Container
+			if connectErr == nil {
+				containers, err := (*scenarioOrchestrator).ListRunningContainers(ctx, nil)
+				if err != nil {
+					return err
+				}
+				for _, container := range *containers {
+					running = append(running, container)
+				}
+			}
+			store, err := historyStore(config)
+			if err != nil {
+				return err
+			}
+			root, err := workspace.Root(outputDir, config)
+			if err != nil {
+				return err
+			}
+
+			targets, err := resolveLogTargets(args[0], store, root, running)
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				// a container name or ID
+				if connectErr != nil {
+					return fmt.Errorf("no run or graph node %s found in the history and the container runtime is not reachable: %w", args[0], connectErr)
+				}
+				targets = []logTarget{{Name: args[0], ContainerName: args[0]}}
+			}
+
+			printLogs := func(target logTarget, stdout io.Writer, stderr io.Writer) error {
+				if connectErr == nil {
+					containerID, err := (*scenarioOrchestrator).ResolveContainerName(target.ContainerName, ctx)
+					if err != nil {
+						return err
+					}
+					if containerID != nil {
+						return (*scenarioOrchestrator).Logs(containerID, options, stdout, stderr, ctx)
+					}
+				}
+				if target.LogFile == "" {
+					return fmt.Errorf("no container or log file found for %s", target.Name)
+				}
+				if options.Timestamps || !options.Since.IsZero() {
+					_, err := color.New(color.FgYellow).Fprintf(os.Stderr, "the container of %s has been removed, --since and --timestamps are not applied to its log file\n", target.Name)
+					if err != nil {
+						return err
+					}
+				}
+				return writeLogFile(target.LogFile, options.Tail, stdout)
+			}
+
+			if len(targets) == 1 {
+				return printLogs(targets[0], os.Stdout, os.Stderr)
+			}
+			if !follow {
+				for i, target := range targets {
+					if i > 0 {
+						fmt.Print("\n")
+					}
+					_, err := color.New(color.FgGreen).Printf("==> %s <==\n", target.Name)
+					if err != nil {
+						return err
+					}
+					if err := printLogs(target, os.Stdout, os.Stderr); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			// the scenarios of the run are followed together, every line is prefixed by its node
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			errs := make([]error, len(targets))
+			for i, target := range targets {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					stdout := newPrefixWriter(os.Stdout, fmt.Sprintf("[%s] ", target.Name), &mu)
+					stderr := newPrefixWriter(os.Stderr, fmt.Sprintf("[%s] ", target.Name), &mu)
+					errs[i] = printLogs(target, stdout, stderr)
+					stdout.Flush()
+					stderr.Flush()
+				}()
+			}
+			wg.Wait()
+			return errors.Join(errs...)
+		},
+	}
+	return command
+}
+
+// resolveLogTargets looks for the target as a run ID, then as a graph node ID, as a unique prefix
+// of a run ID and at last as the name of a container whose log file has been kept. The containers
+// of a run still in progress come from its run directory and from the running containers, a node
+// resolves to its running container or to its last recorded run. No target means that the
+// argument is a container name or ID.
+func resolveLogTargets(target string, store *history.Store, root string, running []orchestratorModels.Container) ([]logTarget, error) {
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.RunID == target {
+			return recordLogTargets(record)
+		}
+	}
+
+	targets := make(map[string]logTarget)
+	if ws, err := workspace.Load(filepath.Join(root, target)); err == nil {
+		logs, err := ws.Logs()
+		if err != nil {
+			return nil, err
+		}
+		for _, logFile := range logs {
+			containerName := logContainerName(logFile)
+			targets[containerName] = logTarget{Name: containerName, ContainerName: containerName, LogFile: strings.TrimSuffix(logFile, workspace.GzipExtension)}
+		}
+	}
+	for _, container := range running {
+		if container.Labels != nil && container.Labels.RunID == target {
+			logTarget := targets[container.Name]
+			logTarget.Name = container.Name
+			if container.Labels.NodeID != "" {
+				logTarget.Name = container.Labels.NodeID
+			}
+			logTarget.ContainerName = container.Name
+			targets[container.Name] = logTarget
+		}
+	}
+	if len(targets) > 0 {
+		var result []logTarget
+		for _, logTarget := range targets {
+			result = append(result, logTarget)
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Name < result[j].Name
+		})
+		return result, nil
+	}
+
+	// the most recent container of the node
+	var nodeContainer *orchestratorModels.Container
+	for _, container := range running {
+		if container.Labels != nil && container.Labels.NodeID == target && (nodeContainer == nil || container.Started > nodeContainer.Started) {
+			nodeContainer = &container
+		}
+	}
+	if nodeContainer != nil {
+		return []logTarget{{Name: target, ContainerName: nodeContainer.Name}}, nil
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if node, ok := records[i].Nodes[target]; ok && node.LogFile != "" {
+			return []logTarget{{Name: target, ContainerName: logContainerName(node.LogFile), LogFile: node.LogFile}}, nil
+		}
+	}
+
+	record, err := store.Get(target)
+	if err != nil && !errors.Is(err, history.ErrNotFound) {
+		return nil, err
+	}
+	if record != nil {
+		return recordLogTargets(*record)
+	}
+
+	// the log file of a container already removed from the runtime
+	runs, err := workspace.List(root)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		logFile := runs[i].LogPath(target)
+		for _, path := range []string{logFile, logFile + workspace.GzipExtension} {
+			if _, err := os.Stat(path); err == nil {
+				return []logTarget{{Name: target, ContainerName: target, LogFile: logFile}}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// recordLogTargets returns the nodes started by the recorded run
+func recordLogTargets(record history.Record) ([]logTarget, error) {
+	var targets []logTarget
+	for _, nodeID := range sortedKeys(record.Nodes) {
+		node := record.Nodes[nodeID]
+		if node.LogFile == "" {
+			continue
+		}
+		targets = append(targets, logTarget{Name: nodeID, ContainerName: logContainerName(node.LogFile), LogFile: node.LogFile})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no scenario has been started by run %s", record.RunID)
+	}
+	return targets, nil
+}
+
+// logContainerName returns the name of the container that wrote the log file
+func logContainerName(logFile string) string {
+	name := strings.TrimSuffix(filepath.Base(logFile), workspace.GzipExtension)
+	return strings.TrimSuffix(name, workspace.LogExtension)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeLogFile writes the last lines of the log file, all of them if tail is negative,
+// the file is read compressed if it has been gzipped by krknctl clean
+func writeLogFile(logFile string, tail int, w io.Writer) error {
+	file, err := os.Open(filepath.Clean(logFile))
+	compressed := false
+	if os.IsNotExist(err) {
+		file, err = os.Open(filepath.Clean(logFile + workspace.GzipExtension))
+		compressed = true
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if compressed {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	if tail < 0 {
+		_, err = io.Copy(w, reader)
+		return err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > tail {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixWriter prefixes every line written to the underlying writer, the
+// lines of the writers sharing the mutex are never interleaved. If the filter
+// is set only the lines matching it are written.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	filter *regexp.Regexp
+	buffer []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix, mu: mu}
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buffer = append(p.buffer, data...)
+	for {
+		i := bytes.IndexByte(p.buffer, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.buffer[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buffer = p.buffer[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes the last line if it is not terminated by a newline
+func (p *prefixWriter) Flush() {
+	if len(p.buffer) > 0 {
+		_ = p.writeLine(append(p.buffer, '\n'))
+		p.buffer = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	if p.filter != nil && !p.filter.Match(line) {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(append([]byte(p.prefix), line...))
+	return err
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLogFile(t *testing.T) {
+	ws, err := workspace.New(t.TempDir(), workspace.Metadata{RunID: "run"})
+	assert.Nil(t, err)
+	logFile := ws.LogPath("krknctl-pod-scenarios-1")
+	assert.Nil(t, os.WriteFile(logFile, []byte("first\nsecond\nthird\n"), 0600))
+
+	var out bytes.Buffer
+	assert.Nil(t, writeLogFile(logFile, -1, &out))
+	assert.Equal(t, "first\nsecond\nthird\n", out.String())
+	out.Reset()
+	assert.Nil(t, writeLogFile(logFile, 2, &out))
+	assert.Equal(t, "second\nthird\n", out.String())
+	out.Reset()
+	assert.Nil(t, writeLogFile(logFile, 0, &out))
+	assert.Equal(t, "", out.String())
+
+	// the logs gzipped by clean are still readable by their original path
+	_, err = ws.CompressLogs()
+	assert.Nil(t, err)
+	out.Reset()
+	assert.Nil(t, writeLogFile(logFile, 1, &out))
+	assert.Equal(t, "third\n", out.String())
+
+	assert.NotNil(t, writeLogFile(ws.LogPath("missing"), -1, &out))
+}
+
+func TestResolveLogTargets(t *testing.T) {
+	root := t.TempDir()
+	store := history.NewStore(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	finished, err := workspace.New(root, workspace.Metadata{RunID: "a1b2c3", Started: time.Now().Add(-time.Hour)})
+	assert.Nil(t, err)
+	record := history.NewRecord(map[string]orchestratorModels.ScenarioNode{
+		"pods": {Scenario: orchestratorModels.Scenario{Name: "pod-scenarios"}},
+		"cpu":  {Scenario: orchestratorModels.Scenario{Name: "node-cpu-hog"}},
+	})
+	record.NodeStarted("pods", "", finished.LogPath("pod-scenarios-pods-1"), 1)
+	record.NodeStarted("cpu", "", finished.LogPath("node-cpu-hog-cpu-1"), 1)
+	assert.Nil(t, finished.Finish(workspace.StatusSucceeded, 0, nil))
+	record.Complete(finished)
+	assert.Nil(t, store.Append(*record))
+	assert.Nil(t, os.WriteFile(finished.LogPath("pod-scenarios-pods-1"), []byte("pods\n"), 0600))
+
+	inProgress, err := workspace.New(root, workspace.Metadata{RunID: "d4e5f6", Started: time.Now()})
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(inProgress.LogPath("pod-scenarios-pods-2"), []byte("pods\n"), 0600))
+	running := []orchestratorModels.Container{
+		{Name: "pod-scenarios-pods-2", Started: 100, Labels: &orchestratorModels.ContainerLabels{RunID: "d4e5f6", NodeID: "pods"}},
+		{Name: "node-cpu-hog-cpu-2", Started: 200, Labels: &orchestratorModels.ContainerLabels{RunID: "d4e5f6", NodeID: "cpu"}},
+	}
+
+	// a recorded run
+	targets, err := resolveLogTargets("a1b2c3", store, root, running)
+	assert.Nil(t, err)
+	assert.Equal(t, []logTarget{
+		{Name: "cpu", ContainerName: "node-cpu-hog-cpu-1", LogFile: finished.LogPath("node-cpu-hog-cpu-1")},
+		{Name: "pods", ContainerName: "pod-scenarios-pods-1", LogFile: finished.LogPath("pod-scenarios-pods-1")},
+	}, targets)
+	// by run ID prefix
+	targets, err = resolveLogTargets("a1b", store, root, running)
+	assert.Nil(t, err)
+	assert.Len(t, targets, 2)
+
+	// a run in progress
+	targets, err = resolveLogTargets("d4e5f6", store, root, running)
+	assert.Nil(t, err)
+	assert.Equal(t, []logTarget{
+		{Name: "cpu", ContainerName: "node-cpu-hog-cpu-2"},
+		{Name: "pods", ContainerName: "pod-scenarios-pods-2", LogFile: inProgress.LogPath("pod-scenarios-pods-2")},
+	}, targets)
+
+	// a node ID resolves to its running container first, to its last recorded run then
+	targets, err = resolveLogTargets("cpu", store, root, running)
+	assert.Nil(t, err)
+	assert.Equal(t, []logTarget{{Name: "cpu", ContainerName: "node-cpu-hog-cpu-2"}}, targets)
+	targets, err = resolveLogTargets("cpu", store, root, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []logTarget{{Name: "cpu", ContainerName: "node-cpu-hog-cpu-1", LogFile: finished.LogPath("node-cpu-hog-cpu-1")}}, targets)
+
+	// a container removed from the runtime
+	targets, err = resolveLogTargets("pod-scenarios-pods-1", store, root, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []logTarget{{Name: "pod-scenarios-pods-1", ContainerName: "pod-scenarios-pods-1", LogFile: finished.LogPath("pod-scenarios-pods-1")}}, targets)
+
+	// left to the container runtime
+	targets, err = resolveLogTargets("3f2a1b", store, root, running)
+	assert.Nil(t, err)
+	assert.Nil(t, targets)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	writer := newPrefixWriter(&out, "[pods] ", &mu)
+	_, err := writer.Write([]byte("first line\nsecond "))
+	assert.Nil(t, err)
+	assert.Equal(t, "[pods] first line\n", out.String())
+	_, err = writer.Write([]byte("line\nlast"))
+	assert.Nil(t, err)
+	writer.Flush()
+	assert.Equal(t, "[pods] first line\n[pods] second line\n[pods] last\n", out.String())
+
+	// the lines not matching the filter are dropped
+	out.Reset()
+	writer = newPrefixWriter(&out, "[cpu] ", &mu)
+	writer.filter = regexp.MustCompile("error")
+	_, err = writer.Write([]byte("info: started\nerror: failed\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "[cpu] error: failed\n", out.String())
+}
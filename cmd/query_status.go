@@ -53,6 +53,33 @@ func resolveContainerIDOrName(orchestrator scenarioorchestrator.ScenarioOrchestr
 	return nil
 }
 
+// nodeContainer returns the ID of the last container started for the graph node, nil if there is none
+func nodeContainer(orchestrator scenarioorchestrator.ScenarioOrchestrator, nodeID string, conn context.Context) (*string, error) {
+	filter := &models.ContainerLabels{NodeID: nodeID}
+	running, err := orchestrator.ListRunningContainers(conn, filter)
+	if err != nil {
+		return nil, err
+	}
+	finished, err := orchestrator.ListFinishedContainers(conn, filter)
+	if err != nil {
+		return nil, err
+	}
+	candidates := *finished
+	for _, container := range *running {
+		candidates = append(candidates, container)
+	}
+	var last *models.Container
+	for i := range candidates {
+		if last == nil || candidates[i].Started > last.Started {
+			last = &candidates[i]
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+	return &last.ID, nil
+}
+
 func resolveGraphFile(orchestrator scenarioorchestrator.ScenarioOrchestrator, filename string, conn context.Context) error {
 	var scenarioFile = make(map[string]providermodels.ScenarioDetail)
 	var containers = make([]models.Container, 0)
@@ -66,7 +93,7 @@ func resolveGraphFile(orchestrator scenarioorchestrator.ScenarioOrchestrator, fi
 		return err
 	}
 	for key := range scenarioFile {
-		scenario, err := orchestrator.ResolveContainerName(key, conn)
+		scenario, err := nodeContainer(orchestrator, key, conn)
 		if err != nil {
 			return err
 		}
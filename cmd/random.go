@@ -1,24 +1,25 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strings"
-	"time"
 
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/history"
 	"github.com/krkn-chaos/krknctl/pkg/provider"
 	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
 	"github.com/krkn-chaos/krknctl/pkg/randomgraph"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/dryrun"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	commonutils "github.com/krkn-chaos/krknctl/pkg/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
@@ -41,7 +42,7 @@ func NewRandomRunCommand(factory *providerfactory.ProviderFactory, scenarioOrche
 		Short: "runs a random chaos run",
 		Long:  `runs a random run based on a json test plan`,
 		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) (runErr error) {
 			registrySettings, err := providermodels.NewRegistryV2FromEnv(config)
 			if err != nil {
 				return err
@@ -52,11 +53,63 @@ func NewRandomRunCommand(factory *providerfactory.ProviderFactory, scenarioOrche
 					return err
 				}
 			}
-			(*scenarioOrchestrator).PrintContainerRuntime()
-			if registrySettings != nil {
-				logPrivateRegistry(registrySettings.RegistryURL)
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				return err
+			}
+			outputDir, err := cmd.Flags().GetString("output-dir")
+			if err != nil {
+				return err
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if err := validateRunOutput(output, dryRun); err != nil {
+				return err
+			}
+			eventsFile, err := cmd.Flags().GetString("events-file")
+			if err != nil {
+				return err
+			}
+			pullPolicyFlag, err := cmd.Flags().GetString("pull-policy")
+			if err != nil {
+				return err
+			}
+			pullPolicy, err := models.ParsePullPolicy(pullPolicyFlag)
+			if err != nil {
+				return err
+			}
+			orchestrator := *scenarioOrchestrator
+			var dryRunOrchestrator *dryrun.ScenarioOrchestrator
+			if dryRun {
+				dryRunOrchestrator = dryrun.NewScenarioOrchestrator(config, orchestrator.GetContainerRuntime())
+				orchestrator = dryRunOrchestrator
+			}
+			quiet := dryRun && output == dryRunOutputJSON
+			events, err := openEventWriter(output, eventsFile)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := events.Close(); err != nil && runErr == nil {
+					runErr = err
+				}
+			}()
+			// the events on stdout move the text output to stderr, the spinner included
+			out := events.Output()
+
+			if !quiet {
+				orchestrator.PrintContainerRuntime(out)
+				if registrySettings != nil {
+					logPrivateRegistry(out, registrySettings.RegistryURL)
+				}
 			}
 			spinner := NewSpinnerWithSuffix("running randomly generated chaos plan...")
+			spinner.Writer = out
+			if quiet {
+				spinner.Writer = os.Stderr
+			}
 			volumes := make(map[string]string)
 			environment := make(map[string]string)
 			kubeconfig, err := cmd.Flags().GetString("kubeconfig")
@@ -115,22 +168,34 @@ func NewRandomRunCommand(factory *providerfactory.ProviderFactory, scenarioOrche
 			if err != nil {
 				return err
 			}
-
-			randomGraphFile, err := cmd.Flags().GetString("graph-dump")
+			rollbackOnAbort, err := cmd.Flags().GetBool("rollback-on-abort")
 			if err != nil {
 				return err
 			}
-
-			if randomGraphFile == "" {
-				randomGraphFile = fmt.Sprintf(config.RandomGraphPath, time.Now().Unix())
+			planTimeout, err := cmd.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
 			}
 
-			kubeconfigPath, err := utils.PrepareKubeconfig(&kubeconfig, config)
+			randomGraphFile, err := cmd.Flags().GetString("graph-dump")
 			if err != nil {
 				return err
 			}
-			if kubeconfigPath == nil {
-				return fmt.Errorf("kubeconfig not found: %s", kubeconfig)
+
+			// the random graph is kept in the run directory as plan snapshot,
+			// it is dumped elsewhere only when explicitly requested
+			dumpRandomGraph := randomGraphFile != ""
+
+			// the dry run does not flatten the kubeconfig to avoid leaving temporary files behind
+			kubeconfigPath := dryRunKubeconfig(kubeconfig)
+			if !dryRun {
+				kubeconfigPath, err = utils.PrepareKubeconfig(&kubeconfig, config)
+				if err != nil {
+					return err
+				}
+				if kubeconfigPath == nil {
+					return fmt.Errorf("kubeconfig not found: %s", kubeconfig)
+				}
 			}
 			volumes[*kubeconfigPath] = config.KubeconfigPath
 
@@ -153,6 +218,15 @@ func NewRandomRunCommand(factory *providerfactory.ProviderFactory, scenarioOrche
 			if err != nil {
 				return err
 			}
+			if err = applyPlanTimeout(nodes, planTimeout); err != nil {
+				return err
+			}
+			// the random order does not follow the dependencies of the plan, so the
+			// when conditions on the parents are dropped and every scenario is run
+			for id, node := range nodes {
+				node.When = ""
+				nodes[id] = node
+			}
 			privateRegistry := false
 			if registrySettings != nil {
 				privateRegistry = true
@@ -163,9 +237,10 @@ func NewRandomRunCommand(factory *providerfactory.ProviderFactory, scenarioOrche
 				name *string
 				err  error
 			})
+			secrets := make(map[string]bool)
 			spinner.Start()
 			go func() {
-				validateGraphScenarioInput(dataProvider, nodes, nameChannel, registrySettings)
+				validateGraphScenarioInput(dataProvider, nodes, nameChannel, registrySettings, orchestrator.GetContainerRuntime(), secrets)
 			}()
 
 			for {
@@ -185,68 +260,85 @@ func NewRandomRunCommand(factory *providerfactory.ProviderFactory, scenarioOrche
 
 			executionPlan := randomgraph.NewRandomGraph(nodes, int64(maxParallel),
 				numberOfScenarios)
-			if err = DumpRandomGraph(nodes, executionPlan, randomGraphFile, config.LabelRootNode); err != nil {
-				return err
+			if dumpRandomGraph {
+				if err = DumpRandomGraph(nodes, executionPlan, randomGraphFile, config.LabelRootNode); err != nil {
+					return err
+				}
 			}
 			if len(executionPlan) == 0 {
-				_, err = color.New(color.FgYellow).Println("No scenario to execute; the random graph file appears to be empty (single-node graphs are not supported).")
+				_, err = color.New(color.FgYellow).Fprintln(out, "No scenario to execute; the random graph file appears to be empty (single-node graphs are not supported).")
 				if err != nil {
 					return err
 				}
 				return nil
 			}
 
-			table, err := NewGraphTable(executionPlan, config)
-			if err != nil {
-				return err
+			if !quiet {
+				table, err := NewGraphTable(executionPlan, config)
+				if err != nil {
+					return err
+				}
+				table.WithWriter(out).Print()
+				_, _ = fmt.Fprint(out, "\n\n")
+			}
+			runLabels := utils.NewContainerLabels(config, utils.GraphHash(file))
+			if events != nil {
+				events.runID = runLabels.RunID
+			}
+			plan := RebuildDependencyGraph(nodes, executionPlan, config.LabelRootNode)
+			// the logs, the report and the run metadata are stored in the run directory
+			var ws *workspace.Workspace
+			var record *history.Record
+			var scenarioErr error
+			runDir := ""
+			if !dryRun {
+				record = history.NewRecord(plan)
+				ws, err = newGraphRunWorkspace(outputDir, runCommandName(cmd), args[0], plan, runLabels, orchestrator.GetContainerRuntime(), kubeconfigPath, environment, volumes, secrets, record, config)
+				if err != nil {
+					return err
+				}
+				runDir = ws.Dir
+				record.Images = resolveRunImages(plan, dataProvider, registrySettings)
+				defer func() {
+					// the scenarios failed without exit-on-error do not fail krknctl but the run
+					if runErr != nil {
+						finishRunWorkspace(ws, record, runErr, config)
+					} else {
+						finishRunWorkspace(ws, record, scenarioErr, config)
+					}
+				}()
+				_, _ = fmt.Fprintf(out, "run ID: %s\nrun directory: %s\n\n", runLabels.RunID, ws.Dir)
 			}
-			table.Print()
-			fmt.Print("\n\n")
 			spinner.Suffix = "starting chaos scenarios..."
 			spinner.Start()
 
 			commChannel := make(chan *models.GraphCommChannel)
+			// Ctrl+C or a failure with exit-on-error abort the run: the running scenarios
+			// are killed, the remaining steps skipped and the partial report written
+			runCtx, abortRun, stopRun := newRunContext()
+			defer stopRun()
 
+			// the random plan has no dependency between the scenarios, its steps run one after the other
 			go func() {
-				(*scenarioOrchestrator).RunGraph(nodes, executionPlan, environment, volumes, false, commChannel, registrySettings, nil)
+				orchestrator.RunGraph(nodes, executionPlan, environment, volumes, out, pullPolicy, models.Schedule{MaxParallel: maxParallel, Layered: true}, commChannel, runCtx, registrySettings, nil, &runLabels, runDir)
 			}()
 
-			for {
-				c := <-commChannel
-				if c == nil {
-					break
-				} else {
-					if c.Err != nil {
-						spinner.Stop()
-						var statErr *utils.ExitError
-						if errors.As(c.Err, &statErr) {
-							if c.ScenarioID != nil && c.ScenarioLogFile != nil {
-								_, err = color.New(color.FgHiRed).Println(fmt.Sprintf("scenario %s at step %d with exit status %d, check log file %s aborting chaos run.",
-									*c.ScenarioID,
-									*c.Layer,
-									statErr.ExitStatus,
-									*c.ScenarioLogFile))
-								if err != nil {
-									return err
-								}
-							}
-							if exitOnerror {
-								_, err = color.New(color.FgHiRed).Println(fmt.Sprintf("aborting chaos run with exit status %d", statErr.ExitStatus))
-								if err != nil {
-									return err
-								}
-								os.Exit(statErr.ExitStatus)
-							}
-							spinner.Start()
-						}
-
-					}
-					spinner.Suffix = fmt.Sprintf("Running step %d scenario(s): %s", *c.Layer, strings.Join(executionPlan[*c.Layer], ", "))
-
+			scenarioErr, err = consumeGraphRun(commChannel, executionPlan, events, record, spinner, out, exitOnerror, runCtx, abortRun)
+			spinner.Stop()
+			if err != nil {
+				return err
+			}
+			if runCtx.Err() != nil {
+				cmd.SilenceUsage = true
+				if rollbackOnAbort && record != nil {
+					rollbackAbortedRun(runLabels.RunID, runLabels.GraphHash, record, dataProvider, registrySettings, scenarioOrchestrator, outputDir, out, config)
 				}
+				return &utils.AbortError{Cause: context.Cause(runCtx)}
+			}
 
+			if dryRunOrchestrator != nil {
+				return PrintDryRunPlan(dryRunOrchestrator.Plan(), secrets, registrySettings, output, config)
 			}
-			spinner.Stop()
 
 			return nil
 		},
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/provider"
+	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
+	"github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/provider/registryv2"
+	"github.com/krkn-chaos/krknctl/pkg/registryauth"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func NewRegistryCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "registry",
+		Short: "manage the private registry credentials",
+		Long: `Stores and removes the private registry credentials in the container auth files.
+krknctl falls back to the credentials stored by registry login, podman login or docker login
+when no --private-registry-username/password/token flag or KRKNCTL_PRIVATE_REGISTRY_* variable is set`,
+	}
+}
+
+func NewRegistryLoginCommand(factory *providerfactory.ProviderFactory, config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "login [registry]",
+		Short: "logs in to a private registry",
+		Long: `Verifies the credentials against the registry and stores them in the credential helper
+configured for it or in ${XDG_RUNTIME_DIR}/containers/auth.json (--authfile to change it).
+The registry defaults to --private-registry or KRKNCTL_PRIVATE_REGISTRY`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registryURL, err := registryArg(cmd, args, config)
+			if err != nil {
+				return err
+			}
+			username, _ := cmd.Flags().GetString("username")
+			password, _ := cmd.Flags().GetString("password")
+			passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+			authFile, _ := cmd.Flags().GetString("authfile")
+			insecure, _ := cmd.Flags().GetBool("private-registry-insecure")
+			skipTLS, _ := cmd.Flags().GetBool("private-registry-skip-tls")
+
+			interactive := term.IsTerminal(int(os.Stdin.Fd())) // #nosec G115 -- file descriptors fit in int
+			stdin := bufio.NewReader(os.Stdin)
+			if username == "" {
+				if !interactive || passwordStdin {
+					return errors.New("--username must be set when stdin is not a terminal")
+				}
+				fmt.Print("Username: ")
+				line, err := stdin.ReadString('\n')
+				if err != nil && !errors.Is(err, io.EOF) {
+					return err
+				}
+				username = strings.TrimSpace(line)
+			}
+			switch {
+			case passwordStdin:
+				data, err := io.ReadAll(stdin)
+				if err != nil {
+					return err
+				}
+				password = strings.TrimRight(string(data), "\r\n")
+			case password == "" && interactive:
+				fmt.Print("Password: ")
+				data, err := term.ReadPassword(int(os.Stdin.Fd())) // #nosec G115 -- file descriptors fit in int
+				fmt.Println()
+				if err != nil {
+					return err
+				}
+				password = string(data)
+			}
+			if username == "" || password == "" {
+				return errors.New("username and password cannot be empty")
+			}
+
+			registryProvider, ok := factory.NewInstance(provider.Private).(*registryv2.ScenarioProvider)
+			if !ok {
+				return errors.New("private registry provider not available")
+			}
+			registrySettings := models.RegistryV2{
+				Username:    &username,
+				Password:    &password,
+				RegistryURL: registryURL,
+				SkipTLS:     skipTLS,
+				Insecure:    insecure,
+			}
+			spinner := NewSpinnerWithSuffix(fmt.Sprintf("logging in to %s...", registryURL))
+			spinner.Start()
+			err = registryProvider.CheckCredentials(&registrySettings)
+			spinner.Stop()
+			if err != nil {
+				return fmt.Errorf("login to %s failed: %w", registryURL, err)
+			}
+
+			location, err := registryauth.Login(registryURL, username, password, authFile)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("login succeeded, credentials stored in %s\n", location)
+			return nil
+		},
+	}
+	return command
+}
+
+func NewRegistryLogoutCommand(config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "logout [registry]",
+		Short: "logs out from a private registry",
+		Long: `Removes the registry credentials from the container auth files and credential helpers.
+The registry defaults to --private-registry or KRKNCTL_PRIVATE_REGISTRY`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registryURL, err := registryArg(cmd, args, config)
+			if err != nil {
+				return err
+			}
+			authFile, _ := cmd.Flags().GetString("authfile")
+			if err := registryauth.Logout(registryURL, authFile); err != nil {
+				return err
+			}
+			fmt.Printf("removed the credentials of %s\n", registryURL)
+			return nil
+		},
+	}
+	return command
+}
+
+// registryArg returns the registry passed as argument, falling back
+// to the --private-registry flag and the KRKNCTL_PRIVATE_REGISTRY variable
+func registryArg(cmd *cobra.Command, args []string, config config.Config) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	if registryURL, _ := cmd.Flags().GetString("private-registry"); registryURL != "" {
+		return registryURL, nil
+	}
+	if registryURL := os.Getenv(config.EnvPrivateRegistry); registryURL != "" {
+		return registryURL, nil
+	}
+	return "", fmt.Errorf("registry not set, pass it as argument, with --private-registry or %s", config.EnvPrivateRegistry)
+}
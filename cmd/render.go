@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/krkn-chaos/krknctl/pkg/graphrender"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+)
+
+// runOutcomes returns the outcome of every node recorded with the run, as shown by history show
+func runOutcomes(record history.Record) map[string]graphrender.Outcome {
+	outcomes := make(map[string]graphrender.Outcome, len(record.Nodes))
+	for id, node := range record.Nodes {
+		switch {
+		case node.SkipReason != "":
+			outcomes[id] = graphrender.OutcomeSkipped
+		case node.Aborted:
+			outcomes[id] = graphrender.OutcomeKilled
+		case node.ExitStatus != nil && *node.ExitStatus == 0:
+			outcomes[id] = graphrender.OutcomeSucceeded
+		case node.ExitStatus != nil || node.Error != "":
+			outcomes[id] = graphrender.OutcomeFailed
+		case node.Attempts == 0:
+			outcomes[id] = graphrender.OutcomeNotRun
+		default:
+			outcomes[id] = graphrender.OutcomeUnknown
+		}
+	}
+	return outcomes
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/krkn-chaos/krknctl/pkg/graphrender"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunOutcomes(t *testing.T) {
+	scenario := orchestratormodels.Scenario{Name: "dummy-scenario"}
+	record := history.NewRecord(map[string]orchestratormodels.ScenarioNode{
+		"succeeded": {Scenario: scenario},
+		"failed":    {Scenario: scenario},
+		"killed":    {Scenario: scenario},
+		"skipped":   {Scenario: scenario},
+		"not-run":   {Scenario: scenario},
+		"detached":  {Scenario: scenario},
+	})
+	succeeded := 0
+	// the nodes started without failures are set as succeeded when the run is completed
+	record.Nodes["succeeded"] = history.NodeResult{Attempts: 1, ExitStatus: &succeeded}
+	record.NodeStarted("failed", "", "failed.log", 1)
+	record.NodeFailed("failed", &utils.ExitError{ExitStatus: 1})
+	record.NodeStarted("killed", "", "killed.log", 1)
+	record.NodeFailed("killed", &utils.AbortError{Cause: errors.New("interrupted")})
+	record.NodeSkipped("skipped", "runs on failure but none of root failed")
+	record.NodeStarted("detached", "", "detached.log", 1)
+
+	assert.Equal(t, map[string]graphrender.Outcome{
+		"succeeded": graphrender.OutcomeSucceeded,
+		"failed":    graphrender.OutcomeFailed,
+		"killed":    graphrender.OutcomeKilled,
+		"skipped":   graphrender.OutcomeSkipped,
+		"not-run":   graphrender.OutcomeNotRun,
+		"detached":  graphrender.OutcomeUnknown,
+	}, runOutcomes(*record))
+}
@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/dependencygraph"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
+	"github.com/krkn-chaos/krknctl/pkg/provider"
+	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/spf13/cobra"
+)
+
+// rollbackValue is the value of the rollback environment variable that starts a scenario in rollback mode
+const rollbackValue = "True"
+
+// rollbackNode is a node of a recorded run relaunched in rollback mode
+type rollbackNode struct {
+	ID     string
+	Result history.NodeResult
+	Image  string
+	// Node is the plan node, only the name of the scenario is set for single scenario runs
+	Node models.ScenarioNode
+}
+
+func NewRollbackCommand(factory *providerfactory.ProviderFactory, scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator, config config.Config) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "rollback <container|run-id>",
+		Short: "rolls back the changes made by the scenarios of a run",
+		Long: `relaunches the scenarios of a run, or the scenario of a container, in rollback mode with the image,
+the environment and the volumes recorded in the history. Only the scenarios that advertise rollback
+support are rolled back. The secrets are not recorded and are read from the environment variables with
+the same name (e.g. ES_PASSWORD=... krknctl rollback <run-id>)`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registrySettings, err := providermodels.NewRegistryV2FromEnv(config)
+			if err != nil {
+				return err
+			}
+			if registrySettings == nil {
+				registrySettings, err = parsePrivateRepoArgs(cmd, nil)
+				if err != nil {
+					return err
+				}
+			}
+			kubeconfig, err := cmd.Flags().GetString("kubeconfig")
+			if err != nil {
+				return err
+			}
+			outputDir, err := cmd.Flags().GetString("output-dir")
+			if err != nil {
+				return err
+			}
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
+			if registrySettings != nil {
+				logPrivateRegistry(os.Stdout, registrySettings.RegistryURL)
+			}
+			store, err := historyStore(config)
+			if err != nil {
+				return err
+			}
+			record, nodeIDs, err := resolveRollbackTarget(args[0], store, scenarioOrchestrator)
+			if err != nil {
+				return err
+			}
+			nodes, err := rollbackNodes(*record, nodeIDs)
+			if err != nil {
+				return err
+			}
+			dataProvider := GetProvider(registrySettings != nil, factory)
+			return runRollback(record.RunID, record.GraphHash, nodes, dataProvider, registrySettings, scenarioOrchestrator, kubeconfig, outputDir, os.Stdout, config)
+		},
+	}
+	return command
+}
+
+// resolveRollbackTarget returns the recorded run of the target and the nodes to roll back, all
+// the nodes if the target is a run ID, the node of the container otherwise. The containers are
+// looked up by the name of their log file and, while they exist, by their labels.
+func resolveRollbackTarget(target string, store *history.Store, scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator) (*history.Record, []string, error) {
+	record, err := store.Get(target)
+	if err == nil {
+		return record, nil, nil
+	}
+	if !errors.Is(err, history.ErrNotFound) {
+		return nil, nil, err
+	}
+	records, err := store.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		for nodeID, node := range records[i].Nodes {
+			if node.LogFile != "" && logContainerName(node.LogFile) == target {
+				return &records[i], []string{nodeID}, nil
+			}
+		}
+	}
+
+	socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := (*scenarioOrchestrator).Connect(*socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	containers, err := (*scenarioOrchestrator).ListRunningContainers(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, container := range *containers {
+		if container.Labels == nil || (container.Name != target && !strings.HasPrefix(container.ID, target)) {
+			continue
+		}
+		record, err := store.Get(container.Labels.RunID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("run %s of container %s: %w", container.Labels.RunID, target, err)
+		}
+		// single scenario runs are recorded by scenario name
+		nodeID := container.Labels.NodeID
+		if nodeID == "" {
+			nodeID = container.Labels.Scenario
+		}
+		return record, []string{nodeID}, nil
+	}
+	return nil, nil, fmt.Errorf("no run or container %s found", target)
+}
+
+// rollbackNodes returns the nodes of the run that have been started, restricted to nodeIDs if set.
+// The nodes are returned in reverse topological order so children are rolled back before their parents.
+func rollbackNodes(record history.Record, nodeIDs []string) ([]rollbackNode, error) {
+	selected := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if _, ok := record.Nodes[id]; !ok {
+			return nil, fmt.Errorf("node %s not found in run %s", id, record.RunID)
+		}
+		selected[id] = true
+	}
+	order, err := rollbackOrder(record)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []rollbackNode
+	for _, id := range order {
+		result := record.Nodes[id]
+		if (len(selected) > 0 && !selected[id]) || result.Attempts == 0 {
+			continue
+		}
+		node, ok := record.PlanSnapshot[id]
+		if !ok {
+			node = models.ScenarioNode{Scenario: models.Scenario{Name: result.Scenario}}
+		}
+		image := record.Images[id].Pinned
+		if image == "" {
+			image = record.Images[id].Image
+		}
+		if image == "" {
+			image = node.Image
+		}
+		if image == "" {
+			return nil, fmt.Errorf("the image of node %s has not been recorded in run %s", id, record.RunID)
+		}
+		nodes = append(nodes, rollbackNode{ID: id, Result: result, Image: image, Node: node})
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no scenario has been started by run %s", record.RunID)
+	}
+	return nodes, nil
+}
+
+// rollbackOrder returns the recorded nodes with the last layer of the plan first. The nodes without
+// dependencies, which are not part of any layer, and the nodes of single scenario runs come last.
+func rollbackOrder(record history.Record) ([]string, error) {
+	var order []string
+	ordered := make(map[string]bool, len(record.Nodes))
+	if record.Replayable() {
+		convertedNodes := make(map[string]dependencygraph.ParentProvider, len(record.PlanSnapshot))
+		for key, node := range record.PlanSnapshot {
+			convertedNodes[key] = node
+		}
+		graph, err := dependencygraph.NewGraphFromNodes(convertedNodes)
+		if err != nil {
+			return nil, fmt.Errorf("plan of run %s: %w", record.RunID, err)
+		}
+		layers := graph.TopoSortedLayers()
+		for i := len(layers) - 1; i >= 0; i-- {
+			layer := layers[i]
+			sort.Strings(layer)
+			for _, id := range layer {
+				if _, ok := record.Nodes[id]; ok {
+					order = append(order, id)
+					ordered[id] = true
+				}
+			}
+		}
+	}
+	for _, id := range sortedKeys(record.Nodes) {
+		if !ordered[id] {
+			order = append(order, id)
+		}
+	}
+	return order, nil
+}
+
+// rollbackEnvironment returns the environment and the volumes the node is rolled back with, the
+// kubeconfig mount is replaced by kubeconfigPath if set
+func rollbackEnvironment(node rollbackNode, kubeconfigPath *string, config config.Config) (map[string]string, map[string]string, error) {
+	env, err := node.Result.Environment(os.LookupEnv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("node %s: %w", node.ID, err)
+	}
+	env[config.EnvRollback] = rollbackValue
+	volumes := make(map[string]string, len(node.Result.Volumes))
+	for hostPath, containerPath := range node.Result.Volumes {
+		if kubeconfigPath != nil && containerPath == config.KubeconfigPath {
+			hostPath = *kubeconfigPath
+		}
+		volumes[hostPath] = containerPath
+	}
+	return env, volumes, nil
+}
+
+// recordedKubeconfigMissing tells if the kubeconfig of a node has been deleted, e.g. by krknctl clean
+func recordedKubeconfigMissing(nodes []rollbackNode, config config.Config) bool {
+	for _, node := range nodes {
+		for hostPath, containerPath := range node.Result.Volumes {
+			if containerPath == config.KubeconfigPath && !CheckFileExists(hostPath) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runRollback relaunches the nodes of the run in rollback mode one after the other, the nodes whose
+// scenario does not support rollback are skipped. The rollback is a run of its own with its run
+// directory and its history record.
+func runRollback(runID string,
+	graphHash string,
+	nodes []rollbackNode,
+	dataProvider provider.ScenarioDataProvider,
+	registrySettings *providermodels.RegistryV2,
+	scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator,
+	kubeconfig string,
+	outputDir string,
+	out io.Writer,
+	config config.Config) (runErr error) {
+	var supported []rollbackNode
+	for _, node := range nodes {
+		scenarioDetail, err := dataProvider.GetScenarioDetail(node.Node.Name, registrySettings)
+		if err != nil {
+			return err
+		}
+		if scenarioDetail == nil || !scenarioDetail.HasRollback {
+			_, err = color.New(color.FgYellow).Fprintf(out, "scenario %s of node %s does not support rollback, skipped\n", node.Node.Name, node.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		supported = append(supported, node)
+	}
+	if len(supported) == 0 {
+		_, err := color.New(color.FgYellow).Fprintf(out, "no scenario of run %s supports rollback\n", runID)
+		return err
+	}
+
+	// the kubeconfig is flattened again if it is set or if the recorded copy has been cleaned
+	var kubeconfigPath *string
+	if kubeconfig != "" || recordedKubeconfigMissing(supported, config) {
+		var err error
+		kubeconfigPath, err = utils.PrepareKubeconfig(&kubeconfig, config)
+		if err != nil {
+			return err
+		}
+		if kubeconfigPath == nil {
+			return fmt.Errorf("kubeconfig not found: %s", kubeconfig)
+		}
+	}
+
+	runLabels := utils.NewContainerLabels(config, graphHash)
+	scenarios := make([]string, 0, len(supported))
+	for _, node := range supported {
+		scenarios = append(scenarios, node.Node.Name)
+	}
+	ws, err := newRunWorkspace(outputDir, runMetadata(fmt.Sprintf("rollback %s", runID), "", runLabels, scenarios, (*scenarioOrchestrator).GetContainerRuntime()), kubeconfigPath, make(map[string]string), config)
+	if err != nil {
+		return err
+	}
+	record := history.NewRecord(nil)
+	record.Images = make(map[string]lockfile.LockedImage)
+	defer func() {
+		finishRunWorkspace(ws, record, runErr, config)
+	}()
+	_, _ = fmt.Fprintf(out, "rolling back run %s\nrun ID: %s\nrun directory: %s\n\n", runID, runLabels.RunID, ws.Dir)
+
+	socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+	if err != nil {
+		return err
+	}
+	conn, err := (*scenarioOrchestrator).Connect(*socket)
+	if err != nil {
+		return err
+	}
+	var rollbackErrs []error
+	for _, node := range supported {
+		env, volumes, err := rollbackEnvironment(node, kubeconfigPath, config)
+		if err != nil {
+			return err
+		}
+		record.SetEnvironment(node.ID, env, volumes, secretKeys(node.Result.Secrets))
+		record.Images[node.ID] = lockfile.LockedImage{Scenario: node.Node.Name, Image: node.Image}
+		rollbackName := node.ID + "-rollback"
+		containerName := utils.GenerateContainerName(config, node.Node.Name, &rollbackName)
+		createOpts := scenarioorchestrator.NodeCreateOptions(node.Node, node.ID, &runLabels)
+		createOpts.PullPolicy = models.PullIfNotPresent
+		logFile, err := os.Create(ws.LogPath(containerName))
+		if err != nil {
+			return err
+		}
+		record.NodeStarted(node.ID, node.Node.Name, ws.LogPath(containerName), 1)
+		_, err = color.New(color.FgGreen, color.Underline).Fprintf(out, "rolling back node %s (%s)\n", node.ID, node.Node.Name)
+		if err != nil {
+			_ = logFile.Close()
+			return err
+		}
+		mw := io.MultiWriter(out, logFile)
+		_, err = (*scenarioOrchestrator).RunAttached(node.Image, containerName, env, false, volumes, mw, mw, nil, conn, registrySettings, nil, createOpts, nil)
+		_ = logFile.Close()
+		if err != nil {
+			record.NodeFailed(node.ID, err)
+			_, _ = color.New(color.FgHiRed).Fprintf(out, "rollback of node %s failed: %v, check log file %s\n", node.ID, err, ws.LogPath(containerName))
+			rollbackErrs = append(rollbackErrs, fmt.Errorf("rollback of node %s: %w", node.ID, err))
+		}
+	}
+	return errors.Join(rollbackErrs...)
+}
+
+func secretKeys(secrets []string) map[string]bool {
+	keys := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		keys[secret] = true
+	}
+	return keys
+}
+
+// rollbackAbortedRun rolls back the nodes started by a graph run before it has been aborted,
+// the failures are reported without changing the outcome of the aborted run
+func rollbackAbortedRun(runID string,
+	graphHash string,
+	record *history.Record,
+	dataProvider provider.ScenarioDataProvider,
+	registrySettings *providermodels.RegistryV2,
+	scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator,
+	outputDir string,
+	out io.Writer,
+	config config.Config) {
+	aborted := *record
+	aborted.RunID = runID
+	nodes, err := rollbackNodes(aborted, nil)
+	if err == nil {
+		// the kubeconfig of the aborted run is still in its run directory
+		err = runRollback(runID, graphHash, nodes, dataProvider, registrySettings, scenarioOrchestrator, "", outputDir, out, config)
+	}
+	if err != nil {
+		_, _ = color.New(color.FgHiRed).Fprintf(out, "rollback of run %s failed: %v\n", runID, err)
+	}
+}
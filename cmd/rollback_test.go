@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func rollbackRecord() history.Record {
+	record := history.NewRecord(map[string]orchestratormodels.ScenarioNode{
+		"pods":   {Scenario: orchestratormodels.Scenario{Name: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}},
+		"cpu":    {Scenario: orchestratormodels.Scenario{Name: "node-cpu-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"}},
+		"memory": {Scenario: orchestratormodels.Scenario{Name: "node-memory-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-memory-hog"}},
+	})
+	record.RunID = "a1b2c3"
+	record.Images = map[string]lockfile.LockedImage{
+		"pods": {Scenario: "pod-scenarios", Pinned: "quay.io/krkn-chaos/krkn-hub@sha256:4f53cd"},
+	}
+	record.NodeStarted("pods", "", "/runs/a1b2c3/krknctl-pods-1.log", 1)
+	record.NodeStarted("cpu", "", "/runs/a1b2c3/krknctl-cpu-1.log", 2)
+	return *record
+}
+
+func TestRollbackNodes(t *testing.T) {
+	record := rollbackRecord()
+	nodes, err := rollbackNodes(record, nil)
+	assert.Nil(t, err)
+	// the nodes never started are not rolled back
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, "cpu", nodes[0].ID)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub:node-cpu-hog", nodes[0].Image)
+	assert.Equal(t, "pods", nodes[1].ID)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub@sha256:4f53cd", nodes[1].Image)
+	assert.Equal(t, "pod-scenarios", nodes[1].Node.Name)
+
+	nodes, err = rollbackNodes(record, []string{"pods"})
+	assert.Nil(t, err)
+	assert.Len(t, nodes, 1)
+	_, err = rollbackNodes(record, []string{"network"})
+	assert.NotNil(t, err)
+	_, err = rollbackNodes(record, []string{"memory"})
+	assert.NotNil(t, err)
+
+	// the children are rolled back before their parents whatever their name
+	chain := history.NewRecord(map[string]orchestratormodels.ScenarioNode{
+		"a-child":  {Scenario: orchestratormodels.Scenario{Name: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}, Parents: orchestratormodels.Dependencies{"m-parent"}},
+		"m-parent": {Scenario: orchestratormodels.Scenario{Name: "node-cpu-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"}, Parents: orchestratormodels.Dependencies{"z-root"}},
+		"z-root":   {Scenario: orchestratormodels.Scenario{Name: "node-memory-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-memory-hog"}},
+		"b-alone":  {Scenario: orchestratormodels.Scenario{Name: "node-io-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-io-hog"}},
+	})
+	for i, id := range []string{"z-root", "m-parent", "a-child", "b-alone"} {
+		chain.NodeStarted(id, "", "", i+1)
+	}
+	nodes, err = rollbackNodes(*chain, nil)
+	assert.Nil(t, err)
+	var order []string
+	for _, node := range nodes {
+		order = append(order, node.ID)
+	}
+	assert.Equal(t, []string{"a-child", "m-parent", "z-root", "b-alone"}, order)
+
+	// single scenario runs have no plan
+	single := history.NewRecord(nil)
+	single.Images = map[string]lockfile.LockedImage{"pod-scenarios": {Scenario: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}}
+	single.NodeStarted("pod-scenarios", "pod-scenarios", "", 1)
+	nodes, err = rollbackNodes(*single, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "pod-scenarios", nodes[0].Node.Name)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub:pod-scenarios", nodes[0].Image)
+}
+
+func TestRollbackEnvironment(t *testing.T) {
+	config := getConfig(t)
+	kubeconfig := filepath.Join(t.TempDir(), "krknctl-kubeconfig-1")
+	assert.Nil(t, os.WriteFile(kubeconfig, []byte("kubeconfig"), 0600))
+	record := rollbackRecord()
+	record.SetEnvironment("pods",
+		map[string]string{"NAMESPACE": "default", "KRKN_ROLLBACK_TEST_PASSWORD": "supersecret"},
+		map[string]string{kubeconfig: config.KubeconfigPath, "/tmp/metrics.yaml": config.MetricsProfilePath},
+		map[string]bool{"KRKN_ROLLBACK_TEST_PASSWORD": true})
+	nodes, err := rollbackNodes(record, []string{"pods"})
+	assert.Nil(t, err)
+	assert.False(t, recordedKubeconfigMissing(nodes, config))
+
+	_, _, err = rollbackEnvironment(nodes[0], nil, config)
+	assert.NotNil(t, err)
+	t.Setenv("KRKN_ROLLBACK_TEST_PASSWORD", "supersecret")
+	env, volumes, err := rollbackEnvironment(nodes[0], nil, config)
+	assert.Nil(t, err)
+	assert.Equal(t, "default", env["NAMESPACE"])
+	assert.Equal(t, "supersecret", env["KRKN_ROLLBACK_TEST_PASSWORD"])
+	assert.Equal(t, rollbackValue, env[config.EnvRollback])
+	assert.Equal(t, config.KubeconfigPath, volumes[kubeconfig])
+
+	// the kubeconfig cleaned after the run is replaced
+	assert.Nil(t, os.Remove(kubeconfig))
+	assert.True(t, recordedKubeconfigMissing(nodes, config))
+	flattened := "/tmp/krknctl-kubeconfig-2"
+	_, volumes, err = rollbackEnvironment(nodes[0], &flattened, config)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{flattened: config.KubeconfigPath, "/tmp/metrics.yaml": config.MetricsProfilePath}, volumes)
+}
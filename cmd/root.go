@@ -3,13 +3,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/graphrender"
 	"github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	orchestratorutils "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +28,12 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	rootCmd.PersistentFlags().String("private-registry-token", "", "private registry identity token for token based authentication")
 	rootCmd.PersistentFlags().String("private-registry-scenarios", "", "private registry krkn scenarios image repository")
 	rootCmd.PersistentFlags().String("private-registry-assist", "", "private registry assist image repository")
+	rootCmd.PersistentFlags().String("runtime", "auto", "runtime used to run the scenarios: auto, podman, docker or kubernetes (runs scenarios as Jobs in the cluster)")
+	rootCmd.PersistentFlags().String("runtime-namespace", config.KubernetesNamespace, "namespace where the scenario Jobs are created when --runtime kubernetes is set")
+	rootCmd.PersistentFlags().String("runtime-kubeconfig", "", "kubeconfig of the cluster hosting the scenario Jobs when --runtime kubernetes is set (defaults to --kubeconfig, then to ~/.kube/config)")
+	rootCmd.PersistentFlags().String("container-host", "", "remote container engine (ssh://user@host/path/to/socket, tcp://host:port or a podman connection name), overrides CONTAINER_HOST and DOCKER_HOST")
+	rootCmd.PersistentFlags().String("container-cert-path", "", "folder containing ca.pem, cert.pem and key.pem used to connect to a tcp container host (default DOCKER_CERT_PATH)")
+	rootCmd.PersistentFlags().String("container-identity", "", "ssh private key used to connect to an ssh container host (default CONTAINER_SSHKEY)")
 	var completionCmd = &cobra.Command{
 		Use:       "completion [bash|zsh]",
 		Short:     "Genera script di completamento per bash o zsh",
@@ -53,6 +63,7 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	listCmd := NewListCommand()
 	listScenariosCmd := NewListScenariosCommand(providerFactory, config)
 	listRunningCmd := NewListRunningScenario(scenarioOrchestrator)
+	listRunningCmd.Flags().String("run", "", "lists only the scenarios of the run ID")
 	listCmd.AddCommand(listScenariosCmd)
 	listCmd.AddCommand(listRunningCmd)
 	rootCmd.AddCommand(listCmd)
@@ -66,23 +77,54 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	runCmd.LocalFlags().String("metrics-profile", "", "custom metrics profile file path")
 	runCmd.LocalFlags().Bool("detached", false, "if set this flag will run in detached mode")
 	runCmd.LocalFlags().Bool("form", false, "Use interactive form to collect scenario parameters instead of CLI flags")
+	runCmd.LocalFlags().Bool("dry-run", false, "resolves and prints the scenario container without starting it")
+	runCmd.LocalFlags().String("output", "table", "output format: table or json for the dry run, json-events streams the run events as JSON lines on stdout")
+	runCmd.LocalFlags().String("events-file", "", "writes the run events as JSON lines to the file")
+	runCmd.LocalFlags().String("container-cpus", "", "number of cpus the scenario container can use (e.g. 1.5)")
+	runCmd.LocalFlags().String("container-memory", "", "memory limit of the scenario container (e.g. 512m, 2g)")
+	runCmd.LocalFlags().Int64("container-pids-limit", 0, "maximum number of processes in the scenario container (not supported on Kubernetes)")
+	runCmd.LocalFlags().String("container-cap-add", "", "comma separated list of capabilities added to the scenario container")
+	runCmd.LocalFlags().String("container-cap-drop", "", "comma separated list of capabilities dropped from the scenario container")
+	runCmd.LocalFlags().Bool("container-read-only", false, "mounts the root filesystem of the scenario container as read only")
+	runCmd.LocalFlags().String("container-user", "", "user (name or uid[:gid]) the scenario container runs as, numeric only on Kubernetes")
+	runCmd.LocalFlags().Duration("scenario-timeout", 0, "kills the scenario if it does not complete within the duration (e.g. 30m)")
+	runCmd.LocalFlags().String("pull-policy", string(models.PullAlways), "when the scenario image is pulled: always, if-not-present or never")
+	runCmd.LocalFlags().String("output-dir", "", "folder where the run directory (logs, report and metadata) is created, defaults to ~/.krknctl/runs")
 	runCmd.DisableFlagParsing = true
 	rootCmd.AddCommand(runCmd)
 
 	cleanCmd := NewCleanCommand(scenarioOrchestrator, config)
+	cleanCmd.Flags().String("run", "", "cleans only the containers and the run directory of the run ID")
+	cleanCmd.Flags().String("output-dir", "", "folder of the run directories if the runs have been started with --output-dir")
+	cleanCmd.Flags().Bool("gzip-logs", false, "compresses the log files of the finished runs")
+	cleanCmd.Flags().Bool("dry-run", false, "prints what would be removed without removing anything")
+	cleanCmd.Flags().Duration("older-than", 0, "cleans only the containers, runs and files older than the duration (e.g. 72h)")
+	cleanCmd.Flags().Int("keep-last", 0, "keeps the containers and the run directories of the given number of most recent runs")
+	cleanCmd.Flags().Bool("failed-only", false, "cleans only the failed or aborted runs and the containers exited with a non zero status")
+	cleanCmd.Flags().Bool("containers-only", false, "removes only the containers")
+	cleanCmd.Flags().Bool("files-only", false, "removes only the files, the container runtime is not contacted")
+	cleanCmd.MarkFlagsMutuallyExclusive("containers-only", "files-only")
 	rootCmd.AddCommand(cleanCmd)
 
 	// graph subcommands
 	graphCmd := NewGraphCommand()
 	graphRunCmd := NewGraphRunCommand(providerFactory, scenarioOrchestrator, config)
-	graphRunCmd.Flags().String("kubeconfig", "", "kubeconfig path (if not set will default to ~/.kube/config)")
-	graphRunCmd.Flags().String("alerts-profile", "", "custom alerts profile file path")
-	graphRunCmd.Flags().String("metrics-profile", "", "custom metrics profile file path")
-	graphRunCmd.Flags().Bool("exit-on-error", false, "if set this flag will the workflow will be interrupted and the tool will exit with a status greater than 0")
+	addGraphRunFlags(graphRunCmd)
+	graphLockCmd := NewGraphLockCommand(providerFactory, config)
 	graphScaffoldCmd := NewGraphScaffoldCommand(providerFactory, config)
 	graphScaffoldCmd.Flags().Bool("global-env", false, "if set this flag will add global environment variables to each scenario in the graph")
 	graphCmd.AddCommand(graphRunCmd)
 	graphCmd.AddCommand(graphScaffoldCmd)
+	graphCmd.AddCommand(graphLockCmd)
+	graphValidateCmd := NewGraphValidateCommand(providerFactory, scenarioOrchestrator, config)
+	graphValidateCmd.Flags().String("output", "table", "output format: table or json")
+	graphValidateCmd.Flags().Bool("offline", false, "skips the checks that need the scenario metadata from the registry")
+	graphCmd.AddCommand(graphValidateCmd)
+	graphRenderCmd := NewGraphRenderCommand(config)
+	graphRenderCmd.Flags().String("format", string(graphrender.FormatASCII), "diagram format: dot, mermaid, ascii or svg")
+	graphRenderCmd.Flags().String("run", "", "colors the scenarios by their outcome in the run (a unique prefix of the run ID is enough)")
+	graphRenderCmd.Flags().StringSlice("label-env", []string{"NAMESPACE", "DURATION"}, "environment variables shown in the label of the scenarios")
+	graphCmd.AddCommand(graphRenderCmd)
 	rootCmd.AddCommand(graphCmd)
 
 	// random subcommand
@@ -94,7 +136,14 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	randomRunCmd.Flags().Int("max-parallel", 0, "maximum number of parallel scenarios")
 	randomRunCmd.Flags().Int("number-of-scenarios", 0, "allows you to specify the number of elements to select from the execution plan")
 	randomRunCmd.Flags().Bool("exit-on-error", false, "if set this flag will the workflow will be interrupted and the tool will exit with a status greater than 0")
+	randomRunCmd.Flags().Bool("rollback-on-abort", false, "rolls back the scenarios that support it and had started when the run is aborted")
 	randomRunCmd.Flags().String("graph-dump", "", "specifies the name of the file where the randomly generated dependency graph will be persisted")
+	randomRunCmd.Flags().Bool("dry-run", false, "resolves and prints the execution plan without starting any scenario")
+	randomRunCmd.Flags().String("output", "table", "output format: table or json for the dry run, json-events streams the run events as JSON lines on stdout")
+	randomRunCmd.Flags().String("events-file", "", "writes the run events as JSON lines to the file")
+	randomRunCmd.Flags().Duration("timeout", 0, "default timeout (e.g. 30m) of the scenarios that don't set their own, once expired the scenario is killed")
+	randomRunCmd.Flags().String("pull-policy", string(models.PullAlways), "when the images of the plan are pulled before the first step: always, if-not-present or never")
+	randomRunCmd.Flags().String("output-dir", "", "folder where the run directory (logs, report, plan snapshot and metadata) is created, defaults to ~/.krknctl/runs")
 	err := randomRunCmd.MarkFlagRequired("max-parallel")
 	if err != nil {
 		fmt.Println("Error marking flag as required:", err)
@@ -111,8 +160,32 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	rootCmd.AddCommand(randomCmd)
 
 	attachCmd := NewAttachCmd(scenarioOrchestrator)
+	attachCmd.Flags().Bool("all", false, "attaches all the running scenarios")
+	attachCmd.Flags().String("run", "", "attaches all the running scenarios of the run ID")
+	attachCmd.Flags().String("filter", "", "prints only the lines matching the regular expression")
+	attachCmd.MarkFlagsMutuallyExclusive("all", "run")
 	rootCmd.AddCommand(attachCmd)
 
+	stopCmd := NewStopCommand(scenarioOrchestrator)
+	stopCmd.Flags().String("run", "", "stops all the running scenarios of the run ID")
+	stopCmd.Flags().Bool("all", false, "stops all the running scenarios")
+	stopCmd.Flags().BoolP("yes", "y", false, "stops the scenarios without asking for confirmation")
+	stopCmd.MarkFlagsMutuallyExclusive("run", "all")
+	rootCmd.AddCommand(stopCmd)
+
+	rollbackCmd := NewRollbackCommand(providerFactory, scenarioOrchestrator, config)
+	rollbackCmd.Flags().String("kubeconfig", "", "kubeconfig path, defaults to the kubeconfig of the run or to ~/.kube/config if it has been cleaned")
+	rollbackCmd.Flags().String("output-dir", "", "folder where the run directory of the rollback is created, defaults to ~/.krknctl/runs")
+	rootCmd.AddCommand(rollbackCmd)
+
+	logsCmd := NewLogsCommand(scenarioOrchestrator, config)
+	logsCmd.Flags().BoolP("follow", "f", false, "streams the logs until the scenarios exit")
+	logsCmd.Flags().String("since", "", "prints only the logs written after a timestamp (RFC3339) or within a duration (e.g. 10m)")
+	logsCmd.Flags().Int("tail", -1, "prints only the given number of lines from the end of the logs, all the lines if negative")
+	logsCmd.Flags().BoolP("timestamps", "t", false, "prefixes every line with the time it has been written")
+	logsCmd.Flags().String("output-dir", "", "folder of the run directories if the runs have been started with --output-dir")
+	rootCmd.AddCommand(logsCmd)
+
 	visualizeCmd := NewVisualizeCommand(scenarioOrchestrator, config)
 	rootCmd.AddCommand(visualizeCmd)
 
@@ -152,6 +225,52 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	operatorCmd.AddCommand(operatorUninstallCmd)
 	rootCmd.AddCommand(operatorCmd)
 
+	// history subcommands
+	historyCmd := NewHistoryCommand()
+	historyListCmd := NewHistoryListCommand(config)
+	historyListCmd.Flags().String("output", "table", "output format: table or json")
+	historyListCmd.Flags().Int("limit", 0, "lists only the given number of most recent runs")
+	historyShowCmd := NewHistoryShowCommand(config)
+	historyShowCmd.Flags().String("output", "table", "output format: table or json")
+	historyReplayCmd := NewHistoryReplayCommand(providerFactory, scenarioOrchestrator, config)
+	addGraphRunFlags(historyReplayCmd)
+	// the replayed plan is already pinned to the recorded digests
+	if err := historyReplayCmd.Flags().MarkHidden("locked"); err != nil {
+		fmt.Println("Error hiding flag:", err)
+		os.Exit(1)
+	}
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyReplayCmd)
+	rootCmd.AddCommand(historyCmd)
+
+	// registry subcommands
+	registryCmd := NewRegistryCommand()
+	registryLoginCmd := NewRegistryLoginCommand(providerFactory, config)
+	registryLoginCmd.Flags().StringP("username", "u", "", "registry username, prompted if not set")
+	registryLoginCmd.Flags().StringP("password", "p", "", "registry password, prompted if not set")
+	registryLoginCmd.Flags().Bool("password-stdin", false, "reads the password from stdin")
+	registryLoginCmd.Flags().String("authfile", "", "auth file where the credentials are stored (default ${XDG_RUNTIME_DIR}/containers/auth.json)")
+	registryLoginCmd.MarkFlagsMutuallyExclusive("password", "password-stdin")
+	registryLogoutCmd := NewRegistryLogoutCommand(config)
+	registryLogoutCmd.Flags().String("authfile", "", "auth file the credentials are removed from (default all the auth files)")
+	registryCmd.AddCommand(registryLoginCmd)
+	registryCmd.AddCommand(registryLogoutCmd)
+	rootCmd.AddCommand(registryCmd)
+
+	// the scenario orchestrator is built once cobra has parsed the flags of the command
+	previewCommands := []*cobra.Command{runCmd, graphRunCmd, randomRunCmd, historyReplayCmd}
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// a runtime that can't be reached is not a usage error
+		cmd.SilenceUsage = true
+		orchestrator, err := newScenarioOrchestrator(cmd, args, previewCommands, config)
+		if err != nil {
+			return err
+		}
+		*scenarioOrchestrator = orchestrator
+		return nil
+	}
+
 	// update and deprecation check
 	isDeprecated, err := IsDeprecated(config)
 	if err != nil {
@@ -178,6 +297,29 @@ func Execute(providerFactory *factory.ProviderFactory, scenarioOrchestrator *sce
 	}
 
 	if err := rootCmd.Execute(); err != nil {
+		// aborted chaos runs exit with the status of the failure that aborted them
+		var abortErr *orchestratorutils.AbortError
+		if errors.As(err, &abortErr) {
+			os.Exit(abortErr.ExitStatus())
+		}
 		os.Exit(1)
 	}
 }
+
+// addGraphRunFlags registers the graph run flags, shared with history replay
+func addGraphRunFlags(command *cobra.Command) {
+	command.Flags().String("kubeconfig", "", "kubeconfig path (if not set will default to ~/.kube/config)")
+	command.Flags().String("alerts-profile", "", "custom alerts profile file path")
+	command.Flags().String("metrics-profile", "", "custom metrics profile file path")
+	command.Flags().Bool("exit-on-error", false, "if set this flag will the workflow will be interrupted and the tool will exit with a status greater than 0")
+	command.Flags().Bool("rollback-on-abort", false, "rolls back the scenarios that support it and had started when the run is aborted")
+	command.Flags().Bool("dry-run", false, "resolves and prints the execution plan without starting any scenario")
+	command.Flags().String("output", "table", "output format: table or json for the dry run, json-events streams the run events as JSON lines on stdout")
+	command.Flags().String("events-file", "", "writes the run events as JSON lines to the file")
+	command.Flags().Duration("timeout", 0, "default timeout (e.g. 30m) of the scenarios that don't set their own, once expired the scenario is killed")
+	command.Flags().String("pull-policy", string(models.PullAlways), "when the images of the plan are pulled before the first step: always, if-not-present or never")
+	command.Flags().String("output-dir", "", "folder where the run directory (logs, report, plan snapshot and metadata) is created, defaults to ~/.krknctl/runs")
+	command.Flags().Int("max-parallel", 0, "maximum number of scenarios running at the same time, unlimited if not set")
+	command.Flags().Bool("layered", false, "runs the plan step by step, a step starts only once all the scenarios of the previous one are finished")
+	command.Flags().Bool("locked", false, "runs the scenario images pinned by graph lock, refuses to start if a tag moved since the plan was locked")
+}
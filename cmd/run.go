@@ -14,13 +14,17 @@ import (
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/forms"
+	"github.com/krkn-chaos/krknctl/pkg/history"
 	"github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	"github.com/krkn-chaos/krknctl/pkg/provider/models"
 	"github.com/krkn-chaos/krknctl/pkg/resiliency"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/dryrun"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	"github.com/krkn-chaos/krknctl/pkg/typing"
 	commonutils "github.com/krkn-chaos/krknctl/pkg/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -128,25 +132,58 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 					return err
 				}
 			}
-			if registrySettings != nil {
-				logPrivateRegistry(registrySettings.RegistryURL)
+			if err != nil {
+				return err
 			}
 
+			scenarioName, err := parseScenarioName(args)
 			if err != nil {
 				return err
 			}
 
-			scenarioName, err := parseScenarioName(args)
+			runDetached := false
+			dryRun := false
+			output := dryRunOutputTable
+			if value, found, err := ParseArgValue(args, "--output"); err != nil {
+				return err
+			} else if found {
+				output = value
+			}
+			dryRun, err = commandBoolFlag(cmd, args, "dry-run")
 			if err != nil {
 				return err
 			}
+			if err := validateRunOutput(output, dryRun); err != nil {
+				return err
+			}
+			eventsFile, _, err := ParseArgValue(args, "--events-file")
+			if err != nil {
+				return err
+			}
+			quiet := dryRun && output == dryRunOutputJSON
+			events, err := openEventWriter(output, eventsFile)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = events.Close()
+			}()
+			// the events on stdout move the text output to stderr, the spinner included
+			out := events.Output()
 
-			(*scenarioOrchestrator).PrintContainerRuntime()
+			if !quiet {
+				(*scenarioOrchestrator).PrintContainerRuntime(out)
+				if registrySettings != nil {
+					logPrivateRegistry(out, registrySettings.RegistryURL)
+				}
+			}
 			spinner := NewSpinnerWithSuffix("fetching scenario metadata...")
+			spinner.Writer = out
+			if quiet {
+				spinner.Writer = os.Stderr
+			}
 			spinner.Start()
 
-			runDetached := false
-
 			provider := GetProvider(registrySettings != nil, factory)
 			scenarioDetail, err := provider.GetScenarioDetail(scenarioName, registrySettings)
 			if err != nil {
@@ -159,6 +196,20 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 				return err
 			}
 
+			// the flag is not named timeout because scenarios may have a timeout parameter
+			var timeout *time.Duration
+			if value, found, err := ParseArgValue(args, "--scenario-timeout"); err != nil {
+				spinner.Stop()
+				return err
+			} else if found {
+				parsedTimeout, err := time.ParseDuration(value)
+				if err != nil || parsedTimeout <= 0 {
+					spinner.Stop()
+					return fmt.Errorf("invalid scenario timeout %s: must be a duration greater than zero (e.g. 30m)", value)
+				}
+				timeout = &parsedTimeout
+			}
+
 			environment := make(map[string]string)
 			parsedFields := make(map[string]ParsedField)
 			volumes := make(map[string]string)
@@ -196,6 +247,12 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 				foundAlertsProfile = expandedProfile
 			}
 
+			outputDir := ""
+			if value, found, err := ParseArgValue(args, "--output-dir"); err != nil {
+				return err
+			} else if found {
+				outputDir = value
+			}
 			if value, found, err := ParseArgValue(args, "--metrics-profile"); err != nil {
 				spinner.Stop()
 				return err
@@ -231,15 +288,40 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 				}
 			}
 
-			kubeconfigPath, err := utils.PrepareKubeconfig(foundKubeconfig, config)
+			if runDetached && timeout != nil {
+				spinner.Stop()
+				return fmt.Errorf("--scenario-timeout cannot be used with --detached")
+			}
+
+			// options not supported by the runtime fail before anything is started
+			createOpts, err := parseContainerOptions(args, (*scenarioOrchestrator).GetContainerRuntime())
 			if err != nil {
 				spinner.Stop()
 				return err
 			}
-			if kubeconfigPath == nil {
-				spinner.Stop()
-				return errors.New("kubeconfig not found on default path, please specify a " +
-					"valid kubeconfig path with the --kubeconfig flag")
+			runLabels := utils.NewContainerLabels(config, "")
+			runLabels.Scenario = scenarioDetail.Name
+			createOpts.Labels = &runLabels
+
+			var kubeconfigPath *string
+			if dryRun {
+				// the dry run does not flatten the kubeconfig to avoid leaving temporary files behind
+				if foundKubeconfig != nil {
+					kubeconfigPath = dryRunKubeconfig(*foundKubeconfig)
+				} else {
+					kubeconfigPath = dryRunKubeconfig("")
+				}
+			} else {
+				kubeconfigPath, err = utils.PrepareKubeconfig(foundKubeconfig, config)
+				if err != nil {
+					spinner.Stop()
+					return err
+				}
+				if kubeconfigPath == nil {
+					spinner.Stop()
+					return errors.New("kubeconfig not found on default path, please specify a " +
+						"valid kubeconfig path with the --kubeconfig flag")
+				}
 			}
 			volumes[*kubeconfigPath] = config.KubeconfigPath
 			if foundMetricsProfile != nil {
@@ -282,7 +364,7 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 				}
 
 				// Show form summary
-				formResult.PrintSummary(allFields)
+				formResult.PrintSummary(out, allFields)
 
 				// Convert form results to environment variables
 				formEnv := formResult.GetEnvironmentVariables()
@@ -357,35 +439,102 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 
 			spinner.Stop()
 
+			if dryRun {
+				quayImageURI, err := config.GetCustomDomainImageURI()
+				if err != nil {
+					return err
+				}
+				dryRunOrchestrator := dryrun.NewScenarioOrchestrator(config, (*scenarioOrchestrator).GetContainerRuntime())
+				containerName := utils.GenerateContainerName(config, scenarioDetail.Name, nil)
+				_, err = dryRunOrchestrator.Run(quayImageURI+":"+scenarioDetail.Name, containerName, environment, false, volumes, nil, nil, registrySettings, nil, createOpts)
+				if err != nil {
+					return err
+				}
+				secrets := make(map[string]bool)
+				for k, v := range parsedFields {
+					secrets[k] = v.secret
+				}
+				return PrintDryRunPlan(dryRunOrchestrator.Plan(), secrets, registrySettings, output, config)
+			}
+
+			ws, err := newRunWorkspace(outputDir, runMetadata(runCommandName(cmd), "", runLabels, []string{scenarioDetail.Name}, (*scenarioOrchestrator).GetContainerRuntime()), kubeconfigPath, volumes, config)
+			if err != nil {
+				return err
+			}
+			record := history.NewRecord(nil)
+			secrets := make(map[string]bool)
+			for k, v := range parsedFields {
+				if v.secret {
+					secrets[k] = true
+				}
+			}
+			if err := ws.WriteEnv(map[string]map[string]string{scenarioDetail.Name: environment}, secrets); err != nil {
+				return fmt.Errorf("failed to write the run environment: %w", err)
+			}
+			record.SetEnvironment(scenarioDetail.Name, environment, volumes, secrets)
+
 			tbl := NewEnvironmentTable(parsedFields, config)
-			tbl.Print()
-			fmt.Print("\n")
+			tbl.WithWriter(out).Print()
+			_, _ = fmt.Fprintf(out, "\nrun ID: %s\nrun directory: %s\n\n", runLabels.RunID, ws.Dir)
+			runStarted := time.Now()
+			if events != nil {
+				events.runID = runLabels.RunID
+				startedEvent := orchestratormodels.NewEvent(orchestratormodels.EventRunStarted, runLabels.RunID)
+				startedEvent.Nodes = []string{scenarioDetail.Name}
+				events.Emit(startedEvent)
+			}
 			// restarts the spinner to present image pull progress
 			spinner.Suffix = "pulling scenario image..."
 			spinner.Start()
 
 			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
 			if err != nil {
+				events.RunFinished(runStarted, "", err)
+				finishRunWorkspace(ws, record, err, config)
 				return err
 			}
 			conn, err := (*scenarioOrchestrator).Connect(*socket)
 			if err != nil {
+				events.RunFinished(runStarted, "", err)
+				finishRunWorkspace(ws, record, err, config)
 				return err
 			}
 			startTime := time.Now()
 			containerName := utils.GenerateContainerName(config, scenarioDetail.Name, nil)
 			quayImageURI, err := config.GetCustomDomainImageURI()
 			if err != nil {
+				events.RunFinished(runStarted, "", err)
+				finishRunWorkspace(ws, record, err, config)
 				return err
 			}
+			runScenario := orchestratormodels.Scenario{Name: scenarioDetail.Name, Image: quayImageURI + ":" + scenarioDetail.Name}
+			record.Images = resolveRunImages(map[string]orchestratormodels.ScenarioNode{
+				scenarioDetail.Name: {Scenario: runScenario},
+			}, provider, registrySettings)
 
 			if !runDetached {
 				
 				// Here we are using an io.MultiWriter to multiplex the container's stdout and stderr to both
 				// the terminal stdout and a bytes.Buffer. This allows us to capture the logs for parsing later.
 				// The container stdout and stderr will be written to both the terminal stdout and the bytes.Buffer.
+				// the logs are also written in the run directory
+				logFile, err := os.Create(ws.LogPath(containerName))
+				if err != nil {
+					events.RunFinished(runStarted, "", err)
+					finishRunWorkspace(ws, record, err, config)
+					return err
+				}
 				var logBuf bytes.Buffer
-				mw := io.MultiWriter(os.Stdout, &logBuf)
+				mw := io.MultiWriter(out, &logBuf, logFile)
+				if events != nil {
+					pullingEvent := orchestratormodels.NewEvent(orchestratormodels.EventImagePulling, runLabels.RunID)
+					pullingEvent.Image = runScenario.Image
+					events.Emit(pullingEvent)
+					// the container is reported once the orchestrator has started it, after the image pull
+					conn = utils.ContextWithStarted(conn, func(string) {
+						events.Emit(scenarioorchestrator.NewContainerEvent(orchestratormodels.EventContainerStarted, runLabels.RunID, nil, "", runScenario, containerName, 1, ws.LogPath(containerName)))
+					})
+				}
 
 				commChan := make(chan *string)
 				go func() {
@@ -395,41 +544,84 @@ func NewRunCommand(factory *factory.ProviderFactory, scenarioOrchestrator *scena
 					spinner.Stop()
 				}()
 
-				_, err = (*scenarioOrchestrator).RunAttached(quayImageURI+":"+scenarioDetail.Name, containerName, environment, false, volumes, mw, mw, &commChan, conn, registrySettings, nil, nil)
+				_, err = (*scenarioOrchestrator).RunAttached(quayImageURI+":"+scenarioDetail.Name, containerName, environment, false, volumes, mw, mw, &commChan, conn, registrySettings, nil, createOpts, timeout)
+				_ = logFile.Close()
+				if events != nil {
+					exitedEvent := scenarioorchestrator.NewContainerEvent(orchestratormodels.EventContainerExited, runLabels.RunID, nil, "", runScenario, containerName, 1, ws.LogPath(containerName))
+					scenarioorchestrator.SetContainerExit(exitedEvent, err)
+					exitedEvent.SetDuration(startTime)
+					events.Emit(exitedEvent)
+				}
+				reportPath := ""
 				
 				// Parse resiliency report from captured logs and generate report
 				fmt.Fprintf(os.Stderr, "DEBUG: Attempting to parse resiliency report from %d bytes of logs\n", len(logBuf.Bytes()))
 				if rep, perr := resiliency.ParseResiliencyReport(logBuf.Bytes()); perr == nil {
 					fmt.Fprintf(os.Stderr, "DEBUG: Successfully parsed resiliency report\n")
+					if events != nil {
+						reportEvent := scenarioorchestrator.NewContainerEvent(orchestratormodels.EventReportParsed, runLabels.RunID, nil, "", runScenario, containerName, 1, ws.LogPath(containerName))
+						reportEvent.ResiliencyScore = &rep.OverallReport.ResiliencyScore
+						events.Emit(reportEvent)
+					}
 					if reportErr := resiliency.GenerateAndWriteReport(
 						[]resiliency.DetailedScenarioReport{*rep},
-						"resiliency-report.json",
+						ws.ReportPath(),
 					); reportErr != nil {
 						log.Printf("Error generating resiliency report: %v", reportErr)
 					} else {
-						fmt.Println("Detailed resiliency report written to resiliency-report.json")
+						_, _ = fmt.Fprintf(out, "Detailed resiliency report written to %s\n", ws.ReportPath())
+						reportPath = ws.ReportPath()
 					}
 				} else {
 					fmt.Fprintf(os.Stderr, "Failed to parse resiliency report: %v\n", perr)
 				}
 
+				record.NodeStarted(scenarioDetail.Name, scenarioDetail.Name, ws.LogPath(containerName), 1)
+				if err != nil {
+					record.NodeFailed(scenarioDetail.Name, err)
+				}
+				// os.Exit skips the deferred calls, the outcome is recorded before
+				finishRunWorkspace(ws, record, err, config)
+				events.RunFinished(runStarted, reportPath, err)
+				_ = events.Close()
 				if err != nil {
 					var staterr *utils.ExitError
 					if errors.As(err, &staterr) {
 						os.Exit(staterr.ExitStatus)
 					}
+					var timeoutErr *utils.TimeoutError
+					if errors.As(err, &timeoutErr) {
+						_, _ = color.New(color.FgHiRed).Fprintln(out, fmt.Sprintf("%s timed out after %s and has been killed", scenarioDetail.Name, timeoutErr.Timeout))
+						os.Exit(utils.TimeoutExitStatus)
+					}
 					return err
 				}
 
 				scenarioDuration := time.Since(startTime)
-				fmt.Printf("%s ran for %s\n", scenarioDetail.Name, scenarioDuration.String())
+				_, _ = fmt.Fprintf(out, "%s ran for %s\n", scenarioDetail.Name, scenarioDuration.String())
 			} else {
-				containerID, err := (*scenarioOrchestrator).Run(quayImageURI+":"+scenarioDetail.Name, containerName, environment, false, volumes, nil, conn, registrySettings, nil, nil)
+				containerID, err := (*scenarioOrchestrator).Run(quayImageURI+":"+scenarioDetail.Name, containerName, environment, false, volumes, nil, conn, registrySettings, nil, createOpts)
 				if err != nil {
+					events.RunFinished(runStarted, "", err)
+					finishRunWorkspace(ws, record, err, config)
 					return err
 				}
+				// krknctl does not follow the detached scenario, the outcome is left to the container
+				if err := ws.Finish(workspace.StatusDetached, 0, nil); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to update the run metadata in %s: %v\n", ws.Dir, err)
+				}
+				record.NodeStarted(scenarioDetail.Name, scenarioDetail.Name, "", 1)
+				recordRun(ws, record, config)
+				if events != nil {
+					// krknctl does not follow the detached scenario, the run ends with its start
+					events.Emit(scenarioorchestrator.NewContainerEvent(orchestratormodels.EventContainerStarted, runLabels.RunID, nil, "", runScenario, *containerID, 1, ""))
+					finishedEvent := orchestratormodels.NewEvent(orchestratormodels.EventRunFinished, runLabels.RunID)
+					finishedEvent.Status = string(workspace.StatusDetached)
+					finishedEvent.SetDuration(runStarted)
+					events.Emit(finishedEvent)
+				}
 				spinner.Stop()
-				_, err = color.New(color.FgGreen, color.Underline).Println(fmt.Sprintf("scenario %s started with containerID %s", scenarioDetail.Name, *containerID))
+				_, err = color.New(color.FgGreen, color.Underline).Fprintln(out, fmt.Sprintf("scenario %s started with containerID %s, run ID %s", scenarioDetail.Name, *containerID, runLabels.RunID))
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// stopExitTimeout is how long stop waits for a killed scenario to report its exit status
+const stopExitTimeout = 10 * time.Second
+
+func NewStopCommand(scenarioOrchestrator *scenarioorchestrator.ScenarioOrchestrator) *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "stop [index|container]",
+		Short: "kills running scenarios",
+		Long: `Kills running scenarios, the scenario is selected by the index printed by list running
+(the same used by attach), by its container name or ID, by the run it belongs to (--run) or all the
+running scenarios are killed (--all). The exit status of every killed scenario is reported`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			ctx, err := (*scenarioOrchestrator).Connect(*socket)
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			scenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx, nil)
+			if err != nil || scenarios == nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			var results []string
+			for i, scenario := range *scenarios {
+				start := time.Unix(scenario.Container.Started, 0)
+				description := fmt.Sprintf("%s started %s ago", scenario.Container.Name, time.Since(start).Round(time.Second))
+				for _, completion := range []string{strconv.Itoa(i), scenario.Container.Name} {
+					if strings.HasPrefix(completion, toComplete) {
+						results = append(results, fmt.Sprintf("%s\t%s", completion, description))
+					}
+				}
+			}
+			return results, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runID, err := cmd.Flags().GetString("run")
+			if err != nil {
+				return err
+			}
+			all, err := cmd.Flags().GetBool("all")
+			if err != nil {
+				return err
+			}
+			yes, err := cmd.Flags().GetBool("yes")
+			if err != nil {
+				return err
+			}
+			target := ""
+			if len(args) > 0 {
+				target = args[0]
+			}
+			if (target != "" && (all || runID != "")) || (all && runID != "") {
+				return errors.New("only one of index, container, --run and --all can be set")
+			}
+			if target == "" && !all && runID == "" {
+				return errors.New("neither scenario index, container, --run nor --all specified")
+			}
+
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
+			socket, err := (*scenarioOrchestrator).GetContainerRuntimeSocket(nil)
+			if err != nil {
+				return err
+			}
+			ctx, err := (*scenarioOrchestrator).Connect(*socket)
+			if err != nil {
+				return err
+			}
+			runningScenarios, err := (*scenarioOrchestrator).ListRunningScenarios(ctx, runFilter(runID))
+			if err != nil {
+				return err
+			}
+			var running []orchestratorModels.ScenarioContainer
+			if runningScenarios != nil {
+				running = *runningScenarios
+			}
+			targets, err := resolveRunningScenarios(target, running)
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				if runID != "" {
+					fmt.Printf("no running scenarios in run %s\n", runID)
+				} else {
+					fmt.Println("no running scenarios")
+				}
+				return nil
+			}
+
+			if !yes {
+				if !term.IsTerminal(int(os.Stdin.Fd())) { // #nosec G115 -- file descriptors fit in int
+					return errors.New("stdin is not a terminal, set --yes to stop the scenarios without confirmation")
+				}
+				for _, scenario := range targets {
+					fmt.Printf("%s (%s)\n", scenario.Container.Name, shortContainerID(scenario.Container.ID))
+				}
+				prompt := promptui.Prompt{
+					Label:     fmt.Sprintf("Stop %d scenario(s)", len(targets)),
+					IsConfirm: true,
+					Default:   "n",
+				}
+				if _, err := prompt.Run(); err != nil {
+					fmt.Println("no scenario stopped")
+					return nil
+				}
+			}
+
+			var stopErrors []error
+			for _, scenario := range targets {
+				exitStatus, err := stopScenario(*scenarioOrchestrator, *scenario.Container, stopExitTimeout, ctx)
+				if err != nil {
+					stopErrors = append(stopErrors, fmt.Errorf("failed to stop %s: %w", scenario.Container.Name, err))
+					continue
+				}
+				if exitStatus == nil {
+					_, err = color.New(color.FgYellow).Printf("%s stopped, the container has been removed and its exit status is unknown\n", scenario.Container.Name)
+				} else {
+					_, err = color.New(color.FgGreen).Printf("%s stopped, exit status %d\n", scenario.Container.Name, *exitStatus)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return errors.Join(stopErrors...)
+		},
+	}
+	return command
+}
+
+// resolveRunningScenarios selects scenarios among the running ones, an empty target
+// selects all of them, otherwise the target is an index as printed by list running
+// or the name, the ID or a prefix of the ID of the container
+func resolveRunningScenarios(target string, running []orchestratorModels.ScenarioContainer) ([]orchestratorModels.ScenarioContainer, error) {
+	if target == "" {
+		return running, nil
+	}
+	index, indexErr := strconv.Atoi(target)
+	if indexErr == nil && index >= 0 && index < len(running) {
+		return running[index : index+1], nil
+	}
+	var matches []orchestratorModels.ScenarioContainer
+	for _, scenario := range running {
+		if scenario.Container.Name == target || scenario.Container.ID == target {
+			return []orchestratorModels.ScenarioContainer{scenario}, nil
+		}
+		if strings.HasPrefix(scenario.Container.ID, target) {
+			matches = append(matches, scenario)
+		}
+	}
+	if len(matches) == 0 && indexErr == nil {
+		return nil, fmt.Errorf("invalid scenario index: %d, scenario index out of range", index)
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no running scenario with index, name or ID %s", target)
+	case 1:
+		return matches, nil
+	default:
+		return nil, fmt.Errorf("container ID prefix %s is ambiguous, it matches %d scenarios", target, len(matches))
+	}
+}
+
+// stopScenario kills the container and waits up to timeout for its exit status, nil if the
+// container has been removed by the runtime (e.g. the Kubernetes Job is deleted). A container
+// still running once the timeout expires is reported as an error.
+func stopScenario(orchestrator scenarioorchestrator.ScenarioOrchestrator, container orchestratorModels.Container, timeout time.Duration, ctx context.Context) (*int, error) {
+	if err := orchestrator.Kill(&container.ID, ctx); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		scenario, err := orchestrator.InspectScenario(orchestratorModels.Container{ID: container.ID}, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if scenario == nil || scenario.Container == nil {
+			return nil, nil
+		}
+		if scenario.Container.Status != "running" {
+			return &scenario.Container.ExitStatus, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the container is still running %s after being killed", timeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveRunningScenarios(t *testing.T) {
+	running := []orchestratorModels.ScenarioContainer{
+		{Container: &orchestratorModels.Container{ID: "4f53cda18c2b", Name: "krknctl-pod-scenarios-1"}},
+		{Container: &orchestratorModels.Container{ID: "4f1a2b3c4d5e", Name: "krknctl-node-cpu-hog-2"}},
+		{Container: &orchestratorModels.Container{ID: "1234abcd5678", Name: "krknctl-node-memory-hog-3"}},
+	}
+
+	targets, err := resolveRunningScenarios("", running)
+	assert.Nil(t, err)
+	assert.Len(t, targets, 3)
+
+	targets, err = resolveRunningScenarios("1", running)
+	assert.Nil(t, err)
+	assert.Equal(t, "krknctl-node-cpu-hog-2", targets[0].Container.Name)
+
+	targets, err = resolveRunningScenarios("krknctl-pod-scenarios-1", running)
+	assert.Nil(t, err)
+	assert.Equal(t, "4f53cda18c2b", targets[0].Container.ID)
+
+	targets, err = resolveRunningScenarios("4f5", running)
+	assert.Nil(t, err)
+	assert.Len(t, targets, 1)
+	assert.Equal(t, "krknctl-pod-scenarios-1", targets[0].Container.Name)
+
+	// a numeric ID prefix out of the index range
+	targets, err = resolveRunningScenarios("1234", running)
+	assert.Nil(t, err)
+	assert.Equal(t, "krknctl-node-memory-hog-3", targets[0].Container.Name)
+
+	_, err = resolveRunningScenarios("4f", running)
+	assert.NotNil(t, err)
+	_, err = resolveRunningScenarios("7", running)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "out of range")
+	_, err = resolveRunningScenarios("missing", running)
+	assert.NotNil(t, err)
+}
+
+// stoppingOrchestrator reports the container with status once it has been killed
+type stoppingOrchestrator struct {
+	scenarioorchestrator.ScenarioOrchestrator
+	status string
+}
+
+func (o *stoppingOrchestrator) Kill(containerID *string, ctx context.Context) error {
+	return nil
+}
+
+func (o *stoppingOrchestrator) InspectScenario(container orchestratorModels.Container, ctx context.Context) (*orchestratorModels.ScenarioContainer, error) {
+	return &orchestratorModels.ScenarioContainer{Container: &orchestratorModels.Container{ID: container.ID, Status: o.status, ExitStatus: 137}}, nil
+}
+
+func TestStopScenario(t *testing.T) {
+	container := orchestratorModels.Container{ID: "4f53cda18c2b", Name: "krknctl-pod-scenarios-1"}
+	exitStatus, err := stopScenario(&stoppingOrchestrator{status: "exited"}, container, time.Second, context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 137, *exitStatus)
+
+	// a container surviving the kill is not reported as stopped
+	exitStatus, err = stopScenario(&stoppingOrchestrator{status: "running"}, container, time.Millisecond, context.Background())
+	assert.NotNil(t, err)
+	assert.Nil(t, exitStatus)
+	assert.Contains(t, err.Error(), "still running")
+}
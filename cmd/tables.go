@@ -8,6 +8,8 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
 	"github.com/krkn-chaos/krknctl/pkg/provider/models"
 	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
@@ -18,20 +20,28 @@ import "github.com/rodaine/table"
 var headerFmt = color.New(color.FgGreen, color.Underline).SprintfFunc()
 var columnFmt = color.New(color.FgYellow).SprintfFunc()
 
-func NewScenarioTable(scenarios *[]models.ScenarioTag, private bool) table.Table {
+// NewScenarioTable lists the scenarios, the rollback support of the scenarios missing from rollback is unknown
+func NewScenarioTable(scenarios *[]models.ScenarioTag, private bool, rollback map[string]bool) table.Table {
 	var tbl table.Table
 	if private {
-		tbl = table.New("Name")
+		tbl = table.New("Name", "Rollback")
 	} else {
-		tbl = table.New("Name", "Size", "Digest", "Last Modified")
+		tbl = table.New("Name", "Size", "Digest", "Last Modified", "Rollback")
 	}
 
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 	for _, scenario := range *scenarios {
+		hasRollback := "unknown"
+		if supported, ok := rollback[scenario.Name]; ok {
+			hasRollback = "no"
+			if supported {
+				hasRollback = "yes"
+			}
+		}
 		if private {
-			tbl.AddRow(scenario.Name)
+			tbl.AddRow(scenario.Name, hasRollback)
 		} else {
-			tbl.AddRow(scenario.Name, *scenario.Size, *scenario.Digest, *scenario.LastModified)
+			tbl.AddRow(scenario.Name, *scenario.Size, *scenario.Digest, *scenario.LastModified, hasRollback)
 		}
 
 	}
@@ -181,11 +191,132 @@ func commonPrefix(a, b string) string {
 }
 
 func NewRunningScenariosTable(runningScenarios []orchestratormodels.ScenarioContainer) table.Table {
-	tbl := table.New("Scenario ID", "Scenario Name", "Running Since", "Container Name")
+	tbl := table.New("Scenario ID", "Scenario Name", "Running Since", "Container Name", "Run ID")
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 	for i, v := range runningScenarios {
 		t := time.Unix(v.Container.Started, 0)
-		tbl.AddRow(i, v.Scenario.Name, time.Since(t), v.Container.Name)
+		runID := ""
+		if v.Container.Labels != nil {
+			runID = v.Container.Labels.RunID
+		}
+		tbl.AddRow(i, v.Scenario.Name, time.Since(t), v.Container.Name, runID)
+	}
+	return tbl
+}
+
+func NewLockfileTable(lock lockfile.Lockfile) table.Table {
+	tbl := table.New("Scenario ID", "Scenario Name", "Digest")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	ids := make([]string, 0, len(lock.Nodes))
+	for id := range lock.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		tbl.AddRow(id, lock.Nodes[id].Scenario, lock.Nodes[id].Digest)
+	}
+	return tbl
+}
+
+// NewPlanProblemsTable lists the problems found by graph validate, the position is line:column in the plan
+func NewPlanProblemsTable(problems []planProblem) table.Table {
+	tbl := table.New("Scenario ID", "Position", "Problem")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, problem := range problems {
+		position := ""
+		if problem.Line > 0 {
+			position = fmt.Sprintf("%d:%d", problem.Line, problem.Column)
+		}
+		tbl.AddRow(problem.NodeID, position, problem.Message)
+	}
+	return tbl
+}
+
+func NewHistoryTable(records []history.Record) table.Table {
+	tbl := table.New("Run ID", "Command", "Status", "Exit Status", "Started", "Duration", "Scenarios")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, r := range records {
+		exitStatus := ""
+		duration := ""
+		if r.Finished != nil {
+			exitStatus = fmt.Sprintf("%d", r.ExitStatus)
+			duration = r.Finished.Sub(r.Started).Round(time.Second).String()
+		}
+		tbl.AddRow(r.RunID, r.Command, r.Status, exitStatus, r.Started.Local().Format(time.DateTime), duration, strings.Join(r.Scenarios, ", "))
+	}
+	return tbl
+}
+
+func NewHistoryNodesTable(record history.Record) table.Table {
+	tbl := table.New("Scenario ID", "Scenario Name", "Attempts", "Exit Status", "Digest", "Log File")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	ids := make([]string, 0, len(record.Nodes))
+	for id := range record.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := record.Nodes[id]
+		var exitStatus string
+		switch {
+		case node.Aborted:
+			exitStatus = "killed"
+		case node.ExitStatus != nil && node.TimedOut:
+			exitStatus = fmt.Sprintf("%d (timed out)", *node.ExitStatus)
+		case node.ExitStatus != nil:
+			exitStatus = fmt.Sprintf("%d", *node.ExitStatus)
+		case node.SkipReason != "":
+			exitStatus = fmt.Sprintf("skipped (%s)", node.SkipReason)
+		case node.Attempts == 0:
+			exitStatus = "not run"
+		}
+		tbl.AddRow(id, node.Scenario, node.Attempts, exitStatus, record.Images[id].Digest, node.LogFile)
+	}
+	return tbl
+}
+
+func NewDryRunTable(plan []orchestratormodels.PlannedScenario) table.Table {
+	tbl := table.New("Step", "Scenario ID", "Container Name", "Image")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, v := range plan {
+		tbl.AddRow(v.Layer, v.ScenarioID, v.ContainerName, v.Image)
+	}
+	return tbl
+}
+
+func NewPlannedEnvironmentTable(env map[string]string, config config.Config) table.Table {
+	tbl := table.New("Environment Value", "Value")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tbl.AddRow(k, reduceString(env[k], config))
+	}
+	return tbl
+}
+
+func NewVolumesTable(volumes map[string]string) table.Table {
+	tbl := table.New("Volume", "Mount Path")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	keys := make([]string, 0, len(volumes))
+	for k := range volumes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tbl.AddRow(k, volumes[k])
+	}
+	return tbl
+}
+
+func NewCleanTable(items []cleanItem) table.Table {
+	tbl := table.New("Type", "Name", "Run ID", "Started", "Status")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, item := range items {
+		tbl.AddRow(item.Kind, item.Name, item.RunID, item.Started.Local().Format(time.DateTime), item.Status)
 	}
 	return tbl
 }
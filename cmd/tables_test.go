@@ -3,9 +3,15 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewEnvironmentTable(t *testing.T) {
@@ -95,3 +101,72 @@ func TestNewGraphTable(t *testing.T) {
 	assert.Contains(t, stringBuffer, "(5) a")
 
 }
+
+func TestNewDryRunTable(t *testing.T) {
+	config := getConfig(t)
+	plan := []orchestratormodels.PlannedScenario{
+		{Layer: 0, ScenarioID: "root", ContainerName: "krknctl-root-1", Image: "quay.io/krkn-chaos/krkn-hub:dummy-scenario",
+			Env: map[string]string{"END": "10"}, Volumes: map[string]string{"/tmp/kubeconfig": config.KubeconfigPath}},
+	}
+	var buf bytes.Buffer
+	table := NewDryRunTable(plan).WithWriter(&buf)
+	table.Print()
+	assert.Contains(t, buf.String(), "krknctl-root-1")
+	assert.Contains(t, buf.String(), "quay.io/krkn-chaos/krkn-hub:dummy-scenario")
+
+	buf.Reset()
+	table = NewPlannedEnvironmentTable(plan[0].Env, config).WithWriter(&buf)
+	table.Print()
+	assert.Contains(t, buf.String(), "END")
+
+	buf.Reset()
+	table = NewVolumesTable(plan[0].Volumes).WithWriter(&buf)
+	table.Print()
+	assert.Contains(t, buf.String(), config.KubeconfigPath)
+
+	assert.Nil(t, validateDryRunOutput("table"))
+	assert.Nil(t, validateDryRunOutput("json"))
+	assert.NotNil(t, validateDryRunOutput("yaml"))
+}
+
+func TestNewHistoryTable(t *testing.T) {
+	record := history.NewRecord(map[string]orchestratormodels.ScenarioNode{
+		"pods": {Scenario: orchestratormodels.Scenario{Name: "pod-scenarios"}},
+		"cpu":  {Scenario: orchestratormodels.Scenario{Name: "node-cpu-hog"}},
+	})
+	record.RunID = "a1b2c3"
+	record.Command = "graph run"
+	record.Scenarios = []string{"node-cpu-hog", "pod-scenarios"}
+	record.NodeStarted("pods", "", "pods.log", 1)
+	record.NodeFailed("pods", &utils.ExitError{ExitStatus: 2})
+
+	var buf bytes.Buffer
+	NewHistoryTable([]history.Record{*record}).WithWriter(&buf).Print()
+	assert.Contains(t, buf.String(), "a1b2c3")
+	assert.Contains(t, buf.String(), "graph run")
+	assert.Contains(t, buf.String(), "node-cpu-hog, pod-scenarios")
+
+	buf.Reset()
+	NewHistoryNodesTable(*record).WithWriter(&buf).Print()
+	assert.Contains(t, buf.String(), "pods.log")
+	assert.Contains(t, buf.String(), "not run")
+}
+
+func TestNewScenarioTable(t *testing.T) {
+	size := int64(1024)
+	digest := "sha256:4f53cd"
+	lastModified := time.Now()
+	scenarios := []providermodels.ScenarioTag{
+		{Name: "pod-scenarios", Size: &size, Digest: &digest, LastModified: &lastModified},
+		{Name: "node-cpu-hog", Size: &size, Digest: &digest, LastModified: &lastModified},
+		{Name: "node-memory-hog", Size: &size, Digest: &digest, LastModified: &lastModified},
+	}
+	var buf bytes.Buffer
+	NewScenarioTable(&scenarios, true, map[string]bool{"pod-scenarios": true, "node-cpu-hog": false}).WithWriter(&buf).Print()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "Rollback")
+	assert.Regexp(t, `pod-scenarios\s+yes`, lines[1])
+	assert.Regexp(t, `node-cpu-hog\s+no`, lines[2])
+	assert.Regexp(t, `node-memory-hog\s+unknown`, lines[3])
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,20 +9,41 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/briandowns/spinner"
+	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/history"
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
 	"github.com/krkn-chaos/krknctl/pkg/provider"
 	"github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	"github.com/krkn-chaos/krknctl/pkg/provider/models"
 	"github.com/krkn-chaos/krknctl/pkg/resiliency"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/dryrun"
+	scenarioorchestratorfactory "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/factory"
 	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	"github.com/krkn-chaos/krknctl/pkg/typing"
+	commonutils "github.com/krkn-chaos/krknctl/pkg/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	dryRunOutputTable = "table"
+	dryRunOutputJSON  = "json"
 )
 
 // 🤖 Assisted with Claude Code (claude.ai/code)
@@ -256,13 +278,19 @@ func parsePrivateRepoArgs(cmd *cobra.Command, args *[]string) (*models.RegistryV
 		}
 
 	}
+	if registrySettings != nil {
+		// falls back to the credentials stored by krknctl registry login, podman login or docker login
+		if err := registrySettings.LoadStoredCredentials(); err != nil {
+			return nil, err
+		}
+	}
 	return registrySettings, nil
 
 }
 
-func logPrivateRegistry(registry string) {
+func logPrivateRegistry(w io.Writer, registry string) {
 	log := fmt.Sprintf("[🔐 Private Registry] %s\n", registry)
-	fmt.Println(log)
+	_, _ = fmt.Fprintln(w, log)
 }
 
 func validateGraphScenarioInput(provider provider.ScenarioDataProvider,
@@ -271,7 +299,9 @@ func validateGraphScenarioInput(provider provider.ScenarioDataProvider,
 		name *string
 		err  error
 	},
-	registrySettings *models.RegistryV2) {
+	registrySettings *models.RegistryV2,
+	containerRuntime orchestratorModels.ContainerRuntime,
+	secrets map[string]bool) {
 	for _, n := range nodes {
 		// skip _comment
 		if n.Name == "" {
@@ -281,6 +311,13 @@ func validateGraphScenarioInput(provider provider.ScenarioDataProvider,
 			name *string
 			err  error
 		}{name: &n.Name, err: nil}
+		if err := n.Validate(containerRuntime); err != nil {
+			scenarioNameChannel <- &struct {
+				name *string
+				err  error
+			}{name: &n.Name, err: err}
+			return
+		}
 		scenarioDetail, err := provider.GetScenarioDetail(n.Name, registrySettings)
 
 		if err != nil {
@@ -331,6 +368,10 @@ func validateGraphScenarioInput(provider provider.ScenarioDataProvider,
 				}{name: &n.Name, err: err}
 				return
 			}
+			// the secret values are masked in the environment stored with the run
+			if field.Secret && secrets != nil {
+				secrets[k] = true
+			}
 		}
 
 		for k, v := range n.Volumes {
@@ -355,6 +396,348 @@ func validateGraphScenarioInput(provider provider.ScenarioDataProvider,
 	scenarioNameChannel <- nil
 }
 
+// applyPlanTimeout sets the plan wide timeout on the scenarios that don't define their own
+func applyPlanTimeout(nodes map[string]orchestratorModels.ScenarioNode, timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid timeout %s: must be greater than zero", timeout)
+	}
+	if timeout == 0 {
+		return nil
+	}
+	for id, node := range nodes {
+		// skip _comment
+		if node.Name == "" || node.Timeout != "" {
+			continue
+		}
+		node.Timeout = timeout.String()
+		nodes[id] = node
+	}
+	return nil
+}
+
+// newRunContext returns the context of a chaos run, cancelled with the cause
+// utils.ErrInterrupted on SIGINT or SIGTERM. stop releases the signal handler.
+func newRunContext() (runCtx context.Context, abort context.CancelCauseFunc, stop func()) {
+	runCtx, abort = context.WithCancelCause(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-interrupt:
+			abort(utils.ErrInterrupted)
+		case <-runCtx.Done():
+		}
+	}()
+	return runCtx, abort, func() {
+		signal.Stop(interrupt)
+		abort(nil)
+	}
+}
+
+// consumeGraphRun follows a graph run until RunGraph closes the channel. The events are written to
+// events and the nodes are recorded in record when set. With exitOnError the first failed scenario
+// aborts the run. Returns the first scenario error, and an error if the run could not start its
+// scenarios or the output could not be written.
+func consumeGraphRun(commChannel chan *orchestratorModels.GraphCommChannel,
+	executionPlan [][]string,
+	events *eventWriter,
+	record *history.Record,
+	spinner *spinner.Spinner,
+	out io.Writer,
+	exitOnError bool,
+	runCtx context.Context,
+	abortRun context.CancelCauseFunc) (scenarioErr error, err error) {
+	for {
+		c := <-commChannel
+		if c == nil {
+			return scenarioErr, nil
+		}
+		if c.Event != nil {
+			events.Emit(c.Event)
+			continue
+		}
+		if c.ImagePull != nil {
+			spinner.Suffix = fmt.Sprintf("pulled image %s (%d/%d)", c.ImagePull.Image, c.ImagePull.Pulled, c.ImagePull.Total)
+			continue
+		}
+		// errors not bound to a scenario (e.g. image pre-pull) abort the run
+		if c.Err != nil && c.Layer == nil {
+			spinner.Stop()
+			return scenarioErr, c.Err
+		}
+		if c.SkipReason != "" && c.ScenarioID != nil {
+			if record != nil {
+				record.NodeSkipped(*c.ScenarioID, c.SkipReason)
+			}
+			spinner.Stop()
+			_, err = color.New(color.FgYellow).Fprintln(out, fmt.Sprintf("scenario %s at step %d skipped: %s.",
+				*c.ScenarioID,
+				*c.Layer,
+				c.SkipReason))
+			if err != nil {
+				return scenarioErr, err
+			}
+			spinner.Start()
+			continue
+		}
+		if record != nil && c.ScenarioID != nil {
+			if c.Err != nil {
+				record.NodeFailed(*c.ScenarioID, c.Err)
+			} else if c.ScenarioLogFile != nil {
+				record.NodeStarted(*c.ScenarioID, "", *c.ScenarioLogFile, c.Attempt)
+			}
+		}
+		if c.Err != nil {
+			spinner.Stop()
+			if scenarioErr == nil {
+				scenarioErr = c.Err
+			}
+			var abortErr *utils.AbortError
+			if errors.As(c.Err, &abortErr) {
+				if c.ScenarioID != nil && c.ScenarioLogFile != nil {
+					_, err = color.New(color.FgHiRed).Fprintln(out, fmt.Sprintf("scenario %s at step %d has been killed, check log file %s.",
+						*c.ScenarioID,
+						*c.Layer,
+						*c.ScenarioLogFile))
+					if err != nil {
+						return scenarioErr, err
+					}
+				}
+				// the cause has already been reported by the scenario that aborted the run
+				spinner.Start()
+				continue
+			}
+			var timeoutErr *utils.TimeoutError
+			if errors.As(c.Err, &timeoutErr) {
+				if c.ScenarioID != nil && c.ScenarioLogFile != nil {
+					_, err = color.New(color.FgHiRed).Fprintln(out, fmt.Sprintf("scenario %s at step %d timed out after %s and has been killed, check log file %s.",
+						*c.ScenarioID,
+						*c.Layer,
+						timeoutErr.Timeout,
+						*c.ScenarioLogFile))
+					if err != nil {
+						return scenarioErr, err
+					}
+				}
+				if exitOnError && runCtx.Err() == nil {
+					_, err = color.New(color.FgHiRed).Fprintln(out, fmt.Sprintf("aborting chaos run with exit status %d", utils.TimeoutExitStatus))
+					if err != nil {
+						return scenarioErr, err
+					}
+					abortRun(c.Err)
+				}
+				spinner.Start()
+			}
+			var staterr *utils.ExitError
+			if errors.As(c.Err, &staterr) {
+				if c.ScenarioID != nil && c.ScenarioLogFile != nil {
+					_, err = color.New(color.FgHiRed).Fprintln(out, fmt.Sprintf("scenario %s at step %d with exit status %d, check log file %s.",
+						*c.ScenarioID,
+						*c.Layer,
+						staterr.ExitStatus,
+						*c.ScenarioLogFile))
+					if err != nil {
+						return scenarioErr, err
+					}
+				}
+				if exitOnError && runCtx.Err() == nil {
+					_, err = color.New(color.FgHiRed).Fprintln(out, fmt.Sprintf("aborting chaos run with exit status %d", staterr.ExitStatus))
+					if err != nil {
+						return scenarioErr, err
+					}
+					abortRun(c.Err)
+				}
+				spinner.Start()
+			}
+		}
+		if c.Err == nil && c.Attempt > 1 && c.ScenarioID != nil && c.ScenarioLogFile != nil {
+			spinner.Stop()
+			_, err = color.New(color.FgYellow).Fprintln(out, fmt.Sprintf("retrying scenario %s at step %d (attempt %d), check log file %s.",
+				*c.ScenarioID,
+				*c.Layer,
+				c.Attempt,
+				*c.ScenarioLogFile))
+			if err != nil {
+				return scenarioErr, err
+			}
+			spinner.Start()
+		}
+		spinner.Suffix = fmt.Sprintf("Running step %d scenario(s): %s", *c.Layer, strings.Join(executionPlan[*c.Layer], ", "))
+	}
+}
+
+// newRunWorkspace creates the directory of the run in the runs folder, or in outputDir if set, and moves
+// the flattened kubeconfig there so that the temp folder is not filled with copies of it
+func newRunWorkspace(outputDir string, metadata workspace.Metadata, kubeconfigPath *string, volumes map[string]string, config config.Config) (*workspace.Workspace, error) {
+	root, err := workspace.Root(outputDir, config)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := workspace.New(root, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if kubeconfigPath != nil {
+		adopted, err := ws.Adopt(*kubeconfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move the kubeconfig into the run directory: %w", err)
+		}
+		delete(volumes, *kubeconfigPath)
+		volumes[adopted] = config.KubeconfigPath
+		*kubeconfigPath = adopted
+	}
+	return ws, nil
+}
+
+// newGraphRunWorkspace creates the directory of a graph based run with the snapshot of the plan
+// and the environment every node is started with, the environment is recorded in the run record too
+func newGraphRunWorkspace(outputDir string,
+	command string,
+	planPath string,
+	nodes map[string]orchestratorModels.ScenarioNode,
+	labels orchestratorModels.ContainerLabels,
+	containerRuntime orchestratorModels.ContainerRuntime,
+	kubeconfigPath *string,
+	environment map[string]string,
+	volumes map[string]string,
+	secrets map[string]bool,
+	record *history.Record,
+	config config.Config) (*workspace.Workspace, error) {
+	var nodeIDs []string
+	for id, node := range nodes {
+		// skip _comment
+		if node.Name == "" {
+			continue
+		}
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	scenarios := make([]string, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		scenarios = append(scenarios, nodes[id].Name)
+	}
+
+	metadata := runMetadata(command, planPath, labels, scenarios, containerRuntime)
+	ws, err := newRunWorkspace(outputDir, metadata, kubeconfigPath, volumes, config)
+	if err != nil {
+		return nil, err
+	}
+	// resolved once the kubeconfig has been moved into the run directory
+	nodeEnv := make(map[string]map[string]string, len(nodeIDs))
+	for _, id := range nodeIDs {
+		var nodeVolumes map[string]string
+		nodeEnv[id], nodeVolumes = scenarioorchestrator.ResolveScenarioEnvironment(nodes[id].Scenario, environment, volumes, config)
+		record.SetEnvironment(id, nodeEnv[id], nodeVolumes, secrets)
+	}
+	if err := ws.WritePlan(nodes); err != nil {
+		return nil, fmt.Errorf("failed to write the plan snapshot: %w", err)
+	}
+	if err := ws.WriteEnv(nodeEnv, secrets); err != nil {
+		return nil, fmt.Errorf("failed to write the run environment: %w", err)
+	}
+	return ws, nil
+}
+
+// runMetadata returns the metadata of the run started by command, planPath is empty for single scenario runs
+func runMetadata(command string, planPath string, labels orchestratorModels.ContainerLabels, scenarios []string, containerRuntime orchestratorModels.ContainerRuntime) workspace.Metadata {
+	if planPath != "" {
+		if absPath, err := filepath.Abs(planPath); err == nil {
+			planPath = absPath
+		}
+	}
+	return workspace.Metadata{
+		RunID:     labels.RunID,
+		Command:   command,
+		Plan:      planPath,
+		GraphHash: labels.GraphHash,
+		Scenarios: scenarios,
+		Runtime:   containerRuntime.String(),
+		Version:   labels.Version,
+		User:      labels.User,
+	}
+}
+
+// finishRunWorkspace records the outcome of the run in the run directory and in the history,
+// failing to do so does not fail the run
+func finishRunWorkspace(ws *workspace.Workspace, record *history.Record, runErr error, config config.Config) {
+	if ws == nil {
+		return
+	}
+	status := workspace.StatusSucceeded
+	exitStatus := 0
+	var abortErr *utils.AbortError
+	var exitErr *utils.ExitError
+	var timeoutErr *utils.TimeoutError
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &abortErr):
+		status = workspace.StatusAborted
+		exitStatus = abortErr.ExitStatus()
+	case errors.As(runErr, &exitErr):
+		status = workspace.StatusFailed
+		exitStatus = exitErr.ExitStatus
+	case errors.As(runErr, &timeoutErr):
+		status = workspace.StatusFailed
+		exitStatus = utils.TimeoutExitStatus
+	default:
+		status = workspace.StatusFailed
+		exitStatus = 1
+	}
+	if err := ws.Finish(status, exitStatus, runErr); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to update the run metadata in %s: %v\n", ws.Dir, err)
+	}
+	recordRun(ws, record, config)
+}
+
+// recordRun appends the finished run to the history
+func recordRun(ws *workspace.Workspace, record *history.Record, config config.Config) {
+	if record == nil {
+		return
+	}
+	record.Complete(ws)
+	store, err := historyStore(config)
+	if err == nil {
+		err = store.Append(*record)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to record run %s in the history: %v\n", ws.Metadata.RunID, err)
+	}
+}
+
+// historyStore returns the run history
+func historyStore(config config.Config) (*history.Store, error) {
+	historyFile, err := commonutils.ExpandFolder(config.HistoryFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid history file %s: %w", config.HistoryFile, err)
+	}
+	return history.NewStore(*historyFile), nil
+}
+
+// resolveRunImages returns the digests of the images the nodes are run with, the run
+// is recorded without digests, and cannot be replayed as is, if they cannot be resolved
+func resolveRunImages(nodes map[string]orchestratorModels.ScenarioNode, dataProvider provider.ScenarioDataProvider, registrySettings *models.RegistryV2) map[string]lockfile.LockedImage {
+	lock, err := lockfile.Resolve(nodes, dataProvider, registrySettings)
+	if err == nil {
+		return lock.Nodes
+	}
+	fmt.Fprintf(os.Stderr, "failed to resolve the image digests, the run is recorded without them: %v\n", err)
+	images := make(map[string]lockfile.LockedImage)
+	for id, node := range nodes {
+		if node.Name != "" {
+			images[id] = lockfile.LockedImage{Scenario: node.Name, Image: node.Image}
+		}
+	}
+	return images
+}
+
+// runFilter returns the container label filter matching the run ID, nil (all the krknctl containers) if empty
+func runFilter(runID string) *orchestratorModels.ContainerLabels {
+	if runID == "" {
+		return nil
+	}
+	return &orchestratorModels.ContainerLabels{RunID: runID}
+}
+
 func RebuildDependencyGraph(nodes map[string]orchestratorModels.ScenarioNode, graph [][]string, rootNodeLabel string) map[string]orchestratorModels.ScenarioNode {
 	dependencyGraph := make(map[string]orchestratorModels.ScenarioNode)
 	for i, n := range graph {
@@ -366,7 +749,7 @@ func RebuildDependencyGraph(nodes map[string]orchestratorModels.ScenarioNode, gr
 			} else {
 				// the dependency will set to the first item
 				// of the previous layer
-				node.Parent = &graph[i-1][0]
+				node.Parents = orchestratorModels.Dependencies{graph[i-1][0]}
 			}
 			dependencyGraph[dep] = node
 		}
@@ -514,3 +897,236 @@ func ParseArgValue(args []string, flagName string) (string, bool, error) {
 	}
 	return "", false, nil
 }
+
+// parseContainerOptions reads the resource limits, the security options and the pull
+// policy of the run command and validates them against the container runtime
+func parseContainerOptions(args []string, containerRuntime orchestratorModels.ContainerRuntime) (*scenarioorchestrator.PodmanCreateOptions, error) {
+	resources := orchestratorModels.Resources{}
+	security := orchestratorModels.Security{}
+	if value, found, err := ParseArgValue(args, "--container-cpus"); err != nil {
+		return nil, err
+	} else if found {
+		resources.CPU = value
+	}
+	if value, found, err := ParseArgValue(args, "--container-memory"); err != nil {
+		return nil, err
+	} else if found {
+		resources.Memory = value
+	}
+	if value, found, err := ParseArgValue(args, "--container-pids-limit"); err != nil {
+		return nil, err
+	} else if found {
+		pids, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || pids <= 0 {
+			return nil, fmt.Errorf("invalid pids limit %s: must be a number greater than zero", value)
+		}
+		resources.Pids = pids
+	}
+	if value, found, err := ParseArgValue(args, "--container-cap-add"); err != nil {
+		return nil, err
+	} else if found {
+		security.CapAdd = strings.Split(value, ",")
+	}
+	if value, found, err := ParseArgValue(args, "--container-cap-drop"); err != nil {
+		return nil, err
+	} else if found {
+		security.CapDrop = strings.Split(value, ",")
+	}
+	if value, found, err := ParseArgValue(args, "--container-user"); err != nil {
+		return nil, err
+	} else if found {
+		security.User = value
+	}
+	for _, a := range args {
+		if a == "--container-read-only" {
+			security.ReadOnlyRootfs = true
+		}
+	}
+
+	node := orchestratorModels.ScenarioNode{}
+	if resources != (orchestratorModels.Resources{}) {
+		node.Resources = &resources
+	}
+	if len(security.CapAdd) > 0 || len(security.CapDrop) > 0 || security.ReadOnlyRootfs || security.User != "" {
+		node.Security = &security
+	}
+	if err := node.Validate(containerRuntime); err != nil {
+		return nil, err
+	}
+	createOpts := scenarioorchestrator.NodeCreateOptions(node, "", nil)
+	if value, found, err := ParseArgValue(args, "--pull-policy"); err != nil {
+		return nil, err
+	} else if found {
+		pullPolicy, err := orchestratorModels.ParsePullPolicy(value)
+		if err != nil {
+			return nil, err
+		}
+		createOpts.PullPolicy = pullPolicy
+	}
+	return createOpts, nil
+}
+
+func validateDryRunOutput(output string) error {
+	if output != dryRunOutputTable && output != dryRunOutputJSON {
+		return fmt.Errorf("unsupported output %q, supported values are %s, %s", output, dryRunOutputTable, dryRunOutputJSON)
+	}
+	return nil
+}
+
+// dryRunKubeconfig returns the kubeconfig path that would be mounted in the scenario containers
+func dryRunKubeconfig(kubeconfig string) *string {
+	if kubeconfig == "" {
+		kubeconfig = clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
+	}
+	return &kubeconfig
+}
+
+// commandFlag returns the value of a string flag of the command, "" if the command has no such flag.
+// The commands that parse their own flags (e.g. run) are looked up in the arguments
+func commandFlag(cmd *cobra.Command, args []string, name string) (string, error) {
+	if cmd.DisableFlagParsing {
+		value, _, err := ParseArgValue(args, "--"+name)
+		return value, err
+	}
+	if cmd.Flags().Lookup(name) == nil {
+		return "", nil
+	}
+	return cmd.Flags().GetString(name)
+}
+
+// commandBoolFlag returns the value of a bool flag of the command, false if the command has no such flag
+func commandBoolFlag(cmd *cobra.Command, args []string, name string) (bool, error) {
+	if cmd.DisableFlagParsing {
+		for _, a := range args {
+			if a == "--"+name {
+				return true, nil
+			}
+			if value, found := strings.CutPrefix(a, "--"+name+"="); found {
+				enabled, err := strconv.ParseBool(value)
+				if err != nil {
+					return false, fmt.Errorf("invalid value %s for --%s", value, name)
+				}
+				return enabled, nil
+			}
+		}
+		return false, nil
+	}
+	if cmd.Flags().Lookup(name) == nil {
+		return false, nil
+	}
+	return cmd.Flags().GetBool(name)
+}
+
+// newScenarioOrchestrator builds the scenario orchestrator of the command once its flags are parsed.
+// The preview commands run with --dry-run get the dry-run orchestrator, that needs no container
+// runtime, the default platform is assumed if the runtime is not set
+func newScenarioOrchestrator(cmd *cobra.Command, args []string, previewCommands []*cobra.Command, config config.Config) (scenarioorchestrator.ScenarioOrchestrator, error) {
+	runtimeFlag, err := commandFlag(cmd, args, "runtime")
+	if err != nil {
+		return nil, err
+	}
+	containerRuntime, err := utils.RuntimeFromFlag(runtimeFlag)
+	if err != nil {
+		return nil, err
+	}
+	settings := []struct {
+		flag    string
+		setting *string
+	}{
+		{"runtime-namespace", &config.KubernetesNamespace},
+		{"container-host", &config.ContainerHost},
+		{"container-cert-path", &config.ContainerCertPath},
+		{"container-identity", &config.ContainerIdentity},
+		// the jobs are created in the cluster targeted by the run unless another one is set
+		{"kubeconfig", &config.KubernetesKubeconfig},
+		{"runtime-kubeconfig", &config.KubernetesKubeconfig},
+	}
+	for _, s := range settings {
+		value, err := commandFlag(cmd, args, s.flag)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			*s.setting = value
+		}
+	}
+	// the container host is ignored on kubernetes as CONTAINER_HOST and DOCKER_HOST are
+	if containerRuntime != nil && *containerRuntime == orchestratorModels.Kubernetes && config.ContainerHost != "" {
+		_, _ = color.New(color.FgYellow).Fprintln(os.Stderr, "--container-host is ignored with --runtime kubernetes, the cluster is reached through the kubeconfig")
+	}
+
+	if slices.Contains(previewCommands, cmd) {
+		dryRun, err := commandBoolFlag(cmd, args, "dry-run")
+		if err != nil {
+			return nil, err
+		}
+		if dryRun {
+			if containerRuntime == nil {
+				defaultRuntime := utils.EnvironmentFromString(config.DefaultContainerPlatform)
+				containerRuntime = &defaultRuntime
+			}
+			return dryrun.NewScenarioOrchestrator(config, *containerRuntime), nil
+		}
+	}
+
+	if containerRuntime == nil {
+		containerRuntime, err = utils.DetectContainerRuntime(config)
+		if err != nil {
+			return nil, errors.New("failed to determine container runtime enviroment please install podman or docker and retry")
+		}
+	}
+	orchestrator := scenarioorchestratorfactory.NewScenarioOrchestratorFactory(config).NewInstance(*containerRuntime)
+	if orchestrator == nil {
+		return nil, errors.New("failed to build scenario orchestrator instance")
+	}
+	return orchestrator, nil
+}
+
+// maskDryRunPlan masks the secret values of the plan as the run does: the environment variables
+// of the secret fields and the credentials of the private registry
+func maskDryRunPlan(plan []orchestratorModels.PlannedScenario, secrets map[string]bool, registrySettings *models.RegistryV2) {
+	var credentials []string
+	if registrySettings != nil {
+		for _, credential := range []*string{registrySettings.Password, registrySettings.Token} {
+			if credential != nil && *credential != "" {
+				credentials = append(credentials, *credential)
+			}
+		}
+	}
+	for _, planned := range plan {
+		for k, v := range planned.Env {
+			if secrets[k] || slices.Contains(credentials, v) {
+				planned.Env[k] = utils.MaskString(v)
+			}
+		}
+	}
+}
+
+// PrintDryRunPlan prints the containers recorded by the dry-run orchestrator, the secrets
+// are masked
+func PrintDryRunPlan(plan []orchestratorModels.PlannedScenario, secrets map[string]bool, registrySettings *models.RegistryV2, output string, config config.Config) error {
+	maskDryRunPlan(plan, secrets, registrySettings)
+	if output == dryRunOutputJSON {
+		if plan == nil {
+			plan = []orchestratorModels.PlannedScenario{}
+		}
+		jsonData, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	NewDryRunTable(plan).Print()
+	for _, planned := range plan {
+		fmt.Printf("\n%s\n", headerFmt(planned.ContainerName))
+		NewPlannedEnvironmentTable(planned.Env, config).Print()
+		if len(planned.Volumes) > 0 {
+			fmt.Print("\n")
+			NewVolumesTable(planned.Volumes).Print()
+		}
+	}
+	fmt.Print("\n")
+	return nil
+}
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	krknctlconfig "github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/history"
 	"github.com/krkn-chaos/krknctl/pkg/provider"
 	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
 	providerModels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/dryrun"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	orchestratorutils "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	krknctlutils "github.com/krkn-chaos/krknctl/pkg/utils"
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"os"
@@ -332,10 +338,10 @@ func TestDumpRandomGraph(t *testing.T) {
 
 	graph := RebuildDependencyGraph(unserializedNodes, plan, "root")
 
-	assert.Nil(t, graph["a"].Parent)
+	assert.Nil(t, graph["a"].Parents)
 	assert.Equal(t, graph["a"].Comment, "root")
-	assert.Equal(t, *graph["b"].Parent, "a")
-	assert.Equal(t, *graph["c"].Parent, "a")
+	assert.Equal(t, []string{"a"}, graph["b"].GetParents())
+	assert.Equal(t, []string{"a"}, graph["c"].GetParents())
 
 	fileName := fmt.Sprintf("graph-%d.json", time.Now().Unix())
 	err = DumpRandomGraph(unserializedNodes, plan, fileName, "root")
@@ -473,3 +479,181 @@ func TestParseArgValue(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyPlanTimeout(t *testing.T) {
+	nodes := map[string]models.ScenarioNode{
+		"_comment": {Scenario: models.Scenario{Comment: "comment"}},
+		"default":  {Scenario: models.Scenario{Name: "dummy-scenario"}},
+		"custom":   {Scenario: models.Scenario{Name: "dummy-scenario"}, Timeout: "10m"},
+	}
+	assert.Nil(t, applyPlanTimeout(nodes, 0))
+	assert.Equal(t, "", nodes["default"].Timeout)
+
+	assert.Nil(t, applyPlanTimeout(nodes, 30*time.Minute))
+	assert.Equal(t, "", nodes["_comment"].Timeout)
+	timeout, err := nodes["default"].GetTimeout()
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Minute, *timeout)
+	timeout, err = nodes["custom"].GetTimeout()
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Minute, *timeout)
+
+	assert.NotNil(t, applyPlanTimeout(nodes, -time.Second))
+
+	_, err = models.ScenarioNode{Timeout: "ten minutes"}.GetTimeout()
+	assert.NotNil(t, err)
+	_, err = models.ScenarioNode{Timeout: "0s"}.GetTimeout()
+	assert.NotNil(t, err)
+	timeout, err = models.ScenarioNode{}.GetTimeout()
+	assert.Nil(t, err)
+	assert.Nil(t, timeout)
+}
+
+func TestParseContainerOptions(t *testing.T) {
+	createOpts, err := parseContainerOptions([]string{"--kubeconfig", "/tmp/kubeconfig"}, models.Podman)
+	assert.Nil(t, err)
+	assert.Nil(t, createOpts.Resources)
+	assert.Nil(t, createOpts.Security)
+
+	args := []string{"--container-cpus", "0.5", "--container-memory=1g", "--container-pids-limit", "100",
+		"--container-cap-add", "NET_ADMIN,SYS_TIME", "--container-cap-drop=ALL", "--container-read-only", "--container-user", "1000:1000"}
+	createOpts, err = parseContainerOptions(args, models.Docker)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.5", createOpts.Resources.CPU)
+	assert.Equal(t, "1g", createOpts.Resources.Memory)
+	assert.Equal(t, int64(100), createOpts.Resources.Pids)
+	assert.Equal(t, []string{"NET_ADMIN", "SYS_TIME"}, createOpts.Security.CapAdd)
+	assert.Equal(t, []string{"ALL"}, createOpts.Security.CapDrop)
+	assert.True(t, createOpts.Security.ReadOnlyRootfs)
+	assert.Equal(t, "1000:1000", createOpts.Security.User)
+
+	// pids limit can't be applied to a kubernetes pod
+	_, err = parseContainerOptions(args, models.Kubernetes)
+	assert.NotNil(t, err)
+
+	createOpts, err = parseContainerOptions([]string{"--container-read-only"}, models.Podman)
+	assert.Nil(t, err)
+	assert.Nil(t, createOpts.Resources)
+	assert.True(t, createOpts.Security.ReadOnlyRootfs)
+
+	_, err = parseContainerOptions([]string{"--container-memory", "a lot"}, models.Podman)
+	assert.NotNil(t, err)
+	_, err = parseContainerOptions([]string{"--container-pids-limit", "-1"}, models.Podman)
+	assert.NotNil(t, err)
+	_, err = parseContainerOptions([]string{"--container-cpus"}, models.Podman)
+	assert.NotNil(t, err)
+
+	createOpts, err = parseContainerOptions([]string{"--pull-policy", "never"}, models.Docker)
+	assert.Nil(t, err)
+	assert.Equal(t, models.PullNever, createOpts.PullPolicy)
+	_, err = parseContainerOptions([]string{"--pull-policy=sometimes"}, models.Docker)
+	assert.NotNil(t, err)
+}
+
+func TestNewScenarioOrchestrator(t *testing.T) {
+	conf, err := krknctlconfig.LoadConfig()
+	assert.Nil(t, err)
+	newCommand := func(disableFlagParsing bool) *cobra.Command {
+		command := &cobra.Command{Use: "run", DisableFlagParsing: disableFlagParsing}
+		command.Flags().String("runtime", "auto", "")
+		command.Flags().String("runtime-namespace", conf.KubernetesNamespace, "")
+		command.Flags().String("container-host", "", "")
+		command.Flags().Bool("dry-run", false, "")
+		return command
+	}
+
+	// the flags are parsed by cobra, any form of --dry-run is honored
+	graphRun := newCommand(false)
+	assert.Nil(t, graphRun.ParseFlags([]string{"clean", "--dry-run=true", "--runtime", "kubernetes"}))
+	orchestrator, err := newScenarioOrchestrator(graphRun, graphRun.Flags().Args(), []*cobra.Command{graphRun}, conf)
+	assert.Nil(t, err)
+	assert.IsType(t, &dryrun.ScenarioOrchestrator{}, orchestrator)
+	assert.Equal(t, models.Kubernetes, orchestrator.GetContainerRuntime())
+	assert.Equal(t, conf.KubernetesNamespace, orchestrator.GetConfig().KubernetesNamespace)
+
+	// the run command parses its own flags
+	run := newCommand(true)
+	args := []string{"dummy-scenario", "--dry-run=true", "--runtime-namespace", "chaos"}
+	orchestrator, err = newScenarioOrchestrator(run, args, []*cobra.Command{run}, conf)
+	assert.Nil(t, err)
+	assert.IsType(t, &dryrun.ScenarioOrchestrator{}, orchestrator)
+	assert.Equal(t, orchestratorutils.EnvironmentFromString(conf.DefaultContainerPlatform), orchestrator.GetContainerRuntime())
+	assert.Equal(t, "chaos", orchestrator.GetConfig().KubernetesNamespace)
+	_, err = newScenarioOrchestrator(run, []string{"dummy-scenario", "--dry-run=maybe"}, []*cobra.Command{run}, conf)
+	assert.NotNil(t, err)
+
+	// the other commands never get the dry-run orchestrator
+	clean := newCommand(false)
+	assert.Nil(t, clean.ParseFlags([]string{"--dry-run", "--runtime", "kubernetes"}))
+	orchestrator, err = newScenarioOrchestrator(clean, clean.Flags().Args(), []*cobra.Command{graphRun}, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, models.Kubernetes, orchestrator.GetContainerRuntime())
+	_, isDryRun := orchestrator.(*dryrun.ScenarioOrchestrator)
+	assert.False(t, isDryRun)
+
+	// the container host is ignored on kubernetes
+	orchestrator, err = newScenarioOrchestrator(run, []string{"--runtime", "kubernetes", "--container-host", "tcp://docker.example.com:2376"}, nil, conf)
+	assert.Nil(t, err)
+	socket, err := orchestrator.GetContainerRuntimeSocket(nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "tcp://docker.example.com:2376", *socket)
+
+	_, err = newScenarioOrchestrator(run, []string{"--runtime", "lxc"}, nil, conf)
+	assert.NotNil(t, err)
+}
+
+func TestMaskDryRunPlan(t *testing.T) {
+	password, token := "registry-password", "registry-token"
+	registry := &providerModels.RegistryV2{RegistryURL: "registry.example.com", Password: &password, Token: &token}
+	plan := []models.PlannedScenario{
+		{ScenarioID: "root", Env: map[string]string{"API_KEY": "secret-value", "NAMESPACE": "default", "REGISTRY_PASSWORD": password}},
+		{ScenarioID: "child", Env: map[string]string{"TOKEN": token, "DURATION": "60"}},
+	}
+	maskDryRunPlan(plan, map[string]bool{"API_KEY": true, "NAMESPACE": false}, registry)
+	assert.Equal(t, map[string]string{"API_KEY": "sec*********", "NAMESPACE": "default", "REGISTRY_PASSWORD": "reg**************"}, plan[0].Env)
+	assert.Equal(t, map[string]string{"TOKEN": "reg***********", "DURATION": "60"}, plan[1].Env)
+
+	// without private registry only the secret fields are masked
+	plan = []models.PlannedScenario{{Env: map[string]string{"API_KEY": "secret-value", "PASSWORD": password}}}
+	maskDryRunPlan(plan, map[string]bool{"API_KEY": true}, nil)
+	assert.Equal(t, map[string]string{"API_KEY": "sec*********", "PASSWORD": password}, plan[0].Env)
+}
+
+func TestConsumeGraphRun(t *testing.T) {
+	layer := 0
+	pods, cpu := "pods", "cpu"
+	podsLog, cpuLog := "/runs/a1b2c3/krknctl-pods-1.log", "/runs/a1b2c3/krknctl-cpu-1.log"
+	commChannel := make(chan *models.GraphCommChannel)
+	go func() {
+		commChannel <- &models.GraphCommChannel{Event: models.NewEvent(models.EventRunStarted, "a1b2c3")}
+		commChannel <- &models.GraphCommChannel{Layer: &layer, ScenarioID: &pods, ScenarioLogFile: &podsLog, Attempt: 1}
+		commChannel <- &models.GraphCommChannel{Layer: &layer, ScenarioID: &cpu, ScenarioLogFile: &cpuLog, Attempt: 1}
+		commChannel <- &models.GraphCommChannel{Layer: &layer, ScenarioID: &pods, ScenarioLogFile: &podsLog, Err: &orchestratorutils.ExitError{ExitStatus: 2}}
+		commChannel <- &models.GraphCommChannel{Layer: &layer, ScenarioID: &cpu, ScenarioLogFile: &cpuLog, Err: &orchestratorutils.AbortError{Cause: &orchestratorutils.ExitError{ExitStatus: 2}}}
+		close(commChannel)
+	}()
+	record := history.NewRecord(map[string]models.ScenarioNode{"pods": {}, "cpu": {}})
+	var out bytes.Buffer
+	spinner := NewSpinnerWithSuffix("running graph based chaos plan...")
+	spinner.Writer = &out
+	runCtx, abortRun := context.WithCancelCause(context.Background())
+	defer abortRun(nil)
+
+	scenarioErr, err := consumeGraphRun(commChannel, [][]string{{"pods", "cpu"}}, nil, record, spinner, &out, true, runCtx, abortRun)
+	assert.Nil(t, err)
+	// the first failure is returned and aborts the run, the killed scenario is only reported
+	var exitErr *orchestratorutils.ExitError
+	assert.ErrorAs(t, scenarioErr, &exitErr)
+	assert.Equal(t, 2, exitErr.ExitStatus)
+	assert.ErrorIs(t, context.Cause(runCtx), scenarioErr)
+	assert.Contains(t, out.String(), "scenario pods at step 0 with exit status 2")
+	assert.Contains(t, out.String(), "aborting chaos run with exit status 2")
+	assert.Contains(t, out.String(), "scenario cpu at step 0 has been killed")
+	assert.NotEmpty(t, record.Nodes["pods"].Error)
+
+	// a failure not bound to a scenario ends the run
+	commChannel = make(chan *models.GraphCommChannel, 1)
+	commChannel <- &models.GraphCommChannel{Err: fmt.Errorf("failed to pull image")}
+	_, err = consumeGraphRun(commChannel, nil, nil, nil, spinner, &out, false, context.Background(), func(error) {})
+	assert.EqualError(t, err, "failed to pull image")
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/krkn-chaos/krknctl/pkg/dependencygraph"
+	"github.com/krkn-chaos/krknctl/pkg/provider"
+	"github.com/krkn-chaos/krknctl/pkg/provider/models"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/typing"
+)
+
+// planProblem is an error found in a plan by graph validate, the position is the one of the
+// node when the error is not bound to a precise place of the file
+type planProblem struct {
+	// NodeID is empty for the errors of the whole file (e.g. a JSON syntax error)
+	NodeID  string `json:"node_id,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// planValidation is the outcome of graph validate
+type planValidation struct {
+	Plan     string        `json:"plan"`
+	Valid    bool          `json:"valid"`
+	Problems []planProblem `json:"problems"`
+}
+
+// planPosition returns the line and the column, both starting from 1, of the byte at offset
+func planPosition(data []byte, offset int64) (int, int) {
+	offset = max(0, min(offset, int64(len(data))))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// syntaxProblem reports a JSON syntax error at the character that caused it
+func syntaxProblem(data []byte, err error) planProblem {
+	problem := planProblem{Message: err.Error()}
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// the offset is the number of bytes read including the invalid character
+		problem.Line, problem.Column = planPosition(data, syntaxErr.Offset-1)
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		problem.Line, problem.Column = planPosition(data, int64(len(data)))
+		problem.Message = "unexpected end of file"
+	}
+	return problem
+}
+
+// decodePlan decodes the nodes of the plan one by one so that the error of a node does not hide
+// the others. It returns the nodes, the offset of each node in the file and the problems found,
+// the nodes are nil if the file is not a JSON object. The nodes that can't be decoded are
+// returned as decoded so far and reported in broken.
+func decodePlan(data []byte) (map[string]orchestratorModels.ScenarioNode, map[string]int64, map[string]bool, []planProblem) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, nil, []planProblem{syntaxProblem(data, err)}
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil, nil, []planProblem{{Line: 1, Column: 1, Message: "the plan must be a JSON object of scenarios keyed by their ID"}}
+	}
+
+	nodes := make(map[string]orchestratorModels.ScenarioNode)
+	offsets := make(map[string]int64)
+	broken := make(map[string]bool)
+	var problems []planProblem
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nodes, offsets, broken, append(problems, syntaxProblem(data, err))
+		}
+		id, _ := token.(string)
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nodes, offsets, broken, append(problems, syntaxProblem(data, err))
+		}
+		offset := decoder.InputOffset() - int64(len(raw))
+		if _, ok := offsets[id]; ok {
+			line, column := planPosition(data, offset)
+			problems = append(problems, planProblem{NodeID: id, Line: line, Column: column, Message: "scenario ID defined more than once, only the last definition is kept"})
+		}
+		offsets[id] = offset
+
+		var node orchestratorModels.ScenarioNode
+		if err := json.Unmarshal(raw, &node); err != nil {
+			problem := planProblem{NodeID: id, Message: err.Error()}
+			problem.Line, problem.Column = planPosition(data, offset)
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				problem.Line, problem.Column = planPosition(data, offset+typeErr.Offset-1)
+				problem.Message = fmt.Sprintf("%s must be %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+			}
+			problems = append(problems, problem)
+			broken[id] = true
+		} else if err := decodeStrict(raw, &orchestratorModels.ScenarioNode{}); err != nil {
+			// the misspelled keys (e.g. depend_on) would be ignored by the run
+			line, column := planPosition(data, offset)
+			problems = append(problems, planProblem{NodeID: id, Line: line, Column: column, Message: strings.TrimPrefix(err.Error(), "json: ")})
+		}
+		nodes[id] = node
+	}
+	// the closing brace and nothing after it
+	if _, err := decoder.Token(); err != nil {
+		return nodes, offsets, broken, append(problems, syntaxProblem(data, err))
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		line, column := planPosition(data, decoder.InputOffset())
+		problems = append(problems, planProblem{Line: line, Column: column, Message: "unexpected content after the plan"})
+	}
+	return nodes, offsets, broken, problems
+}
+
+// decodeStrict decodes data rejecting the keys that are not fields of v
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// validatePlan runs every check on the decoded plan and returns all the problems found, without
+// stopping at the first one as validateGraphScenarioInput does. The fields of the scenarios are
+// checked against the scenario metadata of the registry unless offline is set, runMounts are the
+// paths krknctl mounts in every scenario container (e.g. the kubeconfig).
+func validatePlan(nodes map[string]orchestratorModels.ScenarioNode,
+	offsets map[string]int64,
+	broken map[string]bool,
+	data []byte,
+	containerRuntime orchestratorModels.ContainerRuntime,
+	dataProvider provider.ScenarioDataProvider,
+	registrySettings *models.RegistryV2,
+	runMounts []string,
+	offline bool) []planProblem {
+	var problems []planProblem
+	report := func(id string, format string, args ...any) {
+		line, column := planPosition(data, offsets[id])
+		problems = append(problems, planProblem{NodeID: id, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	ids := make([]string, 0, len(nodes))
+	convertedNodes := make(map[string]dependencygraph.ParentProvider, len(nodes))
+	for id, node := range nodes {
+		ids = append(ids, id)
+		convertedNodes[id] = node
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		node := nodes[id]
+		for _, parent := range node.GetParents() {
+			if _, ok := nodes[parent]; !ok {
+				report(id, "depends on %s which is not defined in the plan", parent)
+			}
+		}
+		// skip _comment
+		if node.Name == "" || broken[id] {
+			continue
+		}
+		if err := node.Validate(containerRuntime); err != nil {
+			report(id, "%s", err)
+		}
+	}
+	for _, cycle := range dependencygraph.FindCycles(convertedNodes) {
+		if len(cycle) == 1 {
+			report(cycle[0], "depends on itself")
+			continue
+		}
+		for _, id := range cycle {
+			report(id, "is part of a dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+	}
+
+	if offline {
+		return problems
+	}
+	// the metadata are fetched once for the scenarios run by several nodes
+	details := make(map[string]*models.ScenarioDetail)
+	globalFields := make(map[string][]typing.InputField)
+	detailErrors := make(map[string]error)
+	for _, id := range ids {
+		node := nodes[id]
+		if node.Name == "" || broken[id] {
+			continue
+		}
+		if _, ok := details[node.Name]; !ok {
+			details[node.Name], globalFields[node.Name], detailErrors[node.Name] = getValidationDetail(node.Name, dataProvider, registrySettings)
+		}
+		if err := detailErrors[node.Name]; err != nil {
+			report(id, "%s", err)
+			continue
+		}
+		for _, message := range validateNodeFields(node, details[node.Name], globalFields[node.Name], runMounts) {
+			report(id, "%s", message)
+		}
+	}
+	return problems
+}
+
+// getValidationDetail returns the scenario metadata and the global environment fields,
+// the latter can be set on every scenario
+func getValidationDetail(name string, dataProvider provider.ScenarioDataProvider, registrySettings *models.RegistryV2) (*models.ScenarioDetail, []typing.InputField, error) {
+	scenarioDetail, err := dataProvider.GetScenarioDetail(name, registrySettings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch the metadata of scenario %s: %w", name, err)
+	}
+	if scenarioDetail == nil {
+		return nil, nil, fmt.Errorf("scenario %s not found", name)
+	}
+	globalDetail, err := dataProvider.GetGlobalEnvironment(registrySettings, scenarioDetail.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch the global environment of scenario %s: %w", name, err)
+	}
+	if globalDetail == nil {
+		return scenarioDetail, nil, nil
+	}
+	return scenarioDetail, globalDetail.Fields, nil
+}
+
+// validateNodeFields checks the environment and the volume mounts of the node against the fields
+// of the scenario and the global fields, the required fields of the scenario must be set by the node
+// or, for the files, mounted by krknctl
+func validateNodeFields(node orchestratorModels.ScenarioNode, scenarioDetail *models.ScenarioDetail, globalFields []typing.InputField, runMounts []string) []string {
+	var problems []string
+	mounted := make(map[string]bool, len(node.Volumes)+len(runMounts))
+	for _, mountPath := range runMounts {
+		mounted[mountPath] = true
+	}
+	for _, mountPath := range node.Volumes {
+		mounted[mountPath] = true
+	}
+	for _, field := range scenarioDetail.Fields {
+		if !field.Required || field.Type == typing.Group {
+			continue
+		}
+		if field.Type == typing.File {
+			if field.MountPath != nil && !mounted[*field.MountPath] && (field.Default == nil || *field.Default == "") {
+				problems = append(problems, fmt.Sprintf("required file %s is not mounted at %s", *field.Name, *field.MountPath))
+			}
+			continue
+		}
+		if _, ok := node.Env[*field.Variable]; ok {
+			continue
+		}
+		if _, err := field.Validate(nil); err != nil {
+			problems = append(problems, fmt.Sprintf("required environment variable %s (%s) is not set", *field.Variable, *field.Name))
+		}
+	}
+
+	fields := models.ScenarioDetail{Fields: append(append([]typing.InputField{}, scenarioDetail.Fields...), globalFields...)}
+	for _, k := range sortedKeys(node.Env) {
+		v := node.Env[k]
+		field := fields.GetFieldByEnvVar(k)
+		if field == nil {
+			problems = append(problems, fmt.Sprintf("environment variable %s is not a field of scenario %s", k, scenarioDetail.Name))
+			continue
+		}
+		if _, err := field.Validate(&v); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value of %s: %s", k, err))
+		}
+	}
+	for _, k := range sortedKeys(node.Volumes) {
+		mountPath := node.Volumes[k]
+		field := fields.GetFileFieldByMountPath(mountPath)
+		if field == nil {
+			problems = append(problems, fmt.Sprintf("no file field of scenario %s is mounted at %s", scenarioDetail.Name, mountPath))
+			continue
+		}
+		if _, err := field.Validate(&k); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid file %s mounted at %s: %s", k, mountPath, err))
+		}
+	}
+	return problems
+}
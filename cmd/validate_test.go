@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/krkn-chaos/krknctl/pkg/provider"
+	"github.com/krkn-chaos/krknctl/pkg/provider/models"
+	orchestratorModels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+// validationProvider returns the metadata of dummy-scenario, the other scenarios are not found
+type validationProvider struct {
+	provider.ScenarioDataProvider
+}
+
+func (p validationProvider) GetScenarioDetail(scenario string, registry *models.RegistryV2) (*models.ScenarioDetail, error) {
+	if scenario != "dummy-scenario" {
+		return nil, nil
+	}
+	field := func(name string, variable string, fieldType typing.Type, required bool) typing.InputField {
+		return typing.InputField{Name: &name, Variable: &variable, Type: fieldType, Required: required}
+	}
+	kubeconfigPath, scenarioPath := "/home/krkn/.kube/config", "/home/krkn/scenario.yaml"
+	kubeconfig := field("kubeconfig", "KUBECONFIG", typing.File, true)
+	kubeconfig.MountPath = &kubeconfigPath
+	scenarioFile := field("scenario", "SCENARIO", typing.File, true)
+	scenarioFile.MountPath = &scenarioPath
+	return &models.ScenarioDetail{
+		ScenarioTag: models.ScenarioTag{Name: scenario},
+		Fields: []typing.InputField{
+			field("duration", "DURATION", typing.Number, true),
+			field("namespace", "NAMESPACE", typing.String, false),
+			kubeconfig,
+			scenarioFile,
+		},
+	}, nil
+}
+
+func (p validationProvider) GetGlobalEnvironment(registry *models.RegistryV2, scenario string) (*models.ScenarioDetail, error) {
+	name, variable := "cerberus", "CERBERUS_ENABLED"
+	return &models.ScenarioDetail{Fields: []typing.InputField{{Name: &name, Variable: &variable, Type: typing.Boolean}}}, nil
+}
+
+func TestPlanPosition(t *testing.T) {
+	data := []byte("{\n  \"a\": 1\n}")
+	line, column := planPosition(data, 0)
+	assert.Equal(t, []int{1, 1}, []int{line, column})
+	line, column = planPosition(data, 4)
+	assert.Equal(t, []int{2, 3}, []int{line, column})
+	line, column = planPosition(data, 100)
+	assert.Equal(t, []int{3, 2}, []int{line, column})
+}
+
+func TestDecodePlan(t *testing.T) {
+	_, _, _, problems := decodePlan([]byte("{\n  \"root\": {\"name\": \"dummy-scenario\",}\n}"))
+	assert.Len(t, problems, 1)
+	assert.Equal(t, 2, problems[0].Line)
+	assert.Equal(t, 37, problems[0].Column)
+
+	_, _, _, problems = decodePlan([]byte(`{"root": {`))
+	assert.Len(t, problems, 1)
+	assert.Equal(t, "unexpected end of file", problems[0].Message)
+
+	nodes, _, _, problems := decodePlan([]byte(`["root"]`))
+	assert.Nil(t, nodes)
+	assert.Len(t, problems, 1)
+
+	// the error of a node does not hide the other nodes
+	nodes, offsets, broken, problems := decodePlan([]byte("{\n  \"root\": {\"name\": \"dummy-scenario\", \"retries\": \"two\"},\n  \"child\": {\"name\": \"dummy-scenario\", \"depend_on\": \"root\"}\n}"))
+	assert.Len(t, nodes, 2)
+	assert.True(t, broken["root"])
+	assert.False(t, broken["child"])
+	assert.Equal(t, int64(12), offsets["root"])
+	assert.Len(t, problems, 2)
+	assert.Equal(t, "root", problems[0].NodeID)
+	assert.Equal(t, "retries must be int, got string", problems[0].Message)
+	assert.Equal(t, 2, problems[0].Line)
+	assert.Equal(t, "child", problems[1].NodeID)
+	assert.Contains(t, problems[1].Message, "depend_on")
+	assert.Equal(t, 3, problems[1].Line)
+}
+
+func TestValidatePlan(t *testing.T) {
+	data := []byte(`{
+  "_comment": {"_comment": "plan"},
+  "root": {"name": "dummy-scenario", "env": {"DURATION": "10", "CERBERUS_ENABLED": "true"}, "volumes": {"/tmp": "/home/krkn/scenario.yaml"}},
+  "loop-a": {"name": "dummy-scenario", "depends_on": ["root", "loop-b"], "timeout": "soon"},
+  "loop-b": {"name": "dummy-scenario", "depends_on": "loop-a"},
+  "self": {"name": "dummy-scenario", "depends_on": ["self", "missing"]},
+  "fields": {"name": "dummy-scenario", "depends_on": "root", "env": {"DURATION": "ten", "COLOR": "red"}, "volumes": {"/tmp": "/etc/config"}},
+  "unknown": {"name": "not-a-scenario", "depends_on": "root"}
+}`)
+	nodes, offsets, broken, problems := decodePlan(data)
+	assert.Empty(t, problems)
+
+	messages := func(problems []planProblem) map[string][]string {
+		byNode := make(map[string][]string)
+		for _, problem := range problems {
+			assert.NotZero(t, problem.Line)
+			byNode[problem.NodeID] = append(byNode[problem.NodeID], problem.Message)
+		}
+		return byNode
+	}
+	offline := messages(validatePlan(nodes, offsets, broken, data, orchestratorModels.Podman, nil, nil, nil, true))
+	assert.NotContains(t, offline, "root")
+	assert.NotContains(t, offline, "fields")
+	assert.Len(t, offline["loop-a"], 2)
+	assert.Contains(t, offline["loop-a"][0], "invalid timeout soon")
+	assert.Equal(t, "is part of a dependency cycle between loop-a, loop-b", offline["loop-a"][1])
+	assert.Equal(t, []string{"is part of a dependency cycle between loop-a, loop-b"}, offline["loop-b"])
+	assert.Equal(t, []string{"depends on missing which is not defined in the plan", "depends on itself"}, offline["self"])
+
+	// the kubeconfig is mounted by krknctl
+	online := messages(validatePlan(nodes, offsets, broken, data, orchestratorModels.Podman, validationProvider{}, nil, []string{"/home/krkn/.kube/config"}, false))
+	assert.NotContains(t, online, "root")
+	assert.Equal(t, []string{
+		"required file scenario is not mounted at /home/krkn/scenario.yaml",
+		"environment variable COLOR is not a field of scenario dummy-scenario",
+		"invalid value of DURATION: `value`: 'ten' is not a number",
+		"no file field of scenario dummy-scenario is mounted at /etc/config",
+	}, online["fields"])
+	assert.Equal(t, []string{"scenario not-a-scenario not found"}, online["unknown"])
+	assert.Contains(t, online["self"], "required environment variable DURATION (duration) is not set")
+}
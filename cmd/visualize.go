@@ -33,7 +33,7 @@ func NewVisualizeCommand(scenarioOrchestrator *scenarioorchestrator.ScenarioOrch
 				return fmt.Errorf("required flag \"grafana-password\" not set")
 			}
 
-			(*scenarioOrchestrator).PrintContainerRuntime()
+			(*scenarioOrchestrator).PrintContainerRuntime(os.Stdout)
 
 			var foundKubeconfig *string
 			if kubeconfigPath != "" {
@@ -102,6 +102,8 @@ func NewVisualizeCommand(scenarioOrchestrator *scenarioorchestrator.ScenarioOrch
 			}
 
 			containerName := utils.GenerateContainerName(config, "krkn-visualize", nil)
+			visualizeLabels := utils.NewContainerLabels(config, "")
+			visualizeLabels.Scenario = "krkn-visualize"
 
 			spinner := NewSpinnerWithSuffix(" pulling krkn-visualize image...")
 			spinner.Start()
@@ -117,7 +119,7 @@ func NewVisualizeCommand(scenarioOrchestrator *scenarioorchestrator.ScenarioOrch
 			if err != nil {
 				return err
 			}
-			_, err = (*scenarioOrchestrator).RunAttached(visualizeImage, containerName, environment, false, volumes, os.Stdout, os.Stderr, &commChan, conn, nil, nil, nil)
+			_, err = (*scenarioOrchestrator).RunAttached(visualizeImage, containerName, environment, false, volumes, os.Stdout, os.Stderr, &commChan, conn, nil, nil, &scenarioorchestrator.PodmanCreateOptions{Labels: &visualizeLabels}, nil)
 			if err != nil {
 				return err
 			}
@@ -6,8 +6,7 @@ import (
 	"github.com/krkn-chaos/krknctl/cmd"
 	krknctlconfig "github.com/krkn-chaos/krknctl/pkg/config"
 	providerfactory "github.com/krkn-chaos/krknctl/pkg/provider/factory"
-	scenarioorchestratorfactory "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/factory"
-	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
 	"os"
 )
 
@@ -17,18 +16,9 @@ func main() {
 		fmt.Printf("%s\n", color.New(color.FgHiRed).Sprint("failed to load configuration"))
 		os.Exit(1)
 	}
-	scenarioOrchestratorFactory := scenarioorchestratorfactory.NewScenarioOrchestratorFactory(config)
-	detectedRuntime, err := utils.DetectContainerRuntime(config)
-	if err != nil {
-		fmt.Printf("%s\n", color.New(color.FgHiRed).Sprint("failed to determine container runtime enviroment please install podman or docker and retry"))
-		os.Exit(1)
-	}
-
-	scenarioOrchestrator := scenarioOrchestratorFactory.NewInstance(*detectedRuntime)
-	if scenarioOrchestrator == nil {
-		fmt.Printf("%s\n", color.New(color.FgHiRed).Sprint("failed to build scenario orchestrator instance"))
-		os.Exit(1)
-	}
+	// the scenario orchestrator depends on the runtime flags so it is
+	// built by the root command once cobra has parsed them
+	var scenarioOrchestrator scenarioorchestrator.ScenarioOrchestrator
 	providerFactory := providerfactory.NewProviderFactory(&config)
 
 	cmd.Execute(providerFactory, &scenarioOrchestrator, config)
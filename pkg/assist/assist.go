@@ -96,9 +96,12 @@ func DeployAssistModel(ctx context.Context, orchestrator scenarioorchestrator.Sc
 	}
 
 	// Create PodmanCreateOptions with unified GPU support for both Docker and Podman
+	assistLabels := utils.NewContainerLabels(config, "")
+	assistLabels.Scenario = config.AssistContainerPrefix
 	podmanOpts := &scenarioorchestrator.PodmanCreateOptions{
 		Devices:    devices,
 		GPURequest: gpuRequest,
+		Labels:     &assistLabels,
 	}
 
 	// Use provided spinner for pull progress
@@ -151,7 +154,7 @@ func PerformAssistHealthCheck(containerID string, hostPort string, orchestrator
 
 	for i := 0; i < maxRetries; i++ {
 		// Check if container is still running
-		containers, err := orchestrator.ListRunningContainers(ctx)
+		containers, err := orchestrator.ListRunningContainers(ctx, nil)
 		if err != nil {
 			fmt.Printf("⚠️  warning: failed to list containers: %v\n", err)
 		} else {
@@ -331,7 +334,7 @@ func StartInteractivePrompt(containerID string, hostPort string, orchestrator sc
 					}
 
 					// Show form summary
-					formResult.PrintSummary(allFields)
+					formResult.PrintSummary(os.Stdout, allFields)
 
 					// Execute the scenario with form data
 					err = executeScenario(*response.ScenarioName, scenarioDetail, formResult, orchestrator, ctx, config, scenarioProvider)
@@ -597,6 +600,8 @@ func executeScenario(scenarioName string, scenarioDetail *models.ScenarioDetail,
 
 	// Generate container name
 	containerName := utils.GenerateContainerName(config, scenarioName, nil)
+	scenarioLabels := utils.NewContainerLabels(config, "")
+	scenarioLabels.Scenario = scenarioName
 
 	fmt.Printf("📦 container image: %s\n", scenarioImageURI)
 	fmt.Printf("🏷️  container name: %s\n", containerName)
@@ -617,7 +622,7 @@ func executeScenario(scenarioName string, scenarioDetail *models.ScenarioDetail,
 	}()
 
 	startTime := time.Now()
-	_, err = orchestrator.RunAttached(scenarioImageURI, containerName, environment, false, volumes, os.Stdout, os.Stderr, &commChan, conn, nil, nil, nil)
+	_, err = orchestrator.RunAttached(scenarioImageURI, containerName, environment, false, volumes, os.Stdout, os.Stderr, &commChan, conn, nil, nil, &scenarioorchestrator.PodmanCreateOptions{Labels: &scenarioLabels}, nil)
 	if err != nil {
 		var staterr *utils.ExitError
 		if errors.As(err, &staterr) {
@@ -50,6 +50,10 @@ func (m *MockScenarioOrchestrator) Run(imageURI string, containerName string, en
 	return &m.containerID, nil
 }
 
+func (m *MockScenarioOrchestrator) Logs(*string, orchestratormodels.LogOptions, io.Writer, io.Writer, context.Context) error {
+	return nil
+}
+
 func (m *MockScenarioOrchestrator) Kill(containerID *string, ctx context.Context) error {
 	if m.shouldFailKill {
 		return fmt.Errorf("mock kill failure")
@@ -57,7 +61,7 @@ func (m *MockScenarioOrchestrator) Kill(containerID *string, ctx context.Context
 	return nil
 }
 
-func (m *MockScenarioOrchestrator) ListRunningContainers(ctx context.Context) (*map[int64]orchestratormodels.Container, error) {
+func (m *MockScenarioOrchestrator) ListRunningContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*map[int64]orchestratormodels.Container, error) {
 	if m.shouldFailList {
 		return nil, fmt.Errorf("mock list failure")
 	}
@@ -69,7 +73,7 @@ func (m *MockScenarioOrchestrator) ListRunningContainers(ctx context.Context) (*
 }
 
 // Implement other required methods (empty implementations for test)
-func (m *MockScenarioOrchestrator) PrintContainerRuntime() {}
+func (m *MockScenarioOrchestrator) PrintContainerRuntime(io.Writer) {}
 func (m *MockScenarioOrchestrator) GetContainerRuntime() orchestratormodels.ContainerRuntime {
 	return orchestratormodels.Podman
 }
@@ -80,19 +84,30 @@ func (m *MockScenarioOrchestrator) GetContainerRuntimeSocket(*int) (*string, err
 func (m *MockScenarioOrchestrator) Connect(string) (context.Context, error) {
 	return context.Background(), nil
 }
-func (m *MockScenarioOrchestrator) RunAttached(string, string, map[string]string, bool, map[string]string, io.Writer, io.Writer, *chan *string, context.Context, *models.RegistryV2, []string, *scenarioorchestrator.PodmanCreateOptions) (*string, error) {
+func (m *MockScenarioOrchestrator) RunAttached(string, string, map[string]string, bool, map[string]string, io.Writer, io.Writer, *chan *string, context.Context, *models.RegistryV2, []string, *scenarioorchestrator.PodmanCreateOptions, *time.Duration) (*string, error) {
 	return nil, nil
 }
-func (m *MockScenarioOrchestrator) RunGraph(orchestratormodels.ScenarioSet, orchestratormodels.ResolvedGraph, map[string]string, map[string]string, bool, chan *orchestratormodels.GraphCommChannel, *models.RegistryV2, *int) {
+func (m *MockScenarioOrchestrator) RunGraph(orchestratormodels.ScenarioSet, orchestratormodels.ResolvedGraph, map[string]string, map[string]string, io.Writer, orchestratormodels.PullPolicy, orchestratormodels.Schedule, chan *orchestratormodels.GraphCommChannel, context.Context, *models.RegistryV2, *int, *orchestratormodels.ContainerLabels, string) {
+}
+func (m *MockScenarioOrchestrator) PullImage(string, orchestratormodels.PullPolicy, *models.RegistryV2, *chan *string, context.Context) error {
+	return nil
+}
+func (m *MockScenarioOrchestrator) CleanContainers(context.Context, *orchestratormodels.ContainerLabels) (*int, error) {
+	return nil, nil
+}
+func (m *MockScenarioOrchestrator) ListFinishedContainers(context.Context, *orchestratormodels.ContainerLabels) (*[]orchestratormodels.Container, error) {
+	return nil, nil
+}
+func (m *MockScenarioOrchestrator) RemoveContainer(*string, context.Context) error {
+	return nil
 }
-func (m *MockScenarioOrchestrator) CleanContainers(context.Context) (*int, error) { return nil, nil }
 func (m *MockScenarioOrchestrator) AttachWait(*string, io.Writer, io.Writer, context.Context) (*bool, error) {
 	return nil, nil
 }
 func (m *MockScenarioOrchestrator) Attach(*string, chan os.Signal, io.Writer, io.Writer, context.Context) (bool, error) {
 	return false, nil
 }
-func (m *MockScenarioOrchestrator) ListRunningScenarios(context.Context) (*[]orchestratormodels.ScenarioContainer, error) {
+func (m *MockScenarioOrchestrator) ListRunningScenarios(context.Context, *orchestratormodels.ContainerLabels) (*[]orchestratormodels.ScenarioContainer, error) {
 	return nil, nil
 }
 func (m *MockScenarioOrchestrator) InspectScenario(orchestratormodels.Container, context.Context) (*orchestratormodels.ScenarioContainer, error) {
@@ -22,6 +22,12 @@ type Config struct {
 	CustomDomainHost                 string `json:"custom_domain_host"`
 	PrivateRegistryBaseImageTag      string `json:"private_registry_base_image_tag"`
 	ContainerPrefix                  string `json:"container_prefix"`
+	LabelRunID                       string `json:"label_run_id"`
+	LabelGraphHash                   string `json:"label_graph_hash"`
+	LabelNodeID                      string `json:"label_node_id"`
+	LabelScenario                    string `json:"label_scenario"`
+	LabelVersion                     string `json:"label_version"`
+	LabelUser                        string `json:"label_user"`
 	KubeconfigPrefix                 string `json:"kubeconfig_prefix"`
 	PodmanDarwinSocketTemplate       string `json:"podman_darwin_socket_template"`
 	PodmanLinuxSocketTemplate        string `json:"podman_linux_socket_template"`
@@ -29,11 +35,26 @@ type Config struct {
 	PodmanRunningState               string `json:"podman_running_state"`
 	DockerSocketRoot                 string `json:"docker_socket_root"`
 	DockerRunningState               string `json:"docker_running_state"`
+	DockerRemoteSocket               string `json:"docker_remote_socket"`
+	ContainerHost                    string `json:"container_host"`
+	ContainerCertPath                string `json:"container_cert_path"`
+	ContainerIdentity                string `json:"container_identity"`
+	EnvContainerHost                 string `json:"env_container_host"`
+	EnvDockerHost                    string `json:"env_docker_host"`
+	EnvContainerCertPath             string `json:"env_container_cert_path"`
+	EnvContainerIdentity             string `json:"env_container_identity"`
 	DefaultContainerPlatform         string `json:"default_container_platform"`
+	KubernetesNamespace              string `json:"kubernetes_namespace"`
+	KubernetesKubeconfig             string `json:"kubernetes_kubeconfig"`
+	KubernetesManagedByLabel         string `json:"kubernetes_managed_by_label"`
+	KubernetesNameAnnotation         string `json:"kubernetes_name_annotation"`
+	KubernetesVolumesAnnotation      string `json:"kubernetes_volumes_annotation"`
+	KubernetesRunningState           string `json:"kubernetes_running_state"`
 	MetricsProfilePath               string `json:"metrics_profile_path"`
 	AlertsProfilePath                string `json:"alerts_profile_path"`
 	KubeconfigPath                   string `json:"kubeconfig_path"`
-	RandomGraphPath                  string `json:"random_graph_path"`
+	RunsFolder                       string `json:"runs_folder"`
+	HistoryFile                      string `json:"history_file"`
 	LabelTitle                       string `json:"label_title"`
 	LabelDescription                 string `json:"label_description"`
 	LabelInputFields                 string `json:"label_input_fields"`
@@ -56,6 +77,7 @@ type Config struct {
 	EnvPrivateRegistryInsecure       string `json:"env_private_registry_insecure"`
 	EnvResiliencyEnabledMode         string `json:"env_resiliency_enabled_mode"`
 	EnvKrknAlertsYamlContent         string `json:"env_krkn_alerts_yaml_content"`
+	EnvRollback                      string `json:"env_rollback"`
 	GithubLatestRelease              string `json:"github_latest_release"`
 	GithubLatestReleaseAPI           string `json:"github_latest_release_api"`
 	GithubReleaseAPI                 string `json:"github_release_api"`
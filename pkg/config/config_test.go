@@ -188,6 +188,8 @@ func TestConfigJSONStructure(t *testing.T) {
 		config.PrivateRegistryBaseImageTag,
 		config.ContainerPrefix,
 		config.KubeconfigPrefix,
+		config.RunsFolder,
+		config.HistoryFile,
 		config.PodmanDarwinSocketTemplate,
 		config.PodmanLinuxSocketTemplate,
 		config.PodmanSocketRoot,
@@ -217,6 +219,7 @@ func TestConfigEnvironmentVariableFields(t *testing.T) {
 		config.EnvPrivateRegistryToken:     "KRKNCTL_PRIVATE_REGISTRY_TOKEN",
 		config.EnvPrivateRegistryScenarios: "KRKNCTL_PRIVATE_REGISTRY_SCENARIOS",
 		config.EnvPrivateRegistryInsecure:  "KRKNCTL_PRIVATE_REGISTRY_INSECURE",
+		config.EnvRollback:                 "ROLLBACK",
 	}
 
 	for actual, expected := range envFields {
@@ -1,22 +1,105 @@
 // Package dependencygraph provides the algorithm and the data structures needed to solve the tool dependency graph
 package dependencygraph
 
-import "github.com/kendru/darwin/go/depgraph"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kendru/darwin/go/depgraph"
+)
 
 type ParentProvider interface {
-	GetParent() *string
+	GetParents() []string
 }
 
+// NewGraphFromNodes builds the graph adding an edge from every node to each of its parents,
+// a parent that is not one of the nodes is rejected
 func NewGraphFromNodes(nodes map[string]ParentProvider) (*depgraph.Graph, error) {
 	graph := depgraph.New()
-	for k, v := range nodes {
-		if parent := v.GetParent(); parent != nil {
-			err := graph.DependOn(k, *parent)
+	ids := make([]string, 0, len(nodes))
+	for k := range nodes {
+		ids = append(ids, k)
+	}
+	// the first error reported does not depend on the map order
+	sort.Strings(ids)
+	for _, k := range ids {
+		for _, parent := range nodes[k].GetParents() {
+			if _, ok := nodes[parent]; !ok {
+				return nil, fmt.Errorf("scenario %s depends on %s which is not defined in the plan", k, parent)
+			}
+			err := graph.DependOn(k, parent)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("scenario %s can't depend on %s: %w", k, parent, err)
 			}
 		}
 	}
 
 	return graph, nil
 }
+
+// FindCycles returns the groups of nodes that depend on each other, a node depending on
+// itself is a group of one node. The parents that are not one of the nodes are ignored,
+// the nodes of each group and the groups are sorted.
+func FindCycles(nodes map[string]ParentProvider) [][]string {
+	ids := make([]string, 0, len(nodes))
+	for k := range nodes {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+
+	// Tarjan's algorithm, every strongly connected component with more than one
+	// node or with a self reference is a cycle
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		lowLink[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		selfReference := false
+		for _, parent := range nodes[id].GetParents() {
+			if _, ok := nodes[parent]; !ok {
+				continue
+			}
+			if parent == id {
+				selfReference = true
+			}
+			if _, visited := index[parent]; !visited {
+				visit(parent)
+				lowLink[id] = min(lowLink[id], lowLink[parent])
+			} else if onStack[parent] {
+				lowLink[id] = min(lowLink[id], index[parent])
+			}
+		}
+		if lowLink[id] != index[id] {
+			return
+		}
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == id {
+				break
+			}
+		}
+		if len(component) > 1 || selfReference {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+	for _, id := range ids {
+		if _, visited := index[id]; !visited {
+			visit(id)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
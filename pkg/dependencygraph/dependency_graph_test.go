@@ -13,8 +13,11 @@ type GenericNode struct {
 	Parent *string `json:"depends_on"`
 }
 
-func (g *GenericNode) GetParent() *string {
-	return g.Parent
+func (g GenericNode) GetParents() []string {
+	if g.Parent == nil {
+		return nil
+	}
+	return []string{*g.Parent}
 }
 
 type NodeList map[string]GenericNode
@@ -239,8 +242,8 @@ func TestDependencyGraph(t *testing.T) {
 	assert.Nil(t, err)
 
 	for k, v := range testStruct {
-		if parent := v.GetParent(); parent != nil {
-			err := graph.DependOn(k, *parent)
+		for _, parent := range v.GetParents() {
+			err := graph.DependOn(k, parent)
 			assert.Nil(t, err)
 		}
 
@@ -251,3 +254,58 @@ func TestDependencyGraph(t *testing.T) {
 	}
 
 }
+
+type MultiParentNode []string
+
+func (m MultiParentNode) GetParents() []string {
+	return m
+}
+
+func TestNewGraphFromNodes(t *testing.T) {
+	// node-drain waits for both the branches
+	nodes := map[string]ParentProvider{
+		"root":        MultiParentNode{},
+		"etcd-kill":   MultiParentNode{"root"},
+		"api-outage":  MultiParentNode{"root"},
+		"slow-branch": MultiParentNode{"api-outage"},
+		"node-drain":  MultiParentNode{"etcd-kill", "slow-branch"},
+	}
+	graph, err := NewGraphFromNodes(nodes)
+	assert.Nil(t, err)
+	layers := graph.TopoSortedLayers()
+	assert.Equal(t, 4, len(layers))
+	assert.Equal(t, []string{"node-drain"}, layers[3])
+	assert.True(t, graph.DependsOn("node-drain", "etcd-kill"))
+	assert.True(t, graph.DependsOn("node-drain", "api-outage"))
+
+	nodes["node-drain"] = MultiParentNode{"etcd-kill", "missing"}
+	_, err = NewGraphFromNodes(nodes)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "node-drain depends on missing")
+
+	nodes["node-drain"] = MultiParentNode{"node-drain"}
+	_, err = NewGraphFromNodes(nodes)
+	assert.NotNil(t, err)
+
+	nodes["node-drain"] = MultiParentNode{"etcd-kill"}
+	nodes["root"] = MultiParentNode{"node-drain"}
+	_, err = NewGraphFromNodes(nodes)
+	assert.NotNil(t, err)
+}
+
+func TestFindCycles(t *testing.T) {
+	nodes := map[string]ParentProvider{
+		"root":       MultiParentNode{},
+		"a":          MultiParentNode{"root", "c"},
+		"b":          MultiParentNode{"a"},
+		"c":          MultiParentNode{"b"},
+		"self":       MultiParentNode{"root", "self"},
+		"unknown":    MultiParentNode{"missing"},
+		"after-loop": MultiParentNode{"c"},
+	}
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"self"}}, FindCycles(nodes))
+
+	delete(nodes, "self")
+	nodes["a"] = MultiParentNode{"root"}
+	assert.Empty(t, FindCycles(nodes))
+}
@@ -6,6 +6,7 @@ package forms
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/fatih/color"
@@ -208,14 +209,14 @@ func (f *Form) processItem(item FormPromptItem, result *FormResult) error {
 	return nil
 }
 
-// PrintSummary prints a summary of the collected form values
-func (r *FormResult) PrintSummary(fields []typing.InputField) {
+// PrintSummary prints a summary of the collected form values to w
+func (r *FormResult) PrintSummary(w io.Writer, fields []typing.InputField) {
 	if len(r.Values) == 0 {
-		fmt.Println("No values collected.")
+		_, _ = fmt.Fprintln(w, "No values collected.")
 		return
 	}
 
-	fmt.Printf("\n%s\n", color.New(color.FgCyan, color.Bold, color.Underline).Sprint("📋 Form Summary"))
+	_, _ = fmt.Fprintf(w, "\n%s\n", color.New(color.FgCyan, color.Bold, color.Underline).Sprint("📋 Form Summary"))
 
 	// Create a map for quick field lookup by Variable name
 	fieldMap := make(map[string]*typing.InputField)
@@ -243,11 +244,11 @@ func (r *FormResult) PrintSummary(fields []typing.InputField) {
 			displayValue = *value
 		}
 
-		fmt.Printf("  %s: %s\n",
+		_, _ = fmt.Fprintf(w, "  %s: %s\n",
 			color.New(color.FgYellow).Sprint(displayName),
 			displayValue)
 	}
-	fmt.Println()
+	_, _ = fmt.Fprintln(w)
 }
 
 // GetValue retrieves a validated value by variable name (environment variable name)
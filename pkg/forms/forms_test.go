@@ -4,6 +4,7 @@
 package forms
 
 import (
+	"io"
 	"testing"
 
 	"github.com/krkn-chaos/krknctl/pkg/typing"
@@ -425,7 +426,7 @@ func TestFormResultPrintSummary(t *testing.T) {
 
 		// Should not panic with empty values
 		assert.NotPanics(t, func() {
-			result.PrintSummary(fields)
+			result.PrintSummary(io.Discard, fields)
 		})
 	})
 
@@ -442,7 +443,7 @@ func TestFormResultPrintSummary(t *testing.T) {
 
 		// Should not panic when result contains variables not in fields
 		assert.NotPanics(t, func() {
-			result.PrintSummary(fields)
+			result.PrintSummary(io.Discard, fields)
 		})
 	})
 
@@ -461,7 +462,7 @@ func TestFormResultPrintSummary(t *testing.T) {
 
 		// Should not panic with nil values
 		assert.NotPanics(t, func() {
-			result.PrintSummary(fields)
+			result.PrintSummary(io.Discard, fields)
 		})
 	})
 }
\ No newline at end of file
@@ -0,0 +1,272 @@
+// Package graphrender draws the execution plan of a dependency graph as a DOT, Mermaid, ASCII or SVG diagram
+package graphrender
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+)
+
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
+	FormatASCII   Format = "ascii"
+	FormatSVG     Format = "svg"
+)
+
+// ParseFormat validates the diagram format
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatDOT, FormatMermaid, FormatASCII, FormatSVG:
+		return Format(format), nil
+	}
+	return "", fmt.Errorf("unsupported format %q, supported values are %s, %s, %s, %s", format, FormatDOT, FormatMermaid, FormatASCII, FormatSVG)
+}
+
+// Outcome is the result of a node in a past run, the nodes are colored by their outcome
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+	OutcomeKilled    Outcome = "killed"
+	OutcomeSkipped   Outcome = "skipped"
+	OutcomeNotRun    Outcome = "not run"
+	// OutcomeUnknown is the outcome of the nodes started by a run that did not record their end (e.g. detached)
+	OutcomeUnknown Outcome = "unknown"
+)
+
+// Node is a scenario of the plan as drawn in the diagram
+type Node struct {
+	ID string
+	// Label is the scenario name followed by the key environment variables, one per line
+	Label   []string
+	Parents []string
+	// When is the condition on the parents, drawn on the edges
+	When models.Condition
+	// Outcome is empty if the diagram does not show a run
+	Outcome Outcome
+}
+
+// Plan is the execution plan to draw, Layers are the steps of the plan as resolved by TopoSortedLayers
+type Plan struct {
+	Layers [][]string
+	Nodes  map[string]Node
+}
+
+// NewPlan builds the plan of the resolved layers, the nodes are labeled with the scenario
+// name and the value of the labelEnv variables they set
+func NewPlan(nodes map[string]models.ScenarioNode, layers [][]string, labelEnv []string) Plan {
+	plan := Plan{Nodes: make(map[string]Node)}
+	for _, layer := range layers {
+		// TopoSortedLayers does not sort the nodes of a layer
+		sorted := append([]string{}, layer...)
+		sort.Strings(sorted)
+		plan.Layers = append(plan.Layers, sorted)
+		for _, id := range sorted {
+			scenarioNode := nodes[id]
+			node := Node{ID: id, Label: []string{scenarioNode.Name}, Parents: scenarioNode.GetParents(), When: scenarioNode.When}
+			for _, key := range labelEnv {
+				if value, ok := scenarioNode.Env[key]; ok {
+					node.Label = append(node.Label, fmt.Sprintf("%s=%s", key, value))
+				}
+			}
+			plan.Nodes[id] = node
+		}
+	}
+	return plan
+}
+
+// SetOutcomes colors the nodes by their outcome in a run, the nodes missing from outcomes are not run
+func (p *Plan) SetOutcomes(outcomes map[string]Outcome) {
+	for id, node := range p.Nodes {
+		node.Outcome = OutcomeNotRun
+		if outcome, ok := outcomes[id]; ok {
+			node.Outcome = outcome
+		}
+		p.Nodes[id] = node
+	}
+}
+
+// Render writes the diagram of the plan in the format
+func Render(w io.Writer, plan Plan, format Format) error {
+	switch format {
+	case FormatDOT:
+		return DOT(w, plan)
+	case FormatMermaid:
+		return Mermaid(w, plan)
+	case FormatASCII:
+		return ASCII(w, plan)
+	case FormatSVG:
+		return SVG(w, plan)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+// palette is the fill and stroke color of every outcome, the nodes without outcome use the first entry
+var palette = map[Outcome][2]string{
+	"":               {"#e3f2fd", "#1565c0"},
+	OutcomeSucceeded: {"#c8e6c9", "#2e7d32"},
+	OutcomeFailed:    {"#ffcdd2", "#c62828"},
+	OutcomeKilled:    {"#ffe0b2", "#ef6c00"},
+	OutcomeSkipped:   {"#fff9c4", "#f9a825"},
+	OutcomeNotRun:    {"#eeeeee", "#757575"},
+	OutcomeUnknown:   {"#e1f5fe", "#0277bd"},
+}
+
+// edges returns the parent of every edge of the plan followed by the child, the parents
+// that are not in the plan are ignored
+func (p Plan) edges() [][2]string {
+	var edges [][2]string
+	for _, layer := range p.Layers {
+		for _, id := range layer {
+			for _, parent := range p.Nodes[id].Parents {
+				if _, ok := p.Nodes[parent]; ok {
+					edges = append(edges, [2]string{parent, id})
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// edgeLabel is the condition drawn on the edges towards the node, empty if the node always runs
+func (n Node) edgeLabel() string {
+	if n.When == "" || n.When == models.WhenAlways {
+		return ""
+	}
+	return string(n.When)
+}
+
+// text is the ID and the label of the node followed by its outcome
+func (n Node) text() []string {
+	text := append([]string{n.ID}, n.Label...)
+	if n.Outcome != "" {
+		text = append(text, fmt.Sprintf("[%s]", n.Outcome))
+	}
+	return text
+}
+
+// DOT writes the plan as a Graphviz digraph, the nodes of a step are drawn on the same rank
+func DOT(w io.Writer, plan Plan) error {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	quote := func(s string) string {
+		return `"` + escape.Replace(s) + `"`
+	}
+	var b strings.Builder
+	b.WriteString("digraph plan {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	for step, layer := range plan.Layers {
+		fmt.Fprintf(&b, "  // step %d\n", step)
+		ids := make([]string, 0, len(layer))
+		for _, id := range layer {
+			node := plan.Nodes[id]
+			colors := palette[node.Outcome]
+			label := make([]string, 0, len(node.text()))
+			for _, line := range node.text() {
+				label = append(label, escape.Replace(line))
+			}
+			fmt.Fprintf(&b, "  %s [label=\"%s\", fillcolor=%s, color=%s];\n", quote(id), strings.Join(label, `\n`), quote(colors[0]), quote(colors[1]))
+			ids = append(ids, quote(id))
+		}
+		fmt.Fprintf(&b, "  { rank=same; %s; }\n", strings.Join(ids, "; "))
+	}
+	for _, edge := range plan.edges() {
+		if label := plan.Nodes[edge[1]].edgeLabel(); label != "" {
+			fmt.Fprintf(&b, "  %s -> %s [label=%s, style=dashed];\n", quote(edge[0]), quote(edge[1]), quote(label))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s;\n", quote(edge[0]), quote(edge[1]))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Mermaid writes the plan as a Mermaid flowchart, the node IDs are replaced by n0, n1... since
+// Mermaid does not accept every character of the scenario IDs
+func Mermaid(w io.Writer, plan Plan) error {
+	escape := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	aliases := make(map[string]string)
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	classes := make(map[Outcome][]string)
+	for _, layer := range plan.Layers {
+		for _, id := range layer {
+			alias := fmt.Sprintf("n%d", len(aliases))
+			aliases[id] = alias
+			node := plan.Nodes[id]
+			label := make([]string, 0, len(node.text()))
+			for _, line := range node.text() {
+				label = append(label, escape.Replace(line))
+			}
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", alias, strings.Join(label, "<br/>"))
+			if node.Outcome != "" {
+				classes[node.Outcome] = append(classes[node.Outcome], alias)
+			}
+		}
+	}
+	for _, edge := range plan.edges() {
+		if label := plan.Nodes[edge[1]].edgeLabel(); label != "" {
+			fmt.Fprintf(&b, "  %s -.->|%s| %s\n", aliases[edge[0]], label, aliases[edge[1]])
+			continue
+		}
+		fmt.Fprintf(&b, "  %s --> %s\n", aliases[edge[0]], aliases[edge[1]])
+	}
+	for _, outcome := range []Outcome{OutcomeSucceeded, OutcomeFailed, OutcomeKilled, OutcomeSkipped, OutcomeNotRun, OutcomeUnknown} {
+		if len(classes[outcome]) == 0 {
+			continue
+		}
+		className := strings.ReplaceAll(string(outcome), " ", "_")
+		colors := palette[outcome]
+		fmt.Fprintf(&b, "  classDef %s fill:%s,stroke:%s\n", className, colors[0], colors[1])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[outcome], ","), className)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// asciiColors are the colors of the outcomes in the terminal
+var asciiColors = map[Outcome]color.Attribute{
+	OutcomeSucceeded: color.FgGreen,
+	OutcomeFailed:    color.FgRed,
+	OutcomeKilled:    color.FgHiRed,
+	OutcomeSkipped:   color.FgYellow,
+	OutcomeNotRun:    color.FgHiBlack,
+	OutcomeUnknown:   color.FgCyan,
+}
+
+// ASCII writes the plan as a tree of steps, every node is followed by the parents it waits for
+func ASCII(w io.Writer, plan Plan) error {
+	var b strings.Builder
+	for step, layer := range plan.Layers {
+		fmt.Fprintf(&b, "step %d\n", step)
+		for i, id := range layer {
+			node := plan.Nodes[id]
+			branch := "├── "
+			if i == len(layer)-1 {
+				branch = "└── "
+			}
+			line := fmt.Sprintf("%s%s: %s", branch, id, strings.Join(node.Label, " "))
+			if len(node.Parents) > 0 {
+				line += fmt.Sprintf(" ← %s", strings.Join(node.Parents, ", "))
+				if label := node.edgeLabel(); label != "" {
+					line += fmt.Sprintf(" (%s)", label)
+				}
+			}
+			if node.Outcome != "" {
+				line += " " + color.New(asciiColors[node.Outcome]).Sprintf("[%s]", node.Outcome)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,81 @@
+package graphrender
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPlan() Plan {
+	nodes := map[string]models.ScenarioNode{
+		"root":    {Scenario: models.Scenario{Name: "pod-scenarios", Env: map[string]string{"NAMESPACE": "openshift-etcd", "DURATION": "60", "KILL_TIMEOUT": "180"}}},
+		"cpu-hog": {Scenario: models.Scenario{Name: "node-cpu-hog", Env: map[string]string{"DURATION": "30"}}, Parents: models.Dependencies{"root"}},
+		"io-hog":  {Scenario: models.Scenario{Name: "node-io-hog"}, Parents: models.Dependencies{"root"}},
+		"cleanup": {Scenario: models.Scenario{Name: "dummy-scenario"}, Parents: models.Dependencies{"root", "io-hog"}, When: models.WhenOnFailure},
+	}
+	return NewPlan(nodes, [][]string{{"root"}, {"io-hog", "cpu-hog"}, {"cleanup"}}, []string{"NAMESPACE", "DURATION"})
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("mermaid")
+	assert.Nil(t, err)
+	assert.Equal(t, FormatMermaid, format)
+	_, err = ParseFormat("png")
+	assert.NotNil(t, err)
+}
+
+func TestNewPlan(t *testing.T) {
+	plan := testPlan()
+	assert.Equal(t, [][]string{{"root"}, {"cpu-hog", "io-hog"}, {"cleanup"}}, plan.Layers)
+	assert.Equal(t, []string{"pod-scenarios", "NAMESPACE=openshift-etcd", "DURATION=60"}, plan.Nodes["root"].Label)
+	assert.Equal(t, [][2]string{{"root", "cpu-hog"}, {"root", "io-hog"}, {"root", "cleanup"}, {"io-hog", "cleanup"}}, plan.edges())
+
+	plan.SetOutcomes(map[string]Outcome{"root": OutcomeSucceeded, "io-hog": OutcomeFailed})
+	assert.Equal(t, OutcomeSucceeded, plan.Nodes["root"].Outcome)
+	assert.Equal(t, OutcomeNotRun, plan.Nodes["cleanup"].Outcome)
+	assert.Equal(t, []string{"io-hog", "node-io-hog", "[failed]"}, plan.Nodes["io-hog"].text())
+}
+
+func TestRender(t *testing.T) {
+	color.NoColor = true
+	plan := testPlan()
+	plan.SetOutcomes(map[string]Outcome{"root": OutcomeSucceeded, "io-hog": OutcomeFailed})
+	render := func(format Format) string {
+		var b strings.Builder
+		assert.Nil(t, Render(&b, plan, format))
+		return b.String()
+	}
+
+	dot := render(FormatDOT)
+	assert.Contains(t, dot, `"root" [label="root\npod-scenarios\nNAMESPACE=openshift-etcd\nDURATION=60\n[succeeded]", fillcolor="#c8e6c9"`)
+	assert.Contains(t, dot, `{ rank=same; "cpu-hog"; "io-hog"; }`)
+	assert.Contains(t, dot, `"io-hog" -> "cleanup" [label="on_failure", style=dashed];`)
+	assert.Contains(t, dot, `"root" -> "cpu-hog";`)
+
+	mermaid := render(FormatMermaid)
+	assert.True(t, strings.HasPrefix(mermaid, "flowchart TD\n"))
+	assert.Contains(t, mermaid, `n1["cpu-hog<br/>node-cpu-hog<br/>DURATION=30<br/>[not run]"]`)
+	assert.Contains(t, mermaid, "n2 -.->|on_failure| n3")
+	assert.Contains(t, mermaid, "class n2 failed")
+	assert.Contains(t, mermaid, "class n1,n3 not_run")
+
+	ascii := render(FormatASCII)
+	assert.Equal(t, `step 0
+└── root: pod-scenarios NAMESPACE=openshift-etcd DURATION=60 [succeeded]
+step 1
+├── cpu-hog: node-cpu-hog DURATION=30 ← root [not run]
+└── io-hog: node-io-hog ← root [failed]
+step 2
+└── cleanup: dummy-scenario ← root, io-hog (on_failure) [not run]
+`, ascii)
+
+	svg := render(FormatSVG)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.Equal(t, 4, strings.Count(svg, "<rect x="))
+	assert.Equal(t, 4, strings.Count(svg, "marker-end="))
+	assert.Contains(t, svg, `fill="#ffcdd2"`)
+	assert.Contains(t, svg, ">on_failure</text>")
+}
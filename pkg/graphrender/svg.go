@@ -0,0 +1,111 @@
+package graphrender
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// layout of the SVG diagram in pixels, the text is monospace so its width is known
+const (
+	svgMargin     = 20
+	svgStepWidth  = 60
+	svgCharWidth  = 7
+	svgLineHeight = 16
+	svgPadding    = 10
+	svgHGap       = 30
+	svgVGap       = 60
+	// svgBendSpace is the room on the right of the boxes for the edges skipping a step and their label
+	svgBendSpace = 80
+)
+
+// SVG writes the plan as a standalone SVG image, a row for every step with the nodes
+// centered on it and the edges drawn from the parents to the children
+func SVG(w io.Writer, plan Plan) error {
+	// every box has the size of the largest one so that the rows are aligned
+	maxChars, maxLines, maxNodes := 0, 0, 0
+	for _, layer := range plan.Layers {
+		maxNodes = max(maxNodes, len(layer))
+		for _, id := range layer {
+			text := plan.Nodes[id].text()
+			maxLines = max(maxLines, len(text))
+			for _, line := range text {
+				maxChars = max(maxChars, len([]rune(line)))
+			}
+		}
+	}
+	boxWidth := maxChars*svgCharWidth + 2*svgPadding
+	boxHeight := maxLines*svgLineHeight + 2*svgPadding
+	width := 2*svgMargin + svgStepWidth + maxNodes*(boxWidth+svgHGap) - svgHGap + svgBendSpace
+	height := 2*svgMargin + len(plan.Layers)*(boxHeight+svgVGap) - svgVGap
+	if len(plan.Layers) == 0 {
+		width, height = 2*svgMargin, 2*svgMargin
+	}
+
+	type point struct{ x, y int }
+	positions := make(map[string]point)
+	steps := make(map[string]int)
+	for step, layer := range plan.Layers {
+		offset := (maxNodes - len(layer)) * (boxWidth + svgHGap) / 2
+		for i, id := range layer {
+			steps[id] = step
+			positions[id] = point{
+				x: svgMargin + svgStepWidth + offset + i*(boxWidth+svgHGap),
+				y: svgMargin + step*(boxHeight+svgVGap),
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n", width, height, width, height)
+	b.WriteString(`  <defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#616161"/></marker></defs>` + "\n")
+	b.WriteString(`  <rect width="100%" height="100%" fill="#ffffff"/>` + "\n")
+	for step := range plan.Layers {
+		y := svgMargin + step*(boxHeight+svgVGap) + boxHeight/2
+		fmt.Fprintf(&b, `  <text x="%d" y="%d" fill="#616161" dominant-baseline="middle">step %d</text>`+"\n", svgMargin, y, step)
+	}
+	for _, edge := range plan.edges() {
+		from, to := positions[edge[0]], positions[edge[1]]
+		x1, y1 := from.x+boxWidth/2, from.y+boxHeight
+		x2, y2 := to.x+boxWidth/2, to.y
+		// the edges skipping a step bow on the right to not cross the boxes in between
+		bend := 0
+		if steps[edge[1]]-steps[edge[0]] > 1 {
+			bend = boxWidth*7/10 + svgHGap
+		}
+		dash := ""
+		label := plan.Nodes[edge[1]].edgeLabel()
+		if label != "" {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `  <path d="M %d %d C %d %d, %d %d, %d %d" fill="none" stroke="#616161"%s marker-end="url(#arrow)"/>`+"\n",
+			x1, y1, x1+bend, y1+svgVGap/2, x2+bend, y2-svgVGap/2, x2, y2, dash)
+		if label != "" {
+			fmt.Fprintf(&b, `  <text x="%d" y="%d" fill="#616161" text-anchor="middle">%s</text>`+"\n", (x1+x2)/2+bend*3/4, (y1+y2)/2, html.EscapeString(label))
+		}
+	}
+	for _, layer := range plan.Layers {
+		for _, id := range layer {
+			node := plan.Nodes[id]
+			position := positions[id]
+			colors := palette[node.Outcome]
+			fmt.Fprintf(&b, `  <g><title>%s</title>`+"\n", html.EscapeString(id))
+			fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="%d" height="%d" rx="8" fill="%s" stroke="%s" stroke-width="2"/>`+"\n",
+				position.x, position.y, boxWidth, boxHeight, colors[0], colors[1])
+			for i, line := range node.text() {
+				weight := ""
+				// the first line is the node ID
+				if i == 0 {
+					weight = ` font-weight="bold"`
+				}
+				fmt.Fprintf(&b, `    <text x="%d" y="%d"%s>%s</text>`+"\n",
+					position.x+svgPadding, position.y+svgPadding+(i+1)*svgLineHeight-4, weight, html.EscapeString(line))
+			}
+			b.WriteString("  </g>\n")
+		}
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
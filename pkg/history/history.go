@@ -0,0 +1,293 @@
+// Package history keeps the record of every run in a JSON lines file (~/.krknctl/history.jsonl),
+// it outlives the containers and the run directories and allows a run to be replayed
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
+)
+
+// ReplayPlanFile is the plan with the pinned images written in the directory of the replayed run
+const ReplayPlanFile = "replay-plan.json"
+
+// ErrNotFound is returned by Get when no run matches the ID
+var ErrNotFound = errors.New("run not found in history")
+
+type Record struct {
+	workspace.Metadata
+	RunDir string `json:"run_dir"`
+	// Report is the path of the resiliency report, empty if the run did not produce one
+	Report string `json:"report,omitempty"`
+	// PlanSnapshot is the plan as it has been run, nil for single scenario runs
+	PlanSnapshot map[string]models.ScenarioNode `json:"plan_snapshot,omitempty"`
+	// Images are the images the nodes have been run with, keyed by node ID
+	Images map[string]lockfile.LockedImage `json:"images,omitempty"`
+	Nodes  map[string]NodeResult           `json:"nodes"`
+}
+
+type NodeResult struct {
+	Scenario string `json:"scenario"`
+	Attempts int    `json:"attempts"`
+	LogFile  string `json:"log_file,omitempty"`
+	// ExitStatus is nil if the node has not been run or did not terminate (e.g. skipped or killed by an abort)
+	ExitStatus *int   `json:"exit_status,omitempty"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Aborted    bool   `json:"aborted,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// SkipReason tells why the node has not been run, set only if its when condition is not met
+	SkipReason string `json:"skip_reason,omitempty"`
+	// Env and Volumes are the environment and the volume mounts the node is started with,
+	// the values of the Secrets are masked and must be provided again to start the node
+	Env     map[string]string `json:"env,omitempty"`
+	Volumes map[string]string `json:"volumes,omitempty"`
+	Secrets []string          `json:"secrets,omitempty"`
+}
+
+// Environment returns the environment the node has been started with, the masked secrets
+// are looked up in lookupEnv and an error lists the ones that are not found
+func (n NodeResult) Environment(lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	env := make(map[string]string, len(n.Env))
+	for k, v := range n.Env {
+		env[k] = v
+	}
+	var missing []string
+	for _, secret := range n.Secrets {
+		value, ok := lookupEnv(secret)
+		if !ok {
+			missing = append(missing, secret)
+			continue
+		}
+		env[secret] = value
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the secrets %s are not recorded, set them as environment variables", strings.Join(missing, ", "))
+	}
+	return env, nil
+}
+
+// NewRecord returns the record of a run of the plan, the nodes are tracked while the run goes on
+func NewRecord(plan map[string]models.ScenarioNode) *Record {
+	r := Record{PlanSnapshot: plan, Nodes: make(map[string]NodeResult)}
+	for id, node := range plan {
+		// skip _comment
+		if node.Name == "" {
+			continue
+		}
+		r.Nodes[id] = NodeResult{Scenario: node.Name}
+	}
+	return &r
+}
+
+// SetEnvironment records the environment and the volume mounts of the node, the secrets are masked
+func (r *Record) SetEnvironment(nodeID string, env map[string]string, volumes map[string]string, secrets map[string]bool) {
+	node := r.Nodes[nodeID]
+	node.Env = make(map[string]string, len(env))
+	node.Secrets = nil
+	for k, v := range env {
+		if secrets[k] {
+			node.Secrets = append(node.Secrets, k)
+			v = utils.MaskString(v)
+		}
+		node.Env[k] = v
+	}
+	sort.Strings(node.Secrets)
+	node.Volumes = make(map[string]string, len(volumes))
+	for k, v := range volumes {
+		node.Volumes[k] = v
+	}
+	r.Nodes[nodeID] = node
+}
+
+// NodeStarted records a new attempt of the node
+func (r *Record) NodeStarted(nodeID string, scenario string, logFile string, attempt int) {
+	node := r.Nodes[nodeID]
+	if scenario != "" {
+		node.Scenario = scenario
+	}
+	node.Attempts = attempt
+	node.LogFile = logFile
+	node.ExitStatus = nil
+	node.Error = ""
+	r.Nodes[nodeID] = node
+}
+
+// NodeFailed records the failure of the last attempt of the node
+func (r *Record) NodeFailed(nodeID string, err error) {
+	node := r.Nodes[nodeID]
+	node.Error = err.Error()
+	var abortErr *utils.AbortError
+	var exitErr *utils.ExitError
+	var timeoutErr *utils.TimeoutError
+	switch {
+	case errors.As(err, &abortErr):
+		node.Aborted = true
+	case errors.As(err, &exitErr):
+		node.ExitStatus = &exitErr.ExitStatus
+	case errors.As(err, &timeoutErr):
+		exitStatus := utils.TimeoutExitStatus
+		node.ExitStatus = &exitStatus
+		node.TimedOut = true
+	}
+	r.Nodes[nodeID] = node
+}
+
+// NodeSkipped records that the node has not been run because its when condition is not met
+func (r *Record) NodeSkipped(nodeID string, reason string) {
+	node := r.Nodes[nodeID]
+	node.SkipReason = reason
+	r.Nodes[nodeID] = node
+}
+
+// Complete fills the record with the outcome of the run stored in the run directory,
+// the nodes started without failures are recorded as succeeded unless the run is detached
+func (r *Record) Complete(ws *workspace.Workspace) {
+	r.Metadata = ws.Metadata
+	r.RunDir = ws.Dir
+	if _, err := os.Stat(ws.ReportPath()); err == nil {
+		r.Report = ws.ReportPath()
+	}
+	if ws.Metadata.Status == workspace.StatusDetached {
+		return
+	}
+	for id, node := range r.Nodes {
+		if node.Attempts > 0 && node.ExitStatus == nil && node.Error == "" && !node.Aborted {
+			succeeded := 0
+			node.ExitStatus = &succeeded
+			r.Nodes[id] = node
+		}
+	}
+}
+
+// Replayable tells if the run can be replayed, only the graph based runs keep their plan
+func (r *Record) Replayable() bool {
+	return len(r.PlanSnapshot) > 0
+}
+
+// ReplayPlan returns the plan of the run with the images pinned to the digests
+// they had when the plan was run, the nodes whose digest is unknown keep their image
+func (r *Record) ReplayPlan() (map[string]models.ScenarioNode, []string, error) {
+	if !r.Replayable() {
+		return nil, nil, fmt.Errorf("run %s has no plan to replay, only graph and random runs can be replayed", r.RunID)
+	}
+	plan := make(map[string]models.ScenarioNode, len(r.PlanSnapshot))
+	var unpinned []string
+	for id, node := range r.PlanSnapshot {
+		if node.Name != "" {
+			if image, ok := r.Images[id]; ok && image.Pinned != "" {
+				node.Image = image.Pinned
+			} else {
+				unpinned = append(unpinned, id)
+			}
+		}
+		plan[id] = node
+	}
+	sort.Strings(unpinned)
+	return plan, unpinned, nil
+}
+
+type Store struct {
+	Path string
+}
+
+func NewStore(filePath string) *Store {
+	return &Store{Path: filePath}
+}
+
+// Append adds the record to the history
+func (s *Store) Append(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Clean(s.Path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// List returns the runs sorted by start time, if a run has been recorded
+// more than once the last record wins. A missing history has no runs.
+func (s *Store) List() ([]Record, error) {
+	file, err := os.Open(filepath.Clean(s.Path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	index := make(map[string]int)
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	// the plan snapshots can be far longer than the default token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid history record at %s:%d: %w", s.Path, line, err)
+		}
+		if i, ok := index[record.RunID]; ok {
+			records[i] = record
+			continue
+		}
+		index[record.RunID] = len(records)
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Started.Before(records[j].Started)
+	})
+	return records, nil
+}
+
+// Get returns the run with the ID, a unique prefix of the ID is enough
+func (s *Store) Get(runID string) (*Record, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Record
+	for _, record := range records {
+		if record.RunID == runID {
+			return &record, nil
+		}
+		if runID != "" && strings.HasPrefix(record.RunID, runID) {
+			matches = append(matches, record)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, runID)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("run ID prefix %s is ambiguous, it matches %d runs", runID, len(matches))
+	}
+}
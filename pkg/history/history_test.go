@@ -0,0 +1,154 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/lockfile"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/krkn-chaos/krknctl/pkg/workspace"
+	"github.com/stretchr/testify/assert"
+)
+
+const podDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+func testPlan() map[string]models.ScenarioNode {
+	return map[string]models.ScenarioNode{
+		"_comment": {},
+		"pods":     {Scenario: models.Scenario{Name: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}},
+		"cpu":      {Scenario: models.Scenario{Name: "node-cpu-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"}},
+		"memory":   {Scenario: models.Scenario{Name: "node-memory-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-memory-hog"}},
+	}
+}
+
+func TestRecord(t *testing.T) {
+	record := NewRecord(testPlan())
+	assert.Len(t, record.Nodes, 3)
+	record.NodeStarted("pods", "", "pods.log", 1)
+	record.NodeStarted("cpu", "", "cpu-1.log", 1)
+	record.NodeFailed("cpu", &utils.ExitError{ExitStatus: 2})
+	record.NodeStarted("cpu", "", "cpu-2.log", 2)
+	record.NodeFailed("cpu", &utils.TimeoutError{Timeout: time.Minute})
+	record.NodeSkipped("memory", "runs on success but cpu failed")
+
+	ws, err := workspace.New(t.TempDir(), workspace.Metadata{RunID: "run"})
+	assert.Nil(t, err)
+	assert.Nil(t, ws.Finish(workspace.StatusFailed, utils.TimeoutExitStatus, nil))
+	record.Complete(ws)
+	assert.Equal(t, "run", record.RunID)
+	assert.Equal(t, ws.Dir, record.RunDir)
+	assert.Equal(t, "", record.Report)
+
+	assert.Equal(t, 0, *record.Nodes["pods"].ExitStatus)
+	cpu := record.Nodes["cpu"]
+	assert.Equal(t, 2, cpu.Attempts)
+	assert.Equal(t, "cpu-2.log", cpu.LogFile)
+	assert.Equal(t, utils.TimeoutExitStatus, *cpu.ExitStatus)
+	assert.True(t, cpu.TimedOut)
+	// never started
+	assert.Nil(t, record.Nodes["memory"].ExitStatus)
+	assert.Equal(t, 0, record.Nodes["memory"].Attempts)
+	assert.Equal(t, "runs on success but cpu failed", record.Nodes["memory"].SkipReason)
+
+	aborted := NewRecord(testPlan())
+	aborted.NodeStarted("pods", "", "pods.log", 1)
+	aborted.NodeFailed("pods", &utils.AbortError{Cause: utils.ErrInterrupted})
+	aborted.Complete(ws)
+	assert.True(t, aborted.Nodes["pods"].Aborted)
+	assert.Nil(t, aborted.Nodes["pods"].ExitStatus)
+}
+
+func TestRecord_ReplayPlan(t *testing.T) {
+	record := NewRecord(testPlan())
+	record.RunID = "run"
+	record.Images = map[string]lockfile.LockedImage{
+		"pods": {Scenario: "pod-scenarios", Digest: podDigest, Pinned: "quay.io/krkn-chaos/krkn-hub@" + podDigest},
+		"cpu":  {Scenario: "node-cpu-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"},
+	}
+	plan, unpinned, err := record.ReplayPlan()
+	assert.Nil(t, err)
+	assert.Len(t, plan, 4)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub@"+podDigest, plan["pods"].Image)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub:node-cpu-hog", plan["cpu"].Image)
+	assert.Equal(t, []string{"cpu", "memory"}, unpinned)
+	// the recorded plan is left untouched
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub:pod-scenarios", record.PlanSnapshot["pods"].Image)
+
+	_, _, err = NewRecord(nil).ReplayPlan()
+	assert.NotNil(t, err)
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "krknctl", "history.jsonl"))
+	records, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+
+	now := time.Now().UTC()
+	first := NewRecord(testPlan())
+	first.RunID = "a1b2c3"
+	first.Started = now.Add(-time.Hour)
+	second := NewRecord(nil)
+	second.RunID = "a1d4e5"
+	second.Started = now
+	assert.Nil(t, store.Append(*second))
+	assert.Nil(t, store.Append(*first))
+
+	info, err := os.Stat(store.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	records, err = store.List()
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "a1b2c3", records[0].RunID)
+	assert.Equal(t, "a1d4e5", records[1].RunID)
+	assert.Equal(t, "pod-scenarios", records[0].PlanSnapshot["pods"].Name)
+
+	// the last record of a run wins
+	first.Status = workspace.StatusSucceeded
+	assert.Nil(t, store.Append(*first))
+	records, err = store.List()
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, workspace.StatusSucceeded, records[0].Status)
+
+	record, err := store.Get("a1b2c3")
+	assert.Nil(t, err)
+	assert.Equal(t, "a1b2c3", record.RunID)
+	record, err = store.Get("a1d")
+	assert.Nil(t, err)
+	assert.Equal(t, "a1d4e5", record.RunID)
+	_, err = store.Get("a1")
+	assert.NotNil(t, err)
+	_, err = store.Get("ffff")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestRecord_SetEnvironment(t *testing.T) {
+	record := NewRecord(testPlan())
+	env := map[string]string{"NAMESPACE": "default", "ES_PASSWORD": "supersecret"}
+	volumes := map[string]string{"/tmp/kubeconfig": "/home/krkn/.kube/config"}
+	record.SetEnvironment("pods", env, volumes, map[string]bool{"ES_PASSWORD": true})
+	// the recorded environment is a copy
+	env["NAMESPACE"] = "changed"
+	pods := record.Nodes["pods"]
+	assert.Equal(t, "pod-scenarios", pods.Scenario)
+	assert.Equal(t, "default", pods.Env["NAMESPACE"])
+	assert.NotEqual(t, "supersecret", pods.Env["ES_PASSWORD"])
+	assert.Equal(t, []string{"ES_PASSWORD"}, pods.Secrets)
+	assert.Equal(t, volumes, pods.Volumes)
+
+	_, err := pods.Environment(func(string) (string, bool) { return "", false })
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "ES_PASSWORD")
+	restored, err := pods.Environment(func(key string) (string, bool) {
+		return map[string]string{"ES_PASSWORD": "supersecret"}[key], key == "ES_PASSWORD"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"NAMESPACE": "default", "ES_PASSWORD": "supersecret"}, restored)
+}
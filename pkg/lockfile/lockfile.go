@@ -0,0 +1,149 @@
+// Package lockfile pins the scenario images of a graph plan to their manifest digests
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/krkn-chaos/krknctl/pkg/provider"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+)
+
+// Lockfile records the digest every node image tag resolved to when the plan was locked
+type Lockfile struct {
+	Generated time.Time              `json:"generated"`
+	Nodes     map[string]LockedImage `json:"nodes"`
+}
+
+type LockedImage struct {
+	Scenario string `json:"scenario"`
+	Image    string `json:"image"`
+	Digest   string `json:"digest"`
+	// Pinned is the image reference the node runs with when the plan is run locked
+	Pinned string `json:"pinned"`
+}
+
+// Path returns the path of the lockfile of the plan, next to the plan itself (plan.json -> plan.lock.json)
+func Path(planPath string) string {
+	ext := path.Ext(planPath)
+	return strings.TrimSuffix(planPath, ext) + ".lock.json"
+}
+
+// PinnedImage replaces the tag of the image with the digest
+func PinnedImage(image string, digest string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %s: %w", image, err)
+	}
+	pinned, err := reference.ParseNormalizedNamed(reference.TrimNamed(named).String() + "@" + digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest %s for image %s: %w", digest, image, err)
+	}
+	return reference.FamiliarString(pinned), nil
+}
+
+// pinnedDigest returns the digest of the image if it is already pinned (e.g. replayed plans), nil otherwise
+func pinnedDigest(image string) *string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil
+	}
+	digested, ok := named.(reference.Digested)
+	if !ok {
+		return nil
+	}
+	digest := digested.Digest().String()
+	return &digest
+}
+
+// Resolve resolves the image tag of every node of the plan to its current digest
+func Resolve(nodes map[string]orchestratormodels.ScenarioNode, dataProvider provider.ScenarioDataProvider, registry *providermodels.RegistryV2) (*Lockfile, error) {
+	lock := Lockfile{Generated: time.Now().UTC(), Nodes: make(map[string]LockedImage)}
+	for _, id := range nodeIDs(nodes) {
+		node := nodes[id]
+		digest := pinnedDigest(node.Image)
+		if digest == nil {
+			var err error
+			digest, err = dataProvider.GetScenarioDigest(node.Name, registry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve the digest of scenario %s (node %s): %w", node.Name, id, err)
+			}
+		}
+		pinned, err := PinnedImage(node.Image, *digest)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", id, err)
+		}
+		lock.Nodes[id] = LockedImage{Scenario: node.Name, Image: node.Image, Digest: *digest, Pinned: pinned}
+	}
+	return &lock, nil
+}
+
+// Apply checks that the plan and the registry tags still match the lockfile and
+// replaces the image of every node with the pinned one
+func (l *Lockfile) Apply(nodes map[string]orchestratormodels.ScenarioNode, dataProvider provider.ScenarioDataProvider, registry *providermodels.RegistryV2) error {
+	for _, id := range nodeIDs(nodes) {
+		node := nodes[id]
+		locked, ok := l.Nodes[id]
+		if !ok {
+			return fmt.Errorf("node %s is not in the lockfile, lock the plan again", id)
+		}
+		if locked.Scenario != node.Name || locked.Image != node.Image {
+			return fmt.Errorf("node %s image changed from %s to %s since the plan was locked, lock the plan again", id, locked.Image, node.Image)
+		}
+		digest, err := dataProvider.GetScenarioDigest(node.Name, registry)
+		if err != nil {
+			return fmt.Errorf("failed to resolve the digest of scenario %s (node %s): %w", node.Name, id, err)
+		}
+		if *digest != locked.Digest {
+			return fmt.Errorf("tag %s of node %s moved from %s to %s since the plan was locked", node.Image, id, locked.Digest, *digest)
+		}
+		node.Image = locked.Pinned
+		nodes[id] = node
+	}
+	return nil
+}
+
+// Load reads the lockfile
+func Load(filePath string) (*Lockfile, error) {
+	data, err := os.ReadFile(path.Clean(filePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("lockfile %s not found, run graph lock first", filePath)
+		}
+		return nil, err
+	}
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid lockfile %s: %w", filePath, err)
+	}
+	return &lock, nil
+}
+
+// Write writes the lockfile
+func (l *Lockfile) Write(filePath string) error {
+	data, err := json.MarshalIndent(l, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Clean(filePath), append(data, '\n'), 0600)
+}
+
+// nodeIDs returns the sorted IDs of the scenario nodes, _comment is skipped
+func nodeIDs(nodes map[string]orchestratormodels.ScenarioNode) []string {
+	var ids []string
+	for id, node := range nodes {
+		if node.Name == "" {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,97 @@
+package lockfile
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/krkn-chaos/krknctl/pkg/provider"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	podDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+	cpuDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// digestProvider resolves the scenario digests from a map
+type digestProvider struct {
+	provider.ScenarioDataProvider
+	digests map[string]string
+}
+
+func (p *digestProvider) GetScenarioDigest(scenario string, registry *providermodels.RegistryV2) (*string, error) {
+	digest, ok := p.digests[scenario]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &digest, nil
+}
+
+func testNodes() map[string]orchestratormodels.ScenarioNode {
+	return map[string]orchestratormodels.ScenarioNode{
+		"_comment": {},
+		"pods":     {Scenario: orchestratormodels.Scenario{Name: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}},
+		"cpu":      {Scenario: orchestratormodels.Scenario{Name: "node-cpu-hog", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"}},
+	}
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "plans/plan.lock.json", Path("plans/plan.json"))
+	assert.Equal(t, "plan.lock.json", Path("plan"))
+}
+
+func TestPinnedImage(t *testing.T) {
+	pinned, err := PinnedImage("quay.io/krkn-chaos/krkn-hub:pod-scenarios", podDigest)
+	assert.Nil(t, err)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub@"+podDigest, pinned)
+	pinned, err = PinnedImage("localhost:5000/krkn-hub:pod-scenarios", podDigest)
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost:5000/krkn-hub@"+podDigest, pinned)
+	_, err = PinnedImage("quay.io/krkn-chaos/krkn-hub:pod-scenarios", "sha256:nope")
+	assert.NotNil(t, err)
+}
+
+func TestLockfile(t *testing.T) {
+	dataProvider := &digestProvider{digests: map[string]string{"pod-scenarios": podDigest, "node-cpu-hog": cpuDigest}}
+	lock, err := Resolve(testNodes(), dataProvider, nil)
+	assert.Nil(t, err)
+	assert.Len(t, lock.Nodes, 2)
+	assert.Equal(t, podDigest, lock.Nodes["pods"].Digest)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub@"+cpuDigest, lock.Nodes["cpu"].Pinned)
+
+	lockPath := path.Join(t.TempDir(), "plan.lock.json")
+	assert.Nil(t, lock.Write(lockPath))
+	loaded, err := Load(lockPath)
+	assert.Nil(t, err)
+	assert.Equal(t, lock.Nodes, loaded.Nodes)
+
+	nodes := testNodes()
+	assert.Nil(t, loaded.Apply(nodes, dataProvider, nil))
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub@"+podDigest, nodes["pods"].Image)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub@"+cpuDigest, nodes["cpu"].Image)
+
+	// the tag moved in the registry
+	dataProvider.digests["node-cpu-hog"] = podDigest
+	err = loaded.Apply(testNodes(), dataProvider, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "moved")
+
+	// the plan changed after the lock
+	nodes = testNodes()
+	nodes["new"] = orchestratormodels.ScenarioNode{Scenario: orchestratormodels.Scenario{Name: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}}
+	assert.NotNil(t, loaded.Apply(nodes, dataProvider, nil))
+
+	// pinned images are not resolved against the registry
+	lock, err = Resolve(map[string]orchestratormodels.ScenarioNode{"x": {Scenario: orchestratormodels.Scenario{Name: "unknown", Image: "quay.io/krkn-chaos/krkn-hub@" + cpuDigest}}}, dataProvider, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, cpuDigest, lock.Nodes["x"].Digest)
+
+	_, err = Resolve(map[string]orchestratormodels.ScenarioNode{"x": {Scenario: orchestratormodels.Scenario{Name: "unknown", Image: "unknown"}}}, dataProvider, nil)
+	assert.NotNil(t, err)
+
+	_, err = Load(path.Join(t.TempDir(), "missing.lock.json"))
+	assert.NotNil(t, err)
+}
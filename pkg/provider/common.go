@@ -103,7 +103,7 @@ func scaffoldScenarios(scenarios []string, includeGlobalEnv bool, registry *mode
 		// if random is not set dependencies will be set
 		if !random {
 			if i > 0 {
-				scenarioNode.Parent = &indexes[i-1]
+				scenarioNode.Parents = models2.Dependencies{indexes[i-1]}
 			} else {
 				scenarioNode.Comment = config.LabelRootNode
 			}
@@ -227,8 +227,8 @@ func scaffoldSeededScenarios(seed *ScaffoldSeed) (map[string]models2.ScenarioNod
 func GetInstructionScenario(rootNodeName string) models2.ScenarioNode {
 	node := models2.ScenarioNode{}
 	node.Comment = fmt.Sprintf("**READ CAREFULLY** To create your scenario run plan, assign an ID to each scenario definition (or keep the existing randomly assigned ones if preferred). "+
-		"Define dependencies between scenarios using the `depends_on` field, ensuring there are no cycles (including transitive ones) "+
-		"or self-references.Nodes not referenced will not be executed, Nodes without dependencies will run first, "+
+		"Define dependencies between scenarios using the `depends_on` field, a scenario ID or a list of IDs that must all be finished before the scenario starts, ensuring there are no cycles (including transitive ones) "+
+		"or self-references. Set `when` to `on_success` or `on_failure` to run a scenario only if its dependencies succeeded or if one of them failed. Nodes not referenced will not be executed, Nodes without dependencies will run first, "+
 		"while nodes that share the same parent will execute in parallel. [CURRENT ROOT SCENARIO IS `%s`]", rootNodeName)
 	return node
 }
@@ -12,6 +12,7 @@ import (
 
 	"github.com/docker/docker/api/types/registry"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/registryauth"
 	"github.com/krkn-chaos/krknctl/pkg/typing"
 )
 
@@ -69,6 +70,9 @@ func NewRegistryV2FromEnv(config config.Config) (*RegistryV2, error) {
 		registryV2.Insecure = isInsecure
 	}
 
+	if err := registryV2.LoadStoredCredentials(); err != nil {
+		return nil, err
+	}
 	return &registryV2, nil
 
 }
@@ -97,13 +101,25 @@ func (r *RegistryV2) GetV2ScenarioDetailAPIURI(scenario string) (string, error)
 	return registryURL.String(), nil
 }
 
+func (r *RegistryV2) GetV2APIURI() (string, error) {
+	prefix := "http://"
+	if !r.Insecure {
+		prefix = "https://"
+	}
+	registryURL, err := url.Parse(fmt.Sprintf("%s/v2/", prefix+r.RegistryURL))
+	if err != nil {
+		return "", err
+	}
+	return registryURL.String(), nil
+}
+
 func (r *RegistryV2) GetPrivateRegistryURI() string {
 	return fmt.Sprintf("%s/%s", r.RegistryURL, r.ScenarioRepository)
 }
 
 func (r *RegistryV2) ToDockerV2AuthString() (*string, error) {
 	authConfig := registry.AuthConfig{}
-	if r.Token != nil {
+	if r.HasToken() {
 		authConfig.RegistryToken = *r.Token
 		authConfig.ServerAddress = r.RegistryURL
 	} else {
@@ -125,6 +141,50 @@ func (r *RegistryV2) ToDockerV2AuthString() (*string, error) {
 
 }
 
+// LoadStoredCredentials fills the credentials of the registry from the container auth files
+// and credential helpers (see registryauth.Lookup) when none is set by flags or environment
+func (r *RegistryV2) LoadStoredCredentials() error {
+	if r.HasToken() || (r.Username != nil && *r.Username != "") {
+		return nil
+	}
+	credentials, err := registryauth.Lookup(r.GetPrivateRegistryURI(), "")
+	if err != nil || credentials == nil {
+		return err
+	}
+	if credentials.IdentityToken != "" {
+		r.Token = &credentials.IdentityToken
+		return nil
+	}
+	r.Username = &credentials.Username
+	r.Password = &credentials.Password
+	return nil
+}
+
+// HasToken returns true if the registry authenticates with a token, the
+// empty token set by NewRegistryV2FromEnv is ignored
+func (r *RegistryV2) HasToken() bool {
+	return r.Token != nil && *r.Token != ""
+}
+
+// ToPodmanAuthString encodes the token in the X-Registry-Auth format of the Podman API.
+// Podman does not accept bearer tokens, so the token is passed as identity token and
+// exchanged by podman through the registry challenge like the registryv2 provider does
+func (r *RegistryV2) ToPodmanAuthString() (*string, error) {
+	if !r.HasToken() {
+		return nil, nil
+	}
+	authConfig := registry.AuthConfig{
+		IdentityToken: *r.Token,
+		ServerAddress: r.RegistryURL,
+	}
+	encodedJSON, err := json.Marshal(authConfig) // #nosec G117 -- not a hardcoded credential, holds runtime user input
+	if err != nil {
+		return nil, err
+	}
+	authStr := base64.URLEncoding.EncodeToString(encodedJSON)
+	return &authStr, nil
+}
+
 type ScenarioTag struct {
 	Name         string     `json:"name"`
 	Digest       *string    `json:"digest"`
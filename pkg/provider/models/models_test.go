@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	krknctlconfig "github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/typing"
@@ -170,3 +171,25 @@ func TestNewRegistryV2FromEnv(t *testing.T) {
 	assert.Nil(t, registryv2)
 
 }
+
+func TestRegistryV2_ToPodmanAuthString(t *testing.T) {
+	empty := ""
+	registry := RegistryV2{RegistryURL: "registry.example.com", Token: &empty}
+	assert.False(t, registry.HasToken())
+	auth, err := registry.ToPodmanAuthString()
+	assert.Nil(t, err)
+	assert.Nil(t, auth)
+
+	token := "identity-token"
+	registry.Token = &token
+	assert.True(t, registry.HasToken())
+	auth, err = registry.ToPodmanAuthString()
+	assert.Nil(t, err)
+	assert.NotNil(t, auth)
+	decoded, err := base64.URLEncoding.DecodeString(*auth)
+	assert.Nil(t, err)
+	var authConfig map[string]string
+	assert.Nil(t, json.Unmarshal(decoded, &authConfig))
+	assert.Equal(t, "identity-token", authConfig["identitytoken"])
+	assert.Equal(t, "registry.example.com", authConfig["serveraddress"])
+}
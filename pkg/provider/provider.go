@@ -118,6 +118,8 @@ type ScenarioDataProvider interface {
 	GetRegistryImages(registry *models.RegistryV2) (*[]models.ScenarioTag, error)
 	GetGlobalEnvironment(registry *models.RegistryV2, scenario string) (*models.ScenarioDetail, error)
 	GetScenarioDetail(scenario string, registry *models.RegistryV2) (*models.ScenarioDetail, error)
+	// GetScenarioDigest resolves the current manifest digest of the scenario tag
+	GetScenarioDigest(scenario string, registry *models.RegistryV2) (*string, error)
 	ScaffoldScenarios(scenarios []string, includeGlobalEnv bool, registry *models.RegistryV2, random bool, seed *ScaffoldSeed) (*string, error)
 }
 
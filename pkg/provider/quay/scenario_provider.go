@@ -84,6 +84,20 @@ func (p *ScenarioProvider) GetRegistryImages(*models.RegistryV2) (*[]models.Scen
 	return scenarioTags, nil
 }
 
+func (p *ScenarioProvider) GetScenarioDigest(scenario string, registry *models.RegistryV2) (*string, error) {
+	scenarios, err := p.GetRegistryImages(registry)
+	if err != nil {
+		return nil, err
+	}
+	for _, scenarioTag := range *scenarios {
+		if scenarioTag.Name == scenario && scenarioTag.Digest != nil && *scenarioTag.Digest != "" {
+			digest := *scenarioTag.Digest
+			return &digest, nil
+		}
+	}
+	return nil, fmt.Errorf("scenario %s not found", scenario)
+}
+
 func (p *ScenarioProvider) ScaffoldScenarios(scenarios []string, includeGlobalEnv bool, registry *models.RegistryV2, random bool, seed *provider.ScaffoldSeed) (*string, error) {
 	return provider.ScaffoldScenarios(scenarios, includeGlobalEnv, registry, p.Config, p, random, seed)
 }
@@ -189,6 +203,16 @@ func (p *ScenarioProvider) getScenarioDetail(dataSource string, foundScenario *m
 	foundTitle := provider.GetKrknctlLabel(titleLabel, layers)
 	foundDescription := provider.GetKrknctlLabel(descriptionLabel, layers)
 	foundInputFields := provider.GetKrknctlLabel(inputFieldsLabel, layers)
+	if !isGlobalEnvironment {
+		foundHasRollback := provider.GetKrknctlLabel(p.Config.LabelHasRollback, layers)
+		if foundHasRollback != nil {
+			parsedHasRollback, err := p.ParseHasRollback(*foundHasRollback)
+			if err != nil {
+				return nil, err
+			}
+			scenarioDetail.HasRollback = *parsedHasRollback
+		}
+	}
 
 	if foundTitle == nil {
 		return nil, fmt.Errorf("%s LABEL not found in tag: %s digest: %s", strings.Replace(titleLabel, "=", "", 1), foundScenario.Name, *foundScenario.Digest)
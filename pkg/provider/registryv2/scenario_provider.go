@@ -27,9 +27,10 @@ type authChallenge struct {
 
 // tokenResponse represents an OAuth2 token response following Docker Registry v2 spec
 type tokenResponse struct {
-	Token     string    `json:"token"`      // JWT bearer token
-	ExpiresIn int       `json:"expires_in"` // Token lifetime in seconds
-	IssuedAt  time.Time `json:"issued_at"`  // Token issue timestamp
+	Token       string    `json:"token"`        // JWT bearer token
+	AccessToken string    `json:"access_token"` // OAuth2 alias of the bearer token
+	ExpiresIn   int       `json:"expires_in"`   // Token lifetime in seconds
+	IssuedAt    time.Time `json:"issued_at"`    // Token issue timestamp
 }
 
 type ScenarioProvider struct {
@@ -150,8 +151,11 @@ func cacheToken(cache interface{ SetString(string, string) }, cacheKey string, t
 	cache.SetString(cacheKey, string(tokenJSON))
 }
 
-// acquireOAuth2Token requests a bearer token from the OAuth2 endpoint specified in the challenge
-func acquireOAuth2Token(challenge *authChallenge, username, password *string, skipTLS bool) (*tokenResponse, error) {
+// acquireOAuth2Token requests a bearer token from the OAuth2 endpoint specified in the challenge.
+// When the identity token is set it is exchanged with the refresh_token grant (the same
+// exchange podman and docker perform for identity tokens), otherwise the credentials are sent
+// with Basic Auth
+func acquireOAuth2Token(challenge *authChallenge, username, password, identityToken *string, skipTLS bool) (*tokenResponse, error) {
 	// Build token request URL
 	tokenURL, err := url.Parse(challenge.Realm)
 	if err != nil {
@@ -178,13 +182,30 @@ func acquireOAuth2Token(challenge *authChallenge, username, password *string, sk
 		Timeout:   10 * time.Second, // Add timeout
 	}
 
-	// Build request with Basic Auth
-	req, err := http.NewRequest("GET", tokenURL.String(), nil)
-	if err != nil {
-		return nil, err
+	var req *http.Request
+	if identityToken != nil {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", *identityToken)
+		form.Set("client_id", "krknctl")
+		for k, v := range params {
+			form[k] = v
+		}
+		tokenURL.RawQuery = ""
+		req, err = http.NewRequest("POST", tokenURL.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		// Build request with Basic Auth
+		req, err = http.NewRequest("GET", tokenURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if username != nil {
+	if identityToken == nil && username != nil {
 		pwd := ""
 		if password != nil {
 			pwd = *password
@@ -211,6 +232,13 @@ func acquireOAuth2Token(challenge *authChallenge, username, password *string, sk
 		return nil, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return nil, fmt.Errorf("token endpoint returned an empty token")
+	}
+
 	// Set issued_at if not provided
 	if token.IssuedAt.IsZero() {
 		token.IssuedAt = time.Now()
@@ -224,13 +252,69 @@ func acquireOAuth2Token(challenge *authChallenge, username, password *string, sk
 	return &token, nil
 }
 
+// ResolveBearerToken resolves the bearer token the image is pulled with.
+// The token is first sent as a bearer token to the manifest of the image, as queryRegistry does.
+// If the registry challenges it, it is exchanged as an identity token for a token of the image repository.
+// Returns nil if the registry does not authenticate with a token.
+func ResolveBearerToken(registry *models.RegistryV2, image string) (*string, error) {
+	if registry == nil || !registry.HasToken() {
+		return nil, nil
+	}
+	apiURI, err := registry.GetV2APIURI()
+	if err != nil {
+		return nil, err
+	}
+	repository := strings.TrimPrefix(image, registry.RegistryURL+"/")
+	reference := "latest"
+	if i := strings.LastIndex(repository, "@"); i >= 0 {
+		repository, reference = repository[:i], repository[i+1:]
+	} else if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, reference = repository[:i], repository[i+1:]
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: registry.SkipTLS}},
+		Timeout:   30 * time.Second,
+	}
+	req, err := http.NewRequest(http.MethodHead, fmt.Sprintf("%s%s/manifests/%s", apiURI, repository, reference), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", *registry.Token))
+	resp, err := client.Do(req) // #nosec G704 -- URL is built from the user-supplied registry config in a CLI context, not external input
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close() // #nosec G104 -- HEAD response, the body is empty
+	if resp.StatusCode != http.StatusUnauthorized {
+		return registry.Token, nil
+	}
+
+	challenge, err := parseWWWAuthenticate(resp.Header.Get("WWW-Authenticate"))
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed with provided token: %w", err)
+	}
+	token, err := acquireOAuth2Token(challenge, nil, nil, registry.Token, registry.SkipTLS)
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed with provided token: %w", err)
+	}
+	return &token.Token, nil
+}
+
 func (s *ScenarioProvider) queryRegistry(uri string, username *string, password *string, token *string, method string, skipTLS bool) (*[]byte, error) {
+	body, _, err := s.queryRegistryWithHeaders(uri, username, password, token, method, skipTLS, nil)
+	return body, err
+}
+
+// queryRegistryWithHeaders sends the request with the additional headers and returns
+// the response body along with the response headers
+func (s *ScenarioProvider) queryRegistryWithHeaders(uri string, username *string, password *string, token *string, method string, skipTLS bool, headers map[string]string) (*[]byte, http.Header, error) {
 	parsedURL, err := url.Parse(uri)
 	if err != nil {
-		return nil, fmt.Errorf("invalid registry URL %q: %w", uri, err)
+		return nil, nil, fmt.Errorf("invalid registry URL %q: %w", uri, err)
 	}
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return nil, fmt.Errorf("unsupported URL scheme %q in %q", parsedURL.Scheme, uri)
+		return nil, nil, fmt.Errorf("unsupported URL scheme %q in %q", parsedURL.Scheme, uri)
 	}
 
 	tr := &http.Transport{
@@ -253,11 +337,15 @@ func (s *ScenarioProvider) queryRegistry(uri string, username *string, password
 	if currentToken != nil && *currentToken == "" {
 		currentToken = nil
 	}
+	identityToken := currentToken
 
 	for retryCount <= maxRetries {
 		req, err := http.NewRequest(method, parsedURL.String(), nil)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
 		}
 
 		// Set authorization header
@@ -273,32 +361,33 @@ func (s *ScenarioProvider) queryRegistry(uri string, username *string, password
 
 		resp, err := client.Do(req) // #nosec G704 -- URL is validated via url.Parse and scheme-allowlisted to http/https; source is user-supplied registry config in a CLI context, not external input
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Handle 401 Unauthorized with OAuth2 flow
 		if resp.StatusCode == http.StatusUnauthorized && retryCount < maxRetries {
 			_ = resp.Body.Close() // #nosec G104 -- ignoring close error on retry path, body is being discarded
 
-			// Skip OAuth2 flow if user already provided a token
-			if token != nil {
-				return nil, fmt.Errorf("authentication failed with provided token")
-			}
-
 			// Parse WWW-Authenticate challenge
 			wwwAuth := resp.Header.Get("WWW-Authenticate")
 			if wwwAuth == "" {
-				return nil, fmt.Errorf("registry returned 401 without WWW-Authenticate header")
+				if identityToken != nil {
+					return nil, nil, fmt.Errorf("authentication failed with provided token")
+				}
+				return nil, nil, fmt.Errorf("registry returned 401 without WWW-Authenticate header")
 			}
 
 			challenge, err := parseWWWAuthenticate(wwwAuth)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse auth challenge: %w", err)
+				if identityToken != nil {
+					return nil, nil, fmt.Errorf("authentication failed with provided token: %w", err)
+				}
+				return nil, nil, fmt.Errorf("failed to parse auth challenge: %w", err)
 			}
 
 			// Check if credentials are available
-			if username == nil {
-				return nil, fmt.Errorf("authentication required but no credentials provided")
+			if username == nil && identityToken == nil {
+				return nil, nil, fmt.Errorf("authentication required but no credentials provided")
 			}
 
 			// Try to get cached token first
@@ -306,10 +395,14 @@ func (s *ScenarioProvider) queryRegistry(uri string, username *string, password
 			tokenResp := getCachedToken(s.Cache, cacheKey)
 
 			if tokenResp == nil {
-				// Acquire new token
-				tokenResp, err = acquireOAuth2Token(challenge, username, password, skipTLS)
+				// Acquire new token, the provided token is not a bearer token
+				// for the registry so it is exchanged as identity token
+				tokenResp, err = acquireOAuth2Token(challenge, username, password, identityToken, skipTLS)
 				if err != nil {
-					return nil, fmt.Errorf("failed to acquire OAuth2 token: %w", err)
+					if identityToken != nil {
+						return nil, nil, fmt.Errorf("authentication failed with provided token: %w", err)
+					}
+					return nil, nil, fmt.Errorf("failed to acquire OAuth2 token: %w", err)
 				}
 
 				// Cache the token
@@ -329,23 +422,23 @@ func (s *ScenarioProvider) queryRegistry(uri string, username *string, password
 		}()
 
 		if resp.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("image not found: %s", uri)
+			return nil, nil, fmt.Errorf("image not found: %s", uri)
 		}
 
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("URI %s returned %d: %s", uri, resp.StatusCode, string(bodyBytes))
+			return nil, nil, fmt.Errorf("URI %s returned %d: %s", uri, resp.StatusCode, string(bodyBytes))
 		}
 
 		bodyBytes, err := io.ReadAll(resp.Body)
 		if err != nil {
 			log.Fatal(err)
-			return nil, err
+			return nil, nil, err
 		}
-		return &bodyBytes, deferErr
+		return &bodyBytes, resp.Header, deferErr
 	}
 
-	return nil, fmt.Errorf("maximum retry attempts exceeded")
+	return nil, nil, fmt.Errorf("maximum retry attempts exceeded")
 }
 
 func (s *ScenarioProvider) GetGlobalEnvironment(registry *models.RegistryV2, scenario string) (*models.ScenarioDetail, error) {
@@ -409,6 +502,47 @@ func (s *ScenarioProvider) GetScenarioDetail(scenario string, registry *models.R
 	return scenarioDetail, nil
 }
 
+// manifestMediaTypes are accepted when resolving the digest so that the registry returns
+// the same manifest (or manifest list) the container runtimes pull
+var manifestMediaTypes = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+// CheckCredentials verifies the registry credentials querying the V2 API base endpoint
+func (s *ScenarioProvider) CheckCredentials(registry *models.RegistryV2) error {
+	if registry == nil {
+		return errors.New("registry cannot be nil in V2 scenario provider")
+	}
+	registryURI, err := registry.GetV2APIURI()
+	if err != nil {
+		return err
+	}
+	_, err = s.queryRegistry(registryURI, registry.Username, registry.Password, registry.Token, "GET", registry.SkipTLS)
+	return err
+}
+
+func (s *ScenarioProvider) GetScenarioDigest(scenario string, registry *models.RegistryV2) (*string, error) {
+	if registry == nil {
+		return nil, errors.New("registry cannot be nil in V2 scenario provider")
+	}
+	registryURI, err := registry.GetV2ScenarioDetailAPIURI(scenario)
+	if err != nil {
+		return nil, err
+	}
+	_, headers, err := s.queryRegistryWithHeaders(registryURI, registry.Username, registry.Password, registry.Token, "HEAD", registry.SkipTLS, map[string]string{"Accept": manifestMediaTypes})
+	if err != nil {
+		return nil, err
+	}
+	digest := headers.Get("Docker-Content-Digest")
+	if digest == "" {
+		return nil, fmt.Errorf("registry %s did not return the digest of scenario %s", registry.RegistryURL, scenario)
+	}
+	return &digest, nil
+}
+
 func (s *ScenarioProvider) ScaffoldScenarios(scenarios []string, includeGlobalEnv bool, registry *models.RegistryV2, random bool, seed *provider.ScaffoldSeed) (*string, error) {
 	return provider.ScaffoldScenarios(scenarios, includeGlobalEnv, registry, s.Config, s, random, seed)
 }
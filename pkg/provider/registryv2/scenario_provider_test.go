@@ -619,6 +619,53 @@ func TestOAuth2Flow_PreProvidedToken(t *testing.T) {
 	assert.Contains(t, err.Error(), "authentication failed with provided token")
 }
 
+func TestOAuth2Flow_IdentityToken(t *testing.T) {
+	// Mock OAuth2 server exchanging the identity token with the refresh_token grant
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "identity-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		assert.Equal(t, "test-registry", r.FormValue("service"))
+		assert.Equal(t, "repository:test/repo:pull", r.FormValue("scope"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"exchanged-token","expires_in":300}`))
+	}))
+	defer authServer.Close()
+
+	// Mock registry accepting only the exchanged bearer token
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer exchanged-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+authServer.URL+`",service="test-registry",scope="repository:test/repo:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"tags":["latest"]}`))
+	}))
+	defer registryServer.Close()
+
+	config := getConfig(t)
+	p := ScenarioProvider{
+		provider.BaseScenarioProvider{
+			Config: config,
+			Cache:  cache.NewCache(),
+		},
+	}
+
+	// a wrong identity token is rejected by the token endpoint
+	wrongToken := "wrong"
+	_, err := p.queryRegistry(registryServer.URL+"/v2/test/repo/tags/list", nil, nil, &wrongToken, "GET", false)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "authentication failed with provided token")
+
+	identityToken := "identity-token"
+	body, err := p.queryRegistry(registryServer.URL+"/v2/test/repo/tags/list", nil, nil, &identityToken, "GET", false)
+	assert.Nil(t, err)
+	assert.Contains(t, string(*body), "latest")
+}
+
 func TestOAuth2Flow_BackwardCompatibility_BasicAuth(t *testing.T) {
 	// Mock registry that accepts basic auth (no 401)
 	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -678,3 +725,82 @@ func TestOAuth2Flow_No_WWWAuthenticate_Header(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "registry returned 401 without WWW-Authenticate header")
 }
+
+func TestScenarioProvider_GetScenarioDigest(t *testing.T) {
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "HEAD", r.Method)
+		assert.Equal(t, "/v2/krkn-chaos/krkn-hub/manifests/pod-scenarios", r.URL.Path)
+		assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+		w.Header().Set("Docker-Content-Digest", "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer registryServer.Close()
+
+	config := getConfig(t)
+	p := ScenarioProvider{
+		provider.BaseScenarioProvider{
+			Config: config,
+			Cache:  cache.NewCache(),
+		},
+	}
+	registry := models.RegistryV2{
+		RegistryURL:        strings.TrimPrefix(registryServer.URL, "http://"),
+		ScenarioRepository: "krkn-chaos/krkn-hub",
+		Insecure:           true,
+	}
+	digest, err := p.GetScenarioDigest("pod-scenarios", &registry)
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945", *digest)
+
+	_, err = p.GetScenarioDigest("pod-scenarios", nil)
+	assert.NotNil(t, err)
+}
+
+func TestResolveBearerToken(t *testing.T) {
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "identity-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "repository:krkn/krkn-hub:pull", r.FormValue("scope"))
+		w.Write([]byte(`{"access_token":"exchanged-token","expires_in":300}`))
+	}))
+	defer authServer.Close()
+
+	// the registry accepts the bearer token and the token exchanged for the identity token
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		assert.Equal(t, "/v2/krkn/krkn-hub/manifests/dummy-scenario", r.URL.Path)
+		switch r.Header.Get("Authorization") {
+		case "Bearer bearer-token", "Bearer exchanged-token":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+authServer.URL+`",service="test-registry",scope="repository:krkn/krkn-hub:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer registryServer.Close()
+
+	host := strings.TrimPrefix(registryServer.URL, "http://")
+	image := host + "/krkn/krkn-hub:dummy-scenario"
+	token := "bearer-token"
+	registry := &models.RegistryV2{RegistryURL: host, Insecure: true, Token: &token}
+	bearer, err := ResolveBearerToken(registry, image)
+	assert.Nil(t, err)
+	assert.Equal(t, "bearer-token", *bearer)
+
+	token = "identity-token"
+	bearer, err = ResolveBearerToken(registry, image)
+	assert.Nil(t, err)
+	assert.Equal(t, "exchanged-token", *bearer)
+
+	token = "wrong"
+	_, err = ResolveBearerToken(registry, image)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "authentication failed with provided token")
+
+	// without token the credentials are passed to the runtime as they are
+	bearer, err = ResolveBearerToken(&models.RegistryV2{RegistryURL: host}, image)
+	assert.Nil(t, err)
+	assert.Nil(t, bearer)
+}
@@ -0,0 +1,71 @@
+// Package registryauth reads and stores the private registry credentials in the standard
+// container auth files and credential helpers, the same podman and docker use
+package registryauth
+
+import (
+	"errors"
+	"fmt"
+
+	"go.podman.io/image/v5/pkg/docker/config"
+	"go.podman.io/image/v5/types"
+)
+
+// ErrNotLoggedIn is returned by Logout when no credentials are stored for the registry
+var ErrNotLoggedIn = config.ErrNotLoggedIn
+
+type Credentials struct {
+	Username string
+	Password string
+	// IdentityToken is set instead of the password when the registry login returned
+	// an OAuth2 refresh token (e.g. docker login against token based registries)
+	IdentityToken string
+}
+
+// systemContext restricts the lookup to authFile if set, otherwise the auth files are searched
+// in the standard order: ${XDG_RUNTIME_DIR}/containers/auth.json, ~/.config/containers/auth.json,
+// ~/.docker/config.json ($DOCKER_CONFIG) and ~/.dockercfg, credHelpers and credsStore included
+func systemContext(authFile string) *types.SystemContext {
+	return &types.SystemContext{AuthFilePath: authFile}
+}
+
+// Lookup returns the credentials stored for key, nil if there are none.
+// The key is a registry host or a repository (registry/namespace/repo), repository
+// entries take precedence over the registry ones.
+func Lookup(key string, authFile string) (*Credentials, error) {
+	authConfig, err := config.GetCredentials(systemContext(authFile), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the stored credentials of %s: %w", key, err)
+	}
+	if authConfig == (types.DockerAuthConfig{}) {
+		return nil, nil
+	}
+	return &Credentials{
+		Username:      authConfig.Username,
+		Password:      authConfig.Password,
+		IdentityToken: authConfig.IdentityToken,
+	}, nil
+}
+
+// Login stores the credentials of the registry in the credential helper configured for it or in the
+// auth file, the first of the search order if authFile is not set. It returns where they have been stored
+func Login(registry string, username string, password string, authFile string) (string, error) {
+	if username == "" {
+		return "", errors.New("username cannot be empty")
+	}
+	location, err := config.SetCredentials(systemContext(authFile), registry, username, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to store the credentials of %s: %w", registry, err)
+	}
+	return location, nil
+}
+
+// Logout removes the credentials of the registry from the auth files and the credential helpers
+func Logout(registry string, authFile string) error {
+	if err := config.RemoveAuthentication(systemContext(authFile), registry); err != nil {
+		if errors.Is(err, config.ErrNotLoggedIn) {
+			return fmt.Errorf("not logged in to %s: %w", registry, ErrNotLoggedIn)
+		}
+		return fmt.Errorf("failed to remove the credentials of %s: %w", registry, err)
+	}
+	return nil
+}
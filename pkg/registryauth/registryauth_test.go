@@ -0,0 +1,54 @@
+package registryauth
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLookupLogout(t *testing.T) {
+	authFile := path.Join(t.TempDir(), "auth.json")
+
+	credentials, err := Lookup("registry.example.com/krkn-chaos/krkn-hub", authFile)
+	assert.Nil(t, err)
+	assert.Nil(t, credentials)
+
+	_, err = Login("registry.example.com", "", "password", authFile)
+	assert.NotNil(t, err)
+
+	location, err := Login("registry.example.com", "user", "password", authFile)
+	assert.Nil(t, err)
+	assert.Contains(t, location, authFile)
+
+	// the repository falls back to the registry entry
+	credentials, err = Lookup("registry.example.com/krkn-chaos/krkn-hub", authFile)
+	assert.Nil(t, err)
+	assert.NotNil(t, credentials)
+	assert.Equal(t, "user", credentials.Username)
+	assert.Equal(t, "password", credentials.Password)
+
+	credentials, err = Lookup("other.example.com", authFile)
+	assert.Nil(t, err)
+	assert.Nil(t, credentials)
+
+	assert.Nil(t, Logout("registry.example.com", authFile))
+	credentials, err = Lookup("registry.example.com", authFile)
+	assert.Nil(t, err)
+	assert.Nil(t, credentials)
+	err = Logout("registry.example.com", authFile)
+	assert.True(t, errors.Is(err, ErrNotLoggedIn))
+}
+
+func TestLookup_IdentityToken(t *testing.T) {
+	authFile := path.Join(t.TempDir(), "config.json")
+	// docker login stores the identity tokens along with the <token> username
+	err := os.WriteFile(authFile, []byte(`{"auths":{"registry.example.com":{"auth":"PHRva2VuPjo=","identitytoken":"identity-token"}}}`), 0600)
+	assert.Nil(t, err)
+	credentials, err := Lookup("registry.example.com", authFile)
+	assert.Nil(t, err)
+	assert.NotNil(t, credentials)
+	assert.Equal(t, "identity-token", credentials.IdentityToken)
+}
@@ -62,6 +62,33 @@ type FinalReport struct {
 	TotalSlos       int                `json:"total_slos"`
 }
 
+// ScenarioAttempt records a single execution of a graph scenario so that
+// the failed attempts of retried scenarios are kept in the final report.
+type ScenarioAttempt struct {
+	ScenarioID    string `json:"scenario_id"`
+	Attempt       int    `json:"attempt"`
+	ContainerName string `json:"container_name"`
+	LogFile       string `json:"log_file"`
+	ExitStatus    *int   `json:"exit_status,omitempty"`
+	TimedOut      bool   `json:"timed_out,omitempty"`
+	Aborted       bool   `json:"aborted,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SkippedScenario is a graph scenario not run because the outcome of the
+// scenarios it depends on does not meet its when condition.
+type SkippedScenario struct {
+	ScenarioID string `json:"scenario_id"`
+	Reason     string `json:"reason"`
+}
+
+// AbortInfo tells why a graph run has been aborted and which scenarios were skipped,
+// the report of an aborted run only covers the scenarios run before the abort.
+type AbortInfo struct {
+	Reason           string
+	SkippedScenarios []string
+}
+
 // ----------------------------------------------------------------------------
 //  Parser & Aggregator
 // ----------------------------------------------------------------------------
@@ -223,18 +250,37 @@ func WriteFinalReport(report FinalReport, path string) error {
 
 // GenerateAndWriteReport generates a resiliency report and writes it to a file
 func GenerateAndWriteReport(reports []DetailedScenarioReport, outputPath string) error {
+	return GenerateAndWriteGraphReport(reports, nil, nil, nil, outputPath)
+}
+
+// GenerateAndWriteGraphReport generates a resiliency report including every
+// attempt of the graph scenarios and the scenarios skipped by their when condition
+// and writes it to a file, the report is marked as aborted if abort is not nil
+func GenerateAndWriteGraphReport(reports []DetailedScenarioReport, attempts []ScenarioAttempt, skipped []SkippedScenario, abort *AbortInfo, outputPath string) error {
 	final := AggregateReports(reports)
 
 	PrintHumanSummary(final)
 
 	type CombinedReport struct {
-		Summary FinalReport              `json:"summary"`
-		Details []DetailedScenarioReport `json:"details"`
+		Summary          FinalReport              `json:"summary"`
+		Details          []DetailedScenarioReport `json:"details"`
+		Attempts         []ScenarioAttempt        `json:"attempts,omitempty"`
+		Skipped          []SkippedScenario        `json:"skipped,omitempty"`
+		Aborted          bool                     `json:"aborted,omitempty"`
+		AbortReason      string                   `json:"abort_reason,omitempty"`
+		SkippedScenarios []string                 `json:"skipped_scenarios,omitempty"`
 	}
 
 	comb := CombinedReport{
-		Summary: final,
-		Details: reports,
+		Summary:  final,
+		Details:  reports,
+		Attempts: attempts,
+		Skipped:  skipped,
+	}
+	if abort != nil {
+		comb.Aborted = true
+		comb.AbortReason = abort.Reason
+		comb.SkippedScenarios = abort.SkippedScenarios
 	}
 
 	data, err := json.MarshalIndent(comb, "", "  ")
@@ -199,6 +199,58 @@ func TestGenerateAndWriteReport(t *testing.T) {
 	assert.Contains(t, string(data), "details")
 }
 
+func TestGenerateAndWriteGraphReport(t *testing.T) {
+	exitStatus := 1
+	success := 0
+	attempts := []ScenarioAttempt{
+		{ScenarioID: "node", Attempt: 1, ContainerName: "krknctl-node-1", LogFile: "krknctl-node-1.log", ExitStatus: &exitStatus, Error: "Krkn exited with exit status: 1"},
+		{ScenarioID: "node", Attempt: 2, ContainerName: "krknctl-node-2", LogFile: "krknctl-node-2.log", ExitStatus: &success},
+	}
+
+	tmpFile := t.TempDir() + "/test-report.json"
+	err := GenerateAndWriteGraphReport(nil, attempts, []SkippedScenario{{ScenarioID: "recovery-check", Reason: "runs on failure but none of node failed"}}, nil, tmpFile)
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(tmpFile)
+	assert.Nil(t, err)
+	var report struct {
+		Attempts []ScenarioAttempt `json:"attempts"`
+		Skipped  []SkippedScenario `json:"skipped"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &report))
+	assert.Len(t, report.Attempts, 2)
+	assert.Equal(t, []SkippedScenario{{ScenarioID: "recovery-check", Reason: "runs on failure but none of node failed"}}, report.Skipped)
+	assert.Equal(t, 1, *report.Attempts[0].ExitStatus)
+	assert.Equal(t, "krknctl-node-2.log", report.Attempts[1].LogFile)
+	assert.Equal(t, 0, *report.Attempts[1].ExitStatus)
+
+	// single scenario reports don't list the attempts
+	err = GenerateAndWriteReport(nil, tmpFile)
+	assert.Nil(t, err)
+	data, err = os.ReadFile(tmpFile)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "attempts")
+	assert.NotContains(t, string(data), "aborted")
+	assert.NotContains(t, string(data), "skipped")
+
+	attempts = append(attempts, ScenarioAttempt{ScenarioID: "child", Attempt: 1, ContainerName: "krknctl-child-1", LogFile: "krknctl-child-1.log", Aborted: true, Error: "chaos run aborted: interrupted by the user"})
+	err = GenerateAndWriteGraphReport(nil, attempts, nil, &AbortInfo{Reason: "interrupted by the user", SkippedScenarios: []string{"leaf"}}, tmpFile)
+	assert.Nil(t, err)
+	data, err = os.ReadFile(tmpFile)
+	assert.Nil(t, err)
+	var aborted struct {
+		Attempts         []ScenarioAttempt `json:"attempts"`
+		Aborted          bool              `json:"aborted"`
+		AbortReason      string            `json:"abort_reason"`
+		SkippedScenarios []string          `json:"skipped_scenarios"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &aborted))
+	assert.True(t, aborted.Aborted)
+	assert.Equal(t, "interrupted by the user", aborted.AbortReason)
+	assert.Equal(t, []string{"leaf"}, aborted.SkippedScenarios)
+	assert.True(t, aborted.Attempts[2].Aborted)
+}
+
 func TestWriteFinalReport(t *testing.T) {
 	report := FinalReport{
 		Scenarios: map[string]float64{
@@ -3,14 +3,18 @@ package scenarioorchestrator
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path"
+	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/krkn-chaos/krknctl/pkg/config"
@@ -25,94 +29,201 @@ func CommonRunGraph(
 	resolvedGraph models.ResolvedGraph,
 	extraEnv map[string]string,
 	extraVolumeMounts map[string]string,
-	cache bool,
+	stdout io.Writer,
+	pullPolicy models.PullPolicy,
+	schedule models.Schedule,
 	commChannel chan *models.GraphCommChannel,
+	runCtx context.Context,
 	orchestrator ScenarioOrchestrator,
 	config config.Config,
 	registry *providermodels.RegistryV2,
 	userID *int,
+	labels *models.ContainerLabels,
+	runDir string,
 ) {
+	// every node of the graph belongs to the same run
+	if labels == nil {
+		runLabels := utils.NewContainerLabels(config, "")
+		labels = &runLabels
+	}
+	runStarted := time.Now()
+	sendEvent := func(event *models.Event) {
+		commChannel <- &models.GraphCommChannel{Event: event}
+	}
+	// errors not bound to a scenario end the run before the report is written
+	failGraph := func(err error) {
+		event := models.NewEvent(models.EventRunFinished, labels.RunID)
+		event.Status = models.EventStatusFailed
+		event.Error = err.Error()
+		event.SetDuration(runStarted)
+		sendEvent(event)
+		commChannel <- &models.GraphCommChannel{Layer: nil, ScenarioID: nil, ScenarioLogFile: nil, Err: err}
+	}
+	startedEvent := models.NewEvent(models.EventRunStarted, labels.RunID)
+	for _, layer := range resolvedGraph {
+		startedEvent.Nodes = append(startedEvent.Nodes, layer...)
+	}
+	sendEvent(startedEvent)
+
+	// the images are pulled before the first layer so that a pull failure
+	// aborts the plan before any chaos is injected
+	socket, err := orchestrator.GetContainerRuntimeSocket(userID)
+	if err != nil {
+		failGraph(err)
+		return
+	}
+	pullCtx, err := orchestrator.Connect(*socket)
+	if err != nil {
+		failGraph(err)
+		return
+	}
+	// aborting the run cancels the pulls still running
+	pullCtx, cancelPull := context.WithCancel(pullCtx)
+	stopPull := context.AfterFunc(runCtx, cancelPull)
+	err = CommonPrePullImages(GraphImages(scenarios, resolvedGraph), pullPolicy, registry, orchestrator, pullCtx, commChannel)
+	stopPull()
+	cancelPull()
+	if err != nil && runCtx.Err() == nil {
+		failGraph(err)
+		return
+	}
+	// images are already there, the nodes pull them again only if removed in the meantime
+	nodePullPolicy := models.PullIfNotPresent
+	if pullPolicy == models.PullNever {
+		nodePullPolicy = models.PullNever
+	}
+
 	// collectors initialization
 	var (
-		allReports []resiliency.DetailedScenarioReport
-		reportsMu  sync.Mutex
+		allReports  []resiliency.DetailedScenarioReport
+		allAttempts []resiliency.ScenarioAttempt
+		reportsMu   sync.Mutex
 	)
 
-	for step, s := range resolvedGraph {
-		var wg sync.WaitGroup
-		for _, scID := range s {
-			scCopy := scenarios[scID]
-
-			socket, err := orchestrator.GetContainerRuntimeSocket(userID)
-			if err != nil {
-				commChannel <- &models.GraphCommChannel{Layer: nil, ScenarioID: nil, ScenarioLogFile: nil, Err: err}
-				return
-			}
-
-			ctx, err := orchestrator.Connect(*socket)
-			if err != nil {
-				commChannel <- &models.GraphCommChannel{Layer: nil, ScenarioID: nil, ScenarioLogFile: nil, Err: err}
-				return
-			}
-
-			env := make(map[string]string)
-			volumes := make(map[string]string)
-
-			for k, v := range extraEnv {
-				env[k] = v
-			}
-
-			for k, v := range extraVolumeMounts {
-				volumes[k] = v
-			}
-
-			scenario := scenarios[scID]
-			for k, v := range scenario.Env {
-				env[k] = v
-			}
-			for k, v := range scenario.Volumes {
-				volumes[k] = v
+	var failed atomic.Bool
+	layers := make(map[string]int)
+	for step, layer := range resolvedGraph {
+		for _, scID := range layer {
+			layers[scID] = step
+		}
+	}
+	parents := GraphDependencies(scenarios, resolvedGraph, schedule.Layered)
+	children := make(map[string][]string)
+	pending := make(map[string]int)
+	var ready []string
+	for _, layer := range resolvedGraph {
+		for _, scID := range layer {
+			pending[scID] = len(parents[scID])
+			if pending[scID] == 0 {
+				ready = append(ready, scID)
 			}
-
-			// set RESILIENCY_ENABLED_MODE using shared utility
-			promURL := ""
-			if prom, ok := env["PROMETHEUS_URL"]; ok {
-				promURL = prom
+			for _, parent := range parents[scID] {
+				children[parent] = append(children[parent], scID)
 			}
-			env[config.EnvResiliencyEnabledMode] = resiliency.ComputeResiliencyMode(promURL, config)
+		}
+	}
+	layerPending := make([]int, len(resolvedGraph))
+	for step, layer := range resolvedGraph {
+		layerPending[step] = len(layer)
+	}
 
-			// inject resiliency config as base64 encoded env variable if provided (Use Case: To pass the custom resiliency config to the container)
-			if scenario.ResiliencyConfigPath != "" {
-				if content, err := os.ReadFile(scenario.ResiliencyConfigPath); err == nil {
-					env[config.EnvKrknAlertsYamlContent] = base64.StdEncoding.EncodeToString(content)
-				}
-			}
+	// a node that can't be started aborts the run, the nodes already running are killed
+	runCtx, cancelRun := context.WithCancelCause(runCtx)
+	defer cancelRun(nil)
 
-			containerName := utils.GenerateContainerName(config, scenario.Name, &scID)
-			filename := fmt.Sprintf("%s.log", containerName)
-			file, err := os.Create(path.Clean(filename))
+	// startNode creates the log file of the node and runs its scenario in the background,
+	// the node is sent on done once finished
+	type nodeDone struct {
+		scID   string
+		failed bool
+	}
+	done := make(chan nodeDone, len(layers))
+	startNode := func(scID string) error {
+		step := layers[scID]
+		socket, err := orchestrator.GetContainerRuntimeSocket(userID)
+		if err != nil {
+			return err
+		}
 
-			if err != nil {
-				commChannel <- &models.GraphCommChannel{Layer: nil, ScenarioID: nil, ScenarioLogFile: nil, Err: err}
-				return
-			}
+		ctx, err := orchestrator.Connect(*socket)
+		if err != nil {
+			return err
+		}
+		// the running containers are killed if the run is aborted
+		ctx = utils.ContextWithAbort(ctx, runCtx)
 
-			// Copy loop variables to avoid pointer race in goroutine
-			stepCopy := step
-			scIDCopy := scID
-			filenameCopy := filename
+		node := scenarios[scID]
+		scenario := node.Scenario
+		env, volumes := ResolveScenarioEnvironment(scenario, extraEnv, extraVolumeMounts, config)
+		timeout, err := node.GetTimeout()
+		if err != nil {
+			return err
+		}
+		backoff, err := node.GetRetryBackoff()
+		if err != nil {
+			return err
+		}
 
-			commChannel <- &models.GraphCommChannel{Layer: &stepCopy, ScenarioID: &scIDCopy, ScenarioLogFile: &filenameCopy, Err: nil}
-			wg.Add(1)
+		containerName := utils.GenerateContainerName(config, scenario.Name, &scID)
+		// the logs and the report are written in the run directory, the working directory if not set
+		filename := filepath.Join(runDir, fmt.Sprintf("%s.log", containerName))
+		file, err := os.Create(path.Clean(filename))
+		if err != nil {
+			return err
+		}
 
-			go func(scenario models.Scenario, stepVal int, scIDVal string, filenameVal string) {
-				defer wg.Done()
-				mw := io.MultiWriter(os.Stdout, file)
-				_, runErr := orchestrator.RunAttached(scenario.Image, containerName, env, cache, volumes, mw, mw, nil, ctx, registry, nil, nil)
+		firstFilename := filename
+		commChannel <- &models.GraphCommChannel{Layer: &step, ScenarioID: &scID, ScenarioLogFile: &firstFilename, Attempt: 1, Err: nil}
+
+		go func() {
+			nodeFailed := false
+			defer func() { done <- nodeDone{scID: scID, failed: nodeFailed} }()
+			for attempt := 1; ; attempt++ {
+				mw := io.MultiWriter(stdout, file)
+				createOpts := NodeCreateOptions(node, scID, labels)
+				createOpts.PullPolicy = nodePullPolicy
+				containerStarted := time.Now()
+				// the container is reported once it has been created and started, after the image pull
+				startedCtx := utils.ContextWithStarted(ctx, func(string) {
+					containerStarted = time.Now()
+					sendEvent(NewContainerEvent(models.EventContainerStarted, labels.RunID, &step, scID, scenario, containerName, attempt, filename))
+				})
+				_, runErr := orchestrator.RunAttached(scenario.Image, containerName, env, false, volumes, mw, mw, nil, startedCtx, registry, nil, createOpts, timeout)
 				_ = file.Sync()
 				_ = file.Close()
+				// a container ending once the run is aborted is reported as aborted
+				var abortErr *utils.AbortError
+				if runErr != nil && runCtx.Err() != nil && !errors.As(runErr, &abortErr) {
+					runErr = &utils.AbortError{Cause: context.Cause(runCtx)}
+				}
+				exitedEvent := NewContainerEvent(models.EventContainerExited, labels.RunID, &step, scID, scenario, containerName, attempt, filename)
+				SetContainerExit(exitedEvent, runErr)
+				exitedEvent.SetDuration(containerStarted)
+				sendEvent(exitedEvent)
+
+				reportsMu.Lock()
+				allAttempts = append(allAttempts, newScenarioAttempt(scID, attempt, containerName, filename, runErr))
+				reportsMu.Unlock()
+
+				if runErr != nil && attempt <= node.Retries && shouldRetry(node, runErr) && waitBackoff(runCtx, retryDelay(backoff, attempt)) {
+					// the failed attempt is kept, the scenario is run again in a new container
+					containerName = utils.GenerateContainerName(config, scenario.Name, &scID)
+					filename = filepath.Join(runDir, fmt.Sprintf("%s.log", containerName))
+					var createErr error
+					file, createErr = os.Create(path.Clean(filename))
+					if createErr != nil {
+						failed.Store(true)
+						nodeFailed = true
+						commChannel <- &models.GraphCommChannel{Layer: &step, ScenarioID: &scID, ScenarioLogFile: nil, Err: createErr}
+						return
+					}
+					nextFilename := filename
+					commChannel <- &models.GraphCommChannel{Layer: &step, ScenarioID: &scID, ScenarioLogFile: &nextFilename, Attempt: attempt + 1, Err: nil}
+					continue
+				}
 
-				if data, readErr := os.ReadFile(path.Clean(filenameVal)); readErr == nil {
+				// only the report of the last attempt contributes to the resiliency score
+				if data, readErr := os.ReadFile(path.Clean(filename)); readErr == nil {
 					if rep, parseErr := resiliency.ParseResiliencyReport(data); parseErr == nil {
 						// Attach weight information for this scenario.
 						weight := scenario.ResiliencyWeight
@@ -124,43 +235,522 @@ func CommonRunGraph(
 						}
 						rep.ScenarioWeights[scenario.Name] = weight
 
-						fmt.Fprintf(os.Stderr, "Parsed resiliency report from %s\n", filenameVal)
+						fmt.Fprintf(os.Stderr, "Parsed resiliency report from %s\n", filename)
+						reportEvent := NewContainerEvent(models.EventReportParsed, labels.RunID, &step, scID, scenario, containerName, attempt, filename)
+						reportEvent.ResiliencyScore = &rep.OverallReport.ResiliencyScore
+						sendEvent(reportEvent)
 						reportsMu.Lock()
 						allReports = append(allReports, *rep)
 						reportsMu.Unlock()
 					} else {
-						fmt.Fprintf(os.Stderr, "Failed to parse resiliency report from %s: %v\n", filenameVal, parseErr)
+						fmt.Fprintf(os.Stderr, "Failed to parse resiliency report from %s: %v\n", filename, parseErr)
 					}
 				}
 
 				if runErr != nil {
-					commChannel <- &models.GraphCommChannel{Layer: &stepVal, ScenarioID: &scIDVal, ScenarioLogFile: &filenameVal, Err: runErr}
-					return
+					failed.Store(true)
+					nodeFailed = true
+					logFile := filename
+					commChannel <- &models.GraphCommChannel{Layer: &step, ScenarioID: &scID, ScenarioLogFile: &logFile, Attempt: attempt, Err: runErr}
 				}
-			}(scCopy.Scenario, stepCopy, scIDCopy, filenameCopy)
+				return
+			}
+		}()
+		return nil
+	}
 
+	// finish releases the children of a finished or skipped node
+	finish := func(scID string) {
+		for _, child := range children[scID] {
+			pending[child]--
+			if pending[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+		step := layers[scID]
+		layerPending[step]--
+		if layerPending[step] == 0 {
+			layerEvent := models.NewEvent(models.EventLayerFinished, labels.RunID)
+			layerEvent.Layer = &step
+			layerEvent.Nodes = resolvedGraph[step]
+			sendEvent(layerEvent)
 		}
-		wg.Wait()
+	}
 
+	// every node is started as soon as all its parents are finished, without
+	// exceeding the maximum number of scenarios running at the same time
+	started := make(map[string]bool)
+	failedNodes := make(map[string]bool)
+	skippedNodes := make(map[string]bool)
+	var skippedByCondition []resiliency.SkippedScenario
+	running := 0
+	var startErr error
+	for len(ready) > 0 || running > 0 {
+		// once the run is aborted no other node is started
+		for len(ready) > 0 && runCtx.Err() == nil && (schedule.MaxParallel <= 0 || running < schedule.MaxParallel) {
+			scID := ready[0]
+			ready = ready[1:]
+			// the nodes whose when condition is not met are not run, their children
+			// are released as if they were finished
+			if reason := scenarios[scID].SkipReason(failedNodes, skippedNodes); reason != "" {
+				step := layers[scID]
+				skippedNodes[scID] = true
+				skippedByCondition = append(skippedByCondition, resiliency.SkippedScenario{ScenarioID: scID, Reason: reason})
+				commChannel <- &models.GraphCommChannel{Layer: &step, ScenarioID: &scID, SkipReason: reason}
+				skippedEvent := NewContainerEvent(models.EventNodeSkipped, labels.RunID, &step, scID, scenarios[scID].Scenario, "", 0, "")
+				skippedEvent.Status = models.EventStatusSkipped
+				skippedEvent.Reason = reason
+				sendEvent(skippedEvent)
+				finish(scID)
+				continue
+			}
+			if err := startNode(scID); err != nil {
+				// the running nodes are waited for so that no container is left behind
+				startErr = err
+				cancelRun(err)
+				break
+			}
+			started[scID] = true
+			running++
+		}
+		if running == 0 {
+			break
+		}
+		result := <-done
+		running--
+		if result.failed {
+			failedNodes[result.scID] = true
+		}
+		finish(result.scID)
+	}
+	var skipped []string
+	for _, layer := range resolvedGraph {
+		for _, scID := range layer {
+			if !started[scID] && !skippedNodes[scID] {
+				skipped = append(skipped, scID)
+			}
+		}
 	}
 
-	if err := resiliency.GenerateAndWriteReport(allReports, "resiliency-report.json"); err != nil {
+	var abort *resiliency.AbortInfo
+	if runCtx.Err() != nil {
+		abort = &resiliency.AbortInfo{Reason: context.Cause(runCtx).Error(), SkippedScenarios: skipped}
+	}
+	finishedEvent := models.NewEvent(models.EventRunFinished, labels.RunID)
+	finishedEvent.SetDuration(runStarted)
+	switch {
+	case startErr != nil:
+		finishedEvent.Status = models.EventStatusFailed
+		finishedEvent.Error = startErr.Error()
+		finishedEvent.Nodes = skipped
+	case abort != nil:
+		finishedEvent.Status = models.EventStatusAborted
+		finishedEvent.Error = abort.Reason
+		finishedEvent.Nodes = skipped
+	case failed.Load():
+		finishedEvent.Status = models.EventStatusFailed
+	default:
+		finishedEvent.Status = models.EventStatusSucceeded
+	}
+	reportPath := filepath.Join(runDir, "resiliency-report.json")
+	if err := resiliency.GenerateAndWriteGraphReport(allReports, allAttempts, skippedByCondition, abort, reportPath); err != nil {
 		fmt.Fprintf(os.Stderr, "Error generating resiliency report: %v\n", err)
 	} else {
-		fmt.Println("Detailed resiliency report written to resiliency-report.json")
+		_, _ = fmt.Fprintf(stdout, "Detailed resiliency report written to %s\n", reportPath)
+		finishedEvent.Report = reportPath
 	}
+	sendEvent(finishedEvent)
 
+	if startErr != nil {
+		commChannel <- &models.GraphCommChannel{Layer: nil, ScenarioID: nil, ScenarioLogFile: nil, Err: startErr}
+		return
+	}
 	commChannel <- nil
 }
 
-func CommonRunAttached(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, stdout io.Writer, stderr io.Writer, c ScenarioOrchestrator, commChan *chan *string, ctx context.Context, registry *providermodels.RegistryV2, publishPorts []string, podmanCreate *PodmanCreateOptions) (*string, error) {
+// NewContainerEvent returns an event bound to the container of a scenario, layer is nil for the single runs
+func NewContainerEvent(eventType models.EventType, runID string, layer *int, nodeID string, scenario models.Scenario, containerName string, attempt int, logFile string) *models.Event {
+	event := models.NewEvent(eventType, runID)
+	event.Layer = layer
+	event.NodeID = nodeID
+	event.Scenario = scenario.Name
+	event.Image = scenario.Image
+	event.Container = containerName
+	event.Attempt = attempt
+	event.LogFile = logFile
+	return event
+}
+
+// SetContainerExit sets the exit code and the status of a container-exited event from the
+// error returned by RunAttached, the exit code is unknown if the container has been killed
+// by an abort or has failed to start
+func SetContainerExit(event *models.Event, err error) {
+	var exitErr *utils.ExitError
+	var timeoutErr *utils.TimeoutError
+	var abortErr *utils.AbortError
+	exitCode := 0
+	switch {
+	case err == nil:
+		event.Status = models.EventStatusSucceeded
+	case errors.As(err, &abortErr):
+		event.Status = models.EventStatusAborted
+		event.Error = err.Error()
+		return
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitStatus
+		event.Status = models.EventStatusFailed
+	case errors.As(err, &timeoutErr):
+		exitCode = utils.TimeoutExitStatus
+		event.Status = models.EventStatusTimedOut
+	default:
+		event.Status = models.EventStatusFailed
+		event.Error = err.Error()
+		return
+	}
+	event.ExitCode = &exitCode
+	if err != nil {
+		event.Error = err.Error()
+	}
+}
+
+// GraphDependencies returns the parents of the nodes of the resolved graph: the nodes they depend on
+// or, if layered, all the nodes of the previous step
+func GraphDependencies(scenarios models.ScenarioSet, resolvedGraph models.ResolvedGraph, layered bool) map[string][]string {
+	dependencies := make(map[string][]string)
+	for step, layer := range resolvedGraph {
+		for _, scID := range layer {
+			if layered {
+				if step > 0 {
+					dependencies[scID] = resolvedGraph[step-1]
+				}
+				continue
+			}
+			dependencies[scID] = scenarios[scID].GetParents()
+		}
+	}
+	return dependencies
+}
+
+// GraphImages returns the distinct images of the resolved graph in execution order
+func GraphImages(scenarios models.ScenarioSet, resolvedGraph models.ResolvedGraph) []string {
+	var images []string
+	seen := make(map[string]bool)
+	for _, layer := range resolvedGraph {
+		for _, scID := range layer {
+			image := scenarios[scID].Image
+			if image == "" || seen[image] {
+				continue
+			}
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// CommonPrePullImages pulls the images in parallel reporting the progress on the graph channel,
+// the pulls still running are cancelled as soon as one of them fails
+func CommonPrePullImages(
+	images []string,
+	pullPolicy models.PullPolicy,
+	registry *providermodels.RegistryV2,
+	orchestrator ScenarioOrchestrator,
+	ctx context.Context,
+	commChannel chan *models.GraphCommChannel,
+) error {
+	if len(images) == 0 {
+		return nil
+	}
+	pullCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		pulled   int
+		firstErr error
+	)
+	for _, image := range images {
+		wg.Add(1)
+		go func(image string) {
+			defer wg.Done()
+			pullingEvent := models.NewEvent(models.EventImagePulling, "")
+			pullingEvent.Image = image
+			pullingEvent.Total = len(images)
+			commChannel <- &models.GraphCommChannel{Event: pullingEvent}
+			err := orchestrator.PullImage(image, pullPolicy, registry, nil, pullCtx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to pull image %s: %w", image, err)
+					cancel()
+				}
+				return
+			}
+			pulled++
+			commChannel <- &models.GraphCommChannel{ImagePull: &models.ImagePullProgress{Image: image, Pulled: pulled, Total: len(images)}}
+			progressEvent := models.NewEvent(models.EventPullProgress, "")
+			progressEvent.Image = image
+			progressEvent.Pulled = pulled
+			progressEvent.Total = len(images)
+			commChannel <- &models.GraphCommChannel{Event: progressEvent}
+		}(image)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// ResolvePullPolicy returns the pull policy set in the create options,
+// if not set the one implied by the cache flag
+func ResolvePullPolicy(cache bool, createOpts *PodmanCreateOptions) models.PullPolicy {
+	if createOpts != nil && createOpts.PullPolicy != "" {
+		return createOpts.PullPolicy
+	}
+	if cache {
+		return models.PullIfNotPresent
+	}
+	return models.PullAlways
+}
+
+// CommonShouldPull tells if the image must be pulled according to the pull policy
+func CommonShouldPull(image string, exists bool, pullPolicy models.PullPolicy) (bool, error) {
+	switch pullPolicy {
+	case models.PullNever:
+		if !exists {
+			return false, fmt.Errorf("image %s is not present and the pull policy is %s", image, models.PullNever)
+		}
+		return false, nil
+	case models.PullIfNotPresent:
+		return !exists, nil
+	default:
+		return true, nil
+	}
+}
+
+// retryDelay is the backoff doubled at every retry after the first one, capped at models.MaxRetryBackoff
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	delay := backoff
+	for i := 1; i < attempt && delay < models.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, models.MaxRetryBackoff)
+}
+
+// waitBackoff waits before retrying the scenario, false if the run is aborted in the meantime
+func waitBackoff(runCtx context.Context, backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-runCtx.Done():
+		return false
+	}
+}
+
+// shouldRetry tells if the failure is covered by the retry policy of the node,
+// timeouts are matched against the exit status returned by krknctl on timeout
+func shouldRetry(node models.ScenarioNode, err error) bool {
+	if len(node.RetryOn) == 0 {
+		return true
+	}
+	var exitErr *utils.ExitError
+	var timeoutErr *utils.TimeoutError
+	var exitStatus int
+	switch {
+	case errors.As(err, &exitErr):
+		exitStatus = exitErr.ExitStatus
+	case errors.As(err, &timeoutErr):
+		exitStatus = utils.TimeoutExitStatus
+	default:
+		// failures not related to the scenario (e.g. image pull) have no exit status
+		return false
+	}
+	for _, status := range node.RetryOn {
+		if status == exitStatus {
+			return true
+		}
+	}
+	return false
+}
+
+func newScenarioAttempt(scenarioID string, attempt int, containerName string, logFile string, err error) resiliency.ScenarioAttempt {
+	scenarioAttempt := resiliency.ScenarioAttempt{
+		ScenarioID:    scenarioID,
+		Attempt:       attempt,
+		ContainerName: containerName,
+		LogFile:       logFile,
+	}
+	var exitErr *utils.ExitError
+	var timeoutErr *utils.TimeoutError
+	var abortErr *utils.AbortError
+	exitStatus := 0
+	switch {
+	case err == nil:
+	case errors.As(err, &abortErr):
+		scenarioAttempt.Aborted = true
+		scenarioAttempt.Error = err.Error()
+		return scenarioAttempt
+	case errors.As(err, &exitErr):
+		exitStatus = exitErr.ExitStatus
+	case errors.As(err, &timeoutErr):
+		exitStatus = utils.TimeoutExitStatus
+		scenarioAttempt.TimedOut = true
+	default:
+		scenarioAttempt.Error = err.Error()
+		return scenarioAttempt
+	}
+	scenarioAttempt.ExitStatus = &exitStatus
+	if err != nil {
+		scenarioAttempt.Error = err.Error()
+	}
+	return scenarioAttempt
+}
+
+// ResolveScenarioEnvironment merges the plan wide environment and volumes with the ones of the scenario
+// and adds the variables injected by krknctl, the result is what the scenario container is started with
+func ResolveScenarioEnvironment(scenario models.Scenario, extraEnv map[string]string, extraVolumeMounts map[string]string, config config.Config) (map[string]string, map[string]string) {
+	env := make(map[string]string)
+	volumes := make(map[string]string)
+
+	for k, v := range extraEnv {
+		env[k] = v
+	}
+
+	for k, v := range extraVolumeMounts {
+		volumes[k] = v
+	}
+
+	for k, v := range scenario.Env {
+		env[k] = v
+	}
+	for k, v := range scenario.Volumes {
+		volumes[k] = v
+	}
+
+	// set RESILIENCY_ENABLED_MODE using shared utility
+	promURL := ""
+	if prom, ok := env["PROMETHEUS_URL"]; ok {
+		promURL = prom
+	}
+	env[config.EnvResiliencyEnabledMode] = resiliency.ComputeResiliencyMode(promURL, config)
+
+	// inject resiliency config as base64 encoded env variable if provided (Use Case: To pass the custom resiliency config to the container)
+	if scenario.ResiliencyConfigPath != "" {
+		if content, err := os.ReadFile(scenario.ResiliencyConfigPath); err == nil {
+			env[config.EnvKrknAlertsYamlContent] = base64.StdEncoding.EncodeToString(content)
+		}
+	}
+	return env, volumes
+}
+
+// NodeCreateOptions returns the container options set in the graph node, labeled with the run labels
+func NodeCreateOptions(node models.ScenarioNode, nodeID string, labels *models.ContainerLabels) *PodmanCreateOptions {
+	createOpts := &PodmanCreateOptions{Resources: node.Resources, Security: node.Security}
+	if labels != nil {
+		nodeLabels := *labels
+		nodeLabels.NodeID = nodeID
+		nodeLabels.Scenario = node.Name
+		createOpts.Labels = &nodeLabels
+	}
+	return createOpts
+}
+
+// CommonContainerLabels returns the labels stamped on a new container,
+// a container started without labels is given a run of its own
+func CommonContainerLabels(createOpts *PodmanCreateOptions, config config.Config) map[string]string {
+	if createOpts == nil || createOpts.Labels == nil {
+		return utils.ContainerLabelsToMap(utils.NewContainerLabels(config, ""), config)
+	}
+	return utils.ContainerLabelsToMap(*createOpts.Labels, config)
+}
+
+// ResolveGraphPlan returns the containers that CommonRunGraph would start for the resolved graph, layer by layer
+func ResolveGraphPlan(
+	scenarios models.ScenarioSet,
+	resolvedGraph models.ResolvedGraph,
+	extraEnv map[string]string,
+	extraVolumeMounts map[string]string,
+	config config.Config,
+) []models.PlannedScenario {
+	var plan []models.PlannedScenario
+	for step, s := range resolvedGraph {
+		for _, scID := range s {
+			scenario := scenarios[scID]
+			env, volumes := ResolveScenarioEnvironment(scenario.Scenario, extraEnv, extraVolumeMounts, config)
+			plan = append(plan, models.PlannedScenario{
+				Layer:         step,
+				ScenarioID:    scID,
+				ScenarioName:  scenario.Name,
+				ContainerName: utils.GenerateContainerName(config, scenario.Name, &scID),
+				Image:         scenario.Image,
+				Env:           env,
+				Volumes:       volumes,
+				Timeout:       scenario.Timeout,
+				Retries:       scenario.Retries,
+				Resources:     scenario.Resources,
+				Security:      scenario.Security,
+			})
+		}
+	}
+	return plan
+}
+
+// timeoutSignal is delivered to Attach when the scenario timeout expires so that
+// the container is killed by every runtime the same way it is on SIGTERM
+type timeoutSignal struct{}
+
+func (timeoutSignal) String() string { return "timeout" }
+func (timeoutSignal) Signal()        {}
+
+// abortSignal is delivered to Attach when the run is aborted
+type abortSignal struct{}
+
+func (abortSignal) String() string { return "abort" }
+func (abortSignal) Signal()        {}
+
+func CommonRunAttached(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, stdout io.Writer, stderr io.Writer, c ScenarioOrchestrator, commChan *chan *string, ctx context.Context, registry *providermodels.RegistryV2, publishPorts []string, podmanCreate *PodmanCreateOptions, timeout *time.Duration) (*string, error) {
+	// the run has been aborted before the scenario started
+	runCtx := utils.AbortFromContext(ctx)
+	if runCtx != nil && runCtx.Err() != nil {
+		return nil, &utils.AbortError{Cause: context.Cause(runCtx)}
+	}
 
 	containerID, err := c.Run(image, containerName, env, cache, volumeMounts, commChan, ctx, registry, publishPorts, podmanCreate)
 	if err != nil {
 		return nil, err
 	}
+	utils.NotifyStarted(ctx, *containerID)
+	// under a run context the interruption is handled once by the run, that cancels the
+	// context with the abort cause, so that the scenario is reported as aborted
 	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	if runCtx == nil {
+		signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signalChan)
+	}
+
+	var timedOut atomic.Bool
+	if timeout != nil {
+		timer := time.AfterFunc(*timeout, func() {
+			timedOut.Store(true)
+			select {
+			case signalChan <- timeoutSignal{}:
+			default:
+			}
+		})
+		defer timer.Stop()
+	}
+
+	if runCtx != nil {
+		stop := context.AfterFunc(runCtx, func() {
+			select {
+			case signalChan <- abortSignal{}:
+			default:
+			}
+		})
+		defer stop()
+	}
 
 	kill, err := c.Attach(containerID, signalChan, stdout, stderr, ctx)
 	if err != nil {
@@ -171,6 +761,12 @@ func CommonRunAttached(image string, containerName string, env map[string]string
 		if err := c.Kill(containerID, ctx); err != nil {
 			return containerID, fmt.Errorf("failed to kill container: %w", err)
 		}
+		if runCtx != nil && runCtx.Err() != nil {
+			return containerID, &utils.AbortError{Cause: context.Cause(runCtx)}
+		}
+		if timedOut.Load() {
+			return containerID, &utils.TimeoutError{Timeout: *timeout}
+		}
 	}
 
 	containerStatus, err := c.InspectScenario(models.Container{ID: *containerID}, ctx)
@@ -178,17 +774,18 @@ func CommonRunAttached(image string, containerName string, env map[string]string
 		return containerID, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	if containerStatus.Container.ExitStatus > 0 {
+	// the container may have been removed by the runtime once killed
+	if containerStatus != nil && containerStatus.Container.ExitStatus > 0 {
 		return containerID, &utils.ExitError{ExitStatus: int(containerStatus.Container.ExitStatus)}
 	}
 
 	return containerID, nil
 }
 
-func CommonPrintRuntime(containerRuntime models.ContainerRuntime) {
+func CommonPrintRuntime(w io.Writer, containerRuntime models.ContainerRuntime) {
 	green := color.New(color.FgGreen).SprintFunc()
 	boldGreen := color.New(color.FgHiGreen, color.Bold).SprintFunc()
-	fmt.Printf("\n\n%s %s\n\n", green("container runtime:"), boldGreen(containerRuntime.String()))
+	_, _ = fmt.Fprintf(w, "\n\n%s %s\n\n", green("container runtime:"), boldGreen(containerRuntime.String()))
 }
 
 func CommonAttachWait(containerID *string, stdout io.Writer, stderr io.Writer, c ScenarioOrchestrator, ctx context.Context) (bool, error) {
@@ -198,8 +795,8 @@ func CommonAttachWait(containerID *string, stdout io.Writer, stderr io.Writer, c
 	return interrupted, err
 }
 
-func CommonListRunningScenarios(c ScenarioOrchestrator, ctx context.Context) (*[]models.ScenarioContainer, error) {
-	containersMap, err := c.ListRunningContainers(ctx)
+func CommonListRunningScenarios(c ScenarioOrchestrator, ctx context.Context, filter *models.ContainerLabels) (*[]models.ScenarioContainer, error) {
+	containersMap, err := c.ListRunningContainers(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,439 @@
+package scenarioorchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/resiliency"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRetry(t *testing.T) {
+	exitErr := &utils.ExitError{ExitStatus: 2}
+	timeoutErr := &utils.TimeoutError{}
+	pullErr := errors.New("failed to pull image")
+
+	// without retry_on every failure is retried
+	node := models.ScenarioNode{Retries: 2}
+	assert.True(t, shouldRetry(node, exitErr))
+	assert.True(t, shouldRetry(node, timeoutErr))
+	assert.True(t, shouldRetry(node, pullErr))
+
+	node.RetryOn = []int{2, utils.TimeoutExitStatus}
+	assert.True(t, shouldRetry(node, exitErr))
+	assert.True(t, shouldRetry(node, timeoutErr))
+	assert.False(t, shouldRetry(node, pullErr))
+	assert.False(t, shouldRetry(node, &utils.ExitError{ExitStatus: 1}))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryDelay(30*time.Second, 1))
+	assert.Equal(t, 2*time.Minute, retryDelay(30*time.Second, 3))
+	// the doubled backoff never goes past the cap nor overflows
+	assert.Equal(t, models.MaxRetryBackoff, retryDelay(30*time.Second, 6))
+	assert.Equal(t, models.MaxRetryBackoff, retryDelay(30*time.Second, 100))
+	assert.Equal(t, time.Duration(0), retryDelay(0, 5))
+}
+
+func TestNewScenarioAttempt(t *testing.T) {
+	attempt := newScenarioAttempt("node", 1, "krknctl-node-1", "krknctl-node-1.log", nil)
+	assert.Equal(t, 0, *attempt.ExitStatus)
+	assert.Equal(t, "", attempt.Error)
+	assert.Equal(t, "krknctl-node-1.log", attempt.LogFile)
+
+	attempt = newScenarioAttempt("node", 2, "krknctl-node-2", "krknctl-node-2.log", &utils.ExitError{ExitStatus: 3})
+	assert.Equal(t, 2, attempt.Attempt)
+	assert.Equal(t, 3, *attempt.ExitStatus)
+	assert.False(t, attempt.TimedOut)
+	assert.NotEmpty(t, attempt.Error)
+
+	attempt = newScenarioAttempt("node", 3, "krknctl-node-3", "krknctl-node-3.log", &utils.TimeoutError{})
+	assert.Equal(t, utils.TimeoutExitStatus, *attempt.ExitStatus)
+	assert.True(t, attempt.TimedOut)
+
+	attempt = newScenarioAttempt("node", 4, "krknctl-node-4", "krknctl-node-4.log", errors.New("failed to pull image"))
+	assert.Nil(t, attempt.ExitStatus)
+	assert.Equal(t, "failed to pull image", attempt.Error)
+}
+
+func TestSetContainerExit(t *testing.T) {
+	layer := 1
+	event := NewContainerEvent(models.EventContainerExited, "run", &layer, "node", models.Scenario{Name: "pod-scenarios", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}, "krknctl-node-1", 1, "krknctl-node-1.log")
+	SetContainerExit(event, nil)
+	assert.Equal(t, "pod-scenarios", event.Scenario)
+	assert.Equal(t, 1, *event.Layer)
+	assert.Equal(t, 0, *event.ExitCode)
+	assert.Equal(t, models.EventStatusSucceeded, event.Status)
+	assert.Empty(t, event.Error)
+
+	event = models.NewEvent(models.EventContainerExited, "run")
+	SetContainerExit(event, &utils.ExitError{ExitStatus: 3})
+	assert.Equal(t, 3, *event.ExitCode)
+	assert.Equal(t, models.EventStatusFailed, event.Status)
+	assert.NotEmpty(t, event.Error)
+
+	event = models.NewEvent(models.EventContainerExited, "run")
+	SetContainerExit(event, &utils.TimeoutError{})
+	assert.Equal(t, utils.TimeoutExitStatus, *event.ExitCode)
+	assert.Equal(t, models.EventStatusTimedOut, event.Status)
+
+	event = models.NewEvent(models.EventContainerExited, "run")
+	SetContainerExit(event, &utils.AbortError{Cause: errors.New("interrupted")})
+	assert.Nil(t, event.ExitCode)
+	assert.Equal(t, models.EventStatusAborted, event.Status)
+
+	event = models.NewEvent(models.EventContainerExited, "run")
+	SetContainerExit(event, errors.New("failed to pull image"))
+	assert.Nil(t, event.ExitCode)
+	assert.Equal(t, models.EventStatusFailed, event.Status)
+	assert.Equal(t, "failed to pull image", event.Error)
+}
+
+func TestResolvePullPolicy(t *testing.T) {
+	assert.Equal(t, models.PullAlways, ResolvePullPolicy(false, nil))
+	assert.Equal(t, models.PullIfNotPresent, ResolvePullPolicy(true, nil))
+	assert.Equal(t, models.PullIfNotPresent, ResolvePullPolicy(true, &PodmanCreateOptions{}))
+	assert.Equal(t, models.PullNever, ResolvePullPolicy(false, &PodmanCreateOptions{PullPolicy: models.PullNever}))
+}
+
+func TestCommonShouldPull(t *testing.T) {
+	pull, err := CommonShouldPull("image", true, models.PullAlways)
+	assert.Nil(t, err)
+	assert.True(t, pull)
+	pull, err = CommonShouldPull("image", true, models.PullIfNotPresent)
+	assert.Nil(t, err)
+	assert.False(t, pull)
+	pull, err = CommonShouldPull("image", false, models.PullIfNotPresent)
+	assert.Nil(t, err)
+	assert.True(t, pull)
+	pull, err = CommonShouldPull("image", true, models.PullNever)
+	assert.Nil(t, err)
+	assert.False(t, pull)
+	_, err = CommonShouldPull("image", false, models.PullNever)
+	assert.NotNil(t, err)
+}
+
+func TestGraphImages(t *testing.T) {
+	scenarios := models.ScenarioSet{
+		"a": models.ScenarioNode{Scenario: models.Scenario{Name: "a", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"}},
+		"b": models.ScenarioNode{Scenario: models.Scenario{Name: "b", Image: "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}},
+		"c": models.ScenarioNode{Scenario: models.Scenario{Name: "c", Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog"}},
+	}
+	images := GraphImages(scenarios, models.ResolvedGraph{{"a"}, {"b", "c"}})
+	assert.Equal(t, []string{"quay.io/krkn-chaos/krkn-hub:node-cpu-hog", "quay.io/krkn-chaos/krkn-hub:pod-scenarios"}, images)
+}
+
+// pullOrchestrator records the pulled images, failing on the image set in fail
+type pullOrchestrator struct {
+	ScenarioOrchestrator
+	fail   string
+	mu     sync.Mutex
+	pulled []string
+}
+
+func (p *pullOrchestrator) PullImage(image string, pullPolicy models.PullPolicy, registry *providermodels.RegistryV2, commChan *chan *string, ctx context.Context) error {
+	if image == p.fail {
+		return errors.New("manifest unknown")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pulled = append(p.pulled, image)
+	return nil
+}
+
+func TestCommonPrePullImages(t *testing.T) {
+	images := []string{"image-a", "image-b", "image-c"}
+	orchestrator := &pullOrchestrator{}
+	// every image sends an image-pulling event, its progress and a pull-progress event
+	commChannel := make(chan *models.GraphCommChannel, 3*len(images))
+	err := CommonPrePullImages(images, models.PullAlways, nil, orchestrator, context.Background(), commChannel)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, images, orchestrator.pulled)
+	close(commChannel)
+	pulled := 0
+	events := make(map[models.EventType]int)
+	for c := range commChannel {
+		if c.Event != nil {
+			assert.Nil(t, c.ImagePull)
+			assert.Contains(t, images, c.Event.Image)
+			events[c.Event.Type]++
+			continue
+		}
+		assert.NotNil(t, c.ImagePull)
+		assert.Equal(t, len(images), c.ImagePull.Total)
+		pulled++
+		assert.Equal(t, pulled, c.ImagePull.Pulled)
+	}
+	assert.Equal(t, len(images), pulled)
+	assert.Equal(t, map[models.EventType]int{models.EventImagePulling: len(images), models.EventPullProgress: len(images)}, events)
+
+	orchestrator = &pullOrchestrator{fail: "image-b"}
+	commChannel = make(chan *models.GraphCommChannel, 3*len(images))
+	err = CommonPrePullImages(images, models.PullAlways, nil, orchestrator, context.Background(), commChannel)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "image-b")
+}
+
+// graphOrchestrator runs every node for the duration set in its env, recording when it starts and ends,
+// the node fails if EXIT_STATUS is set and is killed if the run is aborted. The failConnect-th
+// connection to the runtime fails if set, the first one is the one of the pre-pull
+type graphOrchestrator struct {
+	ScenarioOrchestrator
+	mu          sync.Mutex
+	running     int
+	peak        int
+	connects    int
+	failConnect int
+	started     map[string]time.Time
+	ended       map[string]time.Time
+}
+
+func (g *graphOrchestrator) GetContainerRuntimeSocket(userID *int) (*string, error) {
+	socket := "unix:///run/test.sock"
+	return &socket, nil
+}
+
+func (g *graphOrchestrator) Connect(containerRuntimeURI string) (context.Context, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.connects++
+	if g.connects == g.failConnect {
+		return nil, errors.New("connection refused")
+	}
+	return context.Background(), nil
+}
+
+func (g *graphOrchestrator) PullImage(image string, pullPolicy models.PullPolicy, registry *providermodels.RegistryV2, commChan *chan *string, ctx context.Context) error {
+	return nil
+}
+
+func (g *graphOrchestrator) RunAttached(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, stdout io.Writer, stderr io.Writer, commChan *chan *string, ctx context.Context, registry *providermodels.RegistryV2, publishPorts []string, podmanCreate *PodmanCreateOptions, timeout *time.Duration) (*string, error) {
+	nodeID := podmanCreate.Labels.NodeID
+	duration, err := time.ParseDuration(env["DURATION"])
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	g.started[nodeID] = time.Now()
+	g.running++
+	g.peak = max(g.peak, g.running)
+	g.mu.Unlock()
+	utils.NotifyStarted(ctx, containerName)
+	var aborted <-chan struct{}
+	runCtx := utils.AbortFromContext(ctx)
+	if runCtx != nil {
+		aborted = runCtx.Done()
+	}
+	select {
+	case <-time.After(duration):
+	case <-aborted:
+	}
+	g.mu.Lock()
+	g.ended[nodeID] = time.Now()
+	g.running--
+	g.mu.Unlock()
+	if runCtx != nil && runCtx.Err() != nil {
+		return &containerName, &utils.AbortError{Cause: context.Cause(runCtx)}
+	}
+	if exitStatus, ok := env["EXIT_STATUS"]; ok {
+		status, err := strconv.Atoi(exitStatus)
+		if err != nil {
+			return nil, err
+		}
+		return &containerName, &utils.ExitError{ExitStatus: status}
+	}
+	return &containerName, nil
+}
+
+func runTestGraph(t *testing.T, schedule models.Schedule) *graphOrchestrator {
+	conf, err := config.LoadConfig()
+	assert.Nil(t, err)
+	node := func(duration string, parents ...string) models.ScenarioNode {
+		return models.ScenarioNode{Scenario: models.Scenario{Name: "dummy-scenario", Image: "quay.io/krkn-chaos/krkn-hub:dummy-scenario", Env: map[string]string{"DURATION": duration}}, Parents: parents}
+	}
+	// child waits only for fast, slow is an unrelated branch of the same step
+	nodes := models.ScenarioSet{
+		"root":  node("10ms"),
+		"fast":  node("10ms", "root"),
+		"slow":  node("300ms", "root"),
+		"child": node("10ms", "fast"),
+	}
+	resolvedGraph := models.ResolvedGraph{{"root"}, {"fast", "slow"}, {"child"}}
+	orchestrator := &graphOrchestrator{started: make(map[string]time.Time), ended: make(map[string]time.Time)}
+	commChannel := make(chan *models.GraphCommChannel)
+	go CommonRunGraph(nodes, resolvedGraph, nil, nil, io.Discard, models.PullIfNotPresent, schedule, commChannel, context.Background(), orchestrator, conf, nil, nil, nil, t.TempDir())
+	finished := 0
+	for c := range commChannel {
+		if c == nil {
+			break
+		}
+		assert.Nil(t, c.Err)
+		if c.Event != nil && c.Event.Type == models.EventRunFinished {
+			assert.Equal(t, models.EventStatusSucceeded, c.Event.Status)
+			finished++
+		}
+	}
+	assert.Equal(t, 1, finished)
+	assert.Len(t, orchestrator.ended, len(nodes))
+	return orchestrator
+}
+
+func TestCommonRunGraphSchedule(t *testing.T) {
+	// a node starts as soon as its parents are finished
+	orchestrator := runTestGraph(t, models.Schedule{})
+	assert.True(t, orchestrator.started["fast"].After(orchestrator.ended["root"]))
+	assert.True(t, orchestrator.started["child"].After(orchestrator.ended["fast"]))
+	assert.True(t, orchestrator.started["child"].Before(orchestrator.ended["slow"]))
+	assert.Equal(t, 2, orchestrator.peak)
+
+	// a step starts once the previous one is finished
+	orchestrator = runTestGraph(t, models.Schedule{Layered: true})
+	assert.True(t, orchestrator.started["child"].After(orchestrator.ended["slow"]))
+
+	orchestrator = runTestGraph(t, models.Schedule{MaxParallel: 1})
+	assert.Equal(t, 1, orchestrator.peak)
+}
+
+func TestCommonRunGraphWhen(t *testing.T) {
+	conf, err := config.LoadConfig()
+	assert.Nil(t, err)
+	node := func(when models.Condition, parents ...string) models.ScenarioNode {
+		return models.ScenarioNode{Scenario: models.Scenario{Name: "dummy-scenario", Image: "quay.io/krkn-chaos/krkn-hub:dummy-scenario", Env: map[string]string{"DURATION": "10ms"}}, Parents: parents, When: when}
+	}
+	root := node("")
+	root.Env["EXIT_STATUS"] = "1"
+	nodes := models.ScenarioSet{
+		"root":      root,
+		"success":   node(models.WhenOnSuccess, "root"),
+		"failure":   node(models.WhenOnFailure, "root"),
+		"always":    node(models.WhenAlways, "root"),
+		"afterSkip": node(models.WhenOnSuccess, "success"),
+		"default":   node("", "success"),
+	}
+	resolvedGraph := models.ResolvedGraph{{"root"}, {"always", "failure", "success"}, {"afterSkip", "default"}}
+	orchestrator := &graphOrchestrator{started: make(map[string]time.Time), ended: make(map[string]time.Time)}
+	commChannel := make(chan *models.GraphCommChannel)
+	runDir := t.TempDir()
+	go CommonRunGraph(nodes, resolvedGraph, nil, nil, io.Discard, models.PullIfNotPresent, models.Schedule{}, commChannel, context.Background(), orchestrator, conf, nil, nil, nil, runDir)
+	skipped := make(map[string]string)
+	skippedEvents := 0
+	var status string
+	for c := range commChannel {
+		if c == nil {
+			break
+		}
+		if c.SkipReason != "" {
+			skipped[*c.ScenarioID] = c.SkipReason
+		}
+		if c.Event != nil && c.Event.Type == models.EventNodeSkipped {
+			assert.Equal(t, models.EventStatusSkipped, c.Event.Status)
+			assert.Equal(t, skipped[c.Event.NodeID], c.Event.Reason)
+			skippedEvents++
+		}
+		if c.Event != nil && c.Event.Type == models.EventRunFinished {
+			status = c.Event.Status
+		}
+	}
+	assert.Equal(t, models.EventStatusFailed, status)
+	assert.Equal(t, map[string]string{
+		"success":   "runs on success but root failed",
+		"afterSkip": "runs on success but success has been skipped",
+	}, skipped)
+	assert.Equal(t, 2, skippedEvents)
+	// the children of a skipped node without condition still run
+	assert.Len(t, orchestrator.ended, 4)
+	assert.Contains(t, orchestrator.ended, "failure")
+	assert.Contains(t, orchestrator.ended, "always")
+	assert.Contains(t, orchestrator.ended, "default")
+
+	data, err := os.ReadFile(filepath.Join(runDir, "resiliency-report.json"))
+	assert.Nil(t, err)
+	var report struct {
+		Skipped []resiliency.SkippedScenario `json:"skipped"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &report))
+	assert.Len(t, report.Skipped, 2)
+}
+
+func TestCommonRunGraphStartFailure(t *testing.T) {
+	conf, err := config.LoadConfig()
+	assert.Nil(t, err)
+	node := func(duration string) models.ScenarioNode {
+		return models.ScenarioNode{Scenario: models.Scenario{Name: "dummy-scenario", Image: "quay.io/krkn-chaos/krkn-hub:dummy-scenario", Env: map[string]string{"DURATION": duration}}}
+	}
+	nodes := models.ScenarioSet{
+		"long":   node("1m"),
+		"broken": node("10ms"),
+	}
+	resolvedGraph := models.ResolvedGraph{{"long", "broken"}}
+	// long is started first, broken fails to connect to the runtime while long is running
+	orchestrator := &graphOrchestrator{failConnect: 3, started: make(map[string]time.Time), ended: make(map[string]time.Time)}
+	commChannel := make(chan *models.GraphCommChannel)
+	runDir := t.TempDir()
+	go CommonRunGraph(nodes, resolvedGraph, nil, nil, io.Discard, models.PullIfNotPresent, models.Schedule{}, commChannel, context.Background(), orchestrator, conf, nil, nil, nil, runDir)
+	var killed []string
+	var containersStarted []string
+	var finished *models.Event
+	var runErr error
+	for c := range commChannel {
+		assert.NotNil(t, c)
+		if c.Event != nil {
+			switch c.Event.Type {
+			case models.EventRunFinished:
+				finished = c.Event
+			case models.EventContainerStarted:
+				containersStarted = append(containersStarted, c.Event.NodeID)
+			}
+			continue
+		}
+		var abortErr *utils.AbortError
+		if c.ScenarioID != nil && errors.As(c.Err, &abortErr) {
+			killed = append(killed, *c.ScenarioID)
+		}
+		if c.Err != nil && c.Layer == nil {
+			runErr = c.Err
+			break
+		}
+	}
+	assert.EqualError(t, runErr, "connection refused")
+	// the running node is killed and waited for before the run fails
+	assert.Equal(t, []string{"long"}, killed)
+	assert.Contains(t, orchestrator.ended, "long")
+	assert.NotContains(t, orchestrator.started, "broken")
+	// only the containers actually started are reported as started
+	assert.Equal(t, []string{"long"}, containersStarted)
+	assert.Equal(t, models.EventStatusFailed, finished.Status)
+	assert.Equal(t, "connection refused", finished.Error)
+	assert.FileExists(t, filepath.Join(runDir, "resiliency-report.json"))
+}
+
+func TestGraphDependencies(t *testing.T) {
+	nodes := models.ScenarioSet{
+		"root":  models.ScenarioNode{},
+		"a":     models.ScenarioNode{Parents: models.Dependencies{"root"}},
+		"b":     models.ScenarioNode{Parents: models.Dependencies{"root"}},
+		"fanin": models.ScenarioNode{Parents: models.Dependencies{"a", "b"}},
+	}
+	resolvedGraph := models.ResolvedGraph{{"root"}, {"a", "b"}, {"fanin"}}
+	dependencies := GraphDependencies(nodes, resolvedGraph, false)
+	assert.Empty(t, dependencies["root"])
+	assert.Equal(t, []string{"a", "b"}, dependencies["fanin"])
+
+	nodes["fanin"] = models.ScenarioNode{Parents: models.Dependencies{"a"}}
+	dependencies = GraphDependencies(nodes, resolvedGraph, true)
+	assert.Empty(t, dependencies["root"])
+	assert.Equal(t, []string{"root"}, dependencies["a"])
+	assert.Equal(t, []string{"a", "b"}, dependencies["fanin"])
+}
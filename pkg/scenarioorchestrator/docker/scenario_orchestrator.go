@@ -9,6 +9,7 @@ import (
 
 	cerrdefs "github.com/containerd/errdefs"
 	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	dockerimage "github.com/docker/docker/api/types/image"
 
 	"github.com/docker/docker/api/types/mount"
@@ -18,15 +19,15 @@ import (
 	nat "github.com/docker/go-connections/nat"
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/provider/registryv2"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
 	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	"github.com/krkn-chaos/krknctl/pkg/typing"
 	"io"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
+	"time"
 )
 
 type ScenarioOrchestrator struct {
@@ -76,16 +77,9 @@ func (c *ScenarioOrchestrator) Run(
 		return nil, err
 	}
 
-	exists, err := ImageExists(ctx, cli, image)
-	if err != nil {
+	if err := c.PullImage(image, scenarioorchestrator.ResolvePullPolicy(cache, createOpts), registry, commChan, ctx); err != nil {
 		return nil, err
 	}
-	if !cache || !exists {
-		err := pullImage(ctx, cli, image, commChan, registry)
-		if err != nil {
-			return nil, err
-		}
-	}
 
 	var envVars []string
 
@@ -93,8 +87,14 @@ func (c *ScenarioOrchestrator) Run(
 		envVars = append(envVars, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	// files cannot be bind mounted on a remote engine, they're copied
+	// into the container before it is started
+	remote := utils.ContainerHostFromContext(ctx) != nil
 	var volumes []mount.Mount
 	for k, v := range volumeMounts {
+		if remote {
+			continue
+		}
 		volumes = append(volumes, mount.Mount{
 			Type:   mount.TypeBind,
 			Source: k,
@@ -103,8 +103,9 @@ func (c *ScenarioOrchestrator) Run(
 	}
 
 	containerCfg := &dockercontainer.Config{
-		Image: image,
-		Env:   envVars,
+		Image:  image,
+		Env:    envVars,
+		Labels: scenarioorchestrator.CommonContainerLabels(createOpts, c.Config),
 	}
 	hostCfg := &dockercontainer.HostConfig{
 		Mounts: volumes,
@@ -130,6 +131,12 @@ func (c *ScenarioOrchestrator) Run(
 		hostCfg.DeviceRequests = []dockercontainer.DeviceRequest{gpuReq}
 	}
 
+	if createOpts != nil {
+		if err := applyResourcesAndSecurity(containerCfg, hostCfg, createOpts); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(publishPorts) > 0 {
 		exposed := nat.PortSet{}
 		bindings := nat.PortMap{}
@@ -159,6 +166,17 @@ func (c *ScenarioOrchestrator) Run(
 		return nil, err
 	}
 
+	if remote && len(volumeMounts) > 0 {
+		archive, err := utils.ArchiveVolumeMounts(volumeMounts)
+		if err != nil {
+			return nil, err
+		}
+		// the files are owned by the user the scenario runs as so that it can read them
+		if err := cli.CopyToContainer(ctx, resp.ID, "/", archive, dockercontainer.CopyToContainerOptions{CopyUIDGID: true}); err != nil {
+			return nil, fmt.Errorf("failed to copy files into container %s: %w", containerName, err)
+		}
+	}
+
 	if err := cli.ContainerStart(ctx, resp.ID, dockercontainer.StartOptions{}); err != nil {
 		return nil, err
 	}
@@ -231,6 +249,31 @@ func (c *ScenarioOrchestrator) Attach(containerID *string, signalChannel chan os
 
 }
 
+func (c *ScenarioOrchestrator) Logs(containerID *string, options orchestratormodels.LogOptions, stdout io.Writer, stderr io.Writer, ctx context.Context) error {
+	cli, err := dockerClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	reader, err := cli.ContainerLogs(ctx, *containerID, dockercontainer.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     options.Follow,
+		Since:      options.SinceTimestamp(),
+		Tail:       options.TailLines(),
+		Timestamps: options.Timestamps,
+	})
+	if err != nil {
+		return err
+	}
+	// copies demultiplexed reader to Stdout and Stderr
+	_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	closeErr := reader.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
 func (c *ScenarioOrchestrator) Kill(containerID *string, ctx context.Context) error {
 	cli, err := dockerClientFromContext(ctx)
 	if err != nil {
@@ -244,23 +287,20 @@ func (c *ScenarioOrchestrator) Kill(containerID *string, ctx context.Context) er
 	return nil
 }
 
-func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context) (*int, error) {
-	nameRegex, err := regexp.Compile(fmt.Sprintf("%s.*-[0-9]+$", c.Config.ContainerPrefix))
-	if err != nil {
-		return nil, err
-	}
+func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*int, error) {
 	cli, err := dockerClientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true})
+	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: c.labelFilter()})
 	if err != nil {
 		return nil, err
 	}
 	containerCount := 0
 	for _, container := range containers {
-		if container.State != "running" && nameRegex.MatchString(container.Names[0]) {
+		labels := utils.ContainerLabelsFromMap(container.Labels, c.Config)
+		if container.State != "running" && labels != nil && labels.Matches(filter) {
 			err := cli.ContainerRemove(ctx, container.ID, dockercontainer.RemoveOptions{
 				Force:         true,
 				RemoveVolumes: true,
@@ -275,6 +315,56 @@ func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context) (*int, error
 	return &containerCount, nil
 }
 
+func (c *ScenarioOrchestrator) ListFinishedContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.Container, error) {
+	cli, err := dockerClientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: c.labelFilter()})
+	if err != nil {
+		return nil, err
+	}
+	finished := make([]orchestratormodels.Container, 0)
+	for _, container := range containers {
+		labels := utils.ContainerLabelsFromMap(container.Labels, c.Config)
+		if container.State == c.Config.DockerRunningState || labels == nil || !labels.Matches(filter) {
+			continue
+		}
+		// the exit code is not returned by the list
+		inspectData, err := cli.ContainerInspect(ctx, container.ID)
+		if err != nil {
+			return nil, err
+		}
+		finished = append(finished, orchestratormodels.Container{
+			Name:       strings.Replace(container.Names[0], "/", "", 1),
+			ID:         container.ID,
+			Image:      container.Image,
+			Started:    container.Created,
+			Status:     container.State,
+			ExitStatus: inspectData.State.ExitCode,
+			Labels:     labels,
+		})
+	}
+	return &finished, nil
+}
+
+func (c *ScenarioOrchestrator) RemoveContainer(containerID *string, ctx context.Context) error {
+	cli, err := dockerClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.ContainerRemove(ctx, *containerID, dockercontainer.RemoveOptions{
+		Force:         true,
+		RemoveVolumes: true,
+	})
+}
+
+// labelFilter selects the containers stamped with a krknctl run ID
+func (c *ScenarioOrchestrator) labelFilter() filters.Args {
+	return filters.NewArgs(filters.Arg("label", c.Config.LabelRunID))
+}
+
 func (c *ScenarioOrchestrator) GetContainerRuntimeSocket(userID *int) (*string, error) {
 	return utils.GetSocketByContainerEnvironment(orchestratormodels.Docker, c.Config, userID)
 }
@@ -309,6 +399,28 @@ func ImageExists(ctx context.Context, cli *client.Client, imageName string) (boo
 	return false, nil
 }
 
+func (c *ScenarioOrchestrator) PullImage(
+	image string,
+	pullPolicy orchestratormodels.PullPolicy,
+	registry *providermodels.RegistryV2,
+	commChan *chan *string,
+	ctx context.Context,
+) error {
+	cli, err := dockerClientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := ImageExists(ctx, cli, image)
+	if err != nil {
+		return err
+	}
+	pull, err := scenarioorchestrator.CommonShouldPull(image, exists, pullPolicy)
+	if err != nil || !pull {
+		return err
+	}
+	return pullImage(ctx, cli, image, commChan, registry)
+}
+
 func pullImage(
 	ctx context.Context,
 	cli *client.Client,
@@ -319,7 +431,17 @@ func pullImage(
 
 	pullOptions := dockerimage.PullOptions{}
 	if registry != nil {
-		registryAuth, err := registry.ToDockerV2AuthString()
+		// docker uses the registry token as bearer token, the identity tokens are
+		// exchanged by krknctl so that the token is accepted as podman does
+		bearer, err := registryv2.ResolveBearerToken(registry, imageName)
+		if err != nil {
+			return err
+		}
+		pullRegistry := *registry
+		if bearer != nil {
+			pullRegistry.Token = bearer
+		}
+		registryAuth, err := pullRegistry.ToDockerV2AuthString()
 		if err != nil {
 			return err
 		}
@@ -374,37 +496,29 @@ func (c *ScenarioOrchestrator) GetContainerRuntime() orchestratormodels.Containe
 	return c.ContainerRuntime
 }
 
-func (c *ScenarioOrchestrator) ListRunningContainers(ctx context.Context) (*map[int64]orchestratormodels.Container, error) {
+func (c *ScenarioOrchestrator) ListRunningContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*map[int64]orchestratormodels.Container, error) {
 	scenarios := make(map[int64]orchestratormodels.Container)
-	scenarioNameRegex, err := regexp.Compile(fmt.Sprintf("%s-.*-([0-9]+)", c.Config.ContainerPrefix))
-	if err != nil {
-		return nil, err
-	}
 
 	cli, err := dockerClientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true})
+	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: c.labelFilter()})
 	if err != nil {
 		return nil, err
 	}
 	for _, container := range containers {
-		if container.State == c.Config.DockerRunningState {
-			groups := scenarioNameRegex.FindStringSubmatch(container.Names[0])
-			if len(groups) > 1 {
-				index, err := strconv.ParseInt(groups[1], 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				containerName := strings.Replace(container.Names[0], "/", "", 1)
-				scenarios[index] = orchestratormodels.Container{
-					Name:    containerName,
-					ID:      container.ID,
-					Image:   container.Image,
-					Started: index,
-				}
+		labels := utils.ContainerLabelsFromMap(container.Labels, c.Config)
+		if container.State == c.Config.DockerRunningState && labels != nil && labels.Matches(filter) {
+			containerName := strings.Replace(container.Names[0], "/", "", 1)
+			index := utils.ContainerNameTimestamp(containerName, time.Unix(container.Created, 0))
+			scenarios[index] = orchestratormodels.Container{
+				Name:    containerName,
+				ID:      container.ID,
+				Image:   container.Image,
+				Started: container.Created,
+				Labels:  labels,
 			}
 		}
 	}
@@ -433,6 +547,7 @@ func (c *ScenarioOrchestrator) InspectScenario(container orchestratormodels.Cont
 	container.Name = strings.Replace(inspectData.Name, "/", "", 1)
 	container.Status = inspectData.State.Status
 	container.ExitStatus = inspectData.State.ExitCode
+	container.Labels = utils.ContainerLabelsFromMap(inspectData.Config.Labels, c.Config)
 
 	scenarioDetail := providermodels.ScenarioDetail{}
 	scenarioDetail.Digest = &inspectData.ContainerJSONBase.Image
@@ -480,10 +595,13 @@ func (c *ScenarioOrchestrator) InspectScenario(container orchestratormodels.Cont
 
 func (c *ScenarioOrchestrator) Connect(containerRuntimeURI string) (context.Context, error) {
 	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.WithAPIVersionNegotiation(), client.WithHost(containerRuntimeURI))
+	cli, host, err := utils.NewDockerClient(containerRuntimeURI, c.Config)
 	if err != nil {
 		return nil, err
 	}
+	if host.IsRemote() {
+		ctx = utils.ContextWithContainerHost(ctx, host)
+	}
 
 	ctxWithClient := contextWithDockerClient(ctx, cli)
 	return ctxWithClient, nil
@@ -499,16 +617,17 @@ func (c *ScenarioOrchestrator) ResolveContainerName(containerName string, ctx co
 		return nil, err
 	}
 
-	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true})
+	containers, err := cli.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: c.labelFilter()})
 	if err != nil {
 		return nil, err
 	}
+	var candidates []orchestratormodels.Container
 	for _, container := range containers {
-		if strings.Contains(container.Names[0], containerName) {
-			return &container.ID, nil
+		for _, name := range container.Names {
+			candidates = append(candidates, orchestratormodels.Container{Name: name, ID: container.ID})
 		}
 	}
-	return nil, nil
+	return utils.ResolveContainer(candidates, containerName)
 }
 
 // common functions
@@ -536,8 +655,9 @@ func (c *ScenarioOrchestrator) RunAttached(
 	registry *providermodels.RegistryV2,
 	publishPorts []string,
 	podmanCreate *scenarioorchestrator.PodmanCreateOptions,
+	timeout *time.Duration,
 ) (*string, error) {
-	containerID, err := scenarioorchestrator.CommonRunAttached(image, containerName, env, cache, volumeMounts, stdout, stderr, c, commChan, ctx, registry, publishPorts, podmanCreate)
+	containerID, err := scenarioorchestrator.CommonRunAttached(image, containerName, env, cache, volumeMounts, stdout, stderr, c, commChan, ctx, registry, publishPorts, podmanCreate, timeout)
 	return containerID, err
 }
 
@@ -546,18 +666,51 @@ func (c *ScenarioOrchestrator) RunGraph(
 	resolvedGraph orchestratormodels.ResolvedGraph,
 	extraEnv map[string]string,
 	extraVolumeMounts map[string]string,
-	cache bool,
+	stdout io.Writer,
+	pullPolicy orchestratormodels.PullPolicy,
+	schedule orchestratormodels.Schedule,
 	commChannel chan *orchestratormodels.GraphCommChannel,
+	ctx context.Context,
 	registry *providermodels.RegistryV2,
 	userID *int,
+	labels *orchestratormodels.ContainerLabels,
+	runDir string,
 ) {
-	scenarioorchestrator.CommonRunGraph(scenarios, resolvedGraph, extraEnv, extraVolumeMounts, cache, commChannel, c, c.Config, registry, userID)
+	scenarioorchestrator.CommonRunGraph(scenarios, resolvedGraph, extraEnv, extraVolumeMounts, stdout, pullPolicy, schedule, commChannel, ctx, c, c.Config, registry, userID, labels, runDir)
 }
 
-func (c *ScenarioOrchestrator) PrintContainerRuntime() {
-	scenarioorchestrator.CommonPrintRuntime(c.ContainerRuntime)
+func (c *ScenarioOrchestrator) PrintContainerRuntime(w io.Writer) {
+	scenarioorchestrator.CommonPrintRuntime(w, c.ContainerRuntime)
 }
 
-func (c *ScenarioOrchestrator) ListRunningScenarios(ctx context.Context) (*[]orchestratormodels.ScenarioContainer, error) {
-	return scenarioorchestrator.CommonListRunningScenarios(c, ctx)
+func (c *ScenarioOrchestrator) ListRunningScenarios(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.ScenarioContainer, error) {
+	return scenarioorchestrator.CommonListRunningScenarios(c, ctx, filter)
+}
+
+// applyResourcesAndSecurity sets the resource limits and the security options
+// of the scenario container
+func applyResourcesAndSecurity(containerCfg *dockercontainer.Config, hostCfg *dockercontainer.HostConfig, createOpts *scenarioorchestrator.PodmanCreateOptions) error {
+	if createOpts.Resources != nil {
+		nanoCPUs, err := createOpts.Resources.NanoCPUs()
+		if err != nil {
+			return err
+		}
+		memory, err := createOpts.Resources.MemoryBytes()
+		if err != nil {
+			return err
+		}
+		hostCfg.NanoCPUs = nanoCPUs
+		hostCfg.Memory = memory
+		if createOpts.Resources.Pids > 0 {
+			pids := createOpts.Resources.Pids
+			hostCfg.PidsLimit = &pids
+		}
+	}
+	if createOpts.Security != nil {
+		hostCfg.CapAdd = createOpts.Security.CapAdd
+		hostCfg.CapDrop = createOpts.Security.CapDrop
+		hostCfg.ReadonlyRootfs = createOpts.Security.ReadOnlyRootfs
+		containerCfg.User = createOpts.Security.User
+	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	dockercontainer "github.com/docker/docker/api/types/container"
 	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/scenarioorchestratortest"
 	"github.com/stretchr/testify/assert"
@@ -51,6 +52,27 @@ func TestParseHostPortPublish(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestApplyResourcesAndSecurity(t *testing.T) {
+	containerCfg := &dockercontainer.Config{}
+	hostCfg := &dockercontainer.HostConfig{}
+	err := applyResourcesAndSecurity(containerCfg, hostCfg, &scenarioorchestrator.PodmanCreateOptions{
+		Resources: &models.Resources{CPU: "2", Memory: "1g", Pids: 200},
+		Security:  &models.Security{CapDrop: []string{"ALL"}, ReadOnlyRootfs: true, User: "krkn"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2000000000), hostCfg.NanoCPUs)
+	assert.Equal(t, int64(1024*1024*1024), hostCfg.Memory)
+	assert.Equal(t, int64(200), *hostCfg.PidsLimit)
+	assert.Equal(t, []string{"ALL"}, []string(hostCfg.CapDrop))
+	assert.True(t, hostCfg.ReadonlyRootfs)
+	assert.Equal(t, "krkn", containerCfg.User)
+
+	err = applyResourcesAndSecurity(containerCfg, hostCfg, &scenarioorchestrator.PodmanCreateOptions{
+		Resources: &models.Resources{Memory: "a lot"},
+	})
+	assert.NotNil(t, err)
+}
+
 func TestScenarioOrchestrator_Docker_Connect(t *testing.T) {
 	config := scenarioorchestratortest.CommonGetTestConfig(t)
 	sopodman := ScenarioOrchestrator{Config: config, ContainerRuntime: models.Docker}
@@ -112,7 +134,7 @@ func TestScenarioOrchestrator_Docker_CleanContainers(t *testing.T) {
 	scenarioorchestratortest.CommonTestScenarioOrchestratorRunAttached(t, &sodocker, config, 5)
 	foundContainers := findContainers(t, config, ctx)
 	assert.Greater(t, len(foundContainers), 0)
-	numcontainers, err := sodocker.CleanContainers(ctx)
+	numcontainers, err := sodocker.CleanContainers(ctx, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, len(foundContainers), *numcontainers)
 	foundContainers = findContainers(t, config, ctx)
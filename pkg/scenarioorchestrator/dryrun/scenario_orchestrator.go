@@ -0,0 +1,209 @@
+// Package dryrun provides a no-op implementation of the orchestrator that records the
+// containers that would be started instead of running them
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+)
+
+type ScenarioOrchestrator struct {
+	Config config.Config
+	// ContainerRuntime is the runtime the plan would have been run with
+	ContainerRuntime orchestratormodels.ContainerRuntime
+	plan             []orchestratormodels.PlannedScenario
+	mu               sync.Mutex
+}
+
+func NewScenarioOrchestrator(config config.Config, containerRuntime orchestratormodels.ContainerRuntime) *ScenarioOrchestrator {
+	return &ScenarioOrchestrator{
+		Config:           config,
+		ContainerRuntime: containerRuntime,
+	}
+}
+
+// Plan returns the containers recorded so far in the order they would have been started
+func (c *ScenarioOrchestrator) Plan() []orchestratormodels.PlannedScenario {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	plan := make([]orchestratormodels.PlannedScenario, len(c.plan))
+	copy(plan, c.plan)
+	return plan
+}
+
+func (c *ScenarioOrchestrator) record(planned ...orchestratormodels.PlannedScenario) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.plan = append(c.plan, planned...)
+}
+
+func (c *ScenarioOrchestrator) Connect(containerRuntimeURI string) (context.Context, error) {
+	return context.Background(), nil
+}
+
+func (c *ScenarioOrchestrator) Run(
+	image string,
+	containerName string,
+	env map[string]string,
+	cache bool,
+	volumeMounts map[string]string,
+	commChan *chan *string,
+	ctx context.Context,
+	registry *providermodels.RegistryV2,
+	publishPorts []string,
+	podmanCreate *scenarioorchestrator.PodmanCreateOptions,
+) (*string, error) {
+	plannedEnv := make(map[string]string)
+	for k, v := range env {
+		plannedEnv[k] = v
+	}
+	plannedVolumes := make(map[string]string)
+	for k, v := range volumeMounts {
+		plannedVolumes[k] = v
+	}
+	planned := orchestratormodels.PlannedScenario{
+		ContainerName: containerName,
+		Image:         image,
+		Env:           plannedEnv,
+		Volumes:       plannedVolumes,
+		PullPolicy:    scenarioorchestrator.ResolvePullPolicy(cache, podmanCreate),
+	}
+	if podmanCreate != nil {
+		planned.Resources = podmanCreate.Resources
+		planned.Security = podmanCreate.Security
+	}
+	c.record(planned)
+	if commChan != nil {
+		close(*commChan)
+	}
+	return &containerName, nil
+}
+
+func (c *ScenarioOrchestrator) RunAttached(
+	image string,
+	containerName string,
+	env map[string]string,
+	cache bool,
+	volumeMounts map[string]string,
+	stdout io.Writer,
+	stderr io.Writer,
+	commChan *chan *string,
+	ctx context.Context,
+	registry *providermodels.RegistryV2,
+	publishPorts []string,
+	podmanCreate *scenarioorchestrator.PodmanCreateOptions,
+	timeout *time.Duration,
+) (*string, error) {
+	return c.Run(image, containerName, env, cache, volumeMounts, commChan, ctx, registry, publishPorts, podmanCreate)
+}
+
+func (c *ScenarioOrchestrator) RunGraph(
+	scenarios orchestratormodels.ScenarioSet,
+	resolvedGraph orchestratormodels.ResolvedGraph,
+	extraEnv map[string]string,
+	extraVolumeMounts map[string]string,
+	stdout io.Writer,
+	pullPolicy orchestratormodels.PullPolicy,
+	schedule orchestratormodels.Schedule,
+	commChannel chan *orchestratormodels.GraphCommChannel,
+	ctx context.Context,
+	registry *providermodels.RegistryV2,
+	userID *int,
+	labels *orchestratormodels.ContainerLabels,
+	runDir string,
+) {
+	plan := scenarioorchestrator.ResolveGraphPlan(scenarios, resolvedGraph, extraEnv, extraVolumeMounts, c.Config)
+	for i := range plan {
+		plan[i].PullPolicy = pullPolicy
+	}
+	c.record(plan...)
+	for _, planned := range plan {
+		layer := planned.Layer
+		scenarioID := planned.ScenarioID
+		commChannel <- &orchestratormodels.GraphCommChannel{Layer: &layer, ScenarioID: &scenarioID, ScenarioLogFile: nil, Err: nil}
+	}
+	commChannel <- nil
+}
+
+// PullImage pulls nothing, the pull policy is recorded in the plan
+func (c *ScenarioOrchestrator) PullImage(image string, pullPolicy orchestratormodels.PullPolicy, registry *providermodels.RegistryV2, commChan *chan *string, ctx context.Context) error {
+	return nil
+}
+
+func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*int, error) {
+	count := 0
+	return &count, nil
+}
+
+func (c *ScenarioOrchestrator) ListFinishedContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.Container, error) {
+	containers := make([]orchestratormodels.Container, 0)
+	return &containers, nil
+}
+
+func (c *ScenarioOrchestrator) RemoveContainer(containerID *string, ctx context.Context) error {
+	return nil
+}
+
+func (c *ScenarioOrchestrator) AttachWait(containerID *string, stdout io.Writer, stderr io.Writer, ctx context.Context) (*bool, error) {
+	interrupted := false
+	return &interrupted, nil
+}
+
+func (c *ScenarioOrchestrator) Attach(containerID *string, signalChannel chan os.Signal, stdout io.Writer, stderr io.Writer, ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (c *ScenarioOrchestrator) Logs(containerID *string, options orchestratormodels.LogOptions, stdout io.Writer, stderr io.Writer, ctx context.Context) error {
+	return nil
+}
+
+func (c *ScenarioOrchestrator) Kill(containerID *string, ctx context.Context) error {
+	return nil
+}
+
+func (c *ScenarioOrchestrator) ListRunningContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*map[int64]orchestratormodels.Container, error) {
+	containers := make(map[int64]orchestratormodels.Container)
+	return &containers, nil
+}
+
+func (c *ScenarioOrchestrator) ListRunningScenarios(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.ScenarioContainer, error) {
+	var scenarios []orchestratormodels.ScenarioContainer
+	return &scenarios, nil
+}
+
+func (c *ScenarioOrchestrator) InspectScenario(container orchestratormodels.Container, ctx context.Context) (*orchestratormodels.ScenarioContainer, error) {
+	return nil, nil
+}
+
+func (c *ScenarioOrchestrator) GetContainerRuntimeSocket(userID *int) (*string, error) {
+	socket := ""
+	return &socket, nil
+}
+
+func (c *ScenarioOrchestrator) GetContainerRuntime() orchestratormodels.ContainerRuntime {
+	return c.ContainerRuntime
+}
+
+func (c *ScenarioOrchestrator) PrintContainerRuntime(w io.Writer) {
+	green := color.New(color.FgGreen).SprintFunc()
+	boldGreen := color.New(color.FgHiGreen, color.Bold).SprintFunc()
+	_, _ = fmt.Fprintf(w, "\n\n%s %s %s\n\n", green("container runtime:"), boldGreen(c.ContainerRuntime.String()), green("(dry run)"))
+}
+
+func (c *ScenarioOrchestrator) GetConfig() config.Config {
+	return c.Config
+}
+
+func (c *ScenarioOrchestrator) ResolveContainerName(containerName string, ctx context.Context) (*string, error) {
+	return nil, nil
+}
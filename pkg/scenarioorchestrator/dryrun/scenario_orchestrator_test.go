@@ -0,0 +1,94 @@
+package dryrun
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarioOrchestrator_DryRun_RunGraph(t *testing.T) {
+	conf, err := config.LoadConfig()
+	assert.Nil(t, err)
+	so := NewScenarioOrchestrator(conf, models.Podman)
+	root := "root"
+	nodes := models.ScenarioSet{
+		"root": models.ScenarioNode{Scenario: models.Scenario{
+			Name:    "dummy-scenario",
+			Image:   "quay.io/krkn-chaos/krkn-hub:dummy-scenario",
+			Env:     map[string]string{"END": "10", "SHARED": "node"},
+			Volumes: map[string]string{"/tmp/node": "/node"},
+		}},
+		"child": models.ScenarioNode{Scenario: models.Scenario{
+			Name:  "node-cpu-hog",
+			Image: "quay.io/krkn-chaos/krkn-hub:node-cpu-hog",
+		}, Parents: models.Dependencies{root}, Timeout: "15m",
+			Resources: &models.Resources{CPU: "0.5", Memory: "256m"},
+			Security:  &models.Security{CapDrop: []string{"ALL"}, ReadOnlyRootfs: true}},
+	}
+	resolvedGraph := models.ResolvedGraph{{"root"}, {"child"}}
+	extraEnv := map[string]string{"SHARED": "global", "GLOBAL": "1"}
+	extraVolumes := map[string]string{"/tmp/kubeconfig": conf.KubeconfigPath}
+
+	commChannel := make(chan *models.GraphCommChannel)
+	go so.RunGraph(nodes, resolvedGraph, extraEnv, extraVolumes, io.Discard, models.PullIfNotPresent, models.Schedule{}, commChannel, context.Background(), nil, nil, nil, "")
+	var messages []*models.GraphCommChannel
+	for c := range commChannel {
+		if c == nil {
+			break
+		}
+		assert.Nil(t, c.Err)
+		messages = append(messages, c)
+	}
+	assert.Len(t, messages, 2)
+
+	plan := so.Plan()
+	assert.Len(t, plan, 2)
+	assert.Equal(t, 0, plan[0].Layer)
+	assert.Equal(t, "root", plan[0].ScenarioID)
+	assert.Equal(t, "quay.io/krkn-chaos/krkn-hub:dummy-scenario", plan[0].Image)
+	assert.Regexp(t, "^krknctl-root-[0-9]+$", plan[0].ContainerName)
+	// node env overrides the plan wide env
+	assert.Equal(t, "node", plan[0].Env["SHARED"])
+	assert.Equal(t, "1", plan[0].Env["GLOBAL"])
+	assert.Equal(t, "10", plan[0].Env["END"])
+	assert.Contains(t, plan[0].Env, conf.EnvResiliencyEnabledMode)
+	assert.Equal(t, "/node", plan[0].Volumes["/tmp/node"])
+	assert.Equal(t, conf.KubeconfigPath, plan[0].Volumes["/tmp/kubeconfig"])
+	assert.Equal(t, models.PullIfNotPresent, plan[0].PullPolicy)
+
+	assert.Equal(t, 1, plan[1].Layer)
+	assert.Equal(t, "child", plan[1].ScenarioID)
+	assert.Equal(t, "global", plan[1].Env["SHARED"])
+	assert.Equal(t, "", plan[0].Timeout)
+	assert.Equal(t, "15m", plan[1].Timeout)
+	assert.Nil(t, plan[0].Resources)
+	assert.Equal(t, "256m", plan[1].Resources.Memory)
+	assert.True(t, plan[1].Security.ReadOnlyRootfs)
+}
+
+func TestScenarioOrchestrator_DryRun_RunAttached(t *testing.T) {
+	conf, err := config.LoadConfig()
+	assert.Nil(t, err)
+	so := NewScenarioOrchestrator(conf, models.Docker)
+	commChan := make(chan *string)
+	id, err := so.RunAttached("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234",
+		map[string]string{"END": "10"}, false, map[string]string{"/tmp/kubeconfig": conf.KubeconfigPath}, nil, nil, &commChan, context.Background(), nil, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "krknctl-dummy-scenario-1234", *id)
+	_, open := <-commChan
+	assert.False(t, open)
+
+	plan := so.Plan()
+	assert.Len(t, plan, 1)
+	assert.Equal(t, "krknctl-dummy-scenario-1234", plan[0].ContainerName)
+	assert.Equal(t, "10", plan[0].Env["END"])
+
+	containers, err := so.ListRunningContainers(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Len(t, *containers, 0)
+	assert.Equal(t, models.Docker, so.GetContainerRuntime())
+}
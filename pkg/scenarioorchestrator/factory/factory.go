@@ -5,6 +5,7 @@ import (
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/docker"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/kubernetes"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/podman"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
@@ -26,6 +27,8 @@ func (f *ScenarioOrchestratorFactory) NewInstance(containerEnvironment models.Co
 		return f.getOrchestratorInstance(models.Podman)
 	case models.Docker:
 		return f.getOrchestratorInstance(models.Docker)
+	case models.Kubernetes:
+		return f.getOrchestratorInstance(models.Kubernetes)
 	case models.Both:
 		defaultContainerEnvironment := utils.EnvironmentFromString(f.Config.DefaultContainerPlatform)
 		return f.getOrchestratorInstance(defaultContainerEnvironment)
@@ -39,6 +42,11 @@ func (f *ScenarioOrchestratorFactory) getOrchestratorInstance(containerEnvironme
 			Config:           f.Config,
 			ContainerRuntime: containerEnvironment,
 		}
+	} else if containerEnvironment == models.Kubernetes {
+		return &kubernetes.ScenarioOrchestrator{
+			Config:           f.Config,
+			ContainerRuntime: containerEnvironment,
+		}
 	} else {
 		return &docker.ScenarioOrchestrator{
 			Config:           f.Config,
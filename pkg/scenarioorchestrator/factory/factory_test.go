@@ -3,6 +3,7 @@ package factory
 import (
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/docker"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/kubernetes"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/podman"
 	"github.com/stretchr/testify/assert"
@@ -12,6 +13,7 @@ import (
 func TestScenarioOrchestratorFactory_NewInstance(t *testing.T) {
 	typeProviderPodman := &podman.ScenarioOrchestrator{}
 	typeProviderDocker := &docker.ScenarioOrchestrator{}
+	typeProviderKubernetes := &kubernetes.ScenarioOrchestrator{}
 	conf, err := config.LoadConfig()
 	assert.Nil(t, err)
 	assert.NotNil(t, conf)
@@ -25,4 +27,8 @@ func TestScenarioOrchestratorFactory_NewInstance(t *testing.T) {
 	assert.NotNil(t, scenarioPodman)
 	assert.IsType(t, scenarioPodman, typeProviderPodman)
 
+	scenarioKubernetes := factory.NewInstance(models.Kubernetes)
+	assert.NotNil(t, scenarioKubernetes)
+	assert.IsType(t, scenarioKubernetes, typeProviderKubernetes)
+
 }
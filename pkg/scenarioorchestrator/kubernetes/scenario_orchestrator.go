@@ -0,0 +1,973 @@
+// Package kubernetes provides the implementation for running scenarios as Kubernetes Jobs
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// jobNameLabel is the label set by the Job controller on the pods it creates
+	jobNameLabel          = "job-name"
+	scenarioContainerName = "scenario"
+	filesVolumeName       = "krknctl-files"
+	podPollInterval       = 1 * time.Second
+	maxResourceName       = 63
+	managedByValue        = "krknctl"
+	filesSecretKey        = "file-%d"
+	filesSecretName       = "%s-files"
+	pullSecretName        = "%s-pull"
+	envSecretName         = "%s-env"
+	// podExitTimeout is how long the pod status is polled for the exit code once the logs are over
+	podExitTimeout = 1 * time.Minute
+)
+
+type ScenarioOrchestrator struct {
+	Config           config.Config
+	ContainerRuntime orchestratormodels.ContainerRuntime
+}
+
+var invalidNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// jobName converts a container name into a valid DNS-1123 label usable as Job name.
+// The suffix is preserved when the name needs to be truncated because it carries the
+// timestamp that makes the name unique.
+func jobName(containerName string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(containerName), "-")
+	name = strings.Trim(name, "-")
+	// leaves room for the secret suffixes
+	limit := maxResourceName - len("-files")
+	if len(name) > limit {
+		name = name[len(name)-limit:]
+		name = strings.Trim(name, "-")
+	}
+	return name
+}
+
+func (c *ScenarioOrchestrator) managedBySelector() string {
+	return fmt.Sprintf("%s=%s", c.Config.KubernetesManagedByLabel, managedByValue)
+}
+
+// runSelector selects the resources created by krknctl and stamped with a run ID
+func (c *ScenarioOrchestrator) runSelector() string {
+	return fmt.Sprintf("%s,%s", c.managedBySelector(), c.Config.LabelRunID)
+}
+
+var invalidLabelValueChars = regexp.MustCompile("[^A-Za-z0-9_.-]+")
+
+// labelValue converts a value into a valid label value, the user name or the node ID
+// may contain characters that are not allowed in labels
+func labelValue(value string) string {
+	value = invalidLabelValueChars.ReplaceAllString(value, "-")
+	if len(value) > maxResourceName {
+		value = value[:maxResourceName]
+	}
+	return strings.Trim(value, "-_.")
+}
+
+// labelFilter converts the filter the same way the labels are converted when stamped
+func labelFilter(filter *orchestratormodels.ContainerLabels) *orchestratormodels.ContainerLabels {
+	if filter == nil {
+		return nil
+	}
+	return &orchestratormodels.ContainerLabels{
+		RunID:     labelValue(filter.RunID),
+		GraphHash: labelValue(filter.GraphHash),
+		NodeID:    labelValue(filter.NodeID),
+		Scenario:  labelValue(filter.Scenario),
+		Version:   labelValue(filter.Version),
+		User:      labelValue(filter.User),
+	}
+}
+
+func (c *ScenarioOrchestrator) Run(
+	image string,
+	containerName string,
+	env map[string]string,
+	cache bool,
+	volumeMounts map[string]string,
+	commChan *chan *string,
+	ctx context.Context,
+	registry *providermodels.RegistryV2,
+	publishPorts []string,
+	podmanCreate *scenarioorchestrator.PodmanCreateOptions,
+) (containerID *string, err error) {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(publishPorts) > 0 {
+		return nil, errors.New("publishing ports is not supported by the Kubernetes runtime")
+	}
+	// the kubelet honors only the username and password of the pull secrets
+	if registry != nil && registry.HasToken() {
+		return nil, errors.New("token authentication is not supported by the Kubernetes runtime, set the private registry username and password instead")
+	}
+	namespace := c.Config.KubernetesNamespace
+	if err := ensureNamespace(ctx, cli, namespace); err != nil {
+		return nil, err
+	}
+
+	name := jobName(containerName)
+	labels := map[string]string{c.Config.KubernetesManagedByLabel: managedByValue}
+	for k, v := range scenarioorchestrator.CommonContainerLabels(podmanCreate, c.Config) {
+		if value := labelValue(v); value != "" {
+			labels[k] = value
+		}
+	}
+
+	// the secrets are owned by the job once it exists, until then they are
+	// removed together with the job if the submission fails
+	var ownedSecrets []string
+	var created *batchv1.Job
+	defer func() {
+		if err == nil {
+			return
+		}
+		cleanupCtx := context.WithoutCancel(ctx)
+		propagation := metav1.DeletePropagationBackground
+		if created != nil {
+			_ = cli.BatchV1().Jobs(namespace).Delete(cleanupCtx, created.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		}
+		for _, secretName := range ownedSecrets {
+			_ = cli.CoreV1().Secrets(namespace).Delete(cleanupCtx, secretName, metav1.DeleteOptions{})
+		}
+	}()
+
+	// the env values may carry credentials so they are read from a secret
+	// instead of being written in the job spec
+	var envVars []corev1.EnvVar
+	if len(env) > 0 {
+		secret, secretEnv := envSecret(fmt.Sprintf(envSecretName, name), namespace, labels, env)
+		if _, err := cli.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return nil, err
+		}
+		ownedSecrets = append(ownedSecrets, secret.Name)
+		envVars = secretEnv
+	}
+
+	// host files are not reachable from the cluster so they are shipped in a secret
+	// and mounted in the scenario container at the requested path
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	if len(volumeMounts) > 0 {
+		secret, fileMounts, err := filesSecret(fmt.Sprintf(filesSecretName, name), namespace, labels, volumeMounts)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := cli.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return nil, err
+		}
+		ownedSecrets = append(ownedSecrets, secret.Name)
+		volumes = append(volumes, corev1.Volume{
+			Name:         filesVolumeName,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret.Name}},
+		})
+		mounts = fileMounts
+	}
+
+	var pullSecrets []corev1.LocalObjectReference
+	if registry != nil {
+		secret, err := pullSecret(fmt.Sprintf(pullSecretName, name), namespace, labels, registry)
+		if err != nil {
+			return nil, err
+		}
+		if secret != nil {
+			if _, err := cli.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+				return nil, err
+			}
+			ownedSecrets = append(ownedSecrets, secret.Name)
+			pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret.Name})
+		}
+	}
+
+	pullPolicy := corev1.PullAlways
+	switch scenarioorchestrator.ResolvePullPolicy(cache, podmanCreate) {
+	case orchestratormodels.PullIfNotPresent:
+		pullPolicy = corev1.PullIfNotPresent
+	case orchestratormodels.PullNever:
+		pullPolicy = corev1.PullNever
+	}
+
+	volumesAnnotation, err := json.Marshal(volumeMounts)
+	if err != nil {
+		return nil, err
+	}
+
+	container := corev1.Container{
+		Name:            scenarioContainerName,
+		Image:           image,
+		Env:             envVars,
+		VolumeMounts:    mounts,
+		ImagePullPolicy: pullPolicy,
+	}
+	if podmanCreate != nil {
+		if err := applyResourcesAndSecurity(&container, podmanCreate); err != nil {
+			return nil, err
+		}
+	}
+
+	podLabels := map[string]string{jobNameLabel: name}
+	for k, v := range labels {
+		podLabels[k] = v
+	}
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				c.Config.KubernetesNameAnnotation:    containerName,
+				c.Config.KubernetesVolumesAnnotation: string(volumesAnnotation),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyNever,
+					ImagePullSecrets: pullSecrets,
+					Volumes:          volumes,
+					Containers:       []corev1.Container{container},
+				},
+			},
+		},
+	}
+
+	if commChan != nil {
+		message := fmt.Sprintf("creating job %s in namespace %s", name, namespace)
+		*commChan <- &message
+		close(*commChan)
+	}
+
+	created, err = cli.BatchV1().Jobs(namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// secrets are garbage collected with the job
+	for _, secretName := range ownedSecrets {
+		secret, err := cli.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+			Name:       created.Name,
+			UID:        created.UID,
+		})
+		if _, err := cli.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &created.Name, nil
+}
+
+// applyResourcesAndSecurity sets the limits and the security context of the
+// scenario container, pids limits and user names are rejected upfront because
+// they can't be expressed in a pod spec
+func applyResourcesAndSecurity(container *corev1.Container, podmanCreate *scenarioorchestrator.PodmanCreateOptions) error {
+	if podmanCreate.Resources != nil {
+		if err := podmanCreate.Resources.Validate(orchestratormodels.Kubernetes); err != nil {
+			return err
+		}
+		nanoCPUs, err := podmanCreate.Resources.NanoCPUs()
+		if err != nil {
+			return err
+		}
+		memory, err := podmanCreate.Resources.MemoryBytes()
+		if err != nil {
+			return err
+		}
+		limits := corev1.ResourceList{}
+		if nanoCPUs > 0 {
+			limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(nanoCPUs/1e6, resource.DecimalSI)
+		}
+		if memory > 0 {
+			limits[corev1.ResourceMemory] = *resource.NewQuantity(memory, resource.BinarySI)
+		}
+		if len(limits) > 0 {
+			container.Resources.Limits = limits
+		}
+	}
+	if podmanCreate.Security != nil {
+		if err := podmanCreate.Security.Validate(orchestratormodels.Kubernetes); err != nil {
+			return err
+		}
+		securityContext := &corev1.SecurityContext{}
+		if len(podmanCreate.Security.CapAdd) > 0 || len(podmanCreate.Security.CapDrop) > 0 {
+			securityContext.Capabilities = &corev1.Capabilities{}
+			for _, c := range podmanCreate.Security.CapAdd {
+				securityContext.Capabilities.Add = append(securityContext.Capabilities.Add, corev1.Capability(strings.TrimPrefix(strings.ToUpper(c), "CAP_")))
+			}
+			for _, c := range podmanCreate.Security.CapDrop {
+				securityContext.Capabilities.Drop = append(securityContext.Capabilities.Drop, corev1.Capability(strings.TrimPrefix(strings.ToUpper(c), "CAP_")))
+			}
+		}
+		if podmanCreate.Security.ReadOnlyRootfs {
+			readOnly := true
+			securityContext.ReadOnlyRootFilesystem = &readOnly
+		}
+		if podmanCreate.Security.User != "" {
+			uid, gid, err := podmanCreate.Security.UserID()
+			if err != nil {
+				return err
+			}
+			securityContext.RunAsUser = uid
+			securityContext.RunAsGroup = gid
+		}
+		container.SecurityContext = securityContext
+	}
+	return nil
+}
+
+func ensureNamespace(ctx context.Context, cli clientset.Interface, namespace string) error {
+	_, err := cli.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	_, err = cli.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func filesSecret(name string, namespace string, labels map[string]string, volumeMounts map[string]string) (*corev1.Secret, []corev1.VolumeMount, error) {
+	var sources []string
+	for k := range volumeMounts {
+		sources = append(sources, k)
+	}
+	sort.Strings(sources)
+
+	data := make(map[string][]byte)
+	var mounts []corev1.VolumeMount
+	for i, source := range sources {
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.IsDir() {
+			return nil, nil, fmt.Errorf("%s is a directory, only files can be mounted with the Kubernetes runtime", source)
+		}
+		content, err := os.ReadFile(filepath.Clean(source))
+		if err != nil {
+			return nil, nil, err
+		}
+		key := fmt.Sprintf(filesSecretKey, i)
+		data[key] = content
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      filesVolumeName,
+			MountPath: volumeMounts[source],
+			SubPath:   key,
+			ReadOnly:  true,
+		})
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}, mounts, nil
+}
+
+// envSecret stores the env values in a secret and returns the env of the scenario
+// container referencing them, the names stay readable in the job spec
+func envSecret(name string, namespace string, labels map[string]string, env map[string]string) (*corev1.Secret, []corev1.EnvVar) {
+	data := make(map[string][]byte)
+	var envVars []corev1.EnvVar
+	for k, v := range env {
+		data[k] = []byte(v)
+		envVars = append(envVars, corev1.EnvVar{
+			Name: k,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				Key:                  k,
+			}},
+		})
+	}
+	sort.Slice(envVars, func(i, j int) bool { return envVars[i].Name < envVars[j].Name })
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}, envVars
+}
+
+func pullSecret(name string, namespace string, labels map[string]string, registry *providermodels.RegistryV2) (*corev1.Secret, error) {
+	if registry.Username == nil || *registry.Username == "" {
+		return nil, nil
+	}
+	password := ""
+	if registry.Password != nil {
+		password = *registry.Password
+	}
+	auth := map[string]string{
+		"auth": base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", *registry.Username, password))),
+	}
+	dockerConfig, err := json.Marshal(map[string]map[string]map[string]string{
+		"auths": {registry.RegistryURL: auth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+	}, nil
+}
+
+// jobPod returns the most recent pod created by the job or nil if it has not been scheduled yet
+func (c *ScenarioOrchestrator) jobPod(ctx context.Context, cli clientset.Interface, name string) (*corev1.Pod, error) {
+	pods, err := cli.CoreV1().Pods(c.Config.KubernetesNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", jobNameLabel, name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var latest *corev1.Pod
+	for i := range pods.Items {
+		if latest == nil || pods.Items[i].CreationTimestamp.After(latest.CreationTimestamp.Time) {
+			latest = &pods.Items[i]
+		}
+	}
+	return latest, nil
+}
+
+// scenarioStatus returns the status of the scenario container of the pod, nil if not reported yet
+func scenarioStatus(pod *corev1.Pod) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == scenarioContainerName {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// podStartError returns the reason why a pending pod can't start, nil if it may still start.
+// The job is never retried so these pods would stay pending forever
+func podStartError(pod *corev1.Pod) error {
+	status := scenarioStatus(pod)
+	if status == nil || status.State.Waiting == nil {
+		return nil
+	}
+	switch status.State.Waiting.Reason {
+	case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull", "CreateContainerConfigError", "CreateContainerError":
+		return fmt.Errorf("pod %s can't start: %s: %s", pod.Name, status.State.Waiting.Reason, status.State.Waiting.Message)
+	}
+	return nil
+}
+
+// podExited reports whether the exit code of the scenario is known
+func podExited(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+	status := scenarioStatus(pod)
+	return status != nil && status.State.Terminated != nil
+}
+
+// waitPodExit polls the pod of the job until its exit code is known, the end of the log stream
+// is often seen before the pod status is updated
+func (c *ScenarioOrchestrator) waitPodExit(ctx context.Context, cli clientset.Interface, name string, signalChannel chan os.Signal) (bool, error) {
+	deadline := time.Now().Add(podExitTimeout)
+	for {
+		pod, err := c.jobPod(ctx, cli, name)
+		if err != nil {
+			return false, err
+		}
+		if pod == nil {
+			return false, fmt.Errorf("the pod of job %s has been removed before its end", name)
+		}
+		if podExited(pod) {
+			return false, nil
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("pod %s has not terminated %s after the end of its logs", pod.Name, podExitTimeout)
+		}
+		select {
+		case <-signalChannel:
+			return true, nil
+		case <-time.After(podPollInterval):
+		}
+	}
+}
+
+func (c *ScenarioOrchestrator) Attach(containerID *string, signalChannel chan os.Signal, stdout io.Writer, stderr io.Writer, ctx context.Context) (bool, error) {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// waits for the pod to leave the pending phase before streaming the logs
+	var pod *corev1.Pod
+	for {
+		pod, err = c.jobPod(ctx, cli, *containerID)
+		if err != nil {
+			return false, err
+		}
+		if pod != nil && pod.Status.Phase != corev1.PodPending {
+			break
+		}
+		if pod != nil {
+			if err := podStartError(pod); err != nil {
+				return false, err
+			}
+		}
+		select {
+		case <-signalChannel:
+			return true, nil
+		case <-time.After(podPollInterval):
+		}
+	}
+
+	// the log stream is closed if the scenario is killed before the end of the logs
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	errorChan := make(chan error, 1)
+	finishChan := make(chan error, 1)
+	go func() {
+		stream, err := cli.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Follow: true}).Stream(streamCtx)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		_, err = io.Copy(stdout, stream)
+		closeErr := stream.Close()
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		finishChan <- closeErr
+	}()
+
+	select {
+	case err := <-errorChan:
+		return false, err
+	case <-signalChannel:
+		return true, nil
+	case err := <-finishChan:
+		if err != nil {
+			return false, err
+		}
+	}
+	return c.waitPodExit(ctx, cli, *containerID, signalChannel)
+}
+
+func (c *ScenarioOrchestrator) Logs(containerID *string, options orchestratormodels.LogOptions, stdout io.Writer, stderr io.Writer, ctx context.Context) error {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	pod, err := c.jobPod(ctx, cli, *containerID)
+	if err != nil {
+		return err
+	}
+	if pod == nil {
+		return fmt.Errorf("job %s has no pod", *containerID)
+	}
+	podLogOptions := corev1.PodLogOptions{Follow: options.Follow, Timestamps: options.Timestamps}
+	if options.Tail >= 0 {
+		tail := int64(options.Tail)
+		podLogOptions.TailLines = &tail
+	}
+	if !options.Since.IsZero() {
+		since := metav1.NewTime(options.Since)
+		podLogOptions.SinceTime = &since
+	}
+	stream, err := cli.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &podLogOptions).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(stdout, stream)
+	closeErr := stream.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (c *ScenarioOrchestrator) Kill(containerID *string, ctx context.Context) error {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = cli.BatchV1().Jobs(c.Config.KubernetesNamespace).Delete(ctx, *containerID, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// PullImage pulls nothing, the images are pulled by the kubelet honoring
+// the pull policy set on the job container
+func (c *ScenarioOrchestrator) PullImage(image string, pullPolicy orchestratormodels.PullPolicy, registry *providermodels.RegistryV2, commChan *chan *string, ctx context.Context) error {
+	return nil
+}
+
+func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*int, error) {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := cli.BatchV1().Jobs(c.Config.KubernetesNamespace).List(ctx, metav1.ListOptions{LabelSelector: c.runSelector()})
+	if err != nil {
+		return nil, err
+	}
+	propagation := metav1.DeletePropagationBackground
+	containerCount := 0
+	for _, job := range jobs.Items {
+		labels := utils.ContainerLabelsFromMap(job.Labels, c.Config)
+		if job.Status.Active > 0 || labels == nil || !labels.Matches(labelFilter(filter)) {
+			continue
+		}
+		err := cli.BatchV1().Jobs(job.Namespace).Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil {
+			return nil, err
+		}
+		containerCount++
+	}
+	return &containerCount, nil
+}
+
+// ListFinishedContainers returns the jobs without active pods, the exit status is the one
+// of the scenario container in the last pod of the job
+func (c *ScenarioOrchestrator) ListFinishedContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.Container, error) {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := cli.BatchV1().Jobs(c.Config.KubernetesNamespace).List(ctx, metav1.ListOptions{LabelSelector: c.runSelector()})
+	if err != nil {
+		return nil, err
+	}
+	finished := make([]orchestratormodels.Container, 0)
+	for _, job := range jobs.Items {
+		labels := utils.ContainerLabelsFromMap(job.Labels, c.Config)
+		if job.Status.Active > 0 || labels == nil || !labels.Matches(labelFilter(filter)) {
+			continue
+		}
+		container := orchestratormodels.Container{
+			Name:    job.Name,
+			ID:      job.Name,
+			Started: job.CreationTimestamp.Unix(),
+			Status:  strings.ToLower(string(corev1.PodSucceeded)),
+			Labels:  labels,
+		}
+		if name, ok := job.Annotations[c.Config.KubernetesNameAnnotation]; ok {
+			container.Name = name
+		}
+		if len(job.Spec.Template.Spec.Containers) > 0 {
+			container.Image = job.Spec.Template.Spec.Containers[0].Image
+		}
+		if job.Status.Failed > 0 {
+			container.Status = strings.ToLower(string(corev1.PodFailed))
+			container.ExitStatus = 1
+			pod, err := c.jobPod(ctx, cli, job.Name)
+			if err != nil {
+				return nil, err
+			}
+			if pod != nil {
+				for _, status := range pod.Status.ContainerStatuses {
+					if status.Name == scenarioContainerName && status.State.Terminated != nil {
+						container.ExitStatus = int(status.State.Terminated.ExitCode)
+					}
+				}
+			}
+		}
+		finished = append(finished, container)
+	}
+	return &finished, nil
+}
+
+// RemoveContainer deletes the job and its pods
+func (c *ScenarioOrchestrator) RemoveContainer(containerID *string, ctx context.Context) error {
+	return c.Kill(containerID, ctx)
+}
+
+// GetContainerRuntimeSocket returns the kubeconfig path used to reach the cluster
+func (c *ScenarioOrchestrator) GetContainerRuntimeSocket(userID *int) (*string, error) {
+	return utils.GetSocketByContainerEnvironment(orchestratormodels.Kubernetes, c.Config, userID)
+}
+
+type contextKey string
+
+const clientKey contextKey = "kubernetesClient"
+
+func contextWithClientset(ctx context.Context, cli clientset.Interface) context.Context {
+	return context.WithValue(ctx, clientKey, cli)
+}
+
+func clientsetFromContext(ctx context.Context) (clientset.Interface, error) {
+	cli, ok := ctx.Value(clientKey).(clientset.Interface)
+	if !ok {
+		return nil, errors.New("kubernetesClient not found in context")
+	}
+	return cli, nil
+}
+
+func (c *ScenarioOrchestrator) GetContainerRuntime() orchestratormodels.ContainerRuntime {
+	return c.ContainerRuntime
+}
+
+func (c *ScenarioOrchestrator) ListRunningContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*map[int64]orchestratormodels.Container, error) {
+	scenarios := make(map[int64]orchestratormodels.Container)
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := cli.CoreV1().Pods(c.Config.KubernetesNamespace).List(ctx, metav1.ListOptions{LabelSelector: c.runSelector()})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if strings.ToLower(string(pod.Status.Phase)) != c.Config.KubernetesRunningState {
+			continue
+		}
+		name, ok := pod.Labels[jobNameLabel]
+		if !ok {
+			continue
+		}
+		labels := utils.ContainerLabelsFromMap(pod.Labels, c.Config)
+		if labels == nil || !labels.Matches(labelFilter(filter)) {
+			continue
+		}
+		index := utils.ContainerNameTimestamp(name, pod.CreationTimestamp.Time)
+		// names truncated by jobName may lose the timestamp, creation timestamps have a one second resolution
+		for {
+			if _, exists := scenarios[index]; !exists {
+				break
+			}
+			index++
+		}
+		image := ""
+		if len(pod.Spec.Containers) > 0 {
+			image = pod.Spec.Containers[0].Image
+		}
+		scenarios[index] = orchestratormodels.Container{
+			Name:    name,
+			ID:      name,
+			Image:   image,
+			Started: pod.CreationTimestamp.Unix(),
+			Labels:  labels,
+		}
+	}
+	return &scenarios, nil
+}
+
+func (c *ScenarioOrchestrator) InspectScenario(container orchestratormodels.Container, ctx context.Context) (*orchestratormodels.ScenarioContainer, error) {
+	runningScenario := &orchestratormodels.ScenarioContainer{}
+	scenario := orchestratormodels.Scenario{}
+	scenario.Volumes = make(map[string]string)
+	scenario.Env = make(map[string]string)
+	runningScenario.Container = &container
+
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	job, err := cli.BatchV1().Jobs(c.Config.KubernetesNamespace).Get(ctx, container.ID, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(job.Spec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("job %s has no containers", job.Name)
+	}
+	jobContainer := job.Spec.Template.Spec.Containers[0]
+
+	container.Name = job.Name
+	container.Labels = utils.ContainerLabelsFromMap(job.Labels, c.Config)
+	if name, ok := job.Annotations[c.Config.KubernetesNameAnnotation]; ok {
+		container.Name = name
+	}
+	container.Image = jobContainer.Image
+
+	scenarioDetail := providermodels.ScenarioDetail{}
+	pod, err := c.jobPod(ctx, cli, job.Name)
+	if err != nil {
+		return nil, err
+	}
+	if pod != nil {
+		container.Status = strings.ToLower(string(pod.Status.Phase))
+		container.Started = pod.CreationTimestamp.Unix()
+		status := scenarioStatus(pod)
+		if status != nil && status.ImageID != "" {
+			digest := status.ImageID
+			scenarioDetail.Digest = &digest
+		}
+		if status != nil && status.State.Terminated != nil {
+			container.ExitStatus = int(status.State.Terminated.ExitCode)
+		} else if pod.Status.Phase == corev1.PodFailed {
+			// an evicted pod or one that failed to start has no exit code, it is not a success
+			return nil, fmt.Errorf("pod %s failed without exit status: %s %s", pod.Name, pod.Status.Reason, pod.Status.Message)
+		}
+	} else if job.Status.Failed > 0 {
+		// the pod has been removed, the exit code is lost but the scenario failed
+		container.Status = strings.ToLower(string(corev1.PodFailed))
+		container.ExitStatus = 1
+	} else {
+		container.Status = strings.ToLower(string(corev1.PodPending))
+	}
+
+	imageAndTag := strings.Split(jobContainer.Image, ":")
+	if len(imageAndTag) == 2 {
+		scenarioDetail.Name = imageAndTag[1]
+	} else {
+		scenarioDetail.Name = imageAndTag[0]
+	}
+	runningScenario.ScenarioDetail = &scenarioDetail
+
+	scenario.Name = scenarioDetail.Name
+	scenario.Image = jobContainer.Image
+	if volumes, ok := job.Annotations[c.Config.KubernetesVolumesAnnotation]; ok {
+		if err := json.Unmarshal([]byte(volumes), &scenario.Volumes); err != nil {
+			return nil, err
+		}
+	}
+	var secretEnv *corev1.Secret
+	for _, v := range jobContainer.Env {
+		if v.ValueFrom == nil || v.ValueFrom.SecretKeyRef == nil {
+			scenario.Env[v.Name] = v.Value
+			continue
+		}
+		if secretEnv == nil {
+			secretEnv, err = cli.CoreV1().Secrets(job.Namespace).Get(ctx, v.ValueFrom.SecretKeyRef.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+		}
+		scenario.Env[v.Name] = string(secretEnv.Data[v.ValueFrom.SecretKeyRef.Key])
+	}
+
+	runningScenario.Scenario = &scenario
+	return runningScenario, nil
+}
+
+// Connect builds a Kubernetes client from the kubeconfig path returned by GetContainerRuntimeSocket
+func (c *ScenarioOrchestrator) Connect(containerRuntimeURI string) (context.Context, error) {
+	ctx := context.Background()
+	restConfig, err := clientcmd.BuildConfigFromFlags("", containerRuntimeURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	cli, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return contextWithClientset(ctx, cli), nil
+}
+
+func (c *ScenarioOrchestrator) GetConfig() config.Config {
+	return c.Config
+}
+
+func (c *ScenarioOrchestrator) ResolveContainerName(containerName string, ctx context.Context) (*string, error) {
+	cli, err := clientsetFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := cli.BatchV1().Jobs(c.Config.KubernetesNamespace).List(ctx, metav1.ListOptions{LabelSelector: c.runSelector()})
+	if err != nil {
+		return nil, err
+	}
+	// the job is found by the container name it was created for or by its own name
+	var candidates []orchestratormodels.Container
+	for _, job := range jobs.Items {
+		candidates = append(candidates, orchestratormodels.Container{Name: job.Name, ID: job.Name})
+		if name, ok := job.Annotations[c.Config.KubernetesNameAnnotation]; ok {
+			candidates = append(candidates, orchestratormodels.Container{Name: name, ID: job.Name})
+		}
+	}
+	return utils.ResolveContainer(candidates, containerName)
+}
+
+// common functions
+
+func (c *ScenarioOrchestrator) AttachWait(containerID *string, stdout io.Writer, stderr io.Writer, ctx context.Context) (*bool, error) {
+	interrupted, err := scenarioorchestrator.CommonAttachWait(containerID, stdout, stderr, c, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &interrupted, nil
+}
+
+func (c *ScenarioOrchestrator) RunAttached(
+	image string,
+	containerName string,
+	env map[string]string,
+	cache bool,
+	volumeMounts map[string]string,
+	stdout io.Writer,
+	stderr io.Writer,
+	commChan *chan *string,
+	ctx context.Context,
+	registry *providermodels.RegistryV2,
+	publishPorts []string,
+	podmanCreate *scenarioorchestrator.PodmanCreateOptions,
+	timeout *time.Duration,
+) (*string, error) {
+	containerID, err := scenarioorchestrator.CommonRunAttached(image, containerName, env, cache, volumeMounts, stdout, stderr, c, commChan, ctx, registry, publishPorts, podmanCreate, timeout)
+	return containerID, err
+}
+
+func (c *ScenarioOrchestrator) RunGraph(
+	scenarios orchestratormodels.ScenarioSet,
+	resolvedGraph orchestratormodels.ResolvedGraph,
+	extraEnv map[string]string,
+	extraVolumeMounts map[string]string,
+	stdout io.Writer,
+	pullPolicy orchestratormodels.PullPolicy,
+	schedule orchestratormodels.Schedule,
+	commChannel chan *orchestratormodels.GraphCommChannel,
+	ctx context.Context,
+	registry *providermodels.RegistryV2,
+	userID *int,
+	labels *orchestratormodels.ContainerLabels,
+	runDir string,
+) {
+	scenarioorchestrator.CommonRunGraph(scenarios, resolvedGraph, extraEnv, extraVolumeMounts, stdout, pullPolicy, schedule, commChannel, ctx, c, c.Config, registry, userID, labels, runDir)
+}
+
+func (c *ScenarioOrchestrator) PrintContainerRuntime(w io.Writer) {
+	scenarioorchestrator.CommonPrintRuntime(w, c.ContainerRuntime)
+}
+
+func (c *ScenarioOrchestrator) ListRunningScenarios(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.ScenarioContainer, error) {
+	return scenarioorchestrator.CommonListRunningScenarios(c, ctx, filter)
+}
@@ -0,0 +1,506 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func getTestOrchestrator(t *testing.T) (*ScenarioOrchestrator, *fake.Clientset, context.Context) {
+	conf, err := config.LoadConfig()
+	assert.Nil(t, err)
+	cli := fake.NewSimpleClientset()
+	ctx := contextWithClientset(context.Background(), cli)
+	return &ScenarioOrchestrator{Config: conf, ContainerRuntime: models.Kubernetes}, cli, ctx
+}
+
+// startPod simulates the Job controller scheduling the job pod
+func startPod(t *testing.T, cli *fake.Clientset, so *ScenarioOrchestrator, name string, phase corev1.PodPhase, exitCode *int32) {
+	status := corev1.ContainerStatus{Name: scenarioContainerName, ImageID: "sha256:1234"}
+	if exitCode != nil {
+		status.State.Terminated = &corev1.ContainerStateTerminated{ExitCode: *exitCode}
+	}
+	// the pod inherits the labels of the job template
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(context.Background(), name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name + "-abcde",
+			Namespace:         so.Config.KubernetesNamespace,
+			Labels:            job.Spec.Template.Labels,
+			CreationTimestamp: metav1.NewTime(time.Now()),
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: scenarioContainerName, Image: "quay.io/krkn-chaos/krkn-hub:dummy-scenario"}}},
+		Status: corev1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: []corev1.ContainerStatus{status},
+		},
+	}
+	_, err = cli.CoreV1().Pods(so.Config.KubernetesNamespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	assert.Nil(t, err)
+}
+
+func TestJobName(t *testing.T) {
+	assert.Equal(t, "krknctl-dummy-scenario-1234", jobName("krknctl-dummy-scenario-1234"))
+	assert.Equal(t, "krknctl-node-a-1234", jobName("krknctl-Node_A-1234"))
+	long := jobName("krknctl-" + strings.Repeat("a", 80) + "-1234")
+	assert.LessOrEqual(t, len(long), maxResourceName)
+	assert.True(t, strings.HasSuffix(long, "-1234"))
+}
+
+func TestScenarioOrchestrator_Kubernetes_Run(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+
+	file := filepath.Join(t.TempDir(), "kubeconfig")
+	assert.Nil(t, os.WriteFile(file, []byte("kubeconfig"), 0600))
+	username := "user"
+	password := "password"
+	registry := &providermodels.RegistryV2{RegistryURL: "registry.example.com", Username: &username, Password: &password}
+
+	commChan := make(chan *string, 1)
+	id, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234",
+		map[string]string{"END": "10"}, true, map[string]string{file: "/home/krkn/.kube/config"}, &commChan, ctx, registry, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "krknctl-dummy-scenario-1234", *id)
+
+	_, err = cli.CoreV1().Namespaces().Get(ctx, so.Config.KubernetesNamespace, metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *id, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, managedByValue, job.Labels[so.Config.KubernetesManagedByLabel])
+	assert.Equal(t, "krknctl-dummy-scenario-1234", job.Annotations[so.Config.KubernetesNameAnnotation])
+	assert.Equal(t, int32(0), *job.Spec.BackoffLimit)
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, corev1.RestartPolicyNever, podSpec.RestartPolicy)
+	assert.Equal(t, corev1.PullIfNotPresent, podSpec.Containers[0].ImagePullPolicy)
+	// the env values are read from the secret owned by the job
+	assert.Len(t, podSpec.Containers[0].Env, 1)
+	assert.Equal(t, "END", podSpec.Containers[0].Env[0].Name)
+	assert.Equal(t, "", podSpec.Containers[0].Env[0].Value)
+	assert.Equal(t, *id+"-env", podSpec.Containers[0].Env[0].ValueFrom.SecretKeyRef.Name)
+	envSecret, err := cli.CoreV1().Secrets(so.Config.KubernetesNamespace).Get(ctx, *id+"-env", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("10"), envSecret.Data["END"])
+	assert.Len(t, envSecret.OwnerReferences, 1)
+	assert.Len(t, podSpec.Containers[0].VolumeMounts, 1)
+	assert.Equal(t, "/home/krkn/.kube/config", podSpec.Containers[0].VolumeMounts[0].MountPath)
+	assert.Len(t, podSpec.ImagePullSecrets, 1)
+
+	files, err := cli.CoreV1().Secrets(so.Config.KubernetesNamespace).Get(ctx, *id+"-files", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("kubeconfig"), files.Data["file-0"])
+	assert.Len(t, files.OwnerReferences, 1)
+
+	pull, err := cli.CoreV1().Secrets(so.Config.KubernetesNamespace).Get(ctx, *id+"-pull", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, corev1.SecretTypeDockerConfigJson, pull.Type)
+	assert.Contains(t, string(pull.Data[corev1.DockerConfigJsonKey]), "registry.example.com")
+	assert.Contains(t, string(pull.Data[corev1.DockerConfigJsonKey]), `"auth"`)
+
+	// commChan is closed once the job is submitted
+	<-commChan
+	_, open := <-commChan
+	assert.False(t, open)
+
+	_, err = so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, []string{"8080:8080"}, nil)
+	assert.NotNil(t, err)
+
+	_, err = so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, map[string]string{t.TempDir(): "/tmp"}, nil, ctx, nil, nil, nil)
+	assert.NotNil(t, err)
+
+	// the kubelet can't pull with a registry token
+	token := "token"
+	_, err = so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, &providermodels.RegistryV2{RegistryURL: "registry.example.com", Token: &token}, nil, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "token authentication")
+}
+
+func TestScenarioOrchestrator_Kubernetes_RunJobFailure(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	cli.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission denied")
+	})
+	file := filepath.Join(t.TempDir(), "kubeconfig")
+	assert.Nil(t, os.WriteFile(file, []byte("kubeconfig"), 0600))
+
+	_, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234",
+		map[string]string{"END": "10"}, false, map[string]string{file: "/home/krkn/.kube/config"}, nil, ctx, nil, nil, nil)
+	assert.NotNil(t, err)
+	// the secrets created for the job don't outlive it
+	secrets, err := cli.CoreV1().Secrets(so.Config.KubernetesNamespace).List(ctx, metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, secrets.Items, 0)
+}
+
+func TestScenarioOrchestrator_Kubernetes_RunResourcesAndSecurity(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+
+	createOpts := &scenarioorchestrator.PodmanCreateOptions{
+		Resources: &models.Resources{CPU: "1.5", Memory: "512m"},
+		Security:  &models.Security{CapAdd: []string{"net_admin"}, CapDrop: []string{"CAP_ALL"}, ReadOnlyRootfs: true, User: "1000:2000"},
+	}
+	id, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, nil, ctx, nil, nil, createOpts)
+	assert.Nil(t, err)
+
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *id, metav1.GetOptions{})
+	assert.Nil(t, err)
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "1500m", container.Resources.Limits.Cpu().String())
+	assert.Equal(t, "512Mi", container.Resources.Limits.Memory().String())
+	assert.Equal(t, []corev1.Capability{"NET_ADMIN"}, container.SecurityContext.Capabilities.Add)
+	assert.Equal(t, []corev1.Capability{"ALL"}, container.SecurityContext.Capabilities.Drop)
+	assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+	assert.Equal(t, int64(1000), *container.SecurityContext.RunAsUser)
+	assert.Equal(t, int64(2000), *container.SecurityContext.RunAsGroup)
+
+	// pids limits and user names can't be set on a pod
+	createOpts = &scenarioorchestrator.PodmanCreateOptions{Resources: &models.Resources{Pids: 100}}
+	_, err = so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, createOpts)
+	assert.NotNil(t, err)
+	createOpts = &scenarioorchestrator.PodmanCreateOptions{Security: &models.Security{User: "krkn"}}
+	_, err = so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, createOpts)
+	assert.NotNil(t, err)
+}
+
+func TestScenarioOrchestrator_Kubernetes_Attach(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	id, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	exitCode := int32(0)
+	startPod(t, cli, so, *id, corev1.PodSucceeded, &exitCode)
+
+	var stdout bytes.Buffer
+	interrupted, err := so.Attach(id, make(chan os.Signal), &stdout, &stdout, ctx)
+	assert.Nil(t, err)
+	assert.False(t, interrupted)
+	assert.Equal(t, "fake logs", stdout.String())
+
+	// the logs are over before the pod status reports the exit code
+	running, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-4321", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	startPod(t, cli, so, *running, corev1.PodRunning, nil)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		pod, err := so.jobPod(context.Background(), cli, *running)
+		assert.Nil(t, err)
+		pod.Status.Phase = corev1.PodFailed
+		pod.Status.ContainerStatuses[0].State.Terminated = &corev1.ContainerStateTerminated{ExitCode: 2}
+		_, err = cli.CoreV1().Pods(pod.Namespace).UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+		assert.Nil(t, err)
+	}()
+	interrupted, err = so.Attach(running, make(chan os.Signal), &stdout, &stdout, ctx)
+	assert.Nil(t, err)
+	assert.False(t, interrupted)
+	scenario, err := so.InspectScenario(models.Container{ID: *running}, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, scenario.Container.ExitStatus)
+
+	// the job is not retried, a pod that can't pull its image never starts
+	pullError, err := so.Run("quay.io/krkn-chaos/krkn-hub:missing", "krknctl-missing-1234", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	startPod(t, cli, so, *pullError, corev1.PodPending, nil)
+	pod, err := so.jobPod(ctx, cli, *pullError)
+	assert.Nil(t, err)
+	pod.Status.ContainerStatuses[0].State.Waiting = &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "manifest unknown"}
+	_, err = cli.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	_, err = so.Attach(pullError, make(chan os.Signal), &stdout, &stdout, ctx)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "ImagePullBackOff")
+
+	signalChan := make(chan os.Signal, 1)
+	pending, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	signalChan <- os.Interrupt
+	interrupted, err = so.Attach(pending, signalChan, &stdout, &stdout, ctx)
+	assert.Nil(t, err)
+	assert.True(t, interrupted)
+}
+
+func TestScenarioOrchestrator_Kubernetes_InspectScenario(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	file := filepath.Join(t.TempDir(), "kubeconfig")
+	assert.Nil(t, os.WriteFile(file, []byte("kubeconfig"), 0600))
+	id, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234",
+		map[string]string{"END": "10"}, false, map[string]string{file: "/home/krkn/.kube/config"}, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+
+	scenario, err := so.InspectScenario(models.Container{ID: *id}, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "pending", scenario.Container.Status)
+
+	exitCode := int32(1)
+	startPod(t, cli, so, *id, corev1.PodFailed, &exitCode)
+	scenario, err = so.InspectScenario(models.Container{ID: *id}, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "krknctl-dummy-scenario-1234", scenario.Container.Name)
+	assert.Equal(t, "failed", scenario.Container.Status)
+	assert.Equal(t, 1, scenario.Container.ExitStatus)
+	assert.Equal(t, "dummy-scenario", scenario.ScenarioDetail.Name)
+	assert.Equal(t, "sha256:1234", *scenario.ScenarioDetail.Digest)
+	assert.Equal(t, "10", scenario.Scenario.Env["END"])
+	assert.Equal(t, "/home/krkn/.kube/config", scenario.Scenario.Volumes[file])
+
+	scenario, err = so.InspectScenario(models.Container{ID: "not-found"}, ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, scenario)
+
+	// an evicted pod has no exit code and is not a success
+	evicted, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	startPod(t, cli, so, *evicted, corev1.PodFailed, nil)
+	_, err = so.InspectScenario(models.Container{ID: *evicted}, ctx)
+	assert.NotNil(t, err)
+
+	// a failed job whose pod has been removed is not a success
+	removed, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-9012", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *removed, metav1.GetOptions{})
+	assert.Nil(t, err)
+	job.Status.Failed = 1
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	scenario, err = so.InspectScenario(models.Container{ID: *removed}, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "failed", scenario.Container.Status)
+	assert.NotEqual(t, 0, scenario.Container.ExitStatus)
+}
+
+func TestScenarioOrchestrator_Kubernetes_ListRunningScenarios(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	running, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	startPod(t, cli, so, *running, corev1.PodRunning, nil)
+	finished, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	exitCode := int32(0)
+	startPod(t, cli, so, *finished, corev1.PodSucceeded, &exitCode)
+
+	containers, err := so.ListRunningContainers(ctx, nil)
+	assert.Nil(t, err)
+	assert.Len(t, *containers, 1)
+	for _, container := range *containers {
+		assert.Equal(t, *running, container.ID)
+	}
+
+	scenarios, err := so.ListRunningScenarios(ctx, nil)
+	assert.Nil(t, err)
+	assert.Len(t, *scenarios, 1)
+	assert.Equal(t, "running", (*scenarios)[0].Container.Status)
+}
+
+func TestScenarioOrchestrator_Kubernetes_Labels(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	labels := utils.NewContainerLabels(so.Config, "abcdef0123456789")
+	labels.NodeID = "Node A"
+	labels.Scenario = "dummy-scenario"
+	labels.User = `DOMAIN\krkn`
+	createOpts := &scenarioorchestrator.PodmanCreateOptions{Labels: &labels}
+	first, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, nil, ctx, nil, nil, createOpts)
+	assert.Nil(t, err)
+	startPod(t, cli, so, *first, corev1.PodRunning, nil)
+	// a container started without labels gets a run of its own
+	other, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	startPod(t, cli, so, *other, corev1.PodRunning, nil)
+
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *first, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, labels.RunID, job.Labels[so.Config.LabelRunID])
+	assert.Equal(t, "abcdef0123456789", job.Labels[so.Config.LabelGraphHash])
+	// values are converted to valid label values
+	assert.Equal(t, "Node-A", job.Labels[so.Config.LabelNodeID])
+	assert.Equal(t, "DOMAIN-krkn", job.Labels[so.Config.LabelUser])
+	assert.Equal(t, managedByValue, job.Labels[so.Config.KubernetesManagedByLabel])
+
+	containers, err := so.ListRunningContainers(ctx, nil)
+	assert.Nil(t, err)
+	assert.Len(t, *containers, 2)
+
+	containers, err = so.ListRunningContainers(ctx, &models.ContainerLabels{RunID: labels.RunID, NodeID: "Node A"})
+	assert.Nil(t, err)
+	assert.Len(t, *containers, 1)
+	for _, container := range *containers {
+		assert.Equal(t, *first, container.ID)
+		assert.Equal(t, labels.RunID, container.Labels.RunID)
+	}
+
+	scenarios, err := so.ListRunningScenarios(ctx, &models.ContainerLabels{RunID: labels.RunID})
+	assert.Nil(t, err)
+	assert.Len(t, *scenarios, 1)
+	assert.Equal(t, "dummy-scenario", (*scenarios)[0].Container.Labels.Scenario)
+
+	// jobs not created by krknctl are never touched
+	foreign := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "krknctl-foreign-1234", Namespace: so.Config.KubernetesNamespace,
+		Labels: map[string]string{so.Config.KubernetesManagedByLabel: managedByValue}}}
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Create(ctx, foreign, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	count, err := so.CleanContainers(ctx, &models.ContainerLabels{RunID: labels.RunID})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *count)
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *other, metav1.GetOptions{})
+	assert.Nil(t, err)
+	count, err = so.CleanContainers(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *count)
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, foreign.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+}
+
+func TestScenarioOrchestrator_Kubernetes_ResolveContainerName(t *testing.T) {
+	so, _, ctx := getTestOrchestrator(t)
+	id, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-Node_A-1234", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+
+	resolved, err := so.ResolveContainerName("krknctl-Node_A-1234", ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, *id, *resolved)
+
+	resolved, err = so.ResolveContainerName(*id, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, *id, *resolved)
+
+	resolved, err = so.ResolveContainerName("missing", ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, resolved)
+	// a part of the name doesn't match
+	resolved, err = so.ResolveContainerName("Node_A", ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, resolved)
+}
+
+func TestScenarioOrchestrator_Kubernetes_RunAttachedTimeout(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	// the pod is never scheduled so the scenario hangs until the timeout expires
+	timeout := 100 * time.Millisecond
+	var stdout bytes.Buffer
+	id, err := so.RunAttached("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, &stdout, &stdout, nil, ctx, nil, nil, nil, &timeout)
+	var timeoutErr *utils.TimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, timeout, timeoutErr.Timeout)
+	// the job has been killed
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *id, metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestScenarioOrchestrator_Kubernetes_RunAttachedAbort(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	// the interruption is handled by the run, that aborts the scenarios with the cause
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	runCtx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	go func() {
+		<-interrupt
+		abort(utils.ErrInterrupted)
+	}()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		process, err := os.FindProcess(os.Getpid())
+		assert.Nil(t, err)
+		assert.Nil(t, process.Signal(syscall.SIGTERM))
+	}()
+
+	// the pod is never scheduled so the scenario runs until the run is aborted
+	var stdout bytes.Buffer
+	id, err := so.RunAttached("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, &stdout, &stdout, nil, utils.ContextWithAbort(ctx, runCtx), nil, nil, nil, nil)
+	var abortErr *utils.AbortError
+	assert.True(t, errors.As(err, &abortErr))
+	assert.ErrorIs(t, err, utils.ErrInterrupted)
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *id, metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestScenarioOrchestrator_Kubernetes_KillAndClean(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	killed, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, so.Kill(killed, ctx))
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *killed, metav1.GetOptions{})
+	assert.NotNil(t, err)
+	// killing an already removed job is not an error
+	assert.Nil(t, so.Kill(killed, ctx))
+
+	finished, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	active, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-9012", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *active, metav1.GetOptions{})
+	assert.Nil(t, err)
+	job.Status.Active = 1
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+
+	count, err := so.CleanContainers(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *count)
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *finished, metav1.GetOptions{})
+	assert.NotNil(t, err)
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *active, metav1.GetOptions{})
+	assert.Nil(t, err)
+}
+
+func TestScenarioOrchestrator_Kubernetes_ListFinishedContainers(t *testing.T) {
+	so, cli, ctx := getTestOrchestrator(t)
+	succeeded, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-1234", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	failed, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-5678", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	exitCode := int32(2)
+	startPod(t, cli, so, *failed, corev1.PodFailed, &exitCode)
+	job, err := cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *failed, metav1.GetOptions{})
+	assert.Nil(t, err)
+	job.Status.Failed = 1
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	active, err := so.Run("quay.io/krkn-chaos/krkn-hub:dummy-scenario", "krknctl-dummy-scenario-9012", nil, false, nil, nil, ctx, nil, nil, nil)
+	assert.Nil(t, err)
+	job, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *active, metav1.GetOptions{})
+	assert.Nil(t, err)
+	job.Status.Active = 1
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+
+	containers, err := so.ListFinishedContainers(ctx, nil)
+	assert.Nil(t, err)
+	assert.Len(t, *containers, 2)
+	exitStatus := make(map[string]int)
+	for _, container := range *containers {
+		exitStatus[container.ID] = container.ExitStatus
+	}
+	assert.Equal(t, map[string]int{*succeeded: 0, *failed: 2}, exitStatus)
+
+	assert.Nil(t, so.RemoveContainer(failed, ctx))
+	_, err = cli.BatchV1().Jobs(so.Config.KubernetesNamespace).Get(ctx, *failed, metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestScenarioOrchestrator_Kubernetes_ClientNotInContext(t *testing.T) {
+	so, _, _ := getTestOrchestrator(t)
+	_, err := so.Run("image", "name", nil, false, nil, nil, context.Background(), nil, nil, nil)
+	assert.NotNil(t, err)
+	_, err = so.CleanContainers(context.Background(), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// EventType is the kind of a run event
+type EventType string
+
+const (
+	EventRunStarted       EventType = "run-started"
+	EventImagePulling     EventType = "image-pulling"
+	EventPullProgress     EventType = "pull-progress"
+	EventContainerStarted EventType = "container-started"
+	EventContainerExited  EventType = "container-exited"
+	EventReportParsed     EventType = "report-parsed"
+	EventNodeSkipped      EventType = "node-skipped"
+	EventLayerFinished    EventType = "layer-finished"
+	EventRunFinished      EventType = "run-finished"
+)
+
+// statuses reported by the container-exited, node-skipped and run-finished events
+const (
+	EventStatusSucceeded = "succeeded"
+	EventStatusFailed    = "failed"
+	EventStatusTimedOut  = "timed-out"
+	EventStatusAborted   = "aborted"
+	EventStatusSkipped   = "skipped"
+)
+
+// Event is a structured step of a chaos run, emitted by the orchestrators for the
+// machines following the run (e.g. --output json-events) instead of the spinner text
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// RunID is the run the event belongs to, set by the consumer when the producer does not know it
+	RunID string `json:"run_id,omitempty"`
+	// Layer is the step of the graph, nil for the single runs and the events of the whole run
+	Layer     *int   `json:"layer,omitempty"`
+	NodeID    string `json:"node_id,omitempty"`
+	Scenario  string `json:"scenario,omitempty"`
+	Container string `json:"container,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+	LogFile   string `json:"log_file,omitempty"`
+	// ExitCode is the exit status of the container, nil if the container did not exit by itself
+	ExitCode        *int     `json:"exit_code,omitempty"`
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+	// Pulled and Total report the progress of the image pre-pull
+	Pulled int `json:"pulled,omitempty"`
+	Total  int `json:"total,omitempty"`
+	// Nodes are the scenarios of the run or of the layer
+	Nodes           []string `json:"nodes,omitempty"`
+	Status          string   `json:"status,omitempty"`
+	ResiliencyScore *float64 `json:"resiliency_score,omitempty"`
+	Report          string   `json:"report,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	// Reason tells why a node has been skipped
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewEvent returns an event of the given type stamped with the current time
+func NewEvent(eventType EventType, runID string) *Event {
+	return &Event{Type: eventType, Time: time.Now().UTC(), RunID: runID}
+}
+
+// SetDuration sets the duration elapsed since start
+func (e *Event) SetDuration(start time.Time) {
+	duration := time.Since(start).Seconds()
+	e.DurationSeconds = &duration
+}
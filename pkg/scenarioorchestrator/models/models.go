@@ -1,7 +1,18 @@
 // Package models provides the data models for the container runtime environment
 package models
 
-import "github.com/krkn-chaos/krknctl/pkg/provider/models"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/krkn-chaos/krknctl/pkg/provider/models"
+)
 
 type ContainerRuntime int64
 
@@ -13,6 +24,8 @@ func (e ContainerRuntime) String() string {
 		return "Docker"
 	case Both:
 		return "Both"
+	case Kubernetes:
+		return "Kubernetes"
 
 	}
 	return "Unknown"
@@ -22,15 +35,240 @@ const (
 	Podman ContainerRuntime = iota
 	Docker
 	Both
+	Kubernetes
 )
 
 type ScenarioNode struct {
 	Scenario
-	Parent *string `json:"depends_on,omitempty"`
+	// Parents are the scenarios that must be finished before the node starts
+	Parents Dependencies `json:"depends_on,omitempty"`
+	// When is the outcome of the parents the node runs after, if not set the node always runs
+	When Condition `json:"when,omitempty"`
+	// Timeout is the maximum duration of the scenario (e.g. 30m), once expired the container is killed
+	Timeout string `json:"timeout,omitempty"`
+	// Retries is the number of times a failed scenario is run again before the failure is reported
+	Retries int `json:"retries,omitempty"`
+	// RetryBackoff is the delay before the first retry (e.g. 30s), doubled at every following retry up to MaxRetryBackoff
+	RetryBackoff string `json:"retry_backoff,omitempty"`
+	// RetryOn restricts the retries to the listed exit statuses, if empty every failure is retried
+	RetryOn []int `json:"retry_on,omitempty"`
+	// Resources caps the resources the scenario container can consume
+	Resources *Resources `json:"resources,omitempty"`
+	// Security hardens the scenario container
+	Security *Security `json:"security,omitempty"`
+}
+
+// Resources are the limits applied to a scenario container
+type Resources struct {
+	// CPU is the number of cpus the container can use (e.g. 1.5)
+	CPU string `json:"cpu,omitempty"`
+	// Memory is the memory limit with an optional unit suffix (e.g. 512m, 2g)
+	Memory string `json:"memory,omitempty"`
+	// Pids is the maximum number of processes in the container
+	Pids int64 `json:"pids,omitempty"`
+}
+
+// NanoCPUs returns the cpu limit in billionths of cpu, zero if not set
+func (r *Resources) NanoCPUs() (int64, error) {
+	if r.CPU == "" {
+		return 0, nil
+	}
+	cpus, err := strconv.ParseFloat(r.CPU, 64)
+	if err != nil || cpus <= 0 {
+		return 0, fmt.Errorf("invalid cpu limit %s: must be a number greater than zero", r.CPU)
+	}
+	return int64(cpus * 1e9), nil
 }
 
-func (s ScenarioNode) GetParent() *string {
-	return s.Parent
+// MemoryBytes returns the memory limit in bytes, zero if not set
+func (r *Resources) MemoryBytes() (int64, error) {
+	if r.Memory == "" {
+		return 0, nil
+	}
+	memory, err := units.RAMInBytes(r.Memory)
+	if err != nil || memory <= 0 {
+		return 0, fmt.Errorf("invalid memory limit %s: must be a size greater than zero (e.g. 512m, 2g)", r.Memory)
+	}
+	return memory, nil
+}
+
+// Validate checks the limits and rejects the ones the container runtime cannot apply
+func (r *Resources) Validate(containerRuntime ContainerRuntime) error {
+	if _, err := r.NanoCPUs(); err != nil {
+		return err
+	}
+	if _, err := r.MemoryBytes(); err != nil {
+		return err
+	}
+	if r.Pids < 0 {
+		return fmt.Errorf("invalid pids limit %d: must be greater than zero", r.Pids)
+	}
+	if r.Pids > 0 && containerRuntime == Kubernetes {
+		return fmt.Errorf("pids limit is not supported by the %s runtime", containerRuntime.String())
+	}
+	return nil
+}
+
+// Security are the hardening options applied to a scenario container
+type Security struct {
+	CapAdd         []string `json:"cap_add,omitempty"`
+	CapDrop        []string `json:"cap_drop,omitempty"`
+	ReadOnlyRootfs bool     `json:"read_only_rootfs,omitempty"`
+	// User is the user the container runs as, name or uid with an optional group (e.g. 1000:1000)
+	User string `json:"user,omitempty"`
+}
+
+// MaxRetryBackoff caps the delay between two retries of a scenario
+const MaxRetryBackoff = 10 * time.Minute
+
+var (
+	capabilityRegex  = regexp.MustCompile(`^(?i)(CAP_)?[A-Z_]+$`)
+	userRegex        = regexp.MustCompile(`^[A-Za-z0-9_.-]+(:[A-Za-z0-9_.-]+)?$`)
+	numericUserRegex = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+)
+
+// UserID returns the numeric uid and gid of User, gid is nil if not set
+func (s *Security) UserID() (*int64, *int64, error) {
+	if !numericUserRegex.MatchString(s.User) {
+		return nil, nil, fmt.Errorf("invalid user %s: must be a numeric uid with an optional gid (e.g. 1000:1000)", s.User)
+	}
+	parts := strings.SplitN(s.User, ":", 2)
+	uid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parts) == 1 {
+		return &uid, nil, nil
+	}
+	gid, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &uid, &gid, nil
+}
+
+// Validate checks the options and rejects the ones the container runtime cannot apply
+func (s *Security) Validate(containerRuntime ContainerRuntime) error {
+	for _, capability := range append(append([]string{}, s.CapAdd...), s.CapDrop...) {
+		if !capabilityRegex.MatchString(capability) {
+			return fmt.Errorf("invalid capability %s", capability)
+		}
+	}
+	if s.User == "" {
+		return nil
+	}
+	if !userRegex.MatchString(s.User) {
+		return fmt.Errorf("invalid user %s", s.User)
+	}
+	// kubernetes accepts only numeric ids in the security context
+	if containerRuntime == Kubernetes {
+		if _, _, err := s.UserID(); err != nil {
+			return fmt.Errorf("%w, user names are not supported by the %s runtime", err, containerRuntime.String())
+		}
+	}
+	return nil
+}
+
+func (s ScenarioNode) GetParents() []string {
+	return s.Parents
+}
+
+// Dependencies are the parents of a graph node, depends_on is either a single
+// scenario ID or a list of scenario IDs
+type Dependencies []string
+
+func (d *Dependencies) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = nil
+		return nil
+	}
+	var parent string
+	if err := json.Unmarshal(data, &parent); err == nil {
+		*d = Dependencies{parent}
+		return nil
+	}
+	var parents []string
+	if err := json.Unmarshal(data, &parents); err != nil {
+		return errors.New("depends_on must be a scenario ID or a list of scenario IDs")
+	}
+	*d = parents
+	return nil
+}
+
+// MarshalJSON writes a single parent as a string so that the plans stay readable by the previous versions
+func (d Dependencies) MarshalJSON() ([]byte, error) {
+	if len(d) == 1 {
+		return json.Marshal(d[0])
+	}
+	return json.Marshal([]string(d))
+}
+
+// GetTimeout parses the node timeout, nil means that the scenario can run indefinitely
+func (s ScenarioNode) GetTimeout() (*time.Duration, error) {
+	if s.Timeout == "" {
+		return nil, nil
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout %s: %w", s.Timeout, err)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %s: must be greater than zero", s.Timeout)
+	}
+	return &timeout, nil
+}
+
+// GetRetryBackoff parses the delay before the first retry, zero if not set
+func (s ScenarioNode) GetRetryBackoff() (time.Duration, error) {
+	if s.RetryBackoff == "" {
+		return 0, nil
+	}
+	backoff, err := time.ParseDuration(s.RetryBackoff)
+	if err != nil {
+		return 0, fmt.Errorf("invalid retry_backoff %s: %w", s.RetryBackoff, err)
+	}
+	if backoff < 0 {
+		return 0, fmt.Errorf("invalid retry_backoff %s: must not be negative", s.RetryBackoff)
+	}
+	if backoff > MaxRetryBackoff {
+		return 0, fmt.Errorf("invalid retry_backoff %s: must not be greater than %s", s.RetryBackoff, MaxRetryBackoff)
+	}
+	return backoff, nil
+}
+
+// Validate checks the timeout, the retry policy and the container options of the node
+func (s ScenarioNode) Validate(containerRuntime ContainerRuntime) error {
+	if _, err := s.GetTimeout(); err != nil {
+		return err
+	}
+	if s.Retries < 0 {
+		return fmt.Errorf("invalid retries %d: must not be negative", s.Retries)
+	}
+	if _, err := s.GetRetryBackoff(); err != nil {
+		return err
+	}
+	if len(s.RetryOn) > 0 && s.Retries == 0 {
+		return errors.New("retry_on is set but retries is not")
+	}
+	if s.When != "" {
+		if _, err := ParseCondition(string(s.When)); err != nil {
+			return err
+		}
+		if len(s.Parents) == 0 {
+			return errors.New("when is set but depends_on is not")
+		}
+	}
+	if s.Resources != nil {
+		if err := s.Resources.Validate(containerRuntime); err != nil {
+			return err
+		}
+	}
+	if s.Security != nil {
+		if err := s.Security.Validate(containerRuntime); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type Scenario struct {
@@ -51,20 +289,193 @@ type ScenarioContainer struct {
 }
 
 type Container struct {
-	ID         string `json:"id,omitempty"`
-	Name       string `json:"name,omitempty"`
-	Image      string `json:"image,omitempty"`
-	Started    int64  `json:"started,omitempty"`
-	Status     string `json:"status,omitempty"`
-	ExitStatus int    `json:"exit_status"`
+	ID         string           `json:"id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	Image      string           `json:"image,omitempty"`
+	Started    int64            `json:"started,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	ExitStatus int              `json:"exit_status"`
+	Labels     *ContainerLabels `json:"labels,omitempty"`
+}
+
+// ContainerLabels are stamped on every container created by krknctl to tell
+// which run, graph and node it belongs to. When used as a filter the empty
+// fields match any value.
+type ContainerLabels struct {
+	RunID     string `json:"run_id,omitempty"`
+	GraphHash string `json:"graph_hash,omitempty"`
+	NodeID    string `json:"node_id,omitempty"`
+	Scenario  string `json:"scenario,omitempty"`
+	Version   string `json:"version,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+// Matches returns true if every field set in the filter has the same value in the labels
+func (l ContainerLabels) Matches(filter *ContainerLabels) bool {
+	if filter == nil {
+		return true
+	}
+	return (filter.RunID == "" || filter.RunID == l.RunID) &&
+		(filter.GraphHash == "" || filter.GraphHash == l.GraphHash) &&
+		(filter.NodeID == "" || filter.NodeID == l.NodeID) &&
+		(filter.Scenario == "" || filter.Scenario == l.Scenario) &&
+		(filter.Version == "" || filter.Version == l.Version) &&
+		(filter.User == "" || filter.User == l.User)
+}
+
+// Condition is the outcome of the parents a graph node runs after
+type Condition string
+
+const (
+	WhenOnSuccess Condition = "on_success"
+	WhenOnFailure Condition = "on_failure"
+	WhenAlways    Condition = "always"
+)
+
+// ParseCondition validates the when condition of a node
+func ParseCondition(condition string) (Condition, error) {
+	switch Condition(condition) {
+	case WhenOnSuccess, WhenOnFailure, WhenAlways:
+		return Condition(condition), nil
+	}
+	return "", fmt.Errorf("invalid when %q: must be %s, %s or %s", condition, WhenOnSuccess, WhenOnFailure, WhenAlways)
+}
+
+// SkipReason evaluates the when condition of the node against the outcome of its parents,
+// failed and skipped are the nodes finished with an error and the ones not run. It returns
+// why the node is skipped, empty if it runs.
+func (s ScenarioNode) SkipReason(failed map[string]bool, skipped map[string]bool) string {
+	switch s.When {
+	case WhenOnSuccess:
+		for _, parent := range s.Parents {
+			if failed[parent] {
+				return fmt.Sprintf("runs on success but %s failed", parent)
+			}
+			if skipped[parent] {
+				return fmt.Sprintf("runs on success but %s has been skipped", parent)
+			}
+		}
+	case WhenOnFailure:
+		for _, parent := range s.Parents {
+			if failed[parent] {
+				return ""
+			}
+		}
+		return fmt.Sprintf("runs on failure but none of %s failed", strings.Join(s.Parents, ", "))
+	}
+	return ""
+}
+
+// PullPolicy tells when the image of a container is pulled before the container is created
+type PullPolicy string
+
+const (
+	PullAlways       PullPolicy = "always"
+	PullIfNotPresent PullPolicy = "if-not-present"
+	PullNever        PullPolicy = "never"
+)
+
+// ParsePullPolicy validates the pull policy set by the user
+func ParsePullPolicy(policy string) (PullPolicy, error) {
+	switch PullPolicy(policy) {
+	case PullAlways, PullIfNotPresent, PullNever:
+		return PullPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid pull policy %q: must be %s, %s or %s", policy, PullAlways, PullIfNotPresent, PullNever)
+}
+
+// PlannedScenario describes a container as it would be started by the orchestrator
+type PlannedScenario struct {
+	Layer         int               `json:"layer"`
+	ScenarioID    string            `json:"scenario_id,omitempty"`
+	ScenarioName  string            `json:"scenario_name,omitempty"`
+	ContainerName string            `json:"container_name"`
+	Image         string            `json:"image"`
+	Env           map[string]string `json:"env"`
+	Volumes       map[string]string `json:"volumes"`
+	Timeout       string            `json:"timeout,omitempty"`
+	Retries       int               `json:"retries,omitempty"`
+	Resources     *Resources        `json:"resources,omitempty"`
+	Security      *Security         `json:"security,omitempty"`
+	PullPolicy    PullPolicy        `json:"pull_policy,omitempty"`
 }
 
 type ScenarioSet map[string]ScenarioNode
 type ResolvedGraph [][]string
 
+// Schedule is how the nodes of a graph are started
+type Schedule struct {
+	// MaxParallel caps the number of scenarios running at the same time, no cap if zero
+	MaxParallel int
+	// Layered starts the scenarios of a step only once all the scenarios of the previous one
+	// are finished, otherwise a scenario starts as soon as the scenarios it depends on are
+	Layered bool
+}
+
 type GraphCommChannel struct {
 	Layer           *int
 	ScenarioID      *string
 	ScenarioLogFile *string
-	Err             error
+	// Attempt is the execution number of the scenario, greater than 1 when it is retried
+	Attempt int
+	// ImagePull is set while the images of the graph are pulled, before the first layer starts
+	ImagePull *ImagePullProgress
+	// SkipReason tells why the scenario is not run, set only if its when condition is not met
+	SkipReason string
+	// Event is a structured step of the run, a message carrying an event carries nothing else
+	Event *Event
+	Err   error
+}
+
+// ImagePullProgress reports the pre-pull of the images of a graph
+type ImagePullProgress struct {
+	Image  string
+	Pulled int
+	Total  int
+}
+
+// LogOptions selects the logs of a container returned by the orchestrator
+type LogOptions struct {
+	// Follow keeps streaming the logs until the container exits
+	Follow bool
+	// Since returns only the logs written after the time, all the logs if zero
+	Since time.Time
+	// Tail returns only the last lines of the logs, all the lines if negative
+	Tail int
+	// Timestamps prefixes every line with the time it has been written
+	Timestamps bool
+}
+
+// TailLines returns the tail option in the format of the container runtime APIs
+func (o LogOptions) TailLines() string {
+	if o.Tail < 0 {
+		return "all"
+	}
+	return strconv.Itoa(o.Tail)
+}
+
+// SinceTimestamp returns the since option in the format of the container runtime APIs, empty if not set
+func (o LogOptions) SinceTimestamp() string {
+	if o.Since.IsZero() {
+		return ""
+	}
+	return o.Since.Format(time.RFC3339Nano)
+}
+
+// ParseLogSince parses the since option set by the user, either a duration
+// relative to now (e.g. 10m) or an RFC3339 timestamp
+func ParseLogSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(since); err == nil {
+		if duration < 0 {
+			return time.Time{}, fmt.Errorf("invalid since %q: the duration must be positive", since)
+		}
+		return now.Add(-duration), nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, since); err == nil {
+		return timestamp, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: must be a duration (e.g. 10m) or an RFC3339 timestamp", since)
 }
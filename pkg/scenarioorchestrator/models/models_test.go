@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarioNode_Validate(t *testing.T) {
+	assert.Nil(t, ScenarioNode{}.Validate(Podman))
+	assert.Nil(t, ScenarioNode{Timeout: "10m", Retries: 3, RetryBackoff: "30s", RetryOn: []int{1, 2}}.Validate(Podman))
+
+	assert.NotNil(t, ScenarioNode{Timeout: "ten minutes"}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Retries: -1}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Retries: 1, RetryBackoff: "soon"}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Retries: 1, RetryBackoff: "-1s"}.Validate(Podman))
+	assert.Nil(t, ScenarioNode{Retries: 1, RetryBackoff: "10m"}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Retries: 1, RetryBackoff: "11m"}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{RetryOn: []int{1}}.Validate(Podman))
+	assert.Nil(t, ScenarioNode{Parents: Dependencies{"root"}, When: WhenOnFailure}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Parents: Dependencies{"root"}, When: "sometimes"}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{When: WhenOnSuccess}.Validate(Podman))
+
+	backoff, err := ScenarioNode{RetryBackoff: "1m30s"}.GetRetryBackoff()
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Second, backoff)
+	backoff, err = ScenarioNode{}.GetRetryBackoff()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), backoff)
+}
+
+func TestScenarioNode_ValidateContainerOptions(t *testing.T) {
+	node := ScenarioNode{
+		Resources: &Resources{CPU: "1.5", Memory: "512m", Pids: 100},
+		Security:  &Security{CapAdd: []string{"NET_ADMIN"}, CapDrop: []string{"ALL"}, ReadOnlyRootfs: true, User: "krkn:krkn"},
+	}
+	assert.Nil(t, node.Validate(Podman))
+	assert.Nil(t, node.Validate(Docker))
+	// pids limit and user names can't be applied to a kubernetes pod
+	assert.NotNil(t, node.Validate(Kubernetes))
+	node.Resources.Pids = 0
+	assert.NotNil(t, node.Validate(Kubernetes))
+	node.Security.User = "1000:1000"
+	assert.Nil(t, node.Validate(Kubernetes))
+
+	assert.NotNil(t, ScenarioNode{Resources: &Resources{CPU: "one"}}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Resources: &Resources{CPU: "0"}}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Resources: &Resources{Memory: "lots"}}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Resources: &Resources{Pids: -1}}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Security: &Security{CapAdd: []string{"NET ADMIN"}}}.Validate(Podman))
+	assert.NotNil(t, ScenarioNode{Security: &Security{User: "root;id"}}.Validate(Podman))
+
+	nanoCPUs, err := (&Resources{CPU: "0.5"}).NanoCPUs()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(500000000), nanoCPUs)
+	memory, err := (&Resources{Memory: "1g"}).MemoryBytes()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024*1024*1024), memory)
+
+	uid, gid, err := (&Security{User: "1000"}).UserID()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), *uid)
+	assert.Nil(t, gid)
+}
+
+func TestParsePullPolicy(t *testing.T) {
+	for _, policy := range []string{"always", "if-not-present", "never"} {
+		pullPolicy, err := ParsePullPolicy(policy)
+		assert.Nil(t, err)
+		assert.Equal(t, PullPolicy(policy), pullPolicy)
+	}
+	_, err := ParsePullPolicy("IfNotPresent")
+	assert.NotNil(t, err)
+	_, err = ParsePullPolicy("")
+	assert.NotNil(t, err)
+}
+
+func TestLogOptions(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	since, err := ParseLogSince("10m", now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(-10*time.Minute), since)
+	since, err = ParseLogSince("2025-03-10T11:00:00Z", now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(-time.Hour), since)
+	since, err = ParseLogSince("", now)
+	assert.Nil(t, err)
+	assert.True(t, since.IsZero())
+	_, err = ParseLogSince("yesterday", now)
+	assert.NotNil(t, err)
+	_, err = ParseLogSince("-5m", now)
+	assert.NotNil(t, err)
+
+	options := LogOptions{Tail: -1}
+	assert.Equal(t, "all", options.TailLines())
+	assert.Equal(t, "", options.SinceTimestamp())
+	options = LogOptions{Tail: 20, Since: now}
+	assert.Equal(t, "20", options.TailLines())
+	assert.Equal(t, "2025-03-10T12:00:00Z", options.SinceTimestamp())
+}
+
+func TestDependencies(t *testing.T) {
+	var nodes map[string]ScenarioNode
+	err := json.Unmarshal([]byte(`{
+		"root": {"name": "dummy-scenario"},
+		"single": {"name": "dummy-scenario", "depends_on": "root"},
+		"fan-in": {"name": "dummy-scenario", "depends_on": ["root", "single"]},
+		"none": {"name": "dummy-scenario", "depends_on": null}
+	}`), &nodes)
+	assert.Nil(t, err)
+	assert.Nil(t, nodes["root"].GetParents())
+	assert.Nil(t, nodes["none"].GetParents())
+	assert.Equal(t, []string{"root"}, nodes["single"].GetParents())
+	assert.Equal(t, []string{"root", "single"}, nodes["fan-in"].GetParents())
+
+	// a single parent is written as a string
+	data, err := json.Marshal(nodes["single"])
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"depends_on":"root"`)
+	data, err = json.Marshal(nodes["fan-in"])
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"depends_on":["root","single"]`)
+	data, err = json.Marshal(nodes["root"])
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "depends_on")
+
+	err = json.Unmarshal([]byte(`{"node": {"depends_on": 1}}`), &nodes)
+	assert.NotNil(t, err)
+}
+
+func TestScenarioNode_SkipReason(t *testing.T) {
+	failed := map[string]bool{"a": true}
+	skipped := map[string]bool{"c": true}
+	node := ScenarioNode{Parents: Dependencies{"a", "b"}}
+	assert.Equal(t, "", node.SkipReason(failed, skipped))
+	node.When = WhenAlways
+	assert.Equal(t, "", node.SkipReason(failed, skipped))
+	node.When = WhenOnSuccess
+	assert.Equal(t, "runs on success but a failed", node.SkipReason(failed, skipped))
+	node.When = WhenOnFailure
+	assert.Equal(t, "", node.SkipReason(failed, skipped))
+
+	node.Parents = Dependencies{"b", "c"}
+	assert.Equal(t, "runs on failure but none of b, c failed", node.SkipReason(failed, skipped))
+	node.When = WhenOnSuccess
+	assert.Equal(t, "runs on success but c has been skipped", node.SkipReason(failed, skipped))
+	node.Parents = Dependencies{"b"}
+	assert.Equal(t, "", node.SkipReason(failed, skipped))
+}
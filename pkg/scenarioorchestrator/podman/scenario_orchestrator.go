@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/containers/podman/v5/pkg/bindings"
 	"github.com/containers/podman/v5/pkg/bindings/containers"
 	"github.com/containers/podman/v5/pkg/bindings/images"
 	"github.com/containers/podman/v5/pkg/errorhandling"
@@ -15,17 +14,25 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	providermodels "github.com/krkn-chaos/krknctl/pkg/provider/models"
+	"github.com/krkn-chaos/krknctl/pkg/provider/registryv2"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator"
 	orchestratormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
 	"github.com/krkn-chaos/krknctl/pkg/typing"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	imagecopy "go.podman.io/image/v5/copy"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/archive"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/signature"
+	imagetypes "go.podman.io/image/v5/types"
 	"io"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"os/exec"
 
@@ -59,8 +66,19 @@ func (w *progressWriter) Write(p []byte) (n int, err error) {
 
 // podmanCreateViaCLI runs podman create as a subprocess (Darwin: avoids REST bind issues; uses -p when publishPorts is set).
 // Each element is passed verbatim to podman -p (e.g. 127.0.0.1:3000:3000).
-func podmanCreateViaCLI(ctx context.Context, containerName, image string, env map[string]string, volumeMounts map[string]string, publishPorts []string, extra *scenarioorchestrator.PodmanCreateOptions) (string, error) {
-	args := []string{"create", "--replace", "--name", containerName}
+func podmanCreateViaCLI(ctx context.Context, containerName, image string, env map[string]string, volumeMounts map[string]string, publishPorts []string, extra *scenarioorchestrator.PodmanCreateOptions, labels map[string]string) (string, error) {
+	var args []string
+	remote := utils.ContainerHostFromContext(ctx)
+	if remote != nil {
+		args = append(args, "--url", remote.URI)
+		if remote.Identity != "" {
+			args = append(args, "--identity", remote.Identity)
+		}
+	}
+	args = append(args, "create", "--replace", "--name", containerName)
+	for k, v := range labels {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
+	}
 	if extra != nil {
 		if extra.ImagePlatform != "" {
 			args = append(args, "--platform", extra.ImagePlatform)
@@ -81,6 +99,31 @@ func podmanCreateViaCLI(ctx context.Context, containerName, image string, env ma
 		if len(extra.CDIDevices) == 0 && extra.GPURequest != "" {
 			args = append(args, "--device", fmt.Sprintf("nvidia.com/gpu=%s", extra.GPURequest))
 		}
+		if extra.Resources != nil {
+			if extra.Resources.CPU != "" {
+				args = append(args, "--cpus", extra.Resources.CPU)
+			}
+			if extra.Resources.Memory != "" {
+				args = append(args, "--memory", extra.Resources.Memory)
+			}
+			if extra.Resources.Pids > 0 {
+				args = append(args, "--pids-limit", strconv.FormatInt(extra.Resources.Pids, 10))
+			}
+		}
+		if extra.Security != nil {
+			for _, c := range extra.Security.CapAdd {
+				args = append(args, "--cap-add", c)
+			}
+			for _, c := range extra.Security.CapDrop {
+				args = append(args, "--cap-drop", c)
+			}
+			if extra.Security.ReadOnlyRootfs {
+				args = append(args, "--read-only")
+			}
+			if extra.Security.User != "" {
+				args = append(args, "--user", extra.Security.User)
+			}
+		}
 	}
 	if len(publishPorts) > 0 {
 		for _, p := range publishPorts {
@@ -92,7 +135,11 @@ func podmanCreateViaCLI(ctx context.Context, containerName, image string, env ma
 	for k, v := range env {
 		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
 	}
+	// on remote engines the files are copied after the container is created
 	for k, v := range volumeMounts {
+		if remote != nil {
+			break
+		}
 		args = append(args, "-v", fmt.Sprintf("%s:%s:z", k, v))
 	}
 	args = append(args, image)
@@ -115,60 +162,159 @@ func podmanCreateViaCLI(ctx context.Context, containerName, image string, env ma
 	return id, nil
 }
 
-func (c *ScenarioOrchestrator) Run(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, commChan *chan *string, ctx context.Context, registry *providermodels.RegistryV2, publishPorts []string, podmanCreate *scenarioorchestrator.PodmanCreateOptions) (*string, error) {
-	imageExists, err := images.Exists(ctx, image, nil)
-	if !cache || !imageExists {
+// copyRemoteVolumeMounts copies the volume mounts into the container when the engine
+// runs on a remote host where the local files cannot be bind mounted
+func copyRemoteVolumeMounts(ctx context.Context, containerID string, volumeMounts map[string]string) error {
+	if utils.ContainerHostFromContext(ctx) == nil || len(volumeMounts) == 0 {
+		return nil
+	}
+	archive, err := utils.ArchiveVolumeMounts(volumeMounts)
+	if err != nil {
+		return err
+	}
+	// the files are owned by the user the scenario runs as so that it can read them
+	copyFunc, err := containers.CopyFromArchiveWithOptions(ctx, containerID, "/", archive, new(containers.CopyOptions).WithChown(true))
+	if err != nil {
+		return err
+	}
+	if err := copyFunc(); err != nil {
+		return fmt.Errorf("failed to copy files into container %s: %w", containerID, err)
+	}
+	return nil
+}
 
-		// add a channel to update the status (eventually)
-		progressChan := make(chan ProgressMessage)
-		errChan := make(chan error)
-		go func() {
-			writer := &progressWriter{channel: progressChan}
-			options := images.PullOptions{}
-			if registry != nil {
-				if registry.Token != nil {
-					errChan <- fmt.Errorf("token authentication not yet supported in podman")
-					close(progressChan)
-					close(errChan)
-					return
-				}
+// pullWithToken pulls the image with the registry token.
+// The libpod API doesn't forward bearer tokens to the registry, so krknctl pulls the image itself.
+// The token is resolved as on docker and the image is loaded into podman from an archive.
+// The archive doesn't keep the manifest digest, so images pinned by digest are rejected.
+func pullWithToken(ctx context.Context, image string, registry *providermodels.RegistryV2, writer io.Writer) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+	tagged, ok := reference.TagNameOnly(named).(reference.NamedTagged)
+	if !ok {
+		return fmt.Errorf("image %s is pinned by digest, podman can't load it by digest when it is pulled with a registry token", image)
+	}
+	token, err := registryv2.ResolveBearerToken(registry, image)
+	if err != nil {
+		return err
+	}
+	source, err := docker.NewReference(tagged)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "krknctl-pull-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir) // #nosec G104 -- the archive has been loaded, a leftover temp dir is not actionable
+	}()
+	archivePath := filepath.Join(dir, "image.tar")
+	destination, err := archive.NewReference(archivePath, tagged)
+	if err != nil {
+		return err
+	}
 
-				if registry.Username != nil {
-					options.Username = registry.Username
-				}
-				if registry.Password != nil {
-					options.Password = registry.Password
+	// the host policy is honored when there is one, podman doesn't check the signatures of loaded images
+	policy, err := signature.DefaultPolicy(nil)
+	if err != nil {
+		policy = &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
+	}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = policyContext.Destroy()
+	}()
+	sourceContext := &imagetypes.SystemContext{
+		DockerBearerRegistryToken:   *token,
+		DockerInsecureSkipTLSVerify: imagetypes.NewOptionalBool(registry.SkipTLS || registry.Insecure),
+	}
+	if _, err := imagecopy.Image(ctx, policyContext, destination, source, &imagecopy.Options{SourceCtx: sourceContext, ReportWriter: writer}); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+
+	file, err := os.Open(archivePath) // #nosec G304 -- the archive has been written by krknctl
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = images.Load(ctx, file)
+	return err
+}
+
+func (c *ScenarioOrchestrator) PullImage(
+	image string,
+	pullPolicy orchestratormodels.PullPolicy,
+	registry *providermodels.RegistryV2,
+	commChan *chan *string,
+	ctx context.Context,
+) error {
+	imageExists, err := images.Exists(ctx, image, nil)
+	if err != nil {
+		return err
+	}
+	pull, err := scenarioorchestrator.CommonShouldPull(image, imageExists, pullPolicy)
+	if err != nil || !pull {
+		return err
+	}
+
+	// add a channel to update the status (eventually)
+	progressChan := make(chan ProgressMessage)
+	errChan := make(chan error)
+	go func() {
+		writer := &progressWriter{channel: progressChan}
+		options := images.PullOptions{}
+		if registry != nil {
+			if registry.HasToken() {
+				if err := pullWithToken(ctx, image, registry, writer); err != nil {
+					errChan <- err
 				}
-				options.SkipTLSVerify = &registry.SkipTLS
+				close(progressChan)
+				close(errChan)
+				return
 			}
-			options.WithProgressWriter(writer)
-			_, err = images.Pull(ctx, image, &options)
-			if err != nil {
-				errChan <- err
-			}
-			close(progressChan)
-			close(errChan)
-		}()
-		go func() {
-			for msg := range progressChan {
-				if commChan != nil {
-					message := fmt.Sprintf("Status: %s - %s", msg.Status, msg.Detail)
-					*commChan <- &message
-				}
 
+			if registry.Username != nil {
+				options.Username = registry.Username
+			}
+			if registry.Password != nil {
+				options.Password = registry.Password
 			}
+			options.SkipTLSVerify = &registry.SkipTLS
+		}
+		options.WithProgressWriter(writer)
+		_, err := images.Pull(ctx, image, &options)
+		if err != nil {
+			errChan <- err
+		}
+		close(progressChan)
+		close(errChan)
+	}()
+	go func() {
+		for msg := range progressChan {
 			if commChan != nil {
-				close(*commChan)
+				message := fmt.Sprintf("Status: %s - %s", msg.Status, msg.Detail)
+				*commChan <- &message
 			}
 
-		}()
-
-		if err := <-errChan; err != nil {
-			return nil, err
 		}
-	}
+		if commChan != nil {
+			close(*commChan)
+		}
 
-	if err != nil {
+	}()
+
+	return <-errChan
+}
+
+func (c *ScenarioOrchestrator) Run(image string, containerName string, env map[string]string, cache bool, volumeMounts map[string]string, commChan *chan *string, ctx context.Context, registry *providermodels.RegistryV2, publishPorts []string, podmanCreate *scenarioorchestrator.PodmanCreateOptions) (*string, error) {
+	if err := c.PullImage(image, scenarioorchestrator.ResolvePullPolicy(cache, podmanCreate), registry, commChan, ctx); err != nil {
 		return nil, err
 	}
 
@@ -180,10 +326,13 @@ func (c *ScenarioOrchestrator) Run(image string, containerName string, env map[s
 		useCLI = true
 	}
 	if useCLI {
-		containerID, err := podmanCreateViaCLI(ctx, containerName, image, env, volumeMounts, publishPorts, podmanCreate)
+		containerID, err := podmanCreateViaCLI(ctx, containerName, image, env, volumeMounts, publishPorts, podmanCreate, scenarioorchestrator.CommonContainerLabels(podmanCreate, c.Config))
 		if err != nil {
 			return nil, err
 		}
+		if err := copyRemoteVolumeMounts(ctx, containerID, volumeMounts); err != nil {
+			return nil, err
+		}
 		if err := containers.Start(ctx, containerID, nil); err != nil {
 			return nil, err
 		}
@@ -194,8 +343,13 @@ func (c *ScenarioOrchestrator) Run(image string, containerName string, env map[s
 
 	s.Name = containerName
 	s.Env = env
+	s.Labels = scenarioorchestrator.CommonContainerLabels(podmanCreate, c.Config)
 
+	remote := utils.ContainerHostFromContext(ctx) != nil
 	for k, v := range volumeMounts {
+		if remote {
+			break
+		}
 		containerMount := specs.Mount{
 			Destination: v,
 			Type:        string(mount.TypeBind),
@@ -227,11 +381,17 @@ func (c *ScenarioOrchestrator) Run(image string, containerName string, env map[s
 		if len(podmanCreate.GroupAdd) > 0 {
 			s.Groups = append(s.Groups, podmanCreate.GroupAdd...)
 		}
+		if err := applyResourcesAndSecurity(s, podmanCreate); err != nil {
+			return nil, err
+		}
 	}
 	createResponse, err := containers.CreateWithSpec(ctx, s, nil)
 	if err != nil {
 		return nil, err
 	}
+	if err := copyRemoteVolumeMounts(ctx, createResponse.ID, volumeMounts); err != nil {
+		return nil, err
+	}
 	if err := containers.Start(ctx, createResponse.ID, nil); err != nil {
 		return nil, err
 	}
@@ -277,35 +437,123 @@ func (c *ScenarioOrchestrator) Attach(containerID *string,
 	}
 }
 
-func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context) (*int, error) {
+func (c *ScenarioOrchestrator) CleanContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*int, error) {
 	_true := true
-	nameRegex, err := regexp.Compile(fmt.Sprintf("^%s.*-[0-9]+$", c.Config.ContainerPrefix))
+	foundContainers, err := containers.List(ctx, &containers.ListOptions{
+		All:     &_true,
+		Filters: c.labelFilter(),
+	})
 	if err != nil {
 		return nil, err
 	}
+	deletedContainers := 0
+
+	for _, container := range foundContainers {
+		labels := utils.ContainerLabelsFromMap(container.Labels, c.Config)
+		if labels == nil || !labels.Matches(filter) {
+			continue
+		}
+		_, err := containers.Remove(ctx, container.ID, &containers.RemoveOptions{
+			Force: &_true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		deletedContainers++
+	}
+
+	return &deletedContainers, nil
+}
+
+func (c *ScenarioOrchestrator) ListFinishedContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.Container, error) {
+	_true := true
 	foundContainers, err := containers.List(ctx, &containers.ListOptions{
-		All: &_true,
+		All:     &_true,
+		Filters: c.labelFilter(),
 	})
 	if err != nil {
 		return nil, err
 	}
-	deletedContainers := 0
+	finished := make([]orchestratormodels.Container, 0)
+	for _, container := range foundContainers {
+		labels := utils.ContainerLabelsFromMap(container.Labels, c.Config)
+		if container.State == c.Config.PodmanRunningState || labels == nil || !labels.Matches(filter) {
+			continue
+		}
+		finished = append(finished, orchestratormodels.Container{
+			Name:       container.Names[0],
+			ID:         container.ID,
+			Image:      container.Image,
+			Started:    container.Created.Unix(),
+			Status:     container.State,
+			ExitStatus: int(container.ExitCode),
+			Labels:     labels,
+		})
+	}
+	return &finished, nil
+}
+
+func (c *ScenarioOrchestrator) RemoveContainer(containerID *string, ctx context.Context) error {
+	_true := true
+	_, err := containers.Remove(ctx, *containerID, &containers.RemoveOptions{
+		Force: &_true,
+	})
+	return err
+}
 
-	for _, c := range foundContainers {
-		for _, n := range c.Names {
-			if nameRegex.MatchString(n) {
-				_, err := containers.Remove(ctx, n, &containers.RemoveOptions{
-					Force: &_true,
-				})
-				if err != nil {
-					return nil, err
+// labelFilter selects the containers stamped with a krknctl run ID
+func (c *ScenarioOrchestrator) labelFilter() map[string][]string {
+	return map[string][]string{"label": {c.Config.LabelRunID}}
+}
+
+func (c *ScenarioOrchestrator) Logs(containerID *string, options orchestratormodels.LogOptions, stdout io.Writer, stderr io.Writer, ctx context.Context) error {
+	logOptions := new(containers.LogOptions).
+		WithStdout(true).
+		WithStderr(true).
+		WithFollow(options.Follow).
+		WithTail(options.TailLines()).
+		WithTimestamps(options.Timestamps)
+	if since := options.SinceTimestamp(); since != "" {
+		logOptions = logOptions.WithSince(since)
+	}
+	logsCtx, cancelLogs := context.WithCancel(ctx)
+	stdoutChan := make(chan string)
+	stderrChan := make(chan string)
+	errorChannel := make(chan error, 1)
+	go func() {
+		errorChannel <- containers.Logs(logsCtx, *containerID, logOptions, stdoutChan, stderrChan)
+	}()
+	// containers.Logs blocks sending the frames on the unbuffered channels, when the output
+	// can't be written anymore the stream is closed and the channels are drained until it returns
+	stop := func(err error) error {
+		cancelLogs()
+		go func() {
+			for {
+				select {
+				case <-stdoutChan:
+				case <-stderrChan:
+				case <-errorChannel:
+					return
 				}
-				deletedContainers++
 			}
+		}()
+		return err
+	}
+	for {
+		select {
+		case line := <-stdoutChan:
+			if _, err := io.WriteString(stdout, line); err != nil {
+				return stop(err)
+			}
+		case line := <-stderrChan:
+			if _, err := io.WriteString(stderr, line); err != nil {
+				return stop(err)
+			}
+		case err := <-errorChannel:
+			cancelLogs()
+			return err
 		}
 	}
-
-	return &deletedContainers, nil
 }
 
 func (c *ScenarioOrchestrator) Kill(containerID *string, ctx context.Context) error {
@@ -324,30 +572,22 @@ func (c *ScenarioOrchestrator) GetContainerRuntime() orchestratormodels.Containe
 	return c.ContainerRuntime
 }
 
-func (c *ScenarioOrchestrator) ListRunningContainers(ctx context.Context) (*map[int64]orchestratormodels.Container, error) {
+func (c *ScenarioOrchestrator) ListRunningContainers(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*map[int64]orchestratormodels.Container, error) {
 	scenarios := make(map[int64]orchestratormodels.Container)
-	scenarioNameRegex, err := regexp.Compile(fmt.Sprintf("%s-.*-([0-9]+)", c.Config.ContainerPrefix))
-	if err != nil {
-		return nil, err
-	}
-	containerList, err := containers.List(ctx, nil)
+	containerList, err := containers.List(ctx, &containers.ListOptions{Filters: c.labelFilter()})
 	if err != nil {
 		return nil, err
 	}
 	for _, container := range containerList {
-		if container.State == c.Config.PodmanRunningState && scenarioNameRegex.MatchString(container.Names[0]) {
-			groups := scenarioNameRegex.FindStringSubmatch(container.Names[0])
-			if len(groups) > 1 {
-				index, err := strconv.ParseInt(groups[1], 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				scenarios[index] = orchestratormodels.Container{
-					Name:    container.Names[0],
-					ID:      container.ID,
-					Image:   container.Image,
-					Started: index,
-				}
+		labels := utils.ContainerLabelsFromMap(container.Labels, c.Config)
+		if container.State == c.Config.PodmanRunningState && labels != nil && labels.Matches(filter) {
+			index := utils.ContainerNameTimestamp(container.Names[0], container.Created)
+			scenarios[index] = orchestratormodels.Container{
+				Name:    container.Names[0],
+				ID:      container.ID,
+				Image:   container.Image,
+				Started: container.Created.Unix(),
+				Labels:  labels,
 			}
 		}
 	}
@@ -380,6 +620,7 @@ func (c *ScenarioOrchestrator) InspectScenario(container orchestratormodels.Cont
 	if inspectData.Config == nil {
 		return nil, fmt.Errorf("container %s has no config", container.ID)
 	}
+	container.Labels = utils.ContainerLabelsFromMap(inspectData.Config.Labels, c.Config)
 	scenarioDetail := providermodels.ScenarioDetail{}
 	scenarioDetail.Digest = &inspectData.ImageDigest
 	imageAndTag := strings.Split(inspectData.ImageName, ":")
@@ -425,7 +666,14 @@ func (c *ScenarioOrchestrator) InspectScenario(container orchestratormodels.Cont
 }
 
 func (c *ScenarioOrchestrator) Connect(containerRuntimeURI string) (context.Context, error) {
-	return bindings.NewConnection(context.Background(), containerRuntimeURI)
+	ctx, host, err := utils.NewPodmanConnection(context.Background(), containerRuntimeURI, c.Config)
+	if err != nil {
+		return nil, err
+	}
+	if host.IsRemote() {
+		ctx = utils.ContextWithContainerHost(ctx, host)
+	}
+	return ctx, nil
 }
 
 func (c *ScenarioOrchestrator) GetConfig() config.Config {
@@ -435,17 +683,19 @@ func (c *ScenarioOrchestrator) GetConfig() config.Config {
 func (c *ScenarioOrchestrator) ResolveContainerName(containerName string, ctx context.Context) (*string, error) {
 	_true := true
 	containerList, err := containers.List(ctx, &containers.ListOptions{
-		All: &_true,
+		All:     &_true,
+		Filters: c.labelFilter(),
 	})
 	if err != nil {
 		return nil, err
 	}
+	var candidates []orchestratormodels.Container
 	for _, container := range containerList {
-		if strings.Contains(container.Names[0], containerName) {
-			return &container.ID, nil
+		for _, name := range container.Names {
+			candidates = append(candidates, orchestratormodels.Container{Name: name, ID: container.ID})
 		}
 	}
-	return nil, nil
+	return utils.ResolveContainer(candidates, containerName)
 }
 
 // common functions
@@ -463,9 +713,10 @@ func (c *ScenarioOrchestrator) RunAttached(
 	registry *providermodels.RegistryV2,
 	publishPorts []string,
 	podmanCreate *scenarioorchestrator.PodmanCreateOptions,
+	timeout *time.Duration,
 ) (*string, error) {
 
-	return scenarioorchestrator.CommonRunAttached(image, containerName, env, cache, volumeMounts, stdout, stderr, c, commChan, ctx, registry, publishPorts, podmanCreate)
+	return scenarioorchestrator.CommonRunAttached(image, containerName, env, cache, volumeMounts, stdout, stderr, c, commChan, ctx, registry, publishPorts, podmanCreate, timeout)
 }
 
 func (c *ScenarioOrchestrator) AttachWait(containerID *string, stdout io.Writer, stderr io.Writer, ctx context.Context) (*bool, error) {
@@ -482,19 +733,64 @@ func (c *ScenarioOrchestrator) RunGraph(
 	resolvedGraph orchestratormodels.ResolvedGraph,
 	extraEnv map[string]string,
 	extraVolumeMounts map[string]string,
-	cache bool,
+	stdout io.Writer,
+	pullPolicy orchestratormodels.PullPolicy,
+	schedule orchestratormodels.Schedule,
 	commChannel chan *orchestratormodels.GraphCommChannel,
+	ctx context.Context,
 	registry *providermodels.RegistryV2,
 	userID *int,
+	labels *orchestratormodels.ContainerLabels,
+	runDir string,
 ) {
 	//TODO: add a getconfig method in scenarioOrchestrator
-	scenarioorchestrator.CommonRunGraph(scenarios, resolvedGraph, extraEnv, extraVolumeMounts, cache, commChannel, c, c.Config, registry, userID)
+	scenarioorchestrator.CommonRunGraph(scenarios, resolvedGraph, extraEnv, extraVolumeMounts, stdout, pullPolicy, schedule, commChannel, ctx, c, c.Config, registry, userID, labels, runDir)
+}
+
+func (c *ScenarioOrchestrator) PrintContainerRuntime(w io.Writer) {
+	scenarioorchestrator.CommonPrintRuntime(w, c.ContainerRuntime)
 }
 
-func (c *ScenarioOrchestrator) PrintContainerRuntime() {
-	scenarioorchestrator.CommonPrintRuntime(c.ContainerRuntime)
+func (c *ScenarioOrchestrator) ListRunningScenarios(ctx context.Context, filter *orchestratormodels.ContainerLabels) (*[]orchestratormodels.ScenarioContainer, error) {
+	return scenarioorchestrator.CommonListRunningScenarios(c, ctx, filter)
 }
 
-func (c *ScenarioOrchestrator) ListRunningScenarios(ctx context.Context) (*[]orchestratormodels.ScenarioContainer, error) {
-	return scenarioorchestrator.CommonListRunningScenarios(c, ctx)
+// applyResourcesAndSecurity sets the resource limits and the security options
+// of the scenario container
+func applyResourcesAndSecurity(s *specgen.SpecGenerator, podmanCreate *scenarioorchestrator.PodmanCreateOptions) error {
+	if podmanCreate.Resources != nil {
+		nanoCPUs, err := podmanCreate.Resources.NanoCPUs()
+		if err != nil {
+			return err
+		}
+		memory, err := podmanCreate.Resources.MemoryBytes()
+		if err != nil {
+			return err
+		}
+		resources := &specs.LinuxResources{}
+		if nanoCPUs > 0 {
+			// same conversion podman does for --cpus
+			period := uint64(100000)
+			quota := nanoCPUs * int64(period) / 1e9
+			resources.CPU = &specs.LinuxCPU{Quota: &quota, Period: &period}
+		}
+		if memory > 0 {
+			resources.Memory = &specs.LinuxMemory{Limit: &memory}
+		}
+		if podmanCreate.Resources.Pids > 0 {
+			pids := podmanCreate.Resources.Pids
+			resources.Pids = &specs.LinuxPids{Limit: &pids}
+		}
+		s.ResourceLimits = resources
+	}
+	if podmanCreate.Security != nil {
+		s.CapAdd = podmanCreate.Security.CapAdd
+		s.CapDrop = podmanCreate.Security.CapDrop
+		if podmanCreate.Security.ReadOnlyRootfs {
+			readOnly := true
+			s.ReadOnlyFilesystem = &readOnly
+		}
+		s.User = podmanCreate.Security.User
+	}
+	return nil
 }
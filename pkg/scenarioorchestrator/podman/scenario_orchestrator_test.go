@@ -77,7 +77,7 @@ func TestScenarioOrchestrator_Podman_CleanContainers(t *testing.T) {
 	scenarioorchestratortest.CommonTestScenarioOrchestratorRunAttached(t, &sopodman, config, 5)
 	foundContainers, ctx := findContainers(t, config)
 	assert.Greater(t, foundContainers, 0)
-	numcontainers, err := sopodman.CleanContainers(ctx)
+	numcontainers, err := sopodman.CleanContainers(ctx, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, foundContainers, *numcontainers)
 	foundContainers, _ = findContainers(t, config)
@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"time"
 
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	"github.com/krkn-chaos/krknctl/pkg/provider/models"
@@ -24,6 +25,10 @@ type PodmanCreateOptions struct {
 	GPURequest    string            // GPU request for both runtimes: "all" or "device=0,1"
 	                                 // Docker: uses DeviceRequest with --gpus semantics
 	                                 // Podman: converts to CDI nvidia.com/gpu=<value> if CDIDevices empty
+	Resources     *orchestrator_models.Resources       // cpu, memory and pids limits for every runtime
+	Security      *orchestrator_models.Security        // capabilities, read-only rootfs and user for every runtime
+	Labels        *orchestrator_models.ContainerLabels // run, graph and node the container belongs to
+	PullPolicy    orchestrator_models.PullPolicy       // when set overrides the cache flag of Run
 }
 
 type ScenarioOrchestrator interface {
@@ -55,6 +60,7 @@ type ScenarioOrchestrator interface {
 		registry *models.RegistryV2,
 		publishPorts []string,
 		podmanCreate *PodmanCreateOptions,
+		timeout *time.Duration,
 	) (*string, error)
 
 	RunGraph(
@@ -62,13 +68,33 @@ type ScenarioOrchestrator interface {
 		resolvedGraph orchestrator_models.ResolvedGraph,
 		extraEnv map[string]string,
 		extraVolumeMounts map[string]string,
-		cache bool,
+		stdout io.Writer,
+		pullPolicy orchestrator_models.PullPolicy,
+		schedule orchestrator_models.Schedule,
 		commChannel chan *orchestrator_models.GraphCommChannel,
+		ctx context.Context,
 		registry *models.RegistryV2,
 		userID *int,
+		labels *orchestrator_models.ContainerLabels,
+		runDir string,
 	)
 
-	CleanContainers(ctx context.Context) (*int, error)
+	// PullImage pulls the image according to the pull policy, fails if the policy
+	// is never and the image is not present
+	PullImage(
+		image string,
+		pullPolicy orchestrator_models.PullPolicy,
+		registry *models.RegistryV2,
+		commChan *chan *string,
+		ctx context.Context,
+	) error
+
+	CleanContainers(ctx context.Context, filter *orchestrator_models.ContainerLabels) (*int, error)
+
+	// ListFinishedContainers returns the krknctl containers that are not running anymore
+	// with their exit status, RemoveContainer deletes one of them
+	ListFinishedContainers(ctx context.Context, filter *orchestrator_models.ContainerLabels) (*[]orchestrator_models.Container, error)
+	RemoveContainer(containerID *string, ctx context.Context) error
 
 	AttachWait(
 		containerID *string,
@@ -85,16 +111,26 @@ type ScenarioOrchestrator interface {
 		ctx context.Context,
 	) (bool, error)
 
+	// Logs writes the logs of a running or exited container to stdout and stderr,
+	// when following the logs it returns once the container exits
+	Logs(
+		containerID *string,
+		options orchestrator_models.LogOptions,
+		stdout io.Writer,
+		stderr io.Writer,
+		ctx context.Context,
+	) error
+
 	Kill(containerID *string, ctx context.Context) error
 
-	ListRunningContainers(ctx context.Context) (*map[int64]orchestrator_models.Container, error)
-	ListRunningScenarios(ctx context.Context) (*[]orchestrator_models.ScenarioContainer, error)
+	ListRunningContainers(ctx context.Context, filter *orchestrator_models.ContainerLabels) (*map[int64]orchestrator_models.Container, error)
+	ListRunningScenarios(ctx context.Context, filter *orchestrator_models.ContainerLabels) (*[]orchestrator_models.ScenarioContainer, error)
 	InspectScenario(container orchestrator_models.Container, ctx context.Context) (*orchestrator_models.ScenarioContainer, error)
 
 	GetContainerRuntimeSocket(userID *int) (*string, error)
 
 	GetContainerRuntime() orchestrator_models.ContainerRuntime
-	PrintContainerRuntime()
+	PrintContainerRuntime(w io.Writer)
 	GetConfig() config.Config
 	ResolveContainerName(containerName string, ctx context.Context) (*string, error)
 }
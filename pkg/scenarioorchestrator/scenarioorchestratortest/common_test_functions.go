@@ -2,6 +2,7 @@
 package scenarioorchestratortest
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -216,7 +217,7 @@ func CommonTestScenarioOrchestratorRunAttached(t *testing.T, so scenarioorchestr
 
 	fmt.Println("CONTAINER SOCKET -> " + *socket)
 	containerName1 := utils.GenerateContainerName(conf, scenario.Name, nil)
-	containerID, err := so.RunAttached(registryURI+":"+scenario.Name, containerName1, env, false, map[string]string{}, os.Stdout, os.Stderr, nil, ctx, nil, nil, nil)
+	containerID, err := so.RunAttached(registryURI+":"+scenario.Name, containerName1, env, false, map[string]string{}, os.Stdout, os.Stderr, nil, ctx, nil, nil, nil, nil)
 	if err != nil {
 		fmt.Println("ERROR -> " + err.Error())
 	}
@@ -228,7 +229,7 @@ func CommonTestScenarioOrchestratorRunAttached(t *testing.T, so scenarioorchestr
 	env["END"] = fmt.Sprintf("%d", duration)
 	env["EXIT_STATUS"] = fmt.Sprintf("%d", exitStatus)
 	containerName2 := utils.GenerateContainerName(conf, scenario.Name, nil)
-	containerID, err = so.RunAttached(registryURI+":"+scenario.Name, containerName2, env, false, map[string]string{}, os.Stdout, os.Stderr, nil, ctx, nil, nil, nil)
+	containerID, err = so.RunAttached(registryURI+":"+scenario.Name, containerName2, env, false, map[string]string{}, os.Stdout, os.Stderr, nil, ctx, nil, nil, nil, nil)
 	if err != nil {
 		fmt.Println("ERROR -> " + err.Error())
 	}
@@ -370,13 +371,15 @@ func CommonTestScenarioOrchestratorRunGraph(t *testing.T, so scenarioorchestrato
 
 	commChannel := make(chan *models.GraphCommChannel)
 	go func() {
-		so.RunGraph(nodes, executionPlan, map[string]string{}, map[string]string{}, false, commChannel, nil, uid)
+		so.RunGraph(nodes, executionPlan, map[string]string{}, map[string]string{}, os.Stdout, models.PullAlways, models.Schedule{}, commChannel, context.Background(), nil, uid, nil, t.TempDir())
 	}()
 
 	for {
 		c := <-commChannel
 		if c == nil {
 			break
+		} else if c.Event != nil {
+			continue
 		} else {
 			assert.Nil(t, (*c).Err)
 			fmt.Printf("Running step %d scenario: %s\n", *c.Layer, *c.ScenarioID)
@@ -446,13 +449,15 @@ func CommonTestScenarioOrchestratorRunGraph(t *testing.T, so scenarioorchestrato
 
 	commChannel = make(chan *models.GraphCommChannel)
 	go func() {
-		so.RunGraph(nodes, executionPlan, map[string]string{}, map[string]string{}, false, commChannel, nil, uid)
+		so.RunGraph(nodes, executionPlan, map[string]string{}, map[string]string{}, os.Stdout, models.PullAlways, models.Schedule{}, commChannel, context.Background(), nil, uid, nil, t.TempDir())
 	}()
 
 	for {
 		c := <-commChannel
 		if c == nil {
 			break
+		} else if c.Event != nil {
+			continue
 		} else {
 			if (*c).Err != nil {
 				assert.NotNil(t, (*c).ScenarioID)
@@ -489,7 +494,7 @@ func CommonTestScenarioOrchestratorListRunningContainers(t *testing.T, so scenar
 	assert.Nil(t, err)
 	assert.NotNil(t, ctx)
 
-	containers, err := so.ListRunningContainers(ctx)
+	containers, err := so.ListRunningContainers(ctx, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, containers)
 }
@@ -510,7 +515,7 @@ func CommonScenarioDetail(t *testing.T, so scenarioorchestrator.ScenarioOrchestr
 	ctx, err := so.Connect(*socket)
 	assert.Nil(t, err)
 	assert.NotNil(t, ctx)
-	scenarios, err := so.ListRunningContainers(ctx)
+	scenarios, err := so.ListRunningContainers(ctx, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, scenarios)
 	for _, v := range *scenarios {
@@ -590,7 +595,7 @@ func CommonTestScenarioOrchestratorResolveContainerName(t *testing.T, so scenari
 
 	fmt.Println("CONTAINER SOCKET -> " + *socket)
 	containerName := utils.GenerateContainerName(conf, scenario.Name, nil)
-	containerID, err := so.RunAttached(registryURI+":"+scenario.Name, containerName, env, false, map[string]string{}, os.Stdout, os.Stderr, nil, ctx, nil, nil, nil)
+	containerID, err := so.RunAttached(registryURI+":"+scenario.Name, containerName, env, false, map[string]string{}, os.Stdout, os.Stderr, nil, ctx, nil, nil, nil, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, containerID)
 
@@ -648,7 +653,7 @@ func CommonTestScenarioOrchestratorKillContainers(t *testing.T, so scenarioorche
 	containerID, err := so.Run(registryURI+":"+scenario.Name, containerName, env, false, map[string]string{}, nil, ctx, nil, nil, nil)
 	assert.Nil(t, err)
 	time.Sleep(2 * time.Second)
-	containers, err := so.ListRunningContainers(ctx)
+	containers, err := so.ListRunningContainers(ctx, nil)
 	assert.Nil(t, err)
 	found := false
 	for _, v := range *containers {
@@ -661,7 +666,7 @@ func CommonTestScenarioOrchestratorKillContainers(t *testing.T, so scenarioorche
 	err = so.Kill(containerID, ctx)
 	assert.Nil(t, err)
 	time.Sleep(2 * time.Second)
-	containers, err = so.ListRunningContainers(ctx)
+	containers, err = so.ListRunningContainers(ctx, nil)
 	assert.Nil(t, err)
 	found = false
 	for _, v := range *containers {
@@ -726,7 +731,7 @@ func CommonTestScenarioOrchestratorListRunningScenarios(t *testing.T, so scenari
 	time.Sleep(1 * time.Second)
 
 	assert.Nil(t, err)
-	runningContainers, err := so.ListRunningScenarios(ctx)
+	runningContainers, err := so.ListRunningScenarios(ctx, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, runningContainers)
 	i := 0
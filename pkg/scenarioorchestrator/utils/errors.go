@@ -1,6 +1,22 @@
 package utils
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutExitStatus is the exit status krknctl returns when a scenario
+// is killed because of its timeout, the same used by coreutils timeout
+const TimeoutExitStatus = 124
+
+// InterruptedExitStatus is the exit status krknctl returns when the run is
+// aborted by SIGINT or SIGTERM, 128 + SIGINT as returned by the shells
+const InterruptedExitStatus = 130
+
+// ErrInterrupted is the cause of the runs aborted by SIGINT or SIGTERM
+var ErrInterrupted = errors.New("interrupted by the user")
 
 type ExitError struct {
 	ExitStatus int
@@ -9,3 +25,74 @@ type ExitError struct {
 func (e *ExitError) Error() string {
 	return fmt.Sprintf("Krkn exited with exit status: %d", e.ExitStatus)
 }
+
+// TimeoutError is returned when the scenario container is killed because it
+// did not complete within its timeout
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Krkn timed out after %s and has been killed", e.Timeout)
+}
+
+// AbortError is returned when the run is aborted, either interrupted or
+// because a scenario failed with exit on error set
+type AbortError struct {
+	Cause error
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("chaos run aborted: %s", e.Cause)
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Cause
+}
+
+// ExitStatus returns the exit status of the aborted run: the one of the
+// failed scenario, TimeoutExitStatus if it timed out, InterruptedExitStatus otherwise
+func (e *AbortError) ExitStatus() int {
+	var exitErr *ExitError
+	var timeoutErr *TimeoutError
+	switch {
+	case errors.As(e.Cause, &exitErr):
+		return exitErr.ExitStatus
+	case errors.As(e.Cause, &timeoutErr):
+		return TimeoutExitStatus
+	default:
+		return InterruptedExitStatus
+	}
+}
+
+type abortContextKey struct{}
+
+// ContextWithAbort attaches the run context to the container runtime context, once the run
+// context is cancelled the containers attached with the returned context are killed
+func ContextWithAbort(ctx context.Context, runCtx context.Context) context.Context {
+	return context.WithValue(ctx, abortContextKey{}, runCtx)
+}
+
+// AbortFromContext returns the run context attached to the container runtime context, nil if none
+func AbortFromContext(ctx context.Context) context.Context {
+	runCtx, ok := ctx.Value(abortContextKey{}).(context.Context)
+	if !ok {
+		return nil
+	}
+	return runCtx
+}
+
+type startedContextKey struct{}
+
+// ContextWithStarted attaches to the container runtime context the function called
+// with the container ID once the container attached with the returned context has started
+func ContextWithStarted(ctx context.Context, started func(containerID string)) context.Context {
+	return context.WithValue(ctx, startedContextKey{}, started)
+}
+
+// NotifyStarted calls the function attached to the context by ContextWithStarted, if any
+func NotifyStarted(ctx context.Context, containerID string) {
+	if started, ok := ctx.Value(startedContextKey{}).(func(string)); ok {
+		started(containerID)
+	}
+}
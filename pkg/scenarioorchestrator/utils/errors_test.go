@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbortError_ExitStatus(t *testing.T) {
+	assert.Equal(t, 3, (&AbortError{Cause: &ExitError{ExitStatus: 3}}).ExitStatus())
+	assert.Equal(t, 3, (&AbortError{Cause: fmt.Errorf("node failed: %w", &ExitError{ExitStatus: 3})}).ExitStatus())
+	assert.Equal(t, TimeoutExitStatus, (&AbortError{Cause: &TimeoutError{Timeout: time.Second}}).ExitStatus())
+	assert.Equal(t, InterruptedExitStatus, (&AbortError{Cause: ErrInterrupted}).ExitStatus())
+	assert.True(t, errors.Is(&AbortError{Cause: ErrInterrupted}, ErrInterrupted))
+}
+
+func TestContextWithAbort(t *testing.T) {
+	assert.Nil(t, AbortFromContext(context.Background()))
+	runCtx, cancel := context.WithCancelCause(context.Background())
+	ctx := ContextWithAbort(context.Background(), runCtx)
+	assert.Equal(t, runCtx, AbortFromContext(ctx))
+	cancel(ErrInterrupted)
+	assert.Nil(t, ctx.Err())
+	assert.Equal(t, ErrInterrupted, context.Cause(AbortFromContext(ctx)))
+}
+
+func TestContextWithStarted(t *testing.T) {
+	// without function the notification is ignored
+	NotifyStarted(context.Background(), "4f53cda18c2b")
+	var started []string
+	ctx := ContextWithStarted(context.Background(), func(containerID string) {
+		started = append(started, containerID)
+	})
+	NotifyStarted(ctx, "4f53cda18c2b")
+	assert.Equal(t, []string{"4f53cda18c2b"}, started)
+}
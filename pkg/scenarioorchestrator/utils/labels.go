@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	orchestatormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+)
+
+// graphHashLength keeps the hash short enough to be a valid Kubernetes label value
+const graphHashLength = 16
+
+// GenerateRunID returns a new run identifier, sortable by start time (e.g. 20240130-154502-9f86d0)
+func GenerateRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%06d", time.Now().Format("20060102-150405"), time.Now().Nanosecond()%1000000)
+	}
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
+// GraphHash returns the short sha256 of the graph file content
+func GraphHash(graph []byte) string {
+	sum := sha256.Sum256(graph)
+	return hex.EncodeToString(sum[:])[:graphHashLength]
+}
+
+// NewContainerLabels returns the labels of a new run, the node ID and the
+// scenario name are set by the caller for each container
+func NewContainerLabels(config config.Config, graphHash string) orchestatormodels.ContainerLabels {
+	return orchestatormodels.ContainerLabels{
+		RunID:     GenerateRunID(),
+		GraphHash: graphHash,
+		Version:   config.Version,
+		User:      currentUser(),
+	}
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// ContainerLabelsToMap returns the container labels keyed by the names set in the config, empty values are omitted
+func ContainerLabelsToMap(labels orchestatormodels.ContainerLabels, config config.Config) map[string]string {
+	labelMap := make(map[string]string)
+	for k, v := range map[string]string{
+		config.LabelRunID:     labels.RunID,
+		config.LabelGraphHash: labels.GraphHash,
+		config.LabelNodeID:    labels.NodeID,
+		config.LabelScenario:  labels.Scenario,
+		config.LabelVersion:   labels.Version,
+		config.LabelUser:      labels.User,
+	} {
+		if v != "" {
+			labelMap[k] = v
+		}
+	}
+	return labelMap
+}
+
+// ContainerLabelsFromMap reads the krknctl labels of a container, nil if
+// the container has no run ID and so was not created by krknctl
+func ContainerLabelsFromMap(labels map[string]string, config config.Config) *orchestatormodels.ContainerLabels {
+	runID, ok := labels[config.LabelRunID]
+	if !ok || runID == "" {
+		return nil
+	}
+	return &orchestatormodels.ContainerLabels{
+		RunID:     runID,
+		GraphHash: labels[config.LabelGraphHash],
+		NodeID:    labels[config.LabelNodeID],
+		Scenario:  labels[config.LabelScenario],
+		Version:   labels[config.LabelVersion],
+		User:      labels[config.LabelUser],
+	}
+}
+
+// ResolveContainer returns the ID of the container named containerName or whose ID starts
+// with it, nil if none matches. An exact name always wins, an ID prefix matching several
+// containers is reported as ambiguous
+func ResolveContainer(containers []orchestatormodels.Container, containerName string) (*string, error) {
+	if containerName == "" {
+		return nil, nil
+	}
+	var matches []string
+	for _, container := range containers {
+		if strings.TrimPrefix(container.Name, "/") == containerName || container.ID == containerName {
+			id := container.ID
+			return &id, nil
+		}
+		if strings.HasPrefix(container.ID, containerName) && !slices.Contains(matches, container.ID) {
+			matches = append(matches, container.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, fmt.Errorf("container ID %s is ambiguous, it matches %d containers", containerName, len(matches))
+}
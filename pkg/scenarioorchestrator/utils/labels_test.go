@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"testing"
+
+	krknctlconfig "github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateRunID(t *testing.T) {
+	runID := GenerateRunID()
+	assert.Regexp(t, "^[0-9]{8}-[0-9]{6}-[0-9a-f]{6}$", runID)
+	assert.NotEqual(t, runID, GenerateRunID())
+}
+
+func TestGraphHash(t *testing.T) {
+	hash := GraphHash([]byte(`{"root":{"image":"quay.io/krkn-chaos/krkn-hub:dummy-scenario"}}`))
+	assert.Len(t, hash, 16)
+	assert.Equal(t, hash, GraphHash([]byte(`{"root":{"image":"quay.io/krkn-chaos/krkn-hub:dummy-scenario"}}`)))
+	assert.NotEqual(t, hash, GraphHash([]byte(`{}`)))
+}
+
+func TestContainerLabels(t *testing.T) {
+	conf, err := krknctlconfig.LoadConfig()
+	assert.Nil(t, err)
+
+	labels := NewContainerLabels(conf, "abcdef0123456789")
+	assert.NotEmpty(t, labels.RunID)
+	assert.Equal(t, conf.Version, labels.Version)
+	labels.NodeID = "root"
+	labels.Scenario = "dummy-scenario"
+
+	labelMap := ContainerLabelsToMap(labels, conf)
+	assert.Equal(t, labels.RunID, labelMap[conf.LabelRunID])
+	assert.Equal(t, "abcdef0123456789", labelMap[conf.LabelGraphHash])
+	assert.Equal(t, "root", labelMap[conf.LabelNodeID])
+	// empty values are not stamped
+	labelMap = ContainerLabelsToMap(models.ContainerLabels{RunID: "run"}, conf)
+	assert.Len(t, labelMap, 1)
+
+	read := ContainerLabelsFromMap(ContainerLabelsToMap(labels, conf), conf)
+	assert.Equal(t, labels, *read)
+	// containers without run ID are not owned by krknctl
+	assert.Nil(t, ContainerLabelsFromMap(map[string]string{conf.LabelScenario: "dummy-scenario"}, conf))
+	assert.Nil(t, ContainerLabelsFromMap(nil, conf))
+
+	assert.True(t, read.Matches(nil))
+	assert.True(t, read.Matches(&models.ContainerLabels{RunID: labels.RunID}))
+	assert.True(t, read.Matches(&models.ContainerLabels{RunID: labels.RunID, NodeID: "root"}))
+	assert.False(t, read.Matches(&models.ContainerLabels{RunID: "another-run"}))
+	assert.False(t, read.Matches(&models.ContainerLabels{RunID: labels.RunID, NodeID: "child"}))
+}
+
+func TestResolveContainer(t *testing.T) {
+	containers := []models.Container{
+		{Name: "/krknctl-node-a-1234", ID: "3f2a9c"},
+		{Name: "krknctl-node-a-12345", ID: "3f2b11"},
+		{Name: "krknctl-node-b-5678", ID: "8e4d07"},
+	}
+	id, err := ResolveContainer(containers, "krknctl-node-a-1234")
+	assert.Nil(t, err)
+	assert.Equal(t, "3f2a9c", *id)
+	id, err = ResolveContainer(containers, "8e4")
+	assert.Nil(t, err)
+	assert.Equal(t, "8e4d07", *id)
+	id, err = ResolveContainer(containers, "3f2b11")
+	assert.Nil(t, err)
+	assert.Equal(t, "3f2b11", *id)
+
+	// a part of a name is not enough
+	id, err = ResolveContainer(containers, "node-a")
+	assert.Nil(t, err)
+	assert.Nil(t, id)
+	_, err = ResolveContainer(containers, "3f2")
+	assert.NotNil(t, err)
+}
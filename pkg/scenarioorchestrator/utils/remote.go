@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/bindings"
+	"github.com/docker/docker/client"
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	orchestatormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	podmanconfig "go.podman.io/common/pkg/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// dockerSSHHost is the placeholder host used by the docker client when the
+// connection is tunnelled over ssh, the real address is handled by the dialer
+const dockerSSHHost = "http://docker.example.com"
+
+// ContainerHost describes the container engine krknctl has been pointed to
+// with --container-host or with the CONTAINER_HOST/DOCKER_HOST environment variables
+type ContainerHost struct {
+	URI      string
+	Identity string
+	TLSCert  string
+	TLSKey   string
+	TLSCA    string
+}
+
+// IsRemote returns true if the engine is reached through the network, in that case
+// the local files cannot be bind mounted and must be copied into the containers
+func (h *ContainerHost) IsRemote() bool {
+	return IsRemoteURI(h.URI)
+}
+
+func IsRemoteURI(uri string) bool {
+	return strings.HasPrefix(uri, "ssh://") || strings.HasPrefix(uri, "tcp://")
+}
+
+type containerHostKey struct{}
+
+func ContextWithContainerHost(ctx context.Context, host *ContainerHost) context.Context {
+	return context.WithValue(ctx, containerHostKey{}, host)
+}
+
+// ContainerHostFromContext returns the remote engine the context is connected to,
+// nil if the context is connected to a local socket
+func ContainerHostFromContext(ctx context.Context) *ContainerHost {
+	host, ok := ctx.Value(containerHostKey{}).(*ContainerHost)
+	if !ok {
+		return nil
+	}
+	return host
+}
+
+// GetContainerHost resolves the engine set by the --container-host flag or, if not set,
+// by CONTAINER_HOST (podman) or DOCKER_HOST (docker). Hosts without a scheme are looked up
+// among the podman system connections. Returns nil if the local socket must be used.
+func GetContainerHost(environment orchestatormodels.ContainerRuntime, config config.Config) (*ContainerHost, error) {
+	// the scenarios run on Kubernetes are reached through the kubeconfig
+	if environment == orchestatormodels.Kubernetes {
+		return nil, nil
+	}
+	host := config.ContainerHost
+	if host == "" {
+		switch environment {
+		case orchestatormodels.Podman:
+			host = os.Getenv(config.EnvContainerHost)
+		case orchestatormodels.Docker:
+			host = os.Getenv(config.EnvDockerHost)
+		default:
+			return nil, nil
+		}
+	}
+	if host == "" {
+		return nil, nil
+	}
+
+	containerHost := ContainerHost{
+		URI:      host,
+		Identity: config.ContainerIdentity,
+	}
+	if containerHost.Identity == "" {
+		containerHost.Identity = os.Getenv(config.EnvContainerIdentity)
+	}
+
+	if !strings.Contains(host, "://") {
+		if environment != orchestatormodels.Podman {
+			return nil, fmt.Errorf("container host %s is not a valid URI, named connections are supported only by podman", host)
+		}
+		podmanConf, err := podmanconfig.Default()
+		if err != nil {
+			return nil, err
+		}
+		connection, err := podmanConf.GetConnection(host, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve podman connection %s: %w", host, err)
+		}
+		containerHost.URI = connection.URI
+		if connection.Identity != "" {
+			containerHost.Identity = connection.Identity
+		}
+		containerHost.TLSCert = connection.TLSCert
+		containerHost.TLSKey = connection.TLSKey
+		containerHost.TLSCA = connection.TLSCA
+	}
+
+	certPath := config.ContainerCertPath
+	if certPath == "" {
+		certPath = os.Getenv(config.EnvContainerCertPath)
+	}
+	if certPath != "" && containerHost.TLSCert == "" {
+		containerHost.TLSCA = filepath.Join(certPath, "ca.pem")
+		containerHost.TLSCert = filepath.Join(certPath, "cert.pem")
+		containerHost.TLSKey = filepath.Join(certPath, "key.pem")
+	}
+	return &containerHost, nil
+}
+
+// connectionHost returns the remote host settings if uri is the resolved container host
+func connectionHost(uri string, environment orchestatormodels.ContainerRuntime, config config.Config) (*ContainerHost, error) {
+	host, err := GetContainerHost(environment, config)
+	if err != nil {
+		return nil, err
+	}
+	if host == nil || host.URI != uri {
+		return &ContainerHost{URI: uri}, nil
+	}
+	return host, nil
+}
+
+// NewDockerClient builds a docker client for local sockets, tcp hosts
+// (with client certificates when configured) and ssh hosts
+func NewDockerClient(uri string, config config.Config) (*client.Client, *ContainerHost, error) {
+	host, err := connectionHost(uri, orchestatormodels.Docker, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+	switch {
+	case strings.HasPrefix(uri, "ssh://"):
+		dialer, err := newSSHDialer(uri, host.Identity, config.DockerRemoteSocket)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, client.WithHost(dockerSSHHost), client.WithDialContext(dialer))
+	case strings.HasPrefix(uri, "tcp://") && host.TLSCert != "":
+		opts = append(opts, client.WithHost(uri), client.WithTLSClientConfig(host.TLSCA, host.TLSCert, host.TLSKey))
+	default:
+		opts = append(opts, client.WithHost(uri))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cli, host, nil
+}
+
+// NewPodmanConnection opens a podman bindings connection passing the ssh identity
+// and the client certificates of the container host when uri points to it
+func NewPodmanConnection(ctx context.Context, uri string, config config.Config) (context.Context, *ContainerHost, error) {
+	host, err := connectionHost(uri, orchestatormodels.Podman, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := bindings.NewConnectionWithOptions(ctx, bindings.Options{
+		URI:         uri,
+		Identity:    host.Identity,
+		TLSCertFile: host.TLSCert,
+		TLSKeyFile:  host.TLSKey,
+		TLSCAFile:   host.TLSCA,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, host, nil
+}
+
+// newSSHDialer returns a dialer that reaches the docker socket of the remote host
+// through an ssh tunnel, authenticating with the identity file and the ssh agent
+func newSSHDialer(uri string, identity string, defaultSocket string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh container host %s: %w", uri, err)
+	}
+	username := u.User.Username()
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		username = current.Username
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "22")
+	}
+	socket := u.Path
+	if socket == "" {
+		socket = defaultSocket
+	}
+
+	var auth []ssh.AuthMethod
+	if identity != "" {
+		key, err := os.ReadFile(identity) // #nosec G304 -- identity file explicitly provided by the user
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh identity %s: %w", identity, err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh identity %s: %w", identity, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if agentSocket := os.Getenv("SSH_AUTH_SOCK"); agentSocket != "" {
+		agentConn, err := net.Dial("unix", agentSocket)
+		if err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		}
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("no ssh identity provided and no ssh agent available, use --container-identity")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ssh known hosts: %w", err)
+	}
+	sshConfig := &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, sshConfig)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		sshClient := ssh.NewClient(sshConn, chans, reqs)
+		remoteConn, err := sshClient.Dial("unix", socket)
+		if err != nil {
+			_ = sshClient.Close()
+			return nil, fmt.Errorf("failed to reach %s on %s: %w", socket, address, err)
+		}
+		return &sshTunnelConn{Conn: remoteConn, client: sshClient}, nil
+	}, nil
+}
+
+// sshTunnelConn closes the ssh session together with the tunnelled connection
+type sshTunnelConn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *sshTunnelConn) Close() error {
+	err := c.Conn.Close()
+	if clientErr := c.client.Close(); err == nil {
+		err = clientErr
+	}
+	return err
+}
+
+// ArchiveVolumeMounts packs the files that would be bind mounted in a tar archive
+// rooted at / so that they can be copied into a container running on a remote host.
+// The archive carries no owner, the engines chown the files to the container user on copy
+func ArchiveVolumeMounts(volumeMounts map[string]string) (io.Reader, error) {
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	for source, destination := range volumeMounts {
+		destination = strings.TrimPrefix(path.Clean(destination), "/")
+		err := filepath.WalkDir(source, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			relative, err := filepath.Rel(source, filePath)
+			if err != nil {
+				return err
+			}
+			name := path.Join(destination, filepath.ToSlash(relative))
+			// the files keep the permissions they have when bind mounted, the kubeconfig
+			// holds the cluster credentials and must not be readable by everyone
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			mode := int64(info.Mode().Perm())
+			if entry.IsDir() {
+				return writer.WriteHeader(&tar.Header{Name: name + "/", Mode: mode, Typeflag: tar.TypeDir})
+			}
+			content, err := os.ReadFile(filePath) // #nosec G304 -- volume sources are set by krknctl
+			if err != nil {
+				return err
+			}
+			if err := writer.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+				return err
+			}
+			_, err = writer.Write(content)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %w", source, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &buffer, nil
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	krknctlconfig "github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetContainerHost(t *testing.T) {
+	conf, err := krknctlconfig.LoadConfig()
+	assert.Nil(t, err)
+	t.Setenv(conf.EnvContainerHost, "")
+	t.Setenv(conf.EnvDockerHost, "")
+	t.Setenv(conf.EnvContainerCertPath, "")
+	t.Setenv(conf.EnvContainerIdentity, "")
+
+	host, err := GetContainerHost(models.Podman, conf)
+	assert.Nil(t, err)
+	assert.Nil(t, host)
+
+	// each runtime honors its own environment variable
+	t.Setenv(conf.EnvContainerHost, "ssh://core@podman.example.com/run/podman/podman.sock")
+	t.Setenv(conf.EnvDockerHost, "tcp://docker.example.com:2376")
+	host, err = GetContainerHost(models.Podman, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "ssh://core@podman.example.com/run/podman/podman.sock", host.URI)
+	assert.True(t, host.IsRemote())
+	host, err = GetContainerHost(models.Docker, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp://docker.example.com:2376", host.URI)
+	assert.Equal(t, "", host.TLSCert)
+
+	host, err = GetContainerHost(models.Kubernetes, conf)
+	assert.Nil(t, err)
+	assert.Nil(t, host)
+
+	// the flag takes precedence over the environment
+	conf.ContainerHost = "tcp://flag.example.com:2376"
+	conf.ContainerCertPath = "/certs"
+	conf.ContainerIdentity = "/keys/id_ed25519"
+	t.Setenv(conf.EnvContainerIdentity, "/keys/env_key")
+	host, err = GetContainerHost(models.Docker, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp://flag.example.com:2376", host.URI)
+	assert.Equal(t, "/keys/id_ed25519", host.Identity)
+	assert.Equal(t, filepath.Join("/certs", "ca.pem"), host.TLSCA)
+	assert.Equal(t, filepath.Join("/certs", "cert.pem"), host.TLSCert)
+	assert.Equal(t, filepath.Join("/certs", "key.pem"), host.TLSKey)
+
+	socket, err := GetSocketByContainerEnvironment(models.Docker, conf, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp://flag.example.com:2376", *socket)
+	// kubernetes is always reached through the kubeconfig
+	host, err = GetContainerHost(models.Kubernetes, conf)
+	assert.Nil(t, err)
+	assert.Nil(t, host)
+	socket, err = GetSocketByContainerEnvironment(models.Kubernetes, conf, nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "tcp://flag.example.com:2376", *socket)
+	conf.KubernetesKubeconfig = "/tmp/kubeconfig"
+	socket, err = GetSocketByContainerEnvironment(models.Kubernetes, conf, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/kubeconfig", *socket)
+
+	conf.ContainerHost = "unix:///run/user/1000/podman/podman.sock"
+	host, err = GetContainerHost(models.Podman, conf)
+	assert.Nil(t, err)
+	assert.False(t, host.IsRemote())
+
+	// named connections are a podman only feature
+	conf.ContainerHost = "bastion"
+	_, err = GetContainerHost(models.Docker, conf)
+	assert.NotNil(t, err)
+}
+
+func TestGetContainerHost_NamedConnection(t *testing.T) {
+	conf, err := krknctlconfig.LoadConfig()
+	assert.Nil(t, err)
+	t.Setenv(conf.EnvContainerCertPath, "")
+	t.Setenv(conf.EnvContainerIdentity, "")
+	connections := filepath.Join(t.TempDir(), "podman-connections.json")
+	err = os.WriteFile(connections, []byte(`{"Connection":{"Default":"bastion","Connections":{
+		"bastion":{"URI":"ssh://core@bastion.example.com:2222/run/user/1000/podman/podman.sock","Identity":"/keys/bastion"},
+		"secure":{"URI":"tcp://secure.example.com:8443","TLSCert":"/tls/cert.pem","TLSKey":"/tls/key.pem","TLSCA":"/tls/ca.pem"}}}}`), 0600)
+	assert.Nil(t, err)
+	t.Setenv("PODMAN_CONNECTIONS_CONF", connections)
+
+	conf.ContainerHost = "bastion"
+	host, err := GetContainerHost(models.Podman, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "ssh://core@bastion.example.com:2222/run/user/1000/podman/podman.sock", host.URI)
+	assert.Equal(t, "/keys/bastion", host.Identity)
+
+	conf.ContainerHost = "secure"
+	host, err = GetContainerHost(models.Podman, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp://secure.example.com:8443", host.URI)
+	assert.Equal(t, "/tls/cert.pem", host.TLSCert)
+	assert.Equal(t, "/tls/ca.pem", host.TLSCA)
+
+	conf.ContainerHost = "not-existing"
+	_, err = GetContainerHost(models.Podman, conf)
+	assert.NotNil(t, err)
+}
+
+func TestNewDockerClient(t *testing.T) {
+	conf, err := krknctlconfig.LoadConfig()
+	assert.Nil(t, err)
+	t.Setenv(conf.EnvDockerHost, "")
+	t.Setenv(conf.EnvContainerIdentity, "")
+
+	cli, host, err := NewDockerClient(conf.DockerSocketRoot, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, conf.DockerSocketRoot, cli.DaemonHost())
+	assert.False(t, host.IsRemote())
+
+	// client certificates that don't exist are reported
+	conf.ContainerHost = "tcp://docker.example.com:2376"
+	conf.ContainerCertPath = t.TempDir()
+	_, _, err = NewDockerClient(conf.ContainerHost, conf)
+	assert.NotNil(t, err)
+
+	// ssh identity that doesn't exist is reported
+	conf.ContainerHost = "ssh://core@docker.example.com"
+	conf.ContainerIdentity = filepath.Join(t.TempDir(), "not_existing")
+	_, _, err = NewDockerClient(conf.ContainerHost, conf)
+	assert.NotNil(t, err)
+
+	ctx := ContextWithContainerHost(context.Background(), host)
+	assert.Equal(t, host, ContainerHostFromContext(ctx))
+	assert.Nil(t, ContainerHostFromContext(context.Background()))
+}
+
+func TestArchiveVolumeMounts(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	assert.Nil(t, os.WriteFile(kubeconfig, []byte("kubeconfig"), 0600))
+	scenarios := filepath.Join(dir, "scenarios")
+	assert.Nil(t, os.Mkdir(scenarios, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(scenarios, "scenario.yaml"), []byte("scenario"), 0644))
+
+	archive, err := ArchiveVolumeMounts(map[string]string{
+		kubeconfig: "/home/krkn/.kube/config",
+		scenarios:  "/home/krkn/scenarios/",
+	})
+	assert.Nil(t, err)
+
+	files := make(map[string]string)
+	modes := make(map[string]int64)
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		content, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		files[header.Name] = string(content)
+		modes[header.Name] = header.Mode
+	}
+	// the files keep their permissions
+	assert.Equal(t, int64(0600), modes["home/krkn/.kube/config"])
+	assert.Equal(t, int64(0644), modes["home/krkn/scenarios/scenario.yaml"])
+	assert.Equal(t, int64(0755), modes["home/krkn/scenarios/"])
+	assert.Equal(t, "kubeconfig", files["home/krkn/.kube/config"])
+	assert.Equal(t, "scenario", files["home/krkn/scenarios/scenario.yaml"])
+	assert.Contains(t, files, "home/krkn/scenarios/")
+
+	_, err = ArchiveVolumeMounts(map[string]string{filepath.Join(dir, "not_existing"): "/tmp/file"})
+	assert.NotNil(t, err)
+}
@@ -5,9 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/containers/podman/v5/pkg/bindings"
 	images "github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/client"
 	"github.com/krkn-chaos/krknctl/pkg/config"
 	orchestatormodels "github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/models"
 	"github.com/krkn-chaos/krknctl/pkg/text"
@@ -18,7 +16,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -109,7 +109,19 @@ func PrepareKubeconfig(kubeconfigPath *string, config config.Config) (*string, e
 	return &path, nil
 }
 
-func CleanKubeconfigFiles(config config.Config) (*int, error) {
+// CleanKubeconfigFiles removes the flattened kubeconfig copies from the working directory,
+// the temp folder and dirs (e.g. the run directories)
+func CleanKubeconfigFiles(config config.Config, dirs ...string) (*int, error) {
+	files, err := FindKubeconfigFiles(config, dirs...)
+	if err != nil {
+		return nil, err
+	}
+	return removeFiles(files)
+}
+
+// FindKubeconfigFiles returns the flattened kubeconfig copies found in the working
+// directory, the temp folder and dirs
+func FindKubeconfigFiles(config config.Config, dirs ...string) ([]string, error) {
 	regex, err := regexp.Compile(fmt.Sprintf("^%s-.*-[0-9]+$", config.KubeconfigPrefix))
 	if err != nil {
 		return nil, err
@@ -120,9 +132,9 @@ func CleanKubeconfigFiles(config config.Config) (*int, error) {
 	}
 	tempDir := os.TempDir()
 
-	deletedFiles := 0
+	var found []string
 	seen := map[string]struct{}{}
-	for _, dir := range []string{currentDir, tempDir} {
+	for _, dir := range append([]string{currentDir, tempDir}, dirs...) {
 		absDir, err := filepath.Abs(dir)
 		if err != nil {
 			return nil, err
@@ -138,18 +150,23 @@ func CleanKubeconfigFiles(config config.Config) (*int, error) {
 		}
 		for _, file := range files {
 			if regex.MatchString(file.Name()) {
-				err := os.Remove(filepath.Join(absDir, file.Name()))
-				if err != nil {
-					return nil, err
-				}
-				deletedFiles++
+				found = append(found, filepath.Join(absDir, file.Name()))
 			}
 		}
 	}
-	return &deletedFiles, nil
+	return found, nil
 }
 
 func CleanLogFiles(config config.Config) (*int, error) {
+	files, err := FindLogFiles(config)
+	if err != nil {
+		return nil, err
+	}
+	return removeFiles(files)
+}
+
+// FindLogFiles returns the scenario log files written in the working directory
+func FindLogFiles(config config.Config) ([]string, error) {
 	regex, err := regexp.Compile(fmt.Sprintf("%s-.*\\-[0-9]+\\.log", config.ContainerPrefix))
 	if err != nil {
 		return nil, err
@@ -163,20 +180,35 @@ func CleanLogFiles(config config.Config) (*int, error) {
 	if err != nil {
 		return nil, err
 	}
-	deletedFiles := 0
+	var found []string
 	for _, file := range files {
 		if regex.MatchString(file.Name()) {
-			err := os.Remove(filepath.Join(currentDir, file.Name()))
-			if err != nil {
-				return nil, err
-			}
-			deletedFiles++
+			found = append(found, filepath.Join(currentDir, file.Name()))
+		}
+	}
+	return found, nil
+}
+
+func removeFiles(files []string) (*int, error) {
+	deletedFiles := 0
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			return nil, err
 		}
+		deletedFiles++
 	}
 	return &deletedFiles, nil
 }
 
 func GetSocketByContainerEnvironment(environment orchestatormodels.ContainerRuntime, config config.Config, userID *int) (*string, error) {
+	// a remote (or explicitly set) engine always takes precedence over the local sockets
+	host, err := GetContainerHost(environment, config)
+	if err != nil {
+		return nil, err
+	}
+	if host != nil {
+		return &host.URI, nil
+	}
 	switch environment {
 	case orchestatormodels.Docker:
 		return &config.DockerSocketRoot, nil
@@ -197,6 +229,13 @@ func GetSocketByContainerEnvironment(environment orchestatormodels.ContainerRunt
 			return &socket, nil
 		}
 		return nil, fmt.Errorf("could not determine container container runtime socket for podman")
+	case orchestatormodels.Kubernetes:
+		// the jobs run in the cluster of the kubeconfig given to the run, the default one otherwise
+		if config.KubernetesKubeconfig != "" {
+			return &config.KubernetesKubeconfig, nil
+		}
+		kubeconfig := clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
+		return &kubeconfig, nil
 	case orchestatormodels.Both:
 		return nil, fmt.Errorf("%s invalid container environment", environment.String())
 	}
@@ -218,18 +257,14 @@ func DetectContainerRuntime(config config.Config) (*orchestatormodels.ContainerR
 	dockerRuntime := false
 	podmanRuntime := false
 
-	cli, err := client.NewClientWithOpts(client.WithAPIVersionNegotiation(), client.WithHost(*socketDocker))
-	if cli == nil {
-		return nil, fmt.Errorf("could not determine docker runtime")
-	}
-	if err != nil {
-		dockerRuntime = false
-	}
-	_, err = cli.ImageList(context.Background(), images.ListOptions{})
+	cli, _, err := NewDockerClient(*socketDocker, config)
 	if err == nil {
-		dockerRuntime = true
+		_, err = cli.ImageList(context.Background(), images.ListOptions{})
+		if err == nil {
+			dockerRuntime = true
+		}
 	}
-	_, err = bindings.NewConnection(context.Background(), *socketPodman)
+	_, _, err = NewPodmanConnection(context.Background(), *socketPodman, config)
 	if err == nil {
 		podmanRuntime = true
 	}
@@ -249,15 +284,44 @@ func DetectContainerRuntime(config config.Config) (*orchestatormodels.ContainerR
 
 }
 
-func GenerateContainerName(config config.Config, scenarioName string, graphNodeName *string) string {
-	// sleep is needed to avoid the same timestamp to two different containers
-	// and break all the logic related to the container name timestamp
+// lastContainerTimestamp is the suffix of the last generated container name
+var lastContainerTimestamp atomic.Int64
+
+// containerTimestamp returns the current unix time in nanoseconds, strictly
+// greater than the one returned by the previous call so that two names
+// generated in the same process never collide and keep their creation order
+func containerTimestamp() int64 {
+	for {
+		last := lastContainerTimestamp.Load()
+		now := time.Now().UnixNano()
+		if now <= last {
+			now = last + 1
+		}
+		if lastContainerTimestamp.CompareAndSwap(last, now) {
+			return now
+		}
+	}
+}
+
+var containerTimestampRegex = regexp.MustCompile(`-([0-9]+)$`)
+
+// ContainerNameTimestamp returns the timestamp set in the container name by GenerateContainerName
+// that sorts the containers by creation, created is used if the name has none
+func ContainerNameTimestamp(containerName string, created time.Time) int64 {
+	groups := containerTimestampRegex.FindStringSubmatch(containerName)
+	if len(groups) > 1 {
+		if timestamp, err := strconv.ParseInt(groups[1], 10, 64); err == nil {
+			return timestamp
+		}
+	}
+	return created.UnixNano()
+}
 
-	time.Sleep(1 * time.Nanosecond)
+func GenerateContainerName(config config.Config, scenarioName string, graphNodeName *string) string {
 	if graphNodeName != nil {
-		return fmt.Sprintf("%s-%s-%d", config.ContainerPrefix, *graphNodeName, time.Now().Nanosecond())
+		return fmt.Sprintf("%s-%s-%d", config.ContainerPrefix, *graphNodeName, containerTimestamp())
 	}
-	return fmt.Sprintf("%s-%s-%d", config.ContainerPrefix, scenarioName, time.Now().Nanosecond())
+	return fmt.Sprintf("%s-%s-%d", config.ContainerPrefix, scenarioName, containerTimestamp())
 }
 
 func EnvironmentFromString(s string) orchestatormodels.ContainerRuntime {
@@ -268,11 +332,32 @@ func EnvironmentFromString(s string) orchestatormodels.ContainerRuntime {
 		return orchestatormodels.Docker
 	case "Both":
 		return orchestatormodels.Both
+	case "Kubernetes":
+		return orchestatormodels.Kubernetes
 	default:
 		panic(fmt.Sprintf("unknown container environment: %q", s))
 	}
 }
 
+// RuntimeFromFlag parses the value of the --runtime flag, nil is returned
+// when the runtime must be detected automatically
+func RuntimeFromFlag(s string) (*orchestatormodels.ContainerRuntime, error) {
+	var containerRuntime orchestatormodels.ContainerRuntime
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return nil, nil
+	case "podman":
+		containerRuntime = orchestatormodels.Podman
+	case "docker":
+		containerRuntime = orchestatormodels.Docker
+	case "kubernetes":
+		containerRuntime = orchestatormodels.Kubernetes
+	default:
+		return nil, fmt.Errorf("unknown runtime %q, supported values are auto, podman, docker, kubernetes", s)
+	}
+	return &containerRuntime, nil
+}
+
 func MaskString(s string) string {
 	if len(s) < 3 {
 		return s
@@ -78,11 +78,17 @@ func TestCleanKubeconfigFiles(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, mdFiles, filepath.Base(*flatKubeconfigPath))
 
-	num, err := CleanKubeconfigFiles(conf)
+	runDir := t.TempDir()
+	runKubeconfigPath := filepath.Join(runDir, filepath.Base(*flatKubeconfigPath))
+	assert.Nil(t, os.WriteFile(runKubeconfigPath, []byte{}, 0600))
+
+	num, err := CleanKubeconfigFiles(conf, runDir)
 	assert.Nil(t, err)
-	assert.GreaterOrEqual(t, *num, 1)
+	assert.GreaterOrEqual(t, *num, 2)
 	_, err = os.Stat(*flatKubeconfigPath)
 	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(runKubeconfigPath)
+	assert.True(t, os.IsNotExist(err))
 
 	mdFiles, err = fs.Glob(root, "krkctl-kubeconifig-*")
 	assert.Nil(t, err)
@@ -206,6 +212,18 @@ func TestGenerateContainerName(t *testing.T) {
 	containernode := GenerateContainerName(conf, scenarioname, &nodename)
 	assert.True(t, renode.MatchString(containernode))
 
+	// names generated concurrently never collide
+	names := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		go func() {
+			names <- GenerateContainerName(conf, scenarioname, nil)
+		}()
+	}
+	generated := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		generated[<-names] = true
+	}
+	assert.Len(t, generated, 100)
 }
 
 func TestEnvironmentFromString(t *testing.T) {
@@ -229,6 +247,31 @@ func TestEnvironmentFromString(t *testing.T) {
 
 }
 
+func TestRuntimeFromFlag(t *testing.T) {
+	runtime, err := RuntimeFromFlag("")
+	assert.Nil(t, err)
+	assert.Nil(t, runtime)
+
+	runtime, err = RuntimeFromFlag("auto")
+	assert.Nil(t, err)
+	assert.Nil(t, runtime)
+
+	runtime, err = RuntimeFromFlag("Podman")
+	assert.Nil(t, err)
+	assert.Equal(t, models.Podman, *runtime)
+
+	runtime, err = RuntimeFromFlag("docker")
+	assert.Nil(t, err)
+	assert.Equal(t, models.Docker, *runtime)
+
+	runtime, err = RuntimeFromFlag("kubernetes")
+	assert.Nil(t, err)
+	assert.Equal(t, models.Kubernetes, *runtime)
+
+	_, err = RuntimeFromFlag("containerd")
+	assert.NotNil(t, err)
+}
+
 func TestMaskString(t *testing.T) {
 	cleanStr := "str123456"
 	maskedStr := MaskString(cleanStr)
@@ -0,0 +1,278 @@
+// Package workspace manages the per-run directories (~/.krknctl/runs/<run-id>/) holding the logs,
+// the resiliency report, the plan snapshot, the effective environment and the metadata of every run
+package workspace
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/krkn-chaos/krknctl/pkg/scenarioorchestrator/utils"
+	commonutils "github.com/krkn-chaos/krknctl/pkg/utils"
+)
+
+const (
+	MetadataFile  = "metadata.json"
+	PlanFile      = "plan.json"
+	EnvFile       = "env.json"
+	ReportFile    = "resiliency-report.json"
+	LogExtension  = ".log"
+	GzipExtension = ".gz"
+)
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusDetached  Status = "detached"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusAborted   Status = "aborted"
+)
+
+type Metadata struct {
+	RunID string `json:"run_id"`
+	// Command is the krknctl command that started the run (run, graph run, random run)
+	Command   string     `json:"command"`
+	Plan      string     `json:"plan,omitempty"`
+	GraphHash string     `json:"graph_hash,omitempty"`
+	Scenarios []string   `json:"scenarios"`
+	Runtime   string     `json:"runtime"`
+	Version   string     `json:"version"`
+	User      string     `json:"user"`
+	Started   time.Time  `json:"started"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Status    Status     `json:"status"`
+	// ExitStatus is the exit status of krknctl, the one of the failed scenario if any
+	ExitStatus int    `json:"exit_status"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Workspace struct {
+	Dir      string
+	Metadata Metadata
+}
+
+// Root returns the folder where the run directories are created, outputDir if set
+func Root(outputDir string, config config.Config) (string, error) {
+	folder := config.RunsFolder
+	if outputDir != "" {
+		folder = outputDir
+	}
+	expanded, err := commonutils.ExpandFolder(folder, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid runs folder %s: %w", folder, err)
+	}
+	return *expanded, nil
+}
+
+// New creates the directory of the run under root and writes its metadata
+func New(root string, metadata Metadata) (*Workspace, error) {
+	if metadata.RunID == "" {
+		return nil, errors.New("run ID cannot be empty")
+	}
+	dir := filepath.Join(root, metadata.RunID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the run directory %s: %w", dir, err)
+	}
+	if metadata.Started.IsZero() {
+		metadata.Started = time.Now().UTC()
+	}
+	metadata.Status = StatusRunning
+	w := Workspace{Dir: dir, Metadata: metadata}
+	if err := w.WriteMetadata(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Load reads the run directory
+func Load(dir string) (*Workspace, error) {
+	data, err := os.ReadFile(filepath.Join(filepath.Clean(dir), MetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	w := Workspace{Dir: dir}
+	if err := json.Unmarshal(data, &w.Metadata); err != nil {
+		return nil, fmt.Errorf("invalid run metadata in %s: %w", dir, err)
+	}
+	return &w, nil
+}
+
+// List returns the runs stored in root sorted by start time, the
+// directories without metadata are skipped. A missing root has no runs.
+func List(root string) ([]*Workspace, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []*Workspace
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		w, err := Load(filepath.Join(root, entry.Name()))
+		if err != nil {
+			continue
+		}
+		runs = append(runs, w)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Metadata.Started.Before(runs[j].Metadata.Started)
+	})
+	return runs, nil
+}
+
+// LogPath returns the path of the log file of the container
+func (w *Workspace) LogPath(containerName string) string {
+	return filepath.Join(w.Dir, containerName+LogExtension)
+}
+
+func (w *Workspace) ReportPath() string {
+	return filepath.Join(w.Dir, ReportFile)
+}
+
+// WritePlan writes the snapshot of the plan as it has been run
+func (w *Workspace) WritePlan(plan any) error {
+	return writeJSON(filepath.Join(w.Dir, PlanFile), plan)
+}
+
+// WriteEnv writes the effective environment of every node (or scenario) of the run,
+// the values of the secret variables are masked
+func (w *Workspace) WriteEnv(env map[string]map[string]string, secrets map[string]bool) error {
+	masked := make(map[string]map[string]string, len(env))
+	for node, nodeEnv := range env {
+		masked[node] = make(map[string]string, len(nodeEnv))
+		for k, v := range nodeEnv {
+			if secrets[k] {
+				v = utils.MaskString(v)
+			}
+			masked[node][k] = v
+		}
+	}
+	return writeJSON(filepath.Join(w.Dir, EnvFile), masked)
+}
+
+func (w *Workspace) WriteMetadata() error {
+	return writeJSON(filepath.Join(w.Dir, MetadataFile), w.Metadata)
+}
+
+// Adopt moves the file into the run directory and returns its new path, used to keep the
+// flattened kubeconfig with the run instead of the temp folder
+func (w *Workspace) Adopt(filePath string) (string, error) {
+	target := filepath.Join(w.Dir, filepath.Base(filePath))
+	if err := os.Rename(filePath, target); err == nil {
+		return target, nil
+	}
+	// the temp folder may be on another filesystem
+	data, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target, data, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Remove(filePath); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// Finish records the outcome of the run
+func (w *Workspace) Finish(status Status, exitStatus int, runErr error) error {
+	finished := time.Now().UTC()
+	w.Metadata.Finished = &finished
+	w.Metadata.Status = status
+	w.Metadata.ExitStatus = exitStatus
+	if runErr != nil {
+		w.Metadata.Error = runErr.Error()
+	}
+	return w.WriteMetadata()
+}
+
+// Finished returns true if the run is over, the detached runs are considered over since
+// krknctl does not follow them anymore
+func (w *Workspace) Finished() bool {
+	return w.Metadata.Status != StatusRunning
+}
+
+// Logs returns the log files of the run, compressed ones included
+func (w *Workspace) Logs() ([]string, error) {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var logs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && (strings.HasSuffix(name, LogExtension) || strings.HasSuffix(name, LogExtension+GzipExtension)) {
+			logs = append(logs, filepath.Join(w.Dir, name))
+		}
+	}
+	return logs, nil
+}
+
+// CompressLogs gzips the log files of the run and removes the originals,
+// it returns the number of compressed files
+func (w *Workspace) CompressLogs() (int, error) {
+	logs, err := w.Logs()
+	if err != nil {
+		return 0, err
+	}
+	compressed := 0
+	for _, log := range logs {
+		if !strings.HasSuffix(log, LogExtension) {
+			continue
+		}
+		if err := gzipFile(log); err != nil {
+			return compressed, fmt.Errorf("failed to compress %s: %w", log, err)
+		}
+		compressed++
+	}
+	return compressed, nil
+}
+
+func gzipFile(filePath string) error {
+	source, err := os.Open(filepath.Clean(filePath))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(filepath.Clean(filePath+GzipExtension), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		_ = writer.Close()
+		_ = target.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filePath)
+}
+
+func writeJSON(filePath string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(filePath), append(data, '\n'), 0600)
+}
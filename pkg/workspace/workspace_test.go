@@ -0,0 +1,123 @@
+package workspace
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krkn-chaos/krknctl/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoot(t *testing.T) {
+	conf := config.Config{RunsFolder: "/var/krknctl/runs"}
+	root, err := Root("", conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "/var/krknctl/runs", root)
+	root, err = Root("/tmp/my-runs", conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/my-runs", root)
+}
+
+func TestWorkspace(t *testing.T) {
+	root := t.TempDir()
+	_, err := New(root, Metadata{})
+	assert.NotNil(t, err)
+
+	first, err := New(root, Metadata{RunID: "first", Command: "graph run", Scenarios: []string{"pod-scenarios"}, Started: time.Now().Add(-time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "first"), first.Dir)
+	assert.Equal(t, StatusRunning, first.Metadata.Status)
+	assert.False(t, first.Finished())
+	assert.Equal(t, filepath.Join(root, "first", "node-1.log"), first.LogPath("node-1"))
+	assert.Equal(t, filepath.Join(root, "first", ReportFile), first.ReportPath())
+
+	second, err := New(root, Metadata{RunID: "second", Command: "run"})
+	assert.Nil(t, err)
+	assert.Nil(t, second.Finish(StatusDetached, 0, nil))
+	assert.True(t, second.Finished())
+
+	assert.Nil(t, first.Finish(StatusFailed, 3, errors.New("scenario failed")))
+	loaded, err := Load(first.Dir)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusFailed, loaded.Metadata.Status)
+	assert.Equal(t, 3, loaded.Metadata.ExitStatus)
+	assert.Equal(t, "scenario failed", loaded.Metadata.Error)
+	assert.NotNil(t, loaded.Metadata.Finished)
+
+	// directories without metadata are not runs
+	assert.Nil(t, os.Mkdir(filepath.Join(root, "other"), 0700))
+	runs, err := List(root)
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "first", runs[0].Metadata.RunID)
+	assert.Equal(t, "second", runs[1].Metadata.RunID)
+
+	runs, err = List(filepath.Join(root, "missing"))
+	assert.Nil(t, err)
+	assert.Len(t, runs, 0)
+}
+
+func TestWorkspace_WriteEnv(t *testing.T) {
+	w, err := New(t.TempDir(), Metadata{RunID: "run"})
+	assert.Nil(t, err)
+	env := map[string]map[string]string{
+		"node-1": {"NAMESPACE": "default", "ES_PASSWORD": "supersecret"},
+	}
+	assert.Nil(t, w.WriteEnv(env, map[string]bool{"ES_PASSWORD": true}))
+	data, err := os.ReadFile(filepath.Join(w.Dir, EnvFile))
+	assert.Nil(t, err)
+	var written map[string]map[string]string
+	assert.Nil(t, json.Unmarshal(data, &written))
+	assert.Equal(t, "default", written["node-1"]["NAMESPACE"])
+	assert.NotEqual(t, "supersecret", written["node-1"]["ES_PASSWORD"])
+	assert.NotContains(t, string(data), "supersecret")
+}
+
+func TestWorkspace_Adopt(t *testing.T) {
+	w, err := New(t.TempDir(), Metadata{RunID: "run"})
+	assert.Nil(t, err)
+	source := filepath.Join(t.TempDir(), "krknctl-kubeconfig-abcde-1")
+	assert.Nil(t, os.WriteFile(source, []byte("kubeconfig"), 0600))
+	adopted, err := w.Adopt(source)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(w.Dir, "krknctl-kubeconfig-abcde-1"), adopted)
+	_, err = os.Stat(source)
+	assert.True(t, os.IsNotExist(err))
+	data, err := os.ReadFile(adopted)
+	assert.Nil(t, err)
+	assert.Equal(t, "kubeconfig", string(data))
+}
+
+func TestWorkspace_CompressLogs(t *testing.T) {
+	w, err := New(t.TempDir(), Metadata{RunID: "run"})
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(w.LogPath("node-1"), []byte("scenario log"), 0600))
+	assert.Nil(t, os.WriteFile(w.LogPath("node-2"), []byte("scenario log"), 0600))
+
+	compressed, err := w.CompressLogs()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, compressed)
+	logs, err := w.Logs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{w.LogPath("node-1") + GzipExtension, w.LogPath("node-2") + GzipExtension}, logs)
+
+	file, err := os.Open(logs[0])
+	assert.Nil(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "scenario log", string(data))
+
+	// already compressed logs are skipped
+	compressed, err = w.CompressLogs()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, compressed)
+}